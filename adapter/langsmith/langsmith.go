@@ -0,0 +1,560 @@
+// Package langsmith exports graph runs to LangSmith.
+//
+// Exporter is a graph.CallbackHandler that builds the run tree of a graph
+// invocation (chain, nodes, LLM and tool calls) and sends it in batches to the
+// LangSmith runs API from a background goroutine:
+//
+//	exporter := langsmith.NewExporter(langsmith.ExporterOptions{Project: "agents"})
+//	defer exporter.Close(context.Background())
+//	runnable.InvokeWithConfig(ctx, input, &graph.Config{Callbacks: []graph.CallbackHandler{exporter}})
+package langsmith
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+const (
+	// DefaultEndpoint is the LangSmith API
+	DefaultEndpoint = "https://api.smith.langchain.com"
+
+	// DefaultBatchSize is the default number of run operations per request
+	DefaultBatchSize = 100
+
+	// DefaultQueueSize is the default number of run operations waiting to be sent
+	DefaultQueueSize = 1000
+
+	// DefaultFlushInterval is the default time between background flushes
+	DefaultFlushInterval = time.Second
+
+	// DefaultMaxRetries is the default number of retries of a failed request
+	DefaultMaxRetries = 3
+
+	// DefaultRetryBackoff is the default delay before the first retry, doubled for each retry
+	DefaultRetryBackoff = 500 * time.Millisecond
+)
+
+// Run types of the LangSmith run tree
+const (
+	RunTypeChain     = "chain"
+	RunTypeLLM       = "llm"
+	RunTypeTool      = "tool"
+	RunTypeRetriever = "retriever"
+)
+
+// ExporterOptions configuration for the LangSmith exporter
+type ExporterOptions struct {
+	// Endpoint of the LangSmith API. Default $LANGSMITH_ENDPOINT or DefaultEndpoint
+	Endpoint string
+
+	// APIKey authenticates the requests. Default $LANGSMITH_API_KEY
+	APIKey string
+
+	// Project the runs are stored in. Default $LANGSMITH_PROJECT or "default"
+	Project string
+
+	// HTTPClient sends the requests. Default is a client with a 30s timeout
+	HTTPClient *http.Client
+
+	// BatchSize is the maximum number of run operations per request.
+	// A full batch is sent without waiting for the flush interval.
+	BatchSize int
+
+	// QueueSize bounds the run operations waiting to be sent. Operations
+	// arriving when the queue is full are dropped.
+	QueueSize int
+
+	// FlushInterval is the time between background flushes
+	FlushInterval time.Duration
+
+	// MaxRetries is the number of retries of a request failing with a
+	// network error, 429 or 5xx response. Default 3, negative disables retries
+	MaxRetries int
+
+	// RetryBackoff is the delay before the first retry, doubled for each retry
+	RetryBackoff time.Duration
+}
+
+// Run is a node of the LangSmith run tree, as sent to the runs API.
+// Creations carry the start of a run, updates its end.
+type Run struct {
+	ID          string                 `json:"id"`
+	TraceID     string                 `json:"trace_id,omitempty"`
+	DottedOrder string                 `json:"dotted_order,omitempty"`
+	ParentRunID string                 `json:"parent_run_id,omitempty"`
+	Name        string                 `json:"name,omitempty"`
+	RunType     string                 `json:"run_type,omitempty"`
+	SessionName string                 `json:"session_name,omitempty"`
+	StartTime   *time.Time             `json:"start_time,omitempty"`
+	EndTime     *time.Time             `json:"end_time,omitempty"`
+	Inputs      map[string]interface{} `json:"inputs,omitempty"`
+	Outputs     map[string]interface{} `json:"outputs,omitempty"`
+	Error       string                 `json:"error,omitempty"`
+	Tags        []string               `json:"tags,omitempty"`
+	Extra       map[string]interface{} `json:"extra,omitempty"`
+	Events      []RunEvent             `json:"events,omitempty"`
+}
+
+// RunEvent is an event recorded during a run, such as a completed graph step
+type RunEvent struct {
+	Name   string                 `json:"name"`
+	Time   time.Time              `json:"time"`
+	Kwargs map[string]interface{} `json:"kwargs,omitempty"`
+}
+
+// batchRequest is the body of a request to the batch endpoint
+type batchRequest struct {
+	Post  []*Run `json:"post"`
+	Patch []*Run `json:"patch"`
+}
+
+// operation is a queued creation or update of a run
+type operation struct {
+	create bool
+	run    *Run
+}
+
+// activeRun is a run that has started and not ended yet
+type activeRun struct {
+	traceID     string
+	dottedOrder string
+	parentRunID string
+	events      []RunEvent
+}
+
+// Exporter is a graph callback handler sending the run tree to LangSmith
+type Exporter struct {
+	endpoint      string
+	apiKey        string
+	project       string
+	client        *http.Client
+	batchSize     int
+	queueSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+
+	mu     sync.Mutex
+	active map[string]*activeRun
+	queue  []operation
+
+	// sendMu serializes flushes so batches are sent in order
+	sendMu  sync.Mutex
+	dropped atomic.Int64
+
+	full      chan struct{}
+	done      chan struct{}
+	stopped   chan struct{}
+	closeOnce sync.Once
+}
+
+var (
+	_ graph.CallbackHandler      = &Exporter{}
+	_ graph.GraphCallbackHandler = &Exporter{}
+)
+
+// NewExporter creates a new LangSmith exporter and starts its background flush
+func NewExporter(opts ExporterOptions) *Exporter {
+	e := &Exporter{
+		endpoint:      strings.TrimRight(firstNonEmpty(opts.Endpoint, os.Getenv("LANGSMITH_ENDPOINT"), DefaultEndpoint), "/"),
+		apiKey:        firstNonEmpty(opts.APIKey, os.Getenv("LANGSMITH_API_KEY")),
+		project:       firstNonEmpty(opts.Project, os.Getenv("LANGSMITH_PROJECT"), "default"),
+		client:        opts.HTTPClient,
+		batchSize:     opts.BatchSize,
+		queueSize:     opts.QueueSize,
+		flushInterval: opts.FlushInterval,
+		maxRetries:    opts.MaxRetries,
+		retryBackoff:  opts.RetryBackoff,
+		active:        make(map[string]*activeRun),
+		full:          make(chan struct{}, 1),
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+	if e.client == nil {
+		e.client = &http.Client{Timeout: 30 * time.Second}
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = DefaultBatchSize
+	}
+	if e.queueSize <= 0 {
+		e.queueSize = DefaultQueueSize
+	}
+	if e.flushInterval <= 0 {
+		e.flushInterval = DefaultFlushInterval
+	}
+	if e.maxRetries < 0 {
+		e.maxRetries = 0
+	} else if e.maxRetries == 0 {
+		e.maxRetries = DefaultMaxRetries
+	}
+	if e.retryBackoff <= 0 {
+		e.retryBackoff = DefaultRetryBackoff
+	}
+
+	go e.loop()
+	return e
+}
+
+// Dropped returns the number of run operations dropped because the queue was
+// full or their batch could not be sent
+func (e *Exporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+// Flush sends the queued run operations
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.sendMu.Lock()
+	defer e.sendMu.Unlock()
+
+	for {
+		e.mu.Lock()
+		n := min(len(e.queue), e.batchSize)
+		ops := e.queue[:n:n]
+		e.queue = e.queue[n:]
+		e.mu.Unlock()
+
+		if len(ops) == 0 {
+			return nil
+		}
+		if err := e.send(ctx, ops); err != nil {
+			e.dropped.Add(int64(len(ops)))
+			return err
+		}
+	}
+}
+
+// Close stops the background flush and sends the queued run operations
+func (e *Exporter) Close(ctx context.Context) error {
+	e.closeOnce.Do(func() {
+		close(e.done)
+	})
+	<-e.stopped
+	return e.Flush(ctx)
+}
+
+// loop flushes the queue periodically, or as soon as a batch is full
+func (e *Exporter) loop() {
+	defer close(e.stopped)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.done:
+			return
+		case <-ticker.C:
+		case <-e.full:
+		}
+		// Failed batches are counted as dropped, the next flush carries on
+		_ = e.Flush(context.Background())
+	}
+}
+
+// send posts a batch, retrying network errors, 429 and 5xx responses
+func (e *Exporter) send(ctx context.Context, ops []operation) error {
+	body, err := json.Marshal(newBatchRequest(ops))
+	if err != nil {
+		return fmt.Errorf("failed to marshal runs: %w", err)
+	}
+
+	backoff := e.retryBackoff
+	for attempt := 0; ; attempt++ {
+		retryable, err := e.post(ctx, body)
+		if err == nil {
+			return nil
+		}
+		if !retryable || attempt >= e.maxRetries {
+			return err
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff *= 2
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// post sends one request to the batch endpoint, and reports whether a failure is worth retrying
+func (e *Exporter) post(ctx context.Context, body []byte) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint+"/runs/batch", bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("x-api-key", e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("failed to send runs: %w", err)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode < 300 {
+		return false, nil
+	}
+	retryable := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+	return retryable, fmt.Errorf("failed to send runs: status %s", resp.Status)
+}
+
+// newBatchRequest builds the body of a batch. The update of a run created in
+// the same batch is merged into its creation.
+func newBatchRequest(ops []operation) *batchRequest {
+	batch := &batchRequest{Post: []*Run{}, Patch: []*Run{}}
+	created := make(map[string]*Run)
+
+	for _, op := range ops {
+		if op.create {
+			batch.Post = append(batch.Post, op.run)
+			created[op.run.ID] = op.run
+			continue
+		}
+		if run, ok := created[op.run.ID]; ok {
+			run.EndTime = op.run.EndTime
+			run.Outputs = op.run.Outputs
+			run.Error = op.run.Error
+			run.Events = op.run.Events
+			continue
+		}
+		batch.Patch = append(batch.Patch, op.run)
+	}
+	return batch
+}
+
+// enqueue adds a run operation, or drops it when the queue is full
+func (e *Exporter) enqueue(op operation) {
+	e.mu.Lock()
+	if len(e.queue) >= e.queueSize {
+		e.mu.Unlock()
+		e.dropped.Add(1)
+		return
+	}
+	e.queue = append(e.queue, op)
+	full := len(e.queue) >= e.batchSize
+	e.mu.Unlock()
+
+	if full {
+		select {
+		case e.full <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// startRun records the start of a run and queues its creation. Runs without
+// a parent are children of the node or graph run executing in ctx, if any.
+func (e *Exporter) startRun(ctx context.Context, runID string, parentRunID *string, name, runType string, inputs map[string]interface{}, tags []string, metadata map[string]interface{}) {
+	start := time.Now().UTC()
+	parentID := ""
+	if parentRunID != nil {
+		parentID = *parentRunID
+	} else if id := graph.GetNodeRunID(ctx); id != "" && id != runID {
+		parentID = id
+	} else if id := graph.GetRunID(ctx); id != runID {
+		parentID = id
+	}
+
+	order := dottedOrderSegment(start, runID)
+	active := &activeRun{traceID: runID, dottedOrder: order}
+
+	e.mu.Lock()
+	if parent, ok := e.active[parentID]; ok {
+		active.traceID = parent.traceID
+		active.dottedOrder = parent.dottedOrder + "." + order
+		active.parentRunID = parentID
+	}
+	e.active[runID] = active
+	e.mu.Unlock()
+
+	run := &Run{
+		ID:          runID,
+		TraceID:     active.traceID,
+		DottedOrder: active.dottedOrder,
+		ParentRunID: active.parentRunID,
+		Name:        name,
+		RunType:     runType,
+		SessionName: e.project,
+		StartTime:   &start,
+		Inputs:      inputs,
+		Tags:        tags,
+	}
+	if len(metadata) > 0 {
+		run.Extra = map[string]interface{}{"metadata": metadata}
+	}
+	e.enqueue(operation{create: true, run: run})
+}
+
+// endRun queues the update ending a run
+func (e *Exporter) endRun(runID string, outputs map[string]interface{}, err error) {
+	end := time.Now().UTC()
+
+	e.mu.Lock()
+	active, ok := e.active[runID]
+	delete(e.active, runID)
+	e.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	run := &Run{
+		ID:          runID,
+		TraceID:     active.traceID,
+		DottedOrder: active.dottedOrder,
+		ParentRunID: active.parentRunID,
+		EndTime:     &end,
+		Outputs:     outputs,
+		Events:      active.events,
+	}
+	if err != nil {
+		run.Error = err.Error()
+	}
+	e.enqueue(operation{run: run})
+}
+
+// OnChainStart implements graph.CallbackHandler
+func (e *Exporter) OnChainStart(ctx context.Context, serialized map[string]interface{}, inputs map[string]interface{}, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	e.startRun(ctx, runID, parentRunID, runName(serialized, "chain"), RunTypeChain, inputs, tags, metadata)
+}
+
+// OnChainEnd implements graph.CallbackHandler
+func (e *Exporter) OnChainEnd(ctx context.Context, outputs map[string]interface{}, runID string) {
+	e.endRun(runID, outputs, nil)
+}
+
+// OnChainError implements graph.CallbackHandler
+func (e *Exporter) OnChainError(ctx context.Context, err error, runID string) {
+	e.endRun(runID, nil, err)
+}
+
+// OnLLMStart implements graph.CallbackHandler
+func (e *Exporter) OnLLMStart(ctx context.Context, serialized map[string]interface{}, prompts []string, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	inputs := map[string]interface{}{"prompts": prompts}
+	e.startRun(ctx, runID, parentRunID, runName(serialized, "llm"), RunTypeLLM, inputs, tags, metadata)
+}
+
+// OnLLMEnd implements graph.CallbackHandler
+func (e *Exporter) OnLLMEnd(ctx context.Context, response interface{}, runID string) {
+	outputs, ok := response.(map[string]interface{})
+	if !ok {
+		outputs = map[string]interface{}{"output": response}
+	}
+	e.endRun(runID, outputs, nil)
+}
+
+// OnLLMError implements graph.CallbackHandler
+func (e *Exporter) OnLLMError(ctx context.Context, err error, runID string) {
+	e.endRun(runID, nil, err)
+}
+
+// OnToolStart implements graph.CallbackHandler
+func (e *Exporter) OnToolStart(ctx context.Context, serialized map[string]interface{}, inputStr string, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	inputs := map[string]interface{}{"input": inputStr}
+	e.startRun(ctx, runID, parentRunID, runName(serialized, "tool"), RunTypeTool, inputs, tags, metadata)
+}
+
+// OnToolEnd implements graph.CallbackHandler
+func (e *Exporter) OnToolEnd(ctx context.Context, output string, runID string) {
+	e.endRun(runID, map[string]interface{}{"output": output}, nil)
+}
+
+// OnToolError implements graph.CallbackHandler
+func (e *Exporter) OnToolError(ctx context.Context, err error, runID string) {
+	e.endRun(runID, nil, err)
+}
+
+// OnNodeStart implements graph.NodeCallbackHandler, recording nodes as chain runs
+func (e *Exporter) OnNodeStart(ctx context.Context, nodeName string, input interface{}, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	e.startRun(ctx, runID, parentRunID, nodeName, RunTypeChain, stateMap(input, "input"), tags, metadata)
+}
+
+// OnNodeEnd implements graph.NodeCallbackHandler
+func (e *Exporter) OnNodeEnd(ctx context.Context, nodeName string, output interface{}, runID string) {
+	e.endRun(runID, stateMap(output, "output"), nil)
+}
+
+// OnNodeError implements graph.NodeCallbackHandler
+func (e *Exporter) OnNodeError(ctx context.Context, nodeName string, err error, runID string) {
+	e.endRun(runID, nil, err)
+}
+
+// OnRetrieverStart implements graph.CallbackHandler
+func (e *Exporter) OnRetrieverStart(ctx context.Context, serialized map[string]interface{}, query string, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	inputs := map[string]interface{}{"query": query}
+	e.startRun(ctx, runID, parentRunID, runName(serialized, "retriever"), RunTypeRetriever, inputs, tags, metadata)
+}
+
+// OnRetrieverEnd implements graph.CallbackHandler
+func (e *Exporter) OnRetrieverEnd(ctx context.Context, documents []interface{}, runID string) {
+	e.endRun(runID, map[string]interface{}{"documents": documents}, nil)
+}
+
+// OnRetrieverError implements graph.CallbackHandler
+func (e *Exporter) OnRetrieverError(ctx context.Context, err error, runID string) {
+	e.endRun(runID, nil, err)
+}
+
+// OnGraphStep implements graph.GraphCallbackHandler, recording the step as
+// an event of the graph run
+func (e *Exporter) OnGraphStep(ctx context.Context, stepNode string, state interface{}) {
+	event := RunEvent{
+		Name:   "graph_step",
+		Time:   time.Now().UTC(),
+		Kwargs: map[string]interface{}{"node": stepNode},
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if active, ok := e.active[graph.GetRunID(ctx)]; ok {
+		active.events = append(active.events, event)
+	}
+}
+
+// dottedOrderSegment orders a run among its siblings, by start time then ID.
+// The time has no dot, which separates the segments of a dotted order.
+func dottedOrderSegment(start time.Time, runID string) string {
+	start = start.UTC()
+	return start.Format("20060102T150405") + fmt.Sprintf("%06dZ", start.Nanosecond()/1000) + runID
+}
+
+// stateMap returns a state as the inputs or outputs of a run, states that
+// aren't maps are set under key
+func stateMap(state interface{}, key string) map[string]interface{} {
+	if m, ok := state.(map[string]interface{}); ok {
+		return m
+	}
+	return map[string]interface{}{key: state}
+}
+
+// runName returns the name of a serialized component, or fallback
+func runName(serialized map[string]interface{}, fallback string) string {
+	if name, ok := serialized["name"].(string); ok && name != "" {
+		return name
+	}
+	return fallback
+}
+
+// firstNonEmpty returns the first non-empty value
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
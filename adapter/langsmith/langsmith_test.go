@@ -0,0 +1,280 @@
+package langsmith
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// standIn is a local stand-in for the LangSmith runs API
+type standIn struct {
+	t        *testing.T
+	mu       sync.Mutex
+	requests int
+	failures []int
+	batches  []batchRequest
+	received chan struct{}
+	server   *httptest.Server
+}
+
+func newStandIn(t *testing.T, failures ...int) *standIn {
+	s := &standIn{t: t, failures: failures, received: make(chan struct{}, 100)}
+	s.server = httptest.NewServer(http.HandlerFunc(s.handle))
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *standIn) handle(w http.ResponseWriter, r *http.Request) {
+	assert.Equal(s.t, "/runs/batch", r.URL.Path)
+	assert.Equal(s.t, "test-key", r.Header.Get("x-api-key"))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.requests++
+	if len(s.failures) > 0 {
+		status := s.failures[0]
+		s.failures = s.failures[1:]
+		w.WriteHeader(status)
+		return
+	}
+
+	var batch batchRequest
+	require.NoError(s.t, json.NewDecoder(r.Body).Decode(&batch))
+	s.batches = append(s.batches, batch)
+	w.WriteHeader(http.StatusAccepted)
+	s.received <- struct{}{}
+}
+
+// runs returns the received runs by ID, with updates applied
+func (s *standIn) runs() map[string]*Run {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	runs := make(map[string]*Run)
+	for _, batch := range s.batches {
+		for _, run := range batch.Post {
+			runs[run.ID] = run
+		}
+		for _, patch := range batch.Patch {
+			if run, ok := runs[patch.ID]; ok {
+				run.EndTime = patch.EndTime
+				run.Outputs = patch.Outputs
+				run.Error = patch.Error
+				run.Events = patch.Events
+			}
+		}
+	}
+	return runs
+}
+
+func (s *standIn) exporter(opts ExporterOptions) *Exporter {
+	opts.Endpoint = s.server.URL
+	opts.APIKey = "test-key"
+	opts.Project = "test-project"
+	if opts.FlushInterval == 0 {
+		opts.FlushInterval = time.Hour
+	}
+	if opts.RetryBackoff == 0 {
+		opts.RetryBackoff = time.Millisecond
+	}
+	return NewExporter(opts)
+}
+
+func TestExporter_RunTree(t *testing.T) {
+	stand := newStandIn(t)
+	exporter := stand.exporter(ExporterOptions{})
+
+	llmRunID := uuid.New().String()
+	g := graph.NewMessageGraph()
+	g.AddNode("agent", func(ctx context.Context, state interface{}) (interface{}, error) {
+		exporter.OnLLMStart(ctx, map[string]interface{}{"name": "gpt"}, []string{"hi"}, llmRunID, nil, nil, nil)
+		exporter.OnLLMEnd(ctx, "hello", llmRunID)
+		return state, nil
+	})
+	g.AddEdge("agent", graph.END)
+	g.SetEntryPoint("agent")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	config := &graph.Config{Callbacks: []graph.CallbackHandler{exporter}, Tags: []string{"test"}}
+	_, err = runnable.InvokeWithConfig(context.Background(), "input", config)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Close(context.Background()))
+
+	runs := stand.runs()
+	require.Len(t, runs, 3)
+
+	var root, node *Run
+	for _, run := range runs {
+		switch {
+		case run.RunType == RunTypeChain && run.ParentRunID == "":
+			root = run
+		case run.RunType == RunTypeChain:
+			node = run
+		}
+	}
+	require.NotNil(t, root)
+	require.NotNil(t, node)
+	llm := runs[llmRunID]
+
+	assert.Equal(t, root.ID, root.TraceID)
+	assert.Regexp(t, `^\d{8}T\d{12}Z`+root.ID+`$`, root.DottedOrder)
+	assert.Equal(t, "test-project", root.SessionName)
+	assert.Equal(t, []string{"test"}, root.Tags)
+	assert.NotNil(t, root.EndTime)
+	require.Len(t, root.Events, 1)
+	assert.Equal(t, "step:[agent]", root.Events[0].Kwargs["node"])
+
+	// Nodes are children of the graph run, LLM calls children of their node
+	for _, link := range []struct{ parent, child *Run }{{root, node}, {node, llm}} {
+		assert.Equal(t, link.parent.ID, link.child.ParentRunID)
+		assert.Equal(t, root.ID, link.child.TraceID)
+		assert.Regexp(t, `^`+regexp.QuoteMeta(link.parent.DottedOrder)+`\.\d{8}T\d{12}Z`+link.child.ID+`$`, link.child.DottedOrder)
+		assert.NotNil(t, link.child.EndTime)
+	}
+	assert.Equal(t, "agent", node.Name)
+	assert.Equal(t, map[string]interface{}{"input": "input"}, node.Inputs)
+	assert.Equal(t, map[string]interface{}{"output": "input"}, node.Outputs)
+	assert.Equal(t, RunTypeLLM, llm.RunType)
+	assert.Equal(t, map[string]interface{}{"prompts": []interface{}{"hi"}}, llm.Inputs)
+	assert.Equal(t, map[string]interface{}{"output": "hello"}, llm.Outputs)
+}
+
+func TestExporter_NestedSubgraph(t *testing.T) {
+	stand := newStandIn(t)
+	exporter := stand.exporter(ExporterOptions{})
+
+	inner := graph.NewMessageGraph()
+	inner.AddNode("inner", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	inner.AddEdge("inner", graph.END)
+	inner.SetEntryPoint("inner")
+
+	g := graph.NewMessageGraph()
+	require.NoError(t, g.AddSubgraph("sub", inner))
+	g.AddEdge("sub", graph.END)
+	g.SetEntryPoint("sub")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	config := &graph.Config{Callbacks: []graph.CallbackHandler{exporter}}
+	_, err = runnable.InvokeWithConfig(context.Background(), "input", config)
+	require.NoError(t, err)
+	require.NoError(t, exporter.Close(context.Background()))
+
+	byName := make(map[string][]*Run)
+	for _, run := range stand.runs() {
+		byName[run.Name] = append(byName[run.Name], run)
+	}
+	require.Len(t, byName["graph"], 2)
+	require.Len(t, byName["sub"], 1)
+	require.Len(t, byName["inner"], 1)
+
+	// graph -> sub -> graph -> inner, all in one trace
+	sub, innerNode := byName["sub"][0], byName["inner"][0]
+	var root, subgraphRun *Run
+	for _, run := range byName["graph"] {
+		if run.ParentRunID == "" {
+			root = run
+		} else {
+			subgraphRun = run
+		}
+	}
+	require.NotNil(t, root)
+	require.NotNil(t, subgraphRun)
+	assert.Equal(t, root.ID, sub.ParentRunID)
+	assert.Equal(t, sub.ID, subgraphRun.ParentRunID)
+	assert.Equal(t, subgraphRun.ID, innerNode.ParentRunID)
+	assert.Equal(t, root.ID, innerNode.TraceID)
+}
+
+func TestExporter_RunError(t *testing.T) {
+	stand := newStandIn(t)
+	exporter := stand.exporter(ExporterOptions{})
+
+	ctx := context.Background()
+	runID := uuid.New().String()
+	exporter.OnToolStart(ctx, nil, "query", runID, nil, nil, nil)
+	exporter.OnToolError(ctx, errors.New("boom"), runID)
+	require.NoError(t, exporter.Flush(ctx))
+
+	// The update is merged into the creation sent in the same batch
+	require.Len(t, stand.batches, 1)
+	assert.Len(t, stand.batches[0].Post, 1)
+	assert.Empty(t, stand.batches[0].Patch)
+	assert.Equal(t, "boom", stand.runs()[runID].Error)
+	assert.Equal(t, "tool", stand.runs()[runID].Name)
+}
+
+func TestExporter_Retry(t *testing.T) {
+	stand := newStandIn(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	exporter := stand.exporter(ExporterOptions{})
+
+	ctx := context.Background()
+	runID := uuid.New().String()
+	exporter.OnChainStart(ctx, nil, nil, runID, nil, nil, nil)
+	require.NoError(t, exporter.Flush(ctx))
+
+	assert.Equal(t, 3, stand.requests)
+	assert.Contains(t, stand.runs(), runID)
+	assert.Zero(t, exporter.Dropped())
+}
+
+func TestExporter_NonRetryableError(t *testing.T) {
+	stand := newStandIn(t, http.StatusBadRequest)
+	exporter := stand.exporter(ExporterOptions{})
+
+	ctx := context.Background()
+	exporter.OnChainStart(ctx, nil, nil, uuid.New().String(), nil, nil, nil)
+	exporter.OnChainStart(ctx, nil, nil, uuid.New().String(), nil, nil, nil)
+	assert.Error(t, exporter.Flush(ctx))
+
+	assert.Equal(t, 1, stand.requests)
+	assert.Equal(t, int64(2), exporter.Dropped())
+}
+
+func TestExporter_BoundedQueue(t *testing.T) {
+	stand := newStandIn(t)
+	exporter := stand.exporter(ExporterOptions{QueueSize: 2})
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		exporter.OnChainStart(ctx, nil, nil, uuid.New().String(), nil, nil, nil)
+	}
+	assert.Equal(t, int64(1), exporter.Dropped())
+
+	require.NoError(t, exporter.Flush(ctx))
+	assert.Len(t, stand.runs(), 2)
+}
+
+func TestExporter_BackgroundFlush(t *testing.T) {
+	stand := newStandIn(t)
+	exporter := stand.exporter(ExporterOptions{BatchSize: 2})
+	defer exporter.Close(context.Background())
+
+	// A full batch is sent without waiting for the flush interval
+	ctx := context.Background()
+	exporter.OnChainStart(ctx, nil, nil, uuid.New().String(), nil, nil, nil)
+	exporter.OnChainStart(ctx, nil, nil, uuid.New().String(), nil, nil, nil)
+
+	select {
+	case <-stand.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the full batch to be sent")
+	}
+	assert.Len(t, stand.runs(), 2)
+}
@@ -0,0 +1,238 @@
+// Package otel exports the spans of a graph.Tracer as OpenTelemetry spans.
+package otel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/smallnest/langgraphgo/graph"
+	otelapi "go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName is the name of the OpenTelemetry tracer used by the hook
+const InstrumentationName = "github.com/smallnest/langgraphgo/adapter/otel"
+
+// Attribute keys set on the exported spans
+const (
+	AttrEvent       = attribute.Key("langgraph.span.event")
+	AttrGraphName   = attribute.Key("langgraph.graph.name")
+	AttrNodeName    = attribute.Key("langgraph.node.name")
+	AttrThreadID    = attribute.Key("langgraph.thread_id")
+	AttrStep        = attribute.Key("langgraph.step")
+	AttrAttempt     = attribute.Key("langgraph.attempt")
+	AttrEdgeFrom    = attribute.Key("langgraph.edge.from")
+	AttrEdgeTo      = attribute.Key("langgraph.edge.to")
+	AttrInterrupted = attribute.Key("langgraph.interrupted")
+)
+
+// HookOptions configuration for the OpenTelemetry hook
+type HookOptions struct {
+	// TracerProvider creates the exported spans. Default is the global provider
+	TracerProvider trace.TracerProvider
+
+	// GraphName names the run spans and is set as langgraph.graph.name. Default "graph"
+	GraphName string
+}
+
+// Hook is a graph.TraceHook that mirrors graph spans as OpenTelemetry spans.
+// Graph and node spans become parent and child, edge traversals become
+// zero-length spans under their parent. A run span without a graph parent
+// continues the trace of the incoming context.
+type Hook struct {
+	tracer    trace.Tracer
+	graphName string
+
+	mu     sync.Mutex
+	active map[string]activeSpan
+}
+
+// activeSpan is an exported span that has not ended yet
+type activeSpan struct {
+	span trace.Span
+	ctx  context.Context
+}
+
+var _ graph.TraceHook = &Hook{}
+
+// NewHook creates a new OpenTelemetry hook
+func NewHook(opts HookOptions) *Hook {
+	provider := opts.TracerProvider
+	if provider == nil {
+		provider = otelapi.GetTracerProvider()
+	}
+	graphName := opts.GraphName
+	if graphName == "" {
+		graphName = "graph"
+	}
+	return &Hook{
+		tracer:    provider.Tracer(InstrumentationName),
+		graphName: graphName,
+		active:    make(map[string]activeSpan),
+	}
+}
+
+// Instrument adds a hook to a new tracer and sets it on the runnable.
+// Any runnable of the graph package can be instrumented.
+func Instrument(runnable interface{ SetTracer(*graph.Tracer) }, opts HookOptions) *graph.Tracer {
+	tracer := graph.NewTracer()
+	tracer.AddHook(NewHook(opts))
+	runnable.SetTracer(tracer)
+	return tracer
+}
+
+// OnEvent implements graph.TraceHook. Spans are reported once when they
+// start and again when they end, edge traversals only once, already ended.
+func (h *Hook) OnEvent(ctx context.Context, span *graph.TraceSpan) {
+	if span.Event == graph.TraceEventEdgeTraversal {
+		h.traceEdge(ctx, span)
+		return
+	}
+
+	if span.EndTime.IsZero() {
+		h.start(ctx, span)
+		return
+	}
+	h.end(span)
+}
+
+// start creates the OpenTelemetry span of a graph span
+func (h *Hook) start(ctx context.Context, span *graph.TraceSpan) {
+	parentCtx := h.parentContext(ctx, span)
+	otelCtx, otelSpan := h.tracer.Start(parentCtx, h.spanName(span),
+		trace.WithTimestamp(span.StartTime),
+		trace.WithAttributes(h.startAttributes(ctx, span)...),
+	)
+
+	h.mu.Lock()
+	h.active[span.ID] = activeSpan{span: otelSpan, ctx: otelCtx}
+	h.mu.Unlock()
+}
+
+// end completes the OpenTelemetry span of a graph span
+func (h *Hook) end(span *graph.TraceSpan) {
+	h.mu.Lock()
+	active, ok := h.active[span.ID]
+	delete(h.active, span.ID)
+	h.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	active.span.SetAttributes(AttrEvent.String(string(span.Event)))
+	active.span.SetAttributes(metadataAttributes(span.Metadata)...)
+	recordError(active.span, span.Error)
+	active.span.End(trace.WithTimestamp(span.EndTime))
+}
+
+// traceEdge exports an edge traversal as a zero-length span
+func (h *Hook) traceEdge(ctx context.Context, span *graph.TraceSpan) {
+	attrs := append(h.startAttributes(ctx, span),
+		AttrEdgeFrom.String(span.FromNode),
+		AttrEdgeTo.String(span.ToNode),
+	)
+
+	_, otelSpan := h.tracer.Start(h.parentContext(ctx, span), h.spanName(span),
+		trace.WithTimestamp(span.StartTime),
+		trace.WithAttributes(attrs...),
+	)
+	otelSpan.End(trace.WithTimestamp(span.EndTime))
+}
+
+// parentContext returns the context of the exported parent span, or the
+// incoming context so runs continue the trace of the request
+func (h *Hook) parentContext(ctx context.Context, span *graph.TraceSpan) context.Context {
+	if span.ParentID == "" {
+		return ctx
+	}
+
+	h.mu.Lock()
+	parent, ok := h.active[span.ParentID]
+	h.mu.Unlock()
+	if !ok {
+		return ctx
+	}
+	return parent.ctx
+}
+
+// spanName names the exported span of a graph span
+func (h *Hook) spanName(span *graph.TraceSpan) string {
+	switch span.Event {
+	case graph.TraceEventGraphStart, graph.TraceEventGraphEnd:
+		return h.graphName
+	case graph.TraceEventEdgeTraversal:
+		return fmt.Sprintf("edge %s -> %s", span.FromNode, span.ToNode)
+	case graph.TraceEventAttemptStart, graph.TraceEventAttemptEnd:
+		return fmt.Sprintf("node %s attempt %v", span.NodeName, span.Metadata["attempt"])
+	case graph.TraceEventCheckpointStart, graph.TraceEventCheckpointEnd:
+		return "checkpoint save"
+	default:
+		return "node " + span.NodeName
+	}
+}
+
+// startAttributes are the attributes known when a span starts
+func (h *Hook) startAttributes(ctx context.Context, span *graph.TraceSpan) []attribute.KeyValue {
+	attrs := []attribute.KeyValue{
+		AttrEvent.String(string(span.Event)),
+		AttrGraphName.String(h.graphName),
+	}
+	attrs = append(attrs, metadataAttributes(span.Metadata)...)
+	if span.NodeName != "" && span.Event != graph.TraceEventGraphStart {
+		attrs = append(attrs, AttrNodeName.String(span.NodeName))
+	}
+	if config := graph.GetConfig(ctx); config != nil && config.Configurable != nil {
+		if threadID, ok := config.Configurable["thread_id"].(string); ok && threadID != "" {
+			attrs = append(attrs, AttrThreadID.String(threadID))
+		}
+	}
+	return attrs
+}
+
+// metadataAttributes converts span metadata to attributes prefixed with
+// "langgraph.", such as langgraph.step and langgraph.attempt
+func metadataAttributes(metadata map[string]interface{}) []attribute.KeyValue {
+	attrs := make([]attribute.KeyValue, 0, len(metadata))
+	for key, value := range metadata {
+		attrKey := attribute.Key("langgraph." + key)
+		switch v := value.(type) {
+		case string:
+			attrs = append(attrs, attrKey.String(v))
+		case bool:
+			attrs = append(attrs, attrKey.Bool(v))
+		case int:
+			attrs = append(attrs, attrKey.Int(v))
+		case int64:
+			attrs = append(attrs, attrKey.Int64(v))
+		case float64:
+			attrs = append(attrs, attrKey.Float64(v))
+		case []string:
+			attrs = append(attrs, attrKey.StringSlice(v))
+		default:
+			attrs = append(attrs, attrKey.String(fmt.Sprint(v)))
+		}
+	}
+	return attrs
+}
+
+// recordError sets the status of a span. Interrupts pause a run and are not
+// reported as errors.
+func recordError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+
+	var graphInterrupt *graph.GraphInterrupt
+	var nodeInterrupt *graph.NodeInterrupt
+	if errors.As(err, &graphInterrupt) || errors.As(err, &nodeInterrupt) {
+		span.SetAttributes(AttrInterrupted.Bool(true))
+		return
+	}
+
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
@@ -0,0 +1,176 @@
+package otel
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func newTestProvider() (*sdktrace.TracerProvider, *tracetest.InMemoryExporter) {
+	exporter := tracetest.NewInMemoryExporter()
+	return sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)), exporter
+}
+
+func spansByName(spans tracetest.SpanStubs) map[string]tracetest.SpanStub {
+	byName := make(map[string]tracetest.SpanStub, len(spans))
+	for _, span := range spans {
+		byName[span.Name] = span
+	}
+	return byName
+}
+
+func attr(span tracetest.SpanStub, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestHook_StateRunnable(t *testing.T) {
+	provider, exporter := newTestProvider()
+
+	g := graph.NewStateGraph()
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+	Instrument(runnable, HookOptions{TracerProvider: provider, GraphName: "test-graph"})
+
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	_, err = runnable.InvokeWithConfig(context.Background(), map[string]interface{}{}, config)
+	require.NoError(t, err)
+
+	spans := spansByName(exporter.GetSpans())
+	require.Contains(t, spans, "test-graph")
+	require.Contains(t, spans, "node a")
+	require.Contains(t, spans, "node b")
+
+	root := spans["test-graph"]
+	assert.False(t, root.Parent.IsValid())
+	assert.Equal(t, "thread-1", attr(root, AttrThreadID).AsString())
+	assert.Equal(t, string(graph.TraceEventGraphEnd), attr(root, AttrEvent).AsString())
+
+	for i, name := range []string{"node a", "node b"} {
+		node := spans[name]
+		assert.Equal(t, root.SpanContext.TraceID(), node.SpanContext.TraceID())
+		assert.Equal(t, root.SpanContext.SpanID(), node.Parent.SpanID())
+		assert.Equal(t, name[len("node "):], attr(node, AttrNodeName).AsString())
+		assert.Equal(t, "thread-1", attr(node, AttrThreadID).AsString())
+		assert.Equal(t, int64(i+1), attr(node, AttrStep).AsInt64())
+		assert.Equal(t, codes.Unset, node.Status.Code)
+	}
+
+	// Edges taken by the run are exported under the run span
+	require.Contains(t, spans, "edge a -> b")
+	assert.Equal(t, root.SpanContext.SpanID(), spans["edge a -> b"].Parent.SpanID())
+}
+
+func TestHook_NodeError(t *testing.T) {
+	provider, exporter := newTestProvider()
+
+	g := graph.NewStateGraph()
+	g.AddNode("fail", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	g.AddEdge("fail", graph.END)
+	g.SetEntryPoint("fail")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+	Instrument(runnable, HookOptions{TracerProvider: provider})
+
+	_, err = runnable.Invoke(context.Background(), map[string]interface{}{})
+	require.Error(t, err)
+
+	spans := spansByName(exporter.GetSpans())
+	require.Contains(t, spans, "node fail")
+
+	node := spans["node fail"]
+	assert.Equal(t, codes.Error, node.Status.Code)
+	assert.Equal(t, string(graph.TraceEventNodeError), attr(node, AttrEvent).AsString())
+	require.NotEmpty(t, node.Events)
+	assert.Equal(t, "exception", node.Events[0].Name)
+
+	// The run span ends even though the run failed
+	root := spans["graph"]
+	assert.Equal(t, codes.Error, root.Status.Code)
+	assert.Equal(t, root.SpanContext.SpanID(), node.Parent.SpanID())
+}
+
+func TestHook_PropagatesIncomingTrace(t *testing.T) {
+	provider, exporter := newTestProvider()
+
+	g := graph.NewMessageGraph()
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddEdge("a", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+	Instrument(runnable, HookOptions{TracerProvider: provider})
+
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	_, err = runnable.Invoke(ctx, "input")
+	require.NoError(t, err)
+	request.End()
+
+	spans := spansByName(exporter.GetSpans())
+	root := spans["graph"]
+	assert.Equal(t, spans["request"].SpanContext.TraceID(), root.SpanContext.TraceID())
+	assert.Equal(t, spans["request"].SpanContext.SpanID(), root.Parent.SpanID())
+}
+
+func TestHook_EdgeTraversal(t *testing.T) {
+	provider, exporter := newTestProvider()
+	tracer := graph.NewTracer()
+	tracer.AddHook(NewHook(HookOptions{TracerProvider: provider}))
+
+	ctx := context.Background()
+	graphSpan := tracer.StartSpan(ctx, graph.TraceEventGraphStart, "graph")
+	tracer.TraceEdgeTraversal(graph.ContextWithSpan(ctx, graphSpan), "a", "b")
+	tracer.EndSpan(ctx, graphSpan, nil, nil)
+
+	spans := spansByName(exporter.GetSpans())
+	require.Contains(t, spans, "edge a -> b")
+
+	edge := spans["edge a -> b"]
+	assert.Equal(t, "a", attr(edge, AttrEdgeFrom).AsString())
+	assert.Equal(t, "b", attr(edge, AttrEdgeTo).AsString())
+	assert.Equal(t, spans["graph"].SpanContext.SpanID(), edge.Parent.SpanID())
+}
+
+func TestHook_Interrupt(t *testing.T) {
+	provider, exporter := newTestProvider()
+	hook := NewHook(HookOptions{TracerProvider: provider})
+	tracer := graph.NewTracer()
+	tracer.AddHook(hook)
+
+	ctx := context.Background()
+	span := tracer.StartSpan(ctx, graph.TraceEventNodeStart, "ask")
+	tracer.EndSpan(ctx, span, nil, &graph.NodeInterrupt{Node: "ask", Value: "question"})
+
+	spans := spansByName(exporter.GetSpans())
+	node := spans["node ask"]
+	assert.Equal(t, codes.Unset, node.Status.Code)
+	assert.True(t, attr(node, AttrInterrupted).AsBool())
+}
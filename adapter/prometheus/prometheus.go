@@ -0,0 +1,265 @@
+// Package prometheus publishes Prometheus metrics for graph executions.
+//
+// Metrics is a source of graph.TraceHooks, one per graph, so the counters
+// and histograms follow the spans of a graph.Tracer:
+//
+//	metrics, _ := prometheus.NewMetrics(prometheus.MetricsOptions{})
+//	metrics.Instrument(runnable, "agent")
+//	http.Handle("/metrics", metrics.Handler())
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+
+	prom "github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+const (
+	// DefaultNamespace prefixes the names of the metrics
+	DefaultNamespace = "langgraph"
+
+	// DefaultMaxNodeLabels is the default number of distinct node names per graph
+	DefaultMaxNodeLabels = 100
+
+	// OtherNodeLabel replaces node names beyond the label limit of a graph
+	OtherNodeLabel = "__other__"
+)
+
+// Run statuses of the runs_total counter
+const (
+	StatusSuccess     = "success"
+	StatusError       = "error"
+	StatusInterrupted = "interrupted"
+)
+
+// MetricsOptions configuration for the metrics
+type MetricsOptions struct {
+	// Registry the metrics are registered with and served from. Default is a new registry
+	Registry *prom.Registry
+
+	// Namespace prefixes the metric names. Default "langgraph"
+	Namespace string
+
+	// MaxNodeLabels bounds the distinct node names of a graph. Nodes named
+	// after the limit is reached, such as dynamically created ones, share the
+	// "__other__" label. Default 100
+	MaxNodeLabels int
+
+	// Buckets of the duration histograms, in seconds. Default prom.DefBuckets
+	Buckets []float64
+}
+
+// Metrics publishes counters and histograms of graph runs, node executions,
+// node errors, retries, interrupts, checkpoint saves and LLM token usage
+type Metrics struct {
+	registry      *prom.Registry
+	maxNodeLabels int
+
+	runs               *prom.CounterVec
+	runDuration        *prom.HistogramVec
+	nodeExecutions     *prom.CounterVec
+	nodeErrors         *prom.CounterVec
+	nodeDuration       *prom.HistogramVec
+	retries            *prom.CounterVec
+	interrupts         *prom.CounterVec
+	checkpointDuration *prom.HistogramVec
+	tokens             *prom.CounterVec
+
+	mu sync.Mutex
+	// nodeLabels holds the node names with a label of their own, by graph
+	nodeLabels map[string]map[string]bool
+	// spanGraphs maps the active node spans to their graph, for token usage
+	spanGraphs map[string]string
+}
+
+// NewMetrics creates the metrics and registers them
+func NewMetrics(opts MetricsOptions) (*Metrics, error) {
+	registry := opts.Registry
+	if registry == nil {
+		registry = prom.NewRegistry()
+	}
+	namespace := opts.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	maxNodeLabels := opts.MaxNodeLabels
+	if maxNodeLabels <= 0 {
+		maxNodeLabels = DefaultMaxNodeLabels
+	}
+	buckets := opts.Buckets
+	if len(buckets) == 0 {
+		buckets = prom.DefBuckets
+	}
+
+	counter := func(name, help string, labels ...string) *prom.CounterVec {
+		return prom.NewCounterVec(prom.CounterOpts{Namespace: namespace, Name: name, Help: help}, labels)
+	}
+	histogram := func(name, help string, labels ...string) *prom.HistogramVec {
+		return prom.NewHistogramVec(prom.HistogramOpts{Namespace: namespace, Name: name, Help: help, Buckets: buckets}, labels)
+	}
+
+	m := &Metrics{
+		registry:      registry,
+		maxNodeLabels: maxNodeLabels,
+
+		runs:               counter("runs_total", "Graph runs by final status.", "graph", "status"),
+		runDuration:        histogram("run_duration_seconds", "Duration of graph runs.", "graph"),
+		nodeExecutions:     counter("node_executions_total", "Node executions.", "graph", "node"),
+		nodeErrors:         counter("node_errors_total", "Node executions that failed.", "graph", "node"),
+		nodeDuration:       histogram("node_duration_seconds", "Duration of node executions.", "graph", "node"),
+		retries:            counter("node_retries_total", "Retried attempts of nodes.", "graph", "node"),
+		interrupts:         counter("interrupts_total", "Runs interrupted before, after or in a node.", "graph", "node"),
+		checkpointDuration: histogram("checkpoint_save_duration_seconds", "Latency of checkpoint saves.", "graph"),
+		tokens:             counter("llm_tokens_total", "LLM tokens used by nodes, by type prompt or completion.", "graph", "node", "type"),
+
+		nodeLabels: make(map[string]map[string]bool),
+		spanGraphs: make(map[string]string),
+	}
+
+	collectors := []prom.Collector{
+		m.runs, m.runDuration, m.nodeExecutions, m.nodeErrors, m.nodeDuration,
+		m.retries, m.interrupts, m.checkpointDuration, m.tokens,
+	}
+	for _, collector := range collectors {
+		if err := registry.Register(collector); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
+	}
+	return m, nil
+}
+
+// Handler serves the metrics of the registry, typically on /metrics
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// Registry returns the registry the metrics are registered with
+func (m *Metrics) Registry() *prom.Registry {
+	return m.registry
+}
+
+// Hook returns the trace hook recording the spans of a graph under graphName
+func (m *Metrics) Hook(graphName string) graph.TraceHook {
+	return graph.TraceHookFunc(func(ctx context.Context, span *graph.TraceSpan) {
+		m.observe(graphName, span)
+	})
+}
+
+// Instrument adds the hook of graphName to a new tracer and sets it on the runnable
+func (m *Metrics) Instrument(runnable interface{ SetTracer(*graph.Tracer) }, graphName string) *graph.Tracer {
+	tracer := graph.NewTracer()
+	tracer.AddHook(m.Hook(graphName))
+	runnable.SetTracer(tracer)
+	return tracer
+}
+
+// RecordTokenUsage counts the tokens of an LLM call made by the node running in ctx
+func (m *Metrics) RecordTokenUsage(ctx context.Context, promptTokens, completionTokens int) {
+	graphName, node := "", ""
+	if span := graph.SpanFromContext(ctx); span != nil {
+		m.mu.Lock()
+		graphName = m.spanGraphs[span.ID]
+		m.mu.Unlock()
+		if graphName != "" {
+			node = m.nodeLabel(graphName, span.NodeName)
+		}
+	}
+
+	if promptTokens > 0 {
+		m.tokens.WithLabelValues(graphName, node, "prompt").Add(float64(promptTokens))
+	}
+	if completionTokens > 0 {
+		m.tokens.WithLabelValues(graphName, node, "completion").Add(float64(completionTokens))
+	}
+}
+
+// observe records a span of graphName
+func (m *Metrics) observe(graphName string, span *graph.TraceSpan) {
+	switch span.Event {
+	case graph.TraceEventNodeStart, graph.TraceEventAttemptStart:
+		m.trackSpan(span.ID, graphName)
+		if attempt, ok := span.Metadata["attempt"].(int); ok && attempt > 1 {
+			m.retries.WithLabelValues(graphName, m.nodeLabel(graphName, span.NodeName)).Inc()
+		}
+
+	case graph.TraceEventAttemptEnd:
+		m.untrackSpan(span.ID)
+
+	case graph.TraceEventNodeEnd, graph.TraceEventNodeError:
+		m.untrackSpan(span.ID)
+		node := m.nodeLabel(graphName, span.NodeName)
+		m.nodeExecutions.WithLabelValues(graphName, node).Inc()
+		m.nodeDuration.WithLabelValues(graphName, node).Observe(span.Duration.Seconds())
+		if span.Error != nil && !isInterrupt(span.Error) {
+			m.nodeErrors.WithLabelValues(graphName, node).Inc()
+		}
+
+	case graph.TraceEventGraphEnd:
+		// Subgraph runs are part of the run of their parent
+		if span.ParentID != "" {
+			return
+		}
+		status := StatusSuccess
+		var graphInterrupt *graph.GraphInterrupt
+		switch {
+		case errors.As(span.Error, &graphInterrupt):
+			status = StatusInterrupted
+			m.interrupts.WithLabelValues(graphName, m.nodeLabel(graphName, graphInterrupt.Node)).Inc()
+		case span.Error != nil:
+			status = StatusError
+		}
+		m.runs.WithLabelValues(graphName, status).Inc()
+		m.runDuration.WithLabelValues(graphName).Observe(span.Duration.Seconds())
+
+	case graph.TraceEventCheckpointEnd:
+		m.checkpointDuration.WithLabelValues(graphName).Observe(span.Duration.Seconds())
+	}
+}
+
+// nodeLabel returns the label of a node, "__other__" once the graph has
+// maxNodeLabels distinct node names
+func (m *Metrics) nodeLabel(graphName, node string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	labels, ok := m.nodeLabels[graphName]
+	if !ok {
+		labels = make(map[string]bool)
+		m.nodeLabels[graphName] = labels
+	}
+	if labels[node] {
+		return node
+	}
+	if len(labels) >= m.maxNodeLabels {
+		return OtherNodeLabel
+	}
+	labels[node] = true
+	return node
+}
+
+// trackSpan remembers the graph of an active node span
+func (m *Metrics) trackSpan(spanID, graphName string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.spanGraphs[spanID] = graphName
+}
+
+// untrackSpan forgets an ended node span
+func (m *Metrics) untrackSpan(spanID string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.spanGraphs, spanID)
+}
+
+// isInterrupt reports whether err pauses the run rather than failing it
+func isInterrupt(err error) bool {
+	var graphInterrupt *graph.GraphInterrupt
+	var nodeInterrupt *graph.NodeInterrupt
+	return errors.As(err, &graphInterrupt) || errors.As(err, &nodeInterrupt)
+}
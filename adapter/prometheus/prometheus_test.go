@@ -0,0 +1,144 @@
+package prometheus
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestMetrics(t *testing.T, opts MetricsOptions) *Metrics {
+	metrics, err := NewMetrics(opts)
+	require.NoError(t, err)
+	return metrics
+}
+
+func TestMetrics_Run(t *testing.T) {
+	metrics := newTestMetrics(t, MetricsOptions{})
+
+	calls := 0
+	g := graph.NewMessageGraph()
+	g.AddNodeWithRetry("flaky", func(ctx context.Context, state interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("transient")
+		}
+		return state, nil
+	}, &graph.RetryConfig{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffFactor: 1})
+	g.AddNode("llm", func(ctx context.Context, state interface{}) (interface{}, error) {
+		metrics.RecordTokenUsage(ctx, 10, 5)
+		return state, nil
+	})
+	g.AddEdge("flaky", "llm")
+	g.AddEdge("llm", graph.END)
+	g.SetEntryPoint("flaky")
+
+	runnable, err := g.Compile(graph.WithCheckpointer(graph.NewMemoryCheckpointStore()))
+	require.NoError(t, err)
+	metrics.Instrument(runnable, "agent")
+
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	_, err = runnable.InvokeWithConfig(context.Background(), "input", config)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.runs.WithLabelValues("agent", StatusSuccess)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("agent", "flaky")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("agent", "llm")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.retries.WithLabelValues("agent", "flaky")))
+	assert.Equal(t, 0.0, testutil.ToFloat64(metrics.nodeErrors.WithLabelValues("agent", "flaky")))
+	assert.Equal(t, 10.0, testutil.ToFloat64(metrics.tokens.WithLabelValues("agent", "llm", "prompt")))
+	assert.Equal(t, 5.0, testutil.ToFloat64(metrics.tokens.WithLabelValues("agent", "llm", "completion")))
+
+	// One histogram series per label set, and one save per superstep
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.runDuration))
+	assert.Equal(t, 2, testutil.CollectAndCount(metrics.nodeDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(metrics.checkpointDuration))
+	assert.Empty(t, metrics.spanGraphs)
+}
+
+func TestMetrics_ErrorsAndInterrupts(t *testing.T) {
+	metrics := newTestMetrics(t, MetricsOptions{})
+
+	g := graph.NewStateGraph()
+	g.AddNode("ok", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddNode("fail", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	g.AddEdge("ok", "fail")
+	g.AddEdge("fail", graph.END)
+	g.SetEntryPoint("ok")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+	metrics.Instrument(runnable, "workflow")
+
+	_, err = runnable.Invoke(context.Background(), map[string]interface{}{})
+	require.Error(t, err)
+
+	config := &graph.Config{InterruptBefore: []string{"fail"}}
+	_, err = runnable.InvokeWithConfig(context.Background(), map[string]interface{}{}, config)
+	var graphInterrupt *graph.GraphInterrupt
+	require.ErrorAs(t, err, &graphInterrupt)
+
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.runs.WithLabelValues("workflow", StatusError)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.runs.WithLabelValues("workflow", StatusInterrupted)))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.nodeErrors.WithLabelValues("workflow", "fail")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.interrupts.WithLabelValues("workflow", "fail")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("workflow", "ok")))
+}
+
+func TestMetrics_NodeLabelLimit(t *testing.T) {
+	metrics := newTestMetrics(t, MetricsOptions{MaxNodeLabels: 2})
+	hook := metrics.Hook("dynamic")
+	tracer := graph.NewTracer()
+	tracer.AddHook(hook)
+
+	ctx := context.Background()
+	for _, name := range []string{"a", "b", "c", "d", "a"} {
+		span := tracer.StartSpan(ctx, graph.TraceEventNodeStart, name)
+		tracer.EndSpan(ctx, span, nil, nil)
+	}
+
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("dynamic", "a")))
+	assert.Equal(t, 1.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("dynamic", "b")))
+	assert.Equal(t, 2.0, testutil.ToFloat64(metrics.nodeExecutions.WithLabelValues("dynamic", OtherNodeLabel)))
+	assert.Equal(t, 3, testutil.CollectAndCount(metrics.nodeExecutions))
+}
+
+func TestMetrics_Handler(t *testing.T) {
+	metrics := newTestMetrics(t, MetricsOptions{Namespace: "app"})
+	tracer := graph.NewTracer()
+	tracer.AddHook(metrics.Hook("agent"))
+
+	ctx := context.Background()
+	span := tracer.StartSpan(ctx, graph.TraceEventGraphStart, "graph")
+	tracer.EndSpan(ctx, span, nil, nil)
+
+	server := httptest.NewServer(metrics.Handler())
+	defer server.Close()
+
+	resp, err := server.Client().Get(server.URL + "/metrics")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Contains(t, string(body), `app_runs_total{graph="agent",status="success"} 1`)
+	assert.Contains(t, string(body), `app_run_duration_seconds_count{graph="agent"} 1`)
+}
+
+func TestNewMetrics_DuplicateRegistration(t *testing.T) {
+	metrics := newTestMetrics(t, MetricsOptions{})
+
+	_, err := NewMetrics(MetricsOptions{Registry: metrics.Registry()})
+	assert.Error(t, err)
+}
@@ -0,0 +1,579 @@
+package bolt
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"go.etcd.io/bbolt"
+)
+
+var (
+	// threadsBucket holds a bucket per thread, its checkpoints keyed in order
+	threadsBucket = []byte("threads")
+	// idsBucket maps checkpoint IDs to their thread and key
+	idsBucket = []byte("ids")
+	// writesBucket holds a bucket per checkpoint, its pending writes keyed by node
+	writesBucket = []byte("writes")
+)
+
+// orderLen is the length of the ordering prefix of checkpoint keys
+const orderLen = 20
+
+var (
+	_ graph.CheckpointStoreV2          = &BoltCheckpointStore{}
+	_ graph.PendingWritesStore         = &BoltCheckpointStore{}
+	_ graph.ThreadLister               = &BoltCheckpointStore{}
+	_ graph.ConditionalCheckpointStore = &BoltCheckpointStore{}
+)
+
+// BoltCheckpointStore implements graph.CheckpointStore on a bbolt database,
+// an embedded key/value store that needs no cgo. Each thread is a bucket
+// whose keys sort its checkpoints by version and timestamp, so the latest
+// checkpoint and pages of history are read with a cursor.
+// bbolt locks the database file, it is opened by one process at a time.
+type BoltCheckpointStore struct {
+	db         *bbolt.DB
+	bucket     []byte
+	serializer graph.Serializer
+}
+
+// BoltOptions configuration for the bolt store
+type BoltOptions struct {
+	Path       string
+	Bucket     string           // Default "checkpoints"
+	Serializer graph.Serializer // Encodes states, default graph.DefaultSerializer()
+
+	// Timeout to acquire the file lock held by another process, default waits indefinitely
+	Timeout time.Duration
+}
+
+// NewBoltCheckpointStore creates a new bolt checkpoint store
+func NewBoltCheckpointStore(opts BoltOptions) (*BoltCheckpointStore, error) {
+	if opts.Path == "" {
+		return nil, fmt.Errorf("path is required")
+	}
+
+	db, err := bbolt.Open(opts.Path, 0o600, &bbolt.Options{Timeout: opts.Timeout})
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+
+	bucket := opts.Bucket
+	if bucket == "" {
+		bucket = "checkpoints"
+	}
+
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
+	store := &BoltCheckpointStore{
+		db:         db,
+		bucket:     []byte(bucket),
+		serializer: serializer,
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists(store.bucket)
+		if err != nil {
+			return err
+		}
+		for _, name := range [][]byte{threadsBucket, idsBucket, writesBucket} {
+			if _, err := root.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create buckets: %w", err)
+	}
+
+	return store, nil
+}
+
+// Close closes the database
+func (s *BoltCheckpointStore) Close() error {
+	return s.db.Close()
+}
+
+// record is the value of a checkpoint key. States whose encoding is JSON
+// are embedded as is, to keep the values readable.
+type record struct {
+	*graph.Checkpoint
+	State      json.RawMessage `json:"state,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	Serializer string          `json:"serializer"`
+}
+
+// writeRecord is the value of a pending write key
+type writeRecord struct {
+	Value      json.RawMessage `json:"value,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	Goto       []string        `json:"goto,omitempty"`
+	Serializer string          `json:"serializer"`
+}
+
+// buckets are the buckets of the store in a transaction
+type buckets struct {
+	threads *bbolt.Bucket
+	ids     *bbolt.Bucket
+	writes  *bbolt.Bucket
+}
+
+func (s *BoltCheckpointStore) buckets(tx *bbolt.Tx) buckets {
+	root := tx.Bucket(s.bucket)
+	return buckets{
+		threads: root.Bucket(threadsBucket),
+		ids:     root.Bucket(idsBucket),
+		writes:  root.Bucket(writesBucket),
+	}
+}
+
+// threadName is the bucket name of a thread. Bucket names cannot be empty,
+// so they start with a fixed byte.
+func threadName(threadID string) []byte {
+	return append([]byte{'t'}, threadID...)
+}
+
+// checkpointKey sorts checkpoints by version, then timestamp. Signed values
+// are stored big endian with the sign bit flipped, so that bytes compare
+// like numbers.
+func checkpointKey(checkpoint *graph.Checkpoint) []byte {
+	key := make([]byte, orderLen, orderLen+len(checkpoint.ID))
+	binary.BigEndian.PutUint64(key[0:8], uint64(int64(checkpoint.Version))^(1<<63))
+	binary.BigEndian.PutUint64(key[8:16], uint64(checkpoint.Timestamp.Unix())^(1<<63))
+	binary.BigEndian.PutUint32(key[16:20], uint32(checkpoint.Timestamp.Nanosecond()))
+	return append(key, checkpoint.ID...)
+}
+
+// threadID returns the thread of a checkpoint
+func threadID(checkpoint *graph.Checkpoint) string {
+	id, _ := checkpoint.Metadata["execution_id"].(string)
+	return id
+}
+
+// locate returns the thread bucket name and key of a checkpoint, or nils
+// when it is not stored
+func (b buckets) locate(checkpointID string) (name, key []byte) {
+	value := b.ids.Get([]byte(checkpointID))
+	if len(value) < orderLen {
+		return nil, nil
+	}
+	// Values are only valid until the bucket changes
+	key = make([]byte, 0, orderLen+len(checkpointID))
+	key = append(append(key, value[:orderLen]...), checkpointID...)
+	return bytes.Clone(value[orderLen:]), key
+}
+
+// remove deletes a checkpoint, keeping its pending writes. Emptied threads
+// are deleted too.
+func (b buckets) remove(checkpointID string) error {
+	name, key := b.locate(checkpointID)
+	if name == nil {
+		return nil
+	}
+	if err := b.ids.Delete([]byte(checkpointID)); err != nil {
+		return err
+	}
+
+	thread := b.threads.Bucket(name)
+	if thread == nil {
+		return nil
+	}
+	if err := thread.Delete(key); err != nil {
+		return err
+	}
+	if k, _ := thread.Cursor().First(); k == nil {
+		return b.threads.DeleteBucket(name)
+	}
+	return nil
+}
+
+// put stores an encoded checkpoint, replacing one saved with the same ID
+func (b buckets) put(checkpoint *graph.Checkpoint, value []byte) error {
+	if err := b.remove(checkpoint.ID); err != nil {
+		return err
+	}
+
+	name := threadName(threadID(checkpoint))
+	thread, err := b.threads.CreateBucketIfNotExists(name)
+	if err != nil {
+		return err
+	}
+	key := checkpointKey(checkpoint)
+	if err := thread.Put(key, value); err != nil {
+		return err
+	}
+
+	location := make([]byte, 0, orderLen+len(name))
+	location = append(append(location, key[:orderLen]...), name...)
+	return b.ids.Put([]byte(checkpoint.ID), location)
+}
+
+// latestID returns the ID of the newest checkpoint of a thread, or "" if it has none
+func (b buckets) latestID(threadID string) string {
+	thread := b.threads.Bucket(threadName(threadID))
+	if thread == nil {
+		return ""
+	}
+	key, _ := thread.Cursor().Last()
+	if len(key) < orderLen {
+		return ""
+	}
+	return string(key[orderLen:])
+}
+
+// Save stores a checkpoint
+func (s *BoltCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
+	value, err := s.encodeCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return s.buckets(tx).put(checkpoint, value)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// SaveIfLatest stores a checkpoint only if the latest checkpoint of its thread
+// is still expectedID. bbolt runs one write transaction at a time, so the
+// check and the save cannot interleave with another writer.
+func (s *BoltCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	value, err := s.encodeCheckpoint(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		threadID := threadID(checkpoint)
+		if actualID := b.latestID(threadID); actualID != expectedID {
+			return &graph.ConcurrentUpdateError{ThreadID: threadID, ExpectedID: expectedID, ActualID: actualID}
+		}
+		return b.put(checkpoint, value)
+	})
+
+	var conflict *graph.ConcurrentUpdateError
+	if errors.As(err, &conflict) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	return nil
+}
+
+// encodeCheckpoint encodes a checkpoint as a record
+func (s *BoltCheckpointStore) encodeCheckpoint(checkpoint *graph.Checkpoint) ([]byte, error) {
+	if checkpoint.ID == "" {
+		return nil, fmt.Errorf("checkpoint ID is required")
+	}
+
+	state, data, err := s.encodeValue(checkpoint.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	stored := *checkpoint
+	stored.State = nil
+	value, err := json.Marshal(record{
+		Checkpoint: &stored,
+		State:      state,
+		Data:       data,
+		Serializer: s.serializer.ID(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+	return value, nil
+}
+
+// decodeCheckpoint reverses encodeCheckpoint
+func (s *BoltCheckpointStore) decodeCheckpoint(value []byte) (*graph.Checkpoint, error) {
+	rec := record{Checkpoint: &graph.Checkpoint{}}
+	if err := json.Unmarshal(value, &rec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+
+	state, err := s.decodeValue(rec.Serializer, rec.State, rec.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	rec.Checkpoint.State = state
+	return rec.Checkpoint, nil
+}
+
+// encodeValue serializes a value, returned as JSON when it is valid JSON
+func (s *BoltCheckpointStore) encodeValue(value interface{}) (json.RawMessage, []byte, error) {
+	data, err := s.serializer.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+	if json.Valid(data) {
+		return data, nil, nil
+	}
+	return nil, data, nil
+}
+
+// decodeValue reverses encodeValue
+func (s *BoltCheckpointStore) decodeValue(serializer string, jsonData json.RawMessage, binaryData []byte) (interface{}, error) {
+	data := []byte(jsonData)
+	if len(binaryData) > 0 {
+		data = binaryData
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return graph.Deserialize(s.serializer, serializer, data)
+}
+
+// Load retrieves a checkpoint by ID
+func (s *BoltCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	var checkpoint *graph.Checkpoint
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		name, key := b.locate(checkpointID)
+		if name == nil {
+			return nil
+		}
+		thread := b.threads.Bucket(name)
+		if thread == nil {
+			return nil
+		}
+		value := thread.Get(key)
+		if value == nil {
+			return nil
+		}
+
+		var err error
+		checkpoint, err = s.decodeCheckpoint(value)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+	if checkpoint == nil {
+		return nil, fmt.Errorf("checkpoint not found: %s", checkpointID)
+	}
+	return checkpoint, nil
+}
+
+// List returns all checkpoints for a given execution, oldest first
+func (s *BoltCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
+	checkpoints := make([]*graph.Checkpoint, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		thread := s.buckets(tx).threads.Bucket(threadName(executionID))
+		if thread == nil {
+			return nil
+		}
+		return thread.ForEach(func(key, value []byte) error {
+			checkpoint, err := s.decodeCheckpoint(value)
+			if err != nil {
+				return err
+			}
+			checkpoints = append(checkpoints, checkpoint)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// ListThreads returns the IDs of the threads that have checkpoints
+func (s *BoltCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	threads := make([]string, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		return s.buckets(tx).threads.ForEach(func(name, _ []byte) error {
+			threads = append(threads, string(name[1:]))
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
+	}
+	return threads, nil
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *BoltCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
+	checkpoints, err := s.ListWithOptions(ctx, threadID, graph.CheckpointListOptions{Limit: 1})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get latest checkpoint: %w", err)
+	}
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	return checkpoints[0], nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first
+func (s *BoltCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	checkpoints := make([]*graph.Checkpoint, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		name := threadName(threadID)
+		thread := b.threads.Bucket(name)
+		if thread == nil {
+			return nil
+		}
+
+		cursor := thread.Cursor()
+		key, value := cursor.Last()
+		if opts.Before != "" {
+			beforeName, beforeKey := b.locate(opts.Before)
+			if !bytes.Equal(beforeName, name) {
+				return nil
+			}
+			cursor.Seek(beforeKey)
+			key, value = cursor.Prev()
+		}
+
+		for ; key != nil; key, value = cursor.Prev() {
+			checkpoint, err := s.decodeCheckpoint(value)
+			if err != nil {
+				return err
+			}
+			if !graph.MetadataMatches(checkpoint.Metadata, opts.Metadata) {
+				continue
+			}
+			checkpoints = append(checkpoints, checkpoint)
+			if opts.Limit > 0 && len(checkpoints) >= opts.Limit {
+				break
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+	return checkpoints, nil
+}
+
+// PutWrites stores pending writes against a checkpoint
+func (s *BoltCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	values := make(map[string][]byte, len(writes))
+	for _, write := range writes {
+		value, data, err := s.encodeValue(write.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
+		encoded, err := json.Marshal(writeRecord{
+			Value:      value,
+			Data:       data,
+			Goto:       write.Goto,
+			Serializer: s.serializer.ID(),
+		})
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
+		values[write.Node] = encoded
+	}
+
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		bucket, err := s.buckets(tx).writes.CreateBucketIfNotExists([]byte(checkpointID))
+		if err != nil {
+			return err
+		}
+		for node, value := range values {
+			if err := bucket.Put([]byte(node), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save pending writes: %w", err)
+	}
+	return nil
+}
+
+// GetWrites returns the pending writes stored against a checkpoint
+func (s *BoltCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	writes := make([]graph.PendingWrite, 0)
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		bucket := s.buckets(tx).writes.Bucket([]byte(checkpointID))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(node, encoded []byte) error {
+			var rec writeRecord
+			if err := json.Unmarshal(encoded, &rec); err != nil {
+				return err
+			}
+			value, err := s.decodeValue(rec.Serializer, rec.Value, rec.Data)
+			if err != nil {
+				return err
+			}
+			writes = append(writes, graph.PendingWrite{Node: string(node), Value: value, Goto: rec.Goto})
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending writes: %w", err)
+	}
+	return writes, nil
+}
+
+// Delete removes a checkpoint
+func (s *BoltCheckpointStore) Delete(ctx context.Context, checkpointID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		if err := b.remove(checkpointID); err != nil {
+			return err
+		}
+		if b.writes.Bucket([]byte(checkpointID)) != nil {
+			return b.writes.DeleteBucket([]byte(checkpointID))
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete checkpoint: %w", err)
+	}
+	return nil
+}
+
+// Clear removes all checkpoints for an execution
+func (s *BoltCheckpointStore) Clear(ctx context.Context, executionID string) error {
+	err := s.db.Update(func(tx *bbolt.Tx) error {
+		b := s.buckets(tx)
+		name := threadName(executionID)
+		thread := b.threads.Bucket(name)
+		if thread == nil {
+			return nil
+		}
+
+		err := thread.ForEach(func(key, _ []byte) error {
+			checkpointID := key[orderLen:]
+			if err := b.ids.Delete(checkpointID); err != nil {
+				return err
+			}
+			if b.writes.Bucket(checkpointID) != nil {
+				return b.writes.DeleteBucket(checkpointID)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		return b.threads.DeleteBucket(name)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clear checkpoints: %w", err)
+	}
+	return nil
+}
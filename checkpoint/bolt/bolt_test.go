@@ -0,0 +1,387 @@
+package bolt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func newTestStore(t *testing.T) *BoltCheckpointStore {
+	store, err := NewBoltCheckpointStore(BoltOptions{Path: filepath.Join(t.TempDir(), "checkpoints.db")})
+	assert.NoError(t, err)
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+func TestBoltCheckpointStore(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+	execID := "exec-123"
+
+	// Create checkpoint
+	cp := &graph.Checkpoint{
+		ID:        "cp-1",
+		NodeName:  "node-a",
+		State:     map[string]interface{}{"foo": "bar"},
+		Timestamp: time.Now(),
+		Version:   1,
+		Next:      []string{"b", "c"},
+		Metadata: map[string]interface{}{
+			"execution_id": execID,
+		},
+	}
+
+	// Test Save
+	err := store.Save(ctx, cp)
+	assert.NoError(t, err)
+
+	// Test Load
+	loaded, err := store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, cp.ID, loaded.ID)
+	assert.Equal(t, cp.NodeName, loaded.NodeName)
+	assert.Equal(t, cp.Next, loaded.Next)
+	assert.True(t, cp.Timestamp.Equal(loaded.Timestamp))
+
+	state, ok := loaded.State.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "bar", state["foo"])
+
+	// Test List
+	list, err := store.List(ctx, execID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, cp.ID, list[0].ID)
+
+	// Saving again with another version moves the checkpoint
+	cp.Version = 3
+	assert.NoError(t, store.Save(ctx, cp))
+	list, err = store.List(ctx, execID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 1)
+	assert.Equal(t, 3, list[0].Version)
+
+	// Test Delete
+	err = store.Delete(ctx, "cp-1")
+	assert.NoError(t, err)
+
+	_, err = store.Load(ctx, "cp-1")
+	assert.Error(t, err)
+
+	list, err = store.List(ctx, execID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	// Test Clear
+	cp2 := &graph.Checkpoint{ID: "cp-2", Metadata: map[string]interface{}{"execution_id": execID}}
+	cp3 := &graph.Checkpoint{ID: "cp-3", Metadata: map[string]interface{}{"execution_id": execID}}
+	store.Save(ctx, cp2)
+	store.Save(ctx, cp3)
+
+	list, err = store.List(ctx, execID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+
+	err = store.Clear(ctx, execID)
+	assert.NoError(t, err)
+
+	list, err = store.List(ctx, execID)
+	assert.NoError(t, err)
+	assert.Len(t, list, 0)
+
+	_, err = store.Load(ctx, "cp-2")
+	assert.Error(t, err)
+}
+
+func TestBoltCheckpointStore_History(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+
+	now := time.Now()
+	parentID := ""
+	for i := 1; i <= 5; i++ {
+		source := "loop"
+		if i == 1 {
+			source = "input"
+		}
+		id := fmt.Sprintf("cp-%d", i)
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			NodeName:  "node",
+			State:     map[string]interface{}{"step": i},
+			ParentID:  parentID,
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Version:   i,
+			Metadata: map[string]interface{}{
+				"execution_id": "thread-1",
+				"source":       source,
+				"step":         i,
+			},
+		})
+		assert.NoError(t, err)
+		parentID = id
+	}
+
+	latest, err = store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-5", latest.ID)
+	assert.Equal(t, "cp-4", latest.ParentID)
+
+	// Newest first, paginated with a cursor
+	page, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-5", "cp-4"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2, Before: "cp-4"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-3", "cp-2"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Before: "missing"})
+	assert.NoError(t, err)
+	assert.Empty(t, page)
+
+	// Metadata filters
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "loop"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-5", "cp-4", "cp-3", "cp-2"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "loop", "step": 3},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-3"}, checkpointIDs(page))
+
+	// List stays oldest first
+	list, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3", "cp-4", "cp-5"}, checkpointIDs(list))
+}
+
+func checkpointIDs(checkpoints []*graph.Checkpoint) []string {
+	ids := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		ids = append(ids, checkpoint.ID)
+	}
+	return ids
+}
+
+func TestBoltCheckpointStore_PendingWrites(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+	err := store.Save(ctx, &graph.Checkpoint{
+		ID:        "cp-1",
+		Timestamp: time.Now(),
+		Version:   1,
+		Next:      []string{"a", "b"},
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{
+		{Node: "b", Value: map[string]interface{}{"count": 1}, Goto: []string{"c"}},
+		{Node: "a", Value: "first"},
+	})
+	assert.NoError(t, err)
+
+	// A node's write replaces its earlier one
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "a", Value: "second"}})
+	assert.NoError(t, err)
+
+	writes, err := store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "a", Value: "second"},
+		{Node: "b", Value: map[string]interface{}{"count": 1}, Goto: []string{"c"}},
+	}, writes)
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	writes, err = store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+}
+
+func TestBoltCheckpointStore_Serializer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+	ctx := context.Background()
+
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{
+			{
+				Role: llms.ChatMessageTypeAI,
+				Parts: []llms.ContentPart{llms.ToolCall{
+					ID:           "call-1",
+					Type:         "function",
+					FunctionCall: &llms.FunctionCall{Name: "search", Arguments: "{}"},
+				}},
+			},
+		},
+		"count": 2,
+	}
+
+	for _, serializer := range []graph.Serializer{graph.DefaultSerializer(), graph.GobSerializer{}} {
+		store, err := NewBoltCheckpointStore(BoltOptions{Path: path, Serializer: serializer})
+		assert.NoError(t, err)
+
+		id := "cp-" + serializer.ID()
+		err = store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			State:     state,
+			Timestamp: time.Now(),
+			Version:   1,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		})
+		assert.NoError(t, err)
+
+		loaded, err := store.Load(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, state, loaded.State)
+		store.Close()
+	}
+
+	// Records are decoded by the serializer that wrote them
+	store, err := NewBoltCheckpointStore(BoltOptions{Path: path, Serializer: graph.JSONSerializer{}})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	for _, checkpoint := range checkpoints {
+		assert.Equal(t, state, checkpoint.State)
+	}
+}
+
+func TestBoltCheckpointStore_ListThreads(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+	for i, threadID := range []string{"thread-2", "thread-1", "thread-2", ""} {
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("cp-%d", i),
+			State:     map[string]interface{}{},
+			Timestamp: time.Now(),
+			Version:   i + 1,
+			Metadata:  map[string]interface{}{"execution_id": threadID},
+		})
+		assert.NoError(t, err)
+	}
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "thread-1", "thread-2"}, threads)
+
+	// Threads go away with their last checkpoint
+	assert.NoError(t, store.Delete(ctx, "cp-1"))
+	assert.NoError(t, store.Clear(ctx, ""))
+	threads, err = store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-2"}, threads)
+}
+
+func TestBoltCheckpointStore_SaveIfLatest(t *testing.T) {
+	store := newTestStore(t)
+
+	ctx := context.Background()
+	newCheckpoint := func(id string, version int) *graph.Checkpoint {
+		return &graph.Checkpoint{
+			ID:        id,
+			State:     map[string]interface{}{"id": id},
+			Timestamp: time.Now(),
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		}
+	}
+
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-1", 1), ""))
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-2", 2), "cp-1"))
+
+	err := store.SaveIfLatest(ctx, newCheckpoint("cp-3", 3), "cp-1")
+	var conflict *graph.ConcurrentUpdateError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, graph.ConcurrentUpdateError{ThreadID: "thread-1", ExpectedID: "cp-1", ActualID: "cp-2"}, *conflict)
+
+	_, err = store.Load(ctx, "cp-3")
+	assert.Error(t, err)
+
+	// Of the workers continuing from the same checkpoint, only one wins
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved, conflicts := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.SaveIfLatest(ctx, newCheckpoint(fmt.Sprintf("worker-%d", i), 3), "cp-2")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.As(err, &conflict) {
+				conflicts++
+				return
+			}
+			assert.NoError(t, err)
+			saved++
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, saved)
+	assert.Equal(t, 7, conflicts)
+}
+
+func TestBoltCheckpointStore_Graph(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+	run := func(input interface{}, config *graph.Config) (interface{}, error) {
+		store, err := NewBoltCheckpointStore(BoltOptions{Path: path})
+		assert.NoError(t, err)
+		defer store.Close()
+
+		g := graph.NewStateGraph()
+		schema := graph.NewMapSchema()
+		schema.RegisterReducer("steps", graph.AppendReducer)
+		g.SetSchema(schema)
+		g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{"a"}}, nil
+		})
+		g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{"b"}}, nil
+		})
+		g.AddEdge("a", "b")
+		g.AddEdge("b", graph.END)
+		g.SetEntryPoint("a")
+
+		runnable, err := g.Compile(graph.WithCheckpointer(store))
+		assert.NoError(t, err)
+		return runnable.InvokeWithConfig(context.Background(), input, config)
+	}
+
+	_, err := run(map[string]interface{}{}, &graph.Config{
+		InterruptBefore: []string{"b"},
+		Configurable:    map[string]interface{}{"thread_id": "thread-1"},
+	})
+	var interrupt *graph.GraphInterrupt
+	assert.ErrorAs(t, err, &interrupt)
+
+	// The thread is picked up after the database was reopened
+	result, err := run(nil, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, result.(map[string]interface{})["steps"])
+}
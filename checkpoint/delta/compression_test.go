@@ -0,0 +1,31 @@
+package delta
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompression_RoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("hello checkpoint "), 100)
+
+	for _, c := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		compressed, err := compress(c, data)
+		assert.NoError(t, err)
+		if c != CompressionNone {
+			assert.Less(t, len(compressed), len(data))
+		}
+
+		decompressed, err := decompress(c, compressed)
+		assert.NoError(t, err)
+		assert.Equal(t, data, decompressed)
+	}
+
+	_, err := compress("lz4", data)
+	assert.Error(t, err)
+	_, err = decompress(CompressionGzip, []byte("not gzip"))
+	assert.Error(t, err)
+	_, err = decompress(CompressionZstd, []byte("not zstd"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,553 @@
+package delta
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+const (
+	formatSnapshot = "snapshot"
+	formatDelta    = "delta"
+)
+
+// defaultSnapshotInterval is the default number of checkpoints between full snapshots
+const defaultSnapshotInterval = 10
+
+// cacheSize bounds the number of threads whose latest state is cached
+const cacheSize = 1024
+
+var (
+	_ graph.CheckpointStoreV2          = &DeltaCheckpointStore{}
+	_ graph.PendingWritesStore         = &DeltaCheckpointStore{}
+	_ graph.ThreadLister               = &DeltaCheckpointStore{}
+	_ graph.ConditionalCheckpointStore = &DeltaCheckpointStore{}
+)
+
+// DeltaCheckpointStore wraps a graph.CheckpointStore and saves the state of
+// a checkpoint as the channels that changed since its parent. Slice channels
+// that grew, such as messages, only store the appended elements. Every
+// SnapshotInterval checkpoints the full state is saved, which bounds the
+// number of loads needed to rebuild a state. Payloads can be compressed.
+//
+// States that are not map[string]interface{} are always saved in full.
+// To combine with encryption, wrap the DeltaCheckpointStore in the
+// encrypted store, since ciphertexts do not compress.
+type DeltaCheckpointStore struct {
+	store            graph.CheckpointStore
+	serializer       graph.Serializer
+	compression      Compression
+	snapshotInterval int
+
+	mu sync.Mutex
+	// latest caches the last state saved on each thread, so consecutive
+	// saves do not need to rebuild their parent from the store
+	latest map[string]*cachedState
+}
+
+// cachedState is a rebuilt state
+type cachedState struct {
+	id    string
+	state map[string]interface{}
+	depth int
+}
+
+// DeltaOptions configuration for the delta store
+type DeltaOptions struct {
+	// Store is the wrapped store
+	Store graph.CheckpointStore
+
+	// SnapshotInterval is the number of checkpoints between full snapshots, default 10.
+	// 1 saves every state in full, which is useful to only compress them.
+	SnapshotInterval int
+
+	// Compression compresses payloads, default CompressionNone
+	Compression Compression
+
+	// Serializer encodes payloads, default graph.DefaultSerializer()
+	Serializer graph.Serializer
+}
+
+// NewDeltaCheckpointStore creates a new delta checkpoint store
+func NewDeltaCheckpointStore(opts DeltaOptions) (*DeltaCheckpointStore, error) {
+	if opts.Store == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+	if _, err := compress(opts.Compression, nil); err != nil {
+		return nil, err
+	}
+
+	interval := opts.SnapshotInterval
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
+	return &DeltaCheckpointStore{
+		store:            opts.Store,
+		serializer:       serializer,
+		compression:      opts.Compression,
+		snapshotInterval: interval,
+		latest:           make(map[string]*cachedState),
+	}, nil
+}
+
+// Save stores a checkpoint as a delta against its parent, or as a full snapshot
+func (s *DeltaCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
+	return s.save(ctx, checkpoint, s.store.Save)
+}
+
+// SaveIfLatest stores a checkpoint like Save if the latest checkpoint of its
+// thread is still expectedID. Without a graph.ConditionalCheckpointStore to
+// wrap, the checkpoint is saved unconditionally.
+func (s *DeltaCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	conditional, ok := s.store.(graph.ConditionalCheckpointStore)
+	if !ok {
+		return s.Save(ctx, checkpoint)
+	}
+	return s.save(ctx, checkpoint, func(ctx context.Context, stored *graph.Checkpoint) error {
+		return conditional.SaveIfLatest(ctx, stored, expectedID)
+	})
+}
+
+// save encodes a checkpoint and stores it with write
+func (s *DeltaCheckpointStore) save(ctx context.Context, checkpoint *graph.Checkpoint, write func(context.Context, *graph.Checkpoint) error) error {
+	state, isMap := checkpoint.State.(map[string]interface{})
+
+	var parent *cachedState
+	if isMap && checkpoint.ParentID != "" {
+		parent = s.parentState(ctx, checkpoint)
+	}
+
+	env := envelope{format: formatSnapshot}
+	var payload interface{} = checkpoint.State
+	if parent != nil && parent.depth+1 < s.snapshotInterval {
+		env = envelope{format: formatDelta, base: parent.id, depth: parent.depth + 1}
+		payload = diff(parent.state, state).toMap()
+	}
+
+	data, err := s.encode(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	env.serializer = s.serializer.ID()
+	env.compression = s.compression
+	env.payload = data
+
+	stored := *checkpoint
+	stored.State = env.toMap()
+	if err := write(ctx, &stored); err != nil {
+		return err
+	}
+
+	if !isMap {
+		return nil
+	}
+
+	// Cache what a later Load would rebuild, rather than the caller's state,
+	// which may still be modified
+	rebuilt, err := s.rebuild(env, parent)
+	if err != nil {
+		return fmt.Errorf("failed to decode checkpoint: %w", err)
+	}
+	if state, ok := rebuilt.(map[string]interface{}); ok {
+		s.cache(threadID(checkpoint), &cachedState{id: checkpoint.ID, state: state, depth: env.depth})
+	}
+	return nil
+}
+
+// Load retrieves a checkpoint by ID and rebuilds its state
+func (s *DeltaCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	stored, err := s.store.Load(ctx, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolve(ctx, stored, nil)
+}
+
+// List returns all checkpoints for a given execution
+func (s *DeltaCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
+	stored, err := s.store.List(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.resolveAll(ctx, stored)
+}
+
+// Delete removes a checkpoint. Checkpoints saved as deltas against it are
+// saved again as full snapshots first, so they can still be loaded.
+func (s *DeltaCheckpointStore) Delete(ctx context.Context, checkpointID string) error {
+	stored, err := s.store.Load(ctx, checkpointID)
+	if err != nil {
+		return s.store.Delete(ctx, checkpointID)
+	}
+
+	thread := threadID(stored)
+	siblings, err := s.store.List(ctx, thread)
+	if err != nil {
+		return err
+	}
+	known := indexByID(siblings)
+	for _, sibling := range siblings {
+		env, ok := parseEnvelope(sibling.State)
+		if !ok || env.format != formatDelta || env.base != checkpointID {
+			continue
+		}
+
+		child, err := s.resolve(ctx, sibling, known)
+		if err != nil {
+			return fmt.Errorf("failed to rebuild checkpoint %s: %w", sibling.ID, err)
+		}
+		if err := s.saveSnapshot(ctx, child); err != nil {
+			return err
+		}
+	}
+
+	s.forget(thread, checkpointID)
+	return s.store.Delete(ctx, checkpointID)
+}
+
+// Clear removes all checkpoints for an execution
+func (s *DeltaCheckpointStore) Clear(ctx context.Context, executionID string) error {
+	s.mu.Lock()
+	delete(s.latest, executionID)
+	s.mu.Unlock()
+
+	return s.store.Clear(ctx, executionID)
+}
+
+// ListThreads returns the threads of the wrapped store, which must be a graph.ThreadLister
+func (s *DeltaCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	lister, ok := s.store.(graph.ThreadLister)
+	if !ok {
+		return nil, fmt.Errorf("store %T does not list threads", s.store)
+	}
+	return lister.ListThreads(ctx)
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *DeltaCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
+	if v2, ok := s.store.(graph.CheckpointStoreV2); ok {
+		stored, err := v2.GetLatest(ctx, threadID)
+		if err != nil || stored == nil {
+			return nil, err
+		}
+		return s.resolve(ctx, stored, nil)
+	}
+
+	checkpoints, err := s.ListWithOptions(ctx, threadID, graph.CheckpointListOptions{Limit: 1})
+	if err != nil || len(checkpoints) == 0 {
+		return nil, err
+	}
+	return checkpoints[0], nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first
+func (s *DeltaCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	var stored []*graph.Checkpoint
+	if v2, ok := s.store.(graph.CheckpointStoreV2); ok {
+		checkpoints, err := v2.ListWithOptions(ctx, threadID, opts)
+		if err != nil {
+			return nil, err
+		}
+		stored = checkpoints
+	} else {
+		checkpoints, err := s.store.List(ctx, threadID)
+		if err != nil {
+			return nil, err
+		}
+		stored = graph.FilterCheckpoints(checkpoints, opts)
+	}
+	return s.resolveAll(ctx, stored)
+}
+
+// PutWrites stores pending writes in the wrapped store. Writes are dropped when
+// it does not support them, so interrupted supersteps re-run in full.
+func (s *DeltaCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	store, ok := s.store.(graph.PendingWritesStore)
+	if !ok {
+		return nil
+	}
+	return store.PutWrites(ctx, checkpointID, writes)
+}
+
+// GetWrites returns the pending writes stored against a checkpoint
+func (s *DeltaCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	store, ok := s.store.(graph.PendingWritesStore)
+	if !ok {
+		return nil, nil
+	}
+	return store.GetWrites(ctx, checkpointID)
+}
+
+// parentState returns the rebuilt state of the parent of checkpoint, or nil
+// when it cannot be used as a delta base
+func (s *DeltaCheckpointStore) parentState(ctx context.Context, checkpoint *graph.Checkpoint) *cachedState {
+	s.mu.Lock()
+	cached := s.latest[threadID(checkpoint)]
+	s.mu.Unlock()
+	if cached != nil && cached.id == checkpoint.ParentID {
+		return cached
+	}
+
+	stored, err := s.store.Load(ctx, checkpoint.ParentID)
+	if err != nil {
+		return nil
+	}
+	env, ok := parseEnvelope(stored.State)
+	if !ok {
+		return nil
+	}
+	parent, err := s.resolve(ctx, stored, nil)
+	if err != nil {
+		return nil
+	}
+	state, ok := parent.State.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return &cachedState{id: parent.ID, state: state, depth: env.depth}
+}
+
+// resolve returns checkpoint with its state rebuilt. Bases are looked up in
+// known before they are loaded from the store.
+// Checkpoints saved without the delta store are returned as is.
+func (s *DeltaCheckpointStore) resolve(ctx context.Context, stored *graph.Checkpoint, known map[string]*graph.Checkpoint) (*graph.Checkpoint, error) {
+	env, ok := parseEnvelope(stored.State)
+	if !ok {
+		return stored, nil
+	}
+
+	// Collect the deltas down to the nearest snapshot or cached state
+	chain := []envelope{env}
+	visited := map[string]bool{stored.ID: true}
+	var base *cachedState
+	for env.format == formatDelta {
+		if visited[env.base] {
+			return nil, fmt.Errorf("delta chain of checkpoint %s has a cycle", stored.ID)
+		}
+		visited[env.base] = true
+
+		if cached := s.cached(threadID(stored), env.base); cached != nil {
+			base = cached
+			break
+		}
+
+		parent, ok := known[env.base]
+		if !ok {
+			loaded, err := s.store.Load(ctx, env.base)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load delta base %s: %w", env.base, err)
+			}
+			parent = loaded
+		}
+		env, ok = parseEnvelope(parent.State)
+		if !ok {
+			// The base was saved before the delta store was used
+			state, isMap := parent.State.(map[string]interface{})
+			if !isMap {
+				return nil, fmt.Errorf("delta base %s has no map state", parent.ID)
+			}
+			base = &cachedState{id: parent.ID, state: state}
+			break
+		}
+		chain = append(chain, env)
+	}
+
+	var state interface{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		rebuilt, err := s.rebuild(chain[i], base)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode checkpoint %s: %w", stored.ID, err)
+		}
+		state = rebuilt
+		if m, ok := rebuilt.(map[string]interface{}); ok {
+			base = &cachedState{state: m}
+		} else {
+			base = nil
+		}
+	}
+
+	checkpoint := *stored
+	checkpoint.State = state
+	return &checkpoint, nil
+}
+
+// resolveAll rebuilds the states of a list of checkpoints, using the listed
+// checkpoints as delta bases where possible
+func (s *DeltaCheckpointStore) resolveAll(ctx context.Context, stored []*graph.Checkpoint) ([]*graph.Checkpoint, error) {
+	known := indexByID(stored)
+	checkpoints := make([]*graph.Checkpoint, 0, len(stored))
+	for _, checkpoint := range stored {
+		resolved, err := s.resolve(ctx, checkpoint, known)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, resolved)
+	}
+	return checkpoints, nil
+}
+
+// rebuild decodes the payload of env and applies it to base
+func (s *DeltaCheckpointStore) rebuild(env envelope, base *cachedState) (interface{}, error) {
+	plaintext, err := decompress(env.compression, env.payload)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := graph.Deserialize(s.serializer, env.serializer, plaintext)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.format == formatSnapshot {
+		return payload, nil
+	}
+	if base == nil {
+		return nil, fmt.Errorf("missing delta base %s", env.base)
+	}
+	m, ok := payload.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected delta payload %T", payload)
+	}
+	return apply(base.state, deltaFromMap(m)), nil
+}
+
+// saveSnapshot saves the full state of an already rebuilt checkpoint
+func (s *DeltaCheckpointStore) saveSnapshot(ctx context.Context, checkpoint *graph.Checkpoint) error {
+	data, err := s.encode(checkpoint.State)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %w", err)
+	}
+	env := envelope{
+		format:      formatSnapshot,
+		serializer:  s.serializer.ID(),
+		compression: s.compression,
+		payload:     data,
+	}
+
+	stored := *checkpoint
+	stored.State = env.toMap()
+	// Cached states keep their depth, which only makes the next snapshot come earlier
+	return s.store.Save(ctx, &stored)
+}
+
+// encode serializes and compresses a payload
+func (s *DeltaCheckpointStore) encode(payload interface{}) ([]byte, error) {
+	data, err := s.serializer.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	return compress(s.compression, data)
+}
+
+// cached returns the cached state of checkpointID on a thread, if any
+func (s *DeltaCheckpointStore) cached(thread, checkpointID string) *cachedState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached := s.latest[thread]; cached != nil && cached.id == checkpointID {
+		return cached
+	}
+	return nil
+}
+
+// cache records the latest state saved on a thread
+func (s *DeltaCheckpointStore) cache(thread string, state *cachedState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.latest[thread]; !ok && len(s.latest) >= cacheSize {
+		for evicted := range s.latest {
+			delete(s.latest, evicted)
+			break
+		}
+	}
+	s.latest[thread] = state
+}
+
+// forget drops the cached state of a deleted checkpoint
+func (s *DeltaCheckpointStore) forget(thread, checkpointID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if cached := s.latest[thread]; cached != nil && cached.id == checkpointID {
+		delete(s.latest, thread)
+	}
+}
+
+// envelope describes how the state of a checkpoint was stored
+type envelope struct {
+	format      string
+	base        string
+	depth       int
+	serializer  string
+	compression Compression
+	payload     []byte
+}
+
+// toMap converts an envelope into a state any serializer can encode
+func (e envelope) toMap() map[string]interface{} {
+	return map[string]interface{}{
+		"format":      e.format,
+		"base":        e.base,
+		"depth":       e.depth,
+		"serializer":  e.serializer,
+		"compression": string(e.compression),
+		"payload":     base64.StdEncoding.EncodeToString(e.payload),
+	}
+}
+
+// parseEnvelope reverses toMap, reporting whether state is an envelope
+func parseEnvelope(state interface{}) (envelope, bool) {
+	m, ok := state.(map[string]interface{})
+	if !ok {
+		return envelope{}, false
+	}
+	format, _ := m["format"].(string)
+	encoded, ok := m["payload"].(string)
+	if !ok || (format != formatSnapshot && format != formatDelta) {
+		return envelope{}, false
+	}
+	payload, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return envelope{}, false
+	}
+
+	env := envelope{format: format, payload: payload}
+	env.base, _ = m["base"].(string)
+	env.serializer, _ = m["serializer"].(string)
+	compression, _ := m["compression"].(string)
+	env.compression = Compression(compression)
+	switch depth := m["depth"].(type) {
+	case int:
+		env.depth = depth
+	case float64:
+		env.depth = int(depth)
+	}
+	return env, true
+}
+
+// threadID returns the thread a checkpoint belongs to
+func threadID(checkpoint *graph.Checkpoint) string {
+	if threadID, ok := checkpoint.Metadata["thread_id"].(string); ok && threadID != "" {
+		return threadID
+	}
+	executionID, _ := checkpoint.Metadata["execution_id"].(string)
+	return executionID
+}
+
+// indexByID maps checkpoints by ID
+func indexByID(checkpoints []*graph.Checkpoint) map[string]*graph.Checkpoint {
+	index := make(map[string]*graph.Checkpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		index[checkpoint.ID] = checkpoint
+	}
+	return index
+}
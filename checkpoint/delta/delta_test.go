@@ -0,0 +1,306 @@
+package delta
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/checkpoint/sqlite"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// saveConversation saves one checkpoint per turn, each with one more message
+func saveConversation(t *testing.T, store graph.CheckpointStore, turns int) []map[string]interface{} {
+	ctx := context.Background()
+	var states []map[string]interface{}
+	var messages []llms.MessageContent
+	for i := 0; i < turns; i++ {
+		messages = append(messages, llms.TextParts(llms.ChatMessageTypeHuman, fmt.Sprintf("turn %d", i)))
+		state := map[string]interface{}{
+			"messages": messages,
+			"turn":     i,
+		}
+		if i%2 == 0 {
+			state["even"] = true
+		}
+		states = append(states, state)
+
+		parentID := ""
+		if i > 0 {
+			parentID = fmt.Sprintf("cp-%d", i-1)
+		}
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("cp-%d", i),
+			State:     state,
+			ParentID:  parentID,
+			Timestamp: time.Now(),
+			Version:   i + 1,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1", "thread_id": "thread-1"},
+		})
+		assert.NoError(t, err)
+	}
+	return states
+}
+
+func TestDeltaCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	inner := graph.NewMemoryCheckpointStore()
+	store, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, SnapshotInterval: 5})
+	assert.NoError(t, err)
+
+	states := saveConversation(t, store, 12)
+
+	// Every fifth checkpoint is a full snapshot, the others only hold the new message
+	for i := range states {
+		stored, err := inner.Load(ctx, fmt.Sprintf("cp-%d", i))
+		assert.NoError(t, err)
+		env, ok := parseEnvelope(stored.State)
+		assert.True(t, ok)
+		if i%5 == 0 {
+			assert.Equal(t, formatSnapshot, env.format, "cp-%d", i)
+			continue
+		}
+		assert.Equal(t, formatDelta, env.format, "cp-%d", i)
+		assert.Equal(t, fmt.Sprintf("cp-%d", i-1), env.base)
+		assert.Equal(t, i%5, env.depth)
+
+		payload, err := graph.Deserialize(nil, env.serializer, env.payload)
+		assert.NoError(t, err)
+		d := deltaFromMap(payload.(map[string]interface{}))
+		assert.Equal(t, states[i]["messages"].([]llms.MessageContent)[i:], d.Append["messages"])
+	}
+
+	// States are rebuilt without the cache of the store that saved them
+	fresh, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, SnapshotInterval: 5})
+	assert.NoError(t, err)
+	for i, state := range states {
+		loaded, err := fresh.Load(ctx, fmt.Sprintf("cp-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, state, loaded.State, "cp-%d", i)
+	}
+
+	checkpoints, err := fresh.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, len(states))
+	for _, checkpoint := range checkpoints {
+		var i int
+		fmt.Sscanf(checkpoint.ID, "cp-%d", &i)
+		assert.Equal(t, states[i], checkpoint.State)
+	}
+
+	latest, err := fresh.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, states[11], latest.State)
+}
+
+func TestDeltaCheckpointStore_Delete(t *testing.T) {
+	ctx := context.Background()
+	inner := graph.NewMemoryCheckpointStore()
+	store, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, SnapshotInterval: 5})
+	assert.NoError(t, err)
+
+	states := saveConversation(t, store, 8)
+
+	// Deleting a base turns its child into a snapshot
+	assert.NoError(t, store.Delete(ctx, "cp-2"))
+	stored, err := inner.Load(ctx, "cp-3")
+	assert.NoError(t, err)
+	env, _ := parseEnvelope(stored.State)
+	assert.Equal(t, formatSnapshot, env.format)
+
+	_, err = store.Load(ctx, "cp-2")
+	assert.Error(t, err)
+	for _, i := range []int{0, 1, 3, 4, 5, 6, 7} {
+		loaded, err := store.Load(ctx, fmt.Sprintf("cp-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, states[i], loaded.State, "cp-%d", i)
+	}
+
+	// The latest state is no longer cached once deleted
+	assert.NoError(t, store.Delete(ctx, "cp-7"))
+	err = store.Save(ctx, &graph.Checkpoint{
+		ID:       "cp-8",
+		State:    map[string]interface{}{"turn": 8},
+		ParentID: "cp-7",
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+	loaded, err := store.Load(ctx, "cp-8")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"turn": 8}, loaded.State)
+}
+
+func TestDeltaCheckpointStore_Migrate(t *testing.T) {
+	ctx := context.Background()
+	inner := graph.NewMemoryCheckpointStore()
+	store, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, SnapshotInterval: 5})
+	assert.NoError(t, err)
+
+	states := saveConversation(t, store, 7)
+
+	// A checkpoint saved by the new version of the graph, as a delta against an old one
+	current := map[string]interface{}{"messages": states[6]["messages"], "step": 7}
+	err = store.Save(ctx, &graph.Checkpoint{
+		ID:        "cp-7",
+		State:     current,
+		ParentID:  "cp-6",
+		Timestamp: time.Now(),
+		Version:   8,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1", "thread_id": "thread-1", graph.SchemaVersionKey: 2},
+	})
+	assert.NoError(t, err)
+
+	migrations := graph.NewMigrations(2).Register(1, func(ctx context.Context, state interface{}) (interface{}, error) {
+		m := state.(map[string]interface{})
+		m["step"] = m["turn"]
+		delete(m, "turn")
+		return m, nil
+	})
+	migrated, err := graph.MigrateThread(ctx, store, "thread-1", migrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, migrated)
+
+	// Every checkpoint still rebuilds, including those stored against migrated ones
+	fresh, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, SnapshotInterval: 5})
+	assert.NoError(t, err)
+	for i := range states {
+		loaded, err := fresh.Load(ctx, fmt.Sprintf("cp-%d", i))
+		assert.NoError(t, err)
+		assert.Equal(t, i, loaded.State.(map[string]interface{})["step"], "cp-%d", i)
+		assert.NotContains(t, loaded.State, "turn")
+		assert.Equal(t, states[i]["messages"], loaded.State.(map[string]interface{})["messages"])
+	}
+	loaded, err := fresh.Load(ctx, "cp-7")
+	assert.NoError(t, err)
+	assert.Equal(t, current, loaded.State)
+}
+
+func TestDeltaCheckpointStore_Compression(t *testing.T) {
+	ctx := context.Background()
+
+	sizes := map[Compression]int{}
+	for _, compression := range []Compression{CompressionNone, CompressionGzip, CompressionZstd} {
+		inner := graph.NewMemoryCheckpointStore()
+		store, err := NewDeltaCheckpointStore(DeltaOptions{
+			Store:            inner,
+			SnapshotInterval: 1,
+			Compression:      compression,
+		})
+		assert.NoError(t, err)
+
+		states := saveConversation(t, store, 20)
+		stored, err := inner.Load(ctx, "cp-19")
+		assert.NoError(t, err)
+		env, _ := parseEnvelope(stored.State)
+		assert.Equal(t, formatSnapshot, env.format)
+		assert.Equal(t, compression, env.compression)
+		sizes[compression] = len(env.payload)
+
+		loaded, err := store.Load(ctx, "cp-19")
+		assert.NoError(t, err)
+		assert.Equal(t, states[19], loaded.State)
+	}
+	assert.Less(t, sizes[CompressionGzip], sizes[CompressionNone])
+	assert.Less(t, sizes[CompressionZstd], sizes[CompressionNone])
+
+	_, err := NewDeltaCheckpointStore(DeltaOptions{Store: graph.NewMemoryCheckpointStore(), Compression: "lz4"})
+	assert.Error(t, err)
+}
+
+func TestDeltaCheckpointStore_Sqlite(t *testing.T) {
+	ctx := context.Background()
+	inner, err := sqlite.NewSqliteCheckpointStore(sqlite.SqliteOptions{
+		Path: filepath.Join(t.TempDir(), "checkpoints.db"),
+	})
+	assert.NoError(t, err)
+	defer inner.Close()
+
+	// Checkpoints saved before the delta store was used are read as they are
+	// and serve as delta bases
+	assert.NoError(t, inner.Save(ctx, &graph.Checkpoint{
+		ID:       "legacy",
+		State:    map[string]interface{}{"steps": []string{"a"}},
+		Version:  1,
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	}))
+
+	store, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner, Compression: CompressionZstd})
+	assert.NoError(t, err)
+	state := map[string]interface{}{"steps": []string{"a", "b"}}
+	assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+		ID:       "cp-1",
+		State:    state,
+		ParentID: "legacy",
+		Version:  2,
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	}))
+
+	loaded, err := store.Load(ctx, "legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"steps": []string{"a"}}, loaded.State)
+
+	fresh, err := NewDeltaCheckpointStore(DeltaOptions{Store: inner})
+	assert.NoError(t, err)
+	loaded, err = fresh.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded.State)
+
+	// Conditional saves are checked by the wrapped store
+	stale := &graph.Checkpoint{
+		ID:       "cp-2",
+		State:    map[string]interface{}{"steps": []string{"a", "c"}},
+		ParentID: "legacy",
+		Version:  2,
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	}
+	var conflict *graph.ConcurrentUpdateError
+	assert.ErrorAs(t, store.SaveIfLatest(ctx, stale, "legacy"), &conflict)
+	_, err = store.Load(ctx, "cp-2")
+	assert.Error(t, err)
+
+	assert.NoError(t, store.SaveIfLatest(ctx, &graph.Checkpoint{
+		ID:       "cp-2",
+		State:    map[string]interface{}{"steps": []string{"a", "b", "c"}},
+		ParentID: "cp-1",
+		Version:  3,
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	}, "cp-1"))
+	loaded, err = fresh.Load(ctx, "cp-2")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"steps": []string{"a", "b", "c"}}, loaded.State)
+}
+
+func TestDeltaCheckpointStore_Graph(t *testing.T) {
+	store, err := NewDeltaCheckpointStore(DeltaOptions{Store: graph.NewMemoryCheckpointStore(), SnapshotInterval: 3})
+	assert.NoError(t, err)
+
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{name}}, nil
+		})
+	}
+	g.AddEdge("a", "b")
+	g.AddEdge("b", "c")
+	g.AddEdge("c", "d")
+	g.AddEdge("d", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, config)
+	assert.NoError(t, err)
+	result, err := runnable.InvokeWithConfig(ctx, map[string]interface{}{}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d", "a", "b", "c", "d"}, result.(map[string]interface{})["steps"])
+}
@@ -0,0 +1,138 @@
+package delta
+
+import (
+	"reflect"
+	"sort"
+)
+
+// stateDelta holds the channels of a state that changed since its parent
+type stateDelta struct {
+	// Set holds channels whose value was replaced
+	Set map[string]interface{}
+	// Append holds the elements appended to slice channels
+	Append map[string]interface{}
+	// Deleted lists the channels that were removed
+	Deleted []string
+}
+
+// diff computes the delta that turns parent into state
+func diff(parent, state map[string]interface{}) stateDelta {
+	d := stateDelta{
+		Set:    make(map[string]interface{}),
+		Append: make(map[string]interface{}),
+	}
+
+	for key, value := range state {
+		old, ok := parent[key]
+		if !ok {
+			d.Set[key] = value
+			continue
+		}
+		if reflect.DeepEqual(old, value) {
+			continue
+		}
+		if appended, ok := appendedElements(old, value); ok {
+			d.Append[key] = appended
+			continue
+		}
+		d.Set[key] = value
+	}
+
+	for key := range parent {
+		if _, ok := state[key]; !ok {
+			d.Deleted = append(d.Deleted, key)
+		}
+	}
+	sort.Strings(d.Deleted)
+	return d
+}
+
+// appendedElements returns the elements added to the end of old, if value
+// is a slice of the same type that starts with old
+func appendedElements(old, value interface{}) (interface{}, bool) {
+	oldValue := reflect.ValueOf(old)
+	newValue := reflect.ValueOf(value)
+	if oldValue.Kind() != reflect.Slice || oldValue.Type() != newValue.Type() {
+		return nil, false
+	}
+	if oldValue.Len() == 0 || newValue.Len() <= oldValue.Len() {
+		return nil, false
+	}
+	if !reflect.DeepEqual(old, newValue.Slice(0, oldValue.Len()).Interface()) {
+		return nil, false
+	}
+	return newValue.Slice(oldValue.Len(), newValue.Len()).Interface(), true
+}
+
+// apply returns a new state made of parent with d applied. Parent is not modified.
+func apply(parent map[string]interface{}, d stateDelta) map[string]interface{} {
+	state := make(map[string]interface{}, len(parent)+len(d.Set))
+	for key, value := range parent {
+		state[key] = value
+	}
+
+	for key, value := range d.Set {
+		state[key] = value
+	}
+	for key, appended := range d.Append {
+		state[key] = concat(state[key], appended)
+	}
+	for _, key := range d.Deleted {
+		delete(state, key)
+	}
+	return state
+}
+
+// concat returns a new slice holding the elements of a followed by those of b.
+// The backing array of a is never shared, since other states may refer to it.
+func concat(a, b interface{}) interface{} {
+	aValue := reflect.ValueOf(a)
+	bValue := reflect.ValueOf(b)
+	if aValue.Kind() != reflect.Slice {
+		return b
+	}
+	if bValue.Kind() != reflect.Slice {
+		return a
+	}
+
+	// Serializers that do not keep Go types may decode one side as []interface{}
+	sliceType := aValue.Type()
+	if bValue.Type() != sliceType {
+		sliceType = reflect.TypeOf([]interface{}{})
+	}
+
+	result := reflect.MakeSlice(sliceType, 0, aValue.Len()+bValue.Len())
+	for _, v := range []reflect.Value{aValue, bValue} {
+		for i := 0; i < v.Len(); i++ {
+			result = reflect.Append(result, v.Index(i))
+		}
+	}
+	return result.Interface()
+}
+
+// toMap converts a delta into a value any serializer can encode
+func (d stateDelta) toMap() map[string]interface{} {
+	deleted := make([]interface{}, len(d.Deleted))
+	for i, key := range d.Deleted {
+		deleted[i] = key
+	}
+	return map[string]interface{}{
+		"set":     d.Set,
+		"append":  d.Append,
+		"deleted": deleted,
+	}
+}
+
+// deltaFromMap reverses toMap
+func deltaFromMap(m map[string]interface{}) stateDelta {
+	d := stateDelta{}
+	d.Set, _ = m["set"].(map[string]interface{})
+	d.Append, _ = m["append"].(map[string]interface{})
+	deleted, _ := m["deleted"].([]interface{})
+	for _, key := range deleted {
+		if s, ok := key.(string); ok {
+			d.Deleted = append(d.Deleted, s)
+		}
+	}
+	return d
+}
@@ -0,0 +1,56 @@
+package delta
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffApply(t *testing.T) {
+	parent := map[string]interface{}{
+		"messages": []string{"a", "b"},
+		"count":    1,
+		"removed":  true,
+		"same":     "x",
+	}
+	state := map[string]interface{}{
+		"messages": []string{"a", "b", "c"},
+		"count":    2,
+		"same":     "x",
+		"added":    []int{1},
+	}
+
+	d := diff(parent, state)
+	assert.Equal(t, map[string]interface{}{"count": 2, "added": []int{1}}, d.Set)
+	assert.Equal(t, map[string]interface{}{"messages": []string{"c"}}, d.Append)
+	assert.Equal(t, []string{"removed"}, d.Deleted)
+
+	assert.Equal(t, state, apply(parent, d))
+	assert.Equal(t, state, apply(parent, deltaFromMap(d.toMap())))
+	assert.Contains(t, parent, "removed", "the parent is not modified")
+}
+
+func TestDiff_ReplacedSlice(t *testing.T) {
+	parent := map[string]interface{}{"messages": []string{"a", "b"}}
+	state := map[string]interface{}{"messages": []string{"x", "b", "c"}}
+
+	d := diff(parent, state)
+	assert.Empty(t, d.Append)
+	assert.Equal(t, state["messages"], d.Set["messages"])
+}
+
+func TestApply_DoesNotShareBackingArray(t *testing.T) {
+	messages := make([]string, 1, 10)
+	messages[0] = "a"
+	parent := map[string]interface{}{"messages": messages}
+
+	first := apply(parent, stateDelta{Append: map[string]interface{}{"messages": []string{"b"}}})
+	second := apply(parent, stateDelta{Append: map[string]interface{}{"messages": []string{"c"}}})
+	assert.Equal(t, []string{"a", "b"}, first["messages"])
+	assert.Equal(t, []string{"a", "c"}, second["messages"])
+}
+
+func TestConcat_MixedTypes(t *testing.T) {
+	assert.Equal(t, []interface{}{"a", "b"}, concat([]string{"a"}, []interface{}{"b"}))
+	assert.Equal(t, []string{"b"}, concat(nil, []string{"b"}))
+}
@@ -0,0 +1,401 @@
+package encrypted
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+// KeyIDMetadataKey is the metadata entry recording the key a checkpoint was encrypted with
+const KeyIDMetadataKey = "encryption_key_id"
+
+// algorithm marks encrypted values
+const algorithm = "aes-gcm"
+
+// ErrNotEncrypted is returned when a checkpoint or pending write read from the
+// wrapped store is not encrypted and plaintext is not allowed
+var ErrNotEncrypted = errors.New("value is not encrypted")
+
+var (
+	_ graph.CheckpointStoreV2          = &EncryptedCheckpointStore{}
+	_ graph.PendingWritesStore         = &EncryptedCheckpointStore{}
+	_ graph.ThreadLister               = &EncryptedCheckpointStore{}
+	_ graph.ConditionalCheckpointStore = &EncryptedCheckpointStore{}
+)
+
+// EncryptedCheckpointStore wraps a graph.CheckpointStore and encrypts the
+// state, the selected metadata entries and the pending writes of every
+// checkpoint with AES-GCM. IDs, versions, timestamps, parents and the
+// remaining metadata are stored as is, so threads stay queryable.
+type EncryptedCheckpointStore struct {
+	store          graph.CheckpointStore
+	keys           KeyProvider
+	serializer     graph.Serializer
+	metadataKeys   []string
+	allowPlaintext bool
+}
+
+// EncryptedOptions configuration for the encrypted store
+type EncryptedOptions struct {
+	// Store is the wrapped store
+	Store graph.CheckpointStore
+
+	// Keys provides the encryption keys
+	Keys KeyProvider
+
+	// MetadataKeys lists the metadata entries encrypted with the state.
+	// Encrypted entries cannot be used in CheckpointListOptions.Metadata filters.
+	MetadataKeys []string
+
+	// Serializer encodes values before encryption, default graph.DefaultSerializer()
+	Serializer graph.Serializer
+
+	// AllowPlaintext returns checkpoints and pending writes written before
+	// encryption was enabled as is, so that Rotate can encrypt them. Otherwise
+	// they are rejected with ErrNotEncrypted, since anyone able to write to the
+	// wrapped store could forge them.
+	AllowPlaintext bool
+}
+
+// NewEncryptedCheckpointStore creates a new encrypted checkpoint store
+func NewEncryptedCheckpointStore(opts EncryptedOptions) (*EncryptedCheckpointStore, error) {
+	if opts.Store == nil {
+		return nil, fmt.Errorf("store is required")
+	}
+	if opts.Keys == nil {
+		return nil, fmt.Errorf("key provider is required")
+	}
+
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
+	return &EncryptedCheckpointStore{
+		store:          opts.Store,
+		keys:           opts.Keys,
+		serializer:     serializer,
+		metadataKeys:   opts.MetadataKeys,
+		allowPlaintext: opts.AllowPlaintext,
+	}, nil
+}
+
+// Save encrypts and stores a checkpoint
+func (s *EncryptedCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
+	stored, err := s.encryptCheckpoint(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+	return s.store.Save(ctx, stored)
+}
+
+// SaveIfLatest encrypts and stores a checkpoint if the latest checkpoint of
+// its thread is still expectedID. Without a graph.ConditionalCheckpointStore
+// to wrap, the checkpoint is saved unconditionally.
+func (s *EncryptedCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	stored, err := s.encryptCheckpoint(ctx, checkpoint)
+	if err != nil {
+		return err
+	}
+	if conditional, ok := s.store.(graph.ConditionalCheckpointStore); ok {
+		return conditional.SaveIfLatest(ctx, stored, expectedID)
+	}
+	return s.store.Save(ctx, stored)
+}
+
+// Load retrieves and decrypts a checkpoint by ID
+func (s *EncryptedCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	stored, err := s.store.Load(ctx, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptCheckpoint(ctx, stored)
+}
+
+// List returns all checkpoints for a given execution
+func (s *EncryptedCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
+	stored, err := s.store.List(ctx, executionID)
+	if err != nil {
+		return nil, err
+	}
+	return s.decryptCheckpoints(ctx, stored)
+}
+
+// Delete removes a checkpoint
+func (s *EncryptedCheckpointStore) Delete(ctx context.Context, checkpointID string) error {
+	return s.store.Delete(ctx, checkpointID)
+}
+
+// Clear removes all checkpoints for an execution
+func (s *EncryptedCheckpointStore) Clear(ctx context.Context, executionID string) error {
+	return s.store.Clear(ctx, executionID)
+}
+
+// ListThreads returns the threads of the wrapped store, which must be a graph.ThreadLister
+func (s *EncryptedCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	lister, ok := s.store.(graph.ThreadLister)
+	if !ok {
+		return nil, fmt.Errorf("store %T does not list threads", s.store)
+	}
+	return lister.ListThreads(ctx)
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *EncryptedCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
+	if v2, ok := s.store.(graph.CheckpointStoreV2); ok {
+		stored, err := v2.GetLatest(ctx, threadID)
+		if err != nil || stored == nil {
+			return nil, err
+		}
+		return s.decryptCheckpoint(ctx, stored)
+	}
+
+	checkpoints, err := s.ListWithOptions(ctx, threadID, graph.CheckpointListOptions{Limit: 1})
+	if err != nil || len(checkpoints) == 0 {
+		return nil, err
+	}
+	return checkpoints[0], nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first
+func (s *EncryptedCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	var stored []*graph.Checkpoint
+	if v2, ok := s.store.(graph.CheckpointStoreV2); ok {
+		checkpoints, err := v2.ListWithOptions(ctx, threadID, opts)
+		if err != nil {
+			return nil, err
+		}
+		stored = checkpoints
+	} else {
+		checkpoints, err := s.store.List(ctx, threadID)
+		if err != nil {
+			return nil, err
+		}
+		stored = graph.FilterCheckpoints(checkpoints, opts)
+	}
+	return s.decryptCheckpoints(ctx, stored)
+}
+
+// PutWrites encrypts and stores pending writes. Writes are dropped when the
+// wrapped store does not support them, so interrupted supersteps re-run in full.
+func (s *EncryptedCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	store, ok := s.store.(graph.PendingWritesStore)
+	if !ok {
+		return nil
+	}
+
+	encrypted := make([]graph.PendingWrite, len(writes))
+	for i, write := range writes {
+		value, err := s.encrypt(ctx, write.Value, writeAdditionalData(checkpointID, write.Node))
+		if err != nil {
+			return fmt.Errorf("failed to encrypt pending write: %w", err)
+		}
+		write.Value = value
+		encrypted[i] = write
+	}
+	return store.PutWrites(ctx, checkpointID, encrypted)
+}
+
+// GetWrites returns the decrypted pending writes stored against a checkpoint
+func (s *EncryptedCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	store, ok := s.store.(graph.PendingWritesStore)
+	if !ok {
+		return nil, nil
+	}
+
+	writes, err := store.GetWrites(ctx, checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	for i, write := range writes {
+		if !isEncrypted(write.Value) {
+			if !s.allowPlaintext {
+				return nil, fmt.Errorf("failed to decrypt pending write of node %s: %w", write.Node, ErrNotEncrypted)
+			}
+			continue
+		}
+		value, err := s.decrypt(ctx, write.Value, writeAdditionalData(checkpointID, write.Node))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decrypt pending write: %w", err)
+		}
+		writes[i].Value = value
+	}
+	return writes, nil
+}
+
+// Rotate re-encrypts the checkpoints of a thread that were not encrypted with
+// the current key, including those written before encryption was enabled
+// when AllowPlaintext is set. It returns the number of checkpoints rewritten.
+func (s *EncryptedCheckpointStore) Rotate(ctx context.Context, threadID string) (int, error) {
+	currentID, _, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to get current key: %w", err)
+	}
+
+	checkpoints, err := s.store.List(ctx, threadID)
+	if err != nil {
+		return 0, err
+	}
+
+	rotated := 0
+	for _, stored := range checkpoints {
+		if keyID, _ := stored.Metadata[KeyIDMetadataKey].(string); keyID == currentID {
+			continue
+		}
+
+		checkpoint, err := s.decryptCheckpoint(ctx, stored)
+		if err != nil {
+			return rotated, err
+		}
+		if err := s.Save(ctx, checkpoint); err != nil {
+			return rotated, err
+		}
+		rotated++
+	}
+	return rotated, nil
+}
+
+// encryptCheckpoint returns a copy of checkpoint with its state and selected
+// metadata replaced by an encrypted value
+func (s *EncryptedCheckpointStore) encryptCheckpoint(ctx context.Context, checkpoint *graph.Checkpoint) (*graph.Checkpoint, error) {
+	stored := *checkpoint
+	stored.Metadata = make(map[string]interface{}, len(checkpoint.Metadata)+1)
+
+	secret := map[string]interface{}{"state": checkpoint.State}
+	secretMetadata := make(map[string]interface{})
+	for key, value := range checkpoint.Metadata {
+		if slices.Contains(s.metadataKeys, key) {
+			secretMetadata[key] = value
+		} else {
+			stored.Metadata[key] = value
+		}
+	}
+	if len(secretMetadata) > 0 {
+		secret["metadata"] = secretMetadata
+	}
+
+	state, err := s.encrypt(ctx, secret, checkpoint.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt checkpoint: %w", err)
+	}
+	stored.State = state
+	stored.Metadata[KeyIDMetadataKey] = state["key_id"]
+	return &stored, nil
+}
+
+// decryptCheckpoint restores a checkpoint saved by encryptCheckpoint.
+// Checkpoints written before encryption was enabled are returned as is when
+// plaintext is allowed.
+func (s *EncryptedCheckpointStore) decryptCheckpoint(ctx context.Context, stored *graph.Checkpoint) (*graph.Checkpoint, error) {
+	if _, ok := stored.Metadata[KeyIDMetadataKey]; !ok {
+		if !s.allowPlaintext {
+			return nil, fmt.Errorf("failed to decrypt checkpoint %s: %w", stored.ID, ErrNotEncrypted)
+		}
+		return stored, nil
+	}
+
+	value, err := s.decrypt(ctx, stored.State, stored.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt checkpoint %s: %w", stored.ID, err)
+	}
+	secret, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("failed to decrypt checkpoint %s: unexpected payload %T", stored.ID, value)
+	}
+
+	checkpoint := *stored
+	checkpoint.State = secret["state"]
+	checkpoint.Metadata = make(map[string]interface{}, len(stored.Metadata))
+	for key, value := range stored.Metadata {
+		if key != KeyIDMetadataKey {
+			checkpoint.Metadata[key] = value
+		}
+	}
+	if secretMetadata, ok := secret["metadata"].(map[string]interface{}); ok {
+		for key, value := range secretMetadata {
+			checkpoint.Metadata[key] = value
+		}
+	}
+	return &checkpoint, nil
+}
+
+// decryptCheckpoints decrypts a list of checkpoints
+func (s *EncryptedCheckpointStore) decryptCheckpoints(ctx context.Context, stored []*graph.Checkpoint) ([]*graph.Checkpoint, error) {
+	checkpoints := make([]*graph.Checkpoint, 0, len(stored))
+	for _, checkpoint := range stored {
+		decrypted, err := s.decryptCheckpoint(ctx, checkpoint)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, decrypted)
+	}
+	return checkpoints, nil
+}
+
+// encrypt serializes and encrypts a value with the current key. The result
+// only holds strings, so any serializer of the wrapped store can encode it.
+func (s *EncryptedCheckpointStore) encrypt(ctx context.Context, value interface{}, additionalData string) (map[string]interface{}, error) {
+	keyID, key, err := s.keys.CurrentKey(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current key: %w", err)
+	}
+	if err := validateKey(key); err != nil {
+		return nil, fmt.Errorf("invalid key %s: %w", keyID, err)
+	}
+
+	plaintext, err := s.serializer.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to serialize: %w", err)
+	}
+	ciphertext, err := seal(key, plaintext, []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"encrypted":  algorithm,
+		"key_id":     keyID,
+		"serializer": s.serializer.ID(),
+		"ciphertext": base64.StdEncoding.EncodeToString(ciphertext),
+	}, nil
+}
+
+// decrypt reverses encrypt
+func (s *EncryptedCheckpointStore) decrypt(ctx context.Context, value interface{}, additionalData string) (interface{}, error) {
+	if !isEncrypted(value) {
+		return nil, fmt.Errorf("value is not encrypted")
+	}
+	envelope := value.(map[string]interface{})
+	keyID, _ := envelope["key_id"].(string)
+	serializerID, _ := envelope["serializer"].(string)
+	encoded, _ := envelope["ciphertext"].(string)
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode ciphertext: %w", err)
+	}
+	key, err := s.keys.Key(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := open(key, ciphertext, []byte(additionalData))
+	if err != nil {
+		return nil, err
+	}
+	return graph.Deserialize(s.serializer, serializerID, plaintext)
+}
+
+// isEncrypted reports whether value was produced by encrypt
+func isEncrypted(value interface{}) bool {
+	envelope, ok := value.(map[string]interface{})
+	return ok && envelope["encrypted"] == algorithm
+}
+
+// writeAdditionalData binds an encrypted pending write to its checkpoint and node
+func writeAdditionalData(checkpointID, node string) string {
+	return checkpointID + "/" + node
+}
@@ -0,0 +1,254 @@
+package encrypted
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/checkpoint/sqlite"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+var (
+	key1 = []byte("0123456789abcdef0123456789abcdef")
+	key2 = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func newCheckpoint(id string, version int, state interface{}) *graph.Checkpoint {
+	return &graph.Checkpoint{
+		ID:        id,
+		NodeName:  "agent",
+		State:     state,
+		Timestamp: time.Now(),
+		Version:   version,
+		Metadata: map[string]interface{}{
+			"execution_id": "thread-1",
+			"thread_id":    "thread-1",
+			"user_email":   "alice@example.com",
+		},
+	}
+}
+
+func TestEncryptedCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+
+	inner := graph.NewMemoryCheckpointStore()
+	store, err := NewEncryptedCheckpointStore(EncryptedOptions{
+		Store:        inner,
+		Keys:         keys,
+		MetadataKeys: []string{"user_email"},
+	})
+	assert.NoError(t, err)
+
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "my SSN is 123-45-6789")},
+	}
+	checkpoint := newCheckpoint("cp-1", 1, state)
+	assert.NoError(t, store.Save(ctx, checkpoint))
+	assert.Equal(t, "alice@example.com", checkpoint.Metadata["user_email"], "the caller's checkpoint is not modified")
+
+	// The wrapped store only sees ciphertext and the identifying fields
+	stored, err := inner.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.NotContains(t, stored.Metadata, "user_email")
+	assert.Equal(t, "thread-1", stored.Metadata["thread_id"])
+	assert.Equal(t, "k1", stored.Metadata[KeyIDMetadataKey])
+	assert.Equal(t, 1, stored.Version)
+	envelope, ok := stored.State.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "aes-gcm", envelope["encrypted"])
+	assert.NotContains(t, envelope["ciphertext"], "123-45-6789")
+
+	loaded, err := store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded.State)
+	assert.Equal(t, checkpoint.Metadata, loaded.Metadata)
+
+	// Filters on plaintext metadata still work
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-2", 2, map[string]interface{}{"count": 2})))
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-2", latest.ID)
+	assert.Equal(t, map[string]interface{}{"count": 2}, latest.State)
+
+	listed, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, listed, 2)
+	assert.Equal(t, state, listed[1].State)
+
+	// Ciphertexts are bound to their checkpoint
+	other, err := inner.Load(ctx, "cp-2")
+	assert.NoError(t, err)
+	other.State = stored.State // the memory store returns the stored checkpoint
+	_, err = store.Load(ctx, "cp-2")
+	assert.Error(t, err)
+}
+
+func TestEncryptedCheckpointStore_Rotate(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+
+	inner := graph.NewMemoryCheckpointStore()
+	// Written before encryption was enabled
+	assert.NoError(t, inner.Save(ctx, newCheckpoint("cp-0", 1, map[string]interface{}{"count": 0})))
+
+	// Plaintext checkpoints are rejected unless the migration allows them
+	store, err := NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: keys})
+	assert.NoError(t, err)
+	_, err = store.Load(ctx, "cp-0")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+	_, err = store.Rotate(ctx, "thread-1")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	store, err = NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: keys, AllowPlaintext: true})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", 2, map[string]interface{}{"count": 1})))
+
+	legacy, err := store.Load(ctx, "cp-0")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": 0}, legacy.State)
+
+	// Checkpoints encrypted with the old key stay readable after a rotation
+	assert.NoError(t, keys.Rotate("k2", key2))
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-2", 3, map[string]interface{}{"count": 2})))
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 3)
+
+	rotated, err := store.Rotate(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, rotated)
+
+	for _, id := range []string{"cp-0", "cp-1", "cp-2"} {
+		stored, err := inner.Load(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", stored.Metadata[KeyIDMetadataKey])
+	}
+
+	// Once rotated, the old key is no longer needed
+	onlyNew, err := NewStaticKeyProvider("k2", key2)
+	assert.NoError(t, err)
+	store, err = NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: onlyNew})
+	assert.NoError(t, err)
+	loaded, err := store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": 1}, loaded.State)
+
+	// A missing key is reported
+	missing, err := NewStaticKeyProvider("k3", key1)
+	assert.NoError(t, err)
+	store, err = NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: missing})
+	assert.NoError(t, err)
+	_, err = store.Load(ctx, "cp-1")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestEncryptedCheckpointStore_RejectsPlaintext(t *testing.T) {
+	ctx := context.Background()
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+
+	inner := graph.NewMemoryCheckpointStore()
+	store, err := NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: keys})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", 1, map[string]interface{}{"count": 1})))
+
+	// A plaintext checkpoint written over an encrypted one is not trusted
+	assert.NoError(t, inner.Save(ctx, newCheckpoint("cp-1", 1, map[string]interface{}{"count": 99})))
+	_, err = store.Load(ctx, "cp-1")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+	_, err = store.GetLatest(ctx, "thread-1")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+
+	assert.NoError(t, inner.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "agent", Value: "forged"}}))
+	_, err = store.GetWrites(ctx, "cp-1")
+	assert.ErrorIs(t, err, ErrNotEncrypted)
+}
+
+func TestEncryptedCheckpointStore_Sqlite(t *testing.T) {
+	ctx := context.Background()
+	inner, err := sqlite.NewSqliteCheckpointStore(sqlite.SqliteOptions{
+		Path:       filepath.Join(t.TempDir(), "checkpoints.db"),
+		Serializer: graph.JSONSerializer{},
+	})
+	assert.NoError(t, err)
+	defer inner.Close()
+
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+	store, err := NewEncryptedCheckpointStore(EncryptedOptions{Store: inner, Keys: keys})
+	assert.NoError(t, err)
+
+	// The state keeps its types even though the wrapped store only writes plain JSON
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeAI, "hello")},
+		"count":    1,
+	}
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", 1, state)))
+
+	loaded, err := store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded.State)
+
+	assert.NoError(t, store.PutWrites(ctx, "cp-1", []graph.PendingWrite{
+		{Node: "search", Value: map[string]interface{}{"result": "secret"}},
+		{Node: "route", Value: "done", Goto: []string{"end"}},
+	}))
+
+	stored, err := inner.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.True(t, isEncrypted(stored[0].Value))
+
+	writes, err := store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "route", Value: "done", Goto: []string{"end"}},
+		{Node: "search", Value: map[string]interface{}{"result": "secret"}},
+	}, writes)
+
+	// Conditional saves are checked by the wrapped store
+	var conflict *graph.ConcurrentUpdateError
+	assert.ErrorAs(t, store.SaveIfLatest(ctx, newCheckpoint("cp-2", 2, state), ""), &conflict)
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-2", 2, state), "cp-1"))
+	loaded, err = store.Load(ctx, "cp-2")
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded.State)
+}
+
+func TestEncryptedCheckpointStore_Graph(t *testing.T) {
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+	store, err := NewEncryptedCheckpointStore(EncryptedOptions{Store: graph.NewMemoryCheckpointStore(), Keys: keys})
+	assert.NoError(t, err)
+
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return map[string]interface{}{"steps": []string{"a"}}, nil
+	})
+	g.AddEdge("a", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, config)
+	assert.NoError(t, err)
+	result, err := runnable.InvokeWithConfig(ctx, map[string]interface{}{}, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "a"}, result.(map[string]interface{})["steps"])
+}
@@ -0,0 +1,147 @@
+package encrypted
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrKeyNotFound is returned when a checkpoint was encrypted with a key the provider does not know
+var ErrKeyNotFound = errors.New("encryption key not found")
+
+// KeyProvider supplies the AES keys used to encrypt checkpoints.
+// Keys are 16, 24 or 32 bytes long, selecting AES-128, AES-192 or AES-256.
+type KeyProvider interface {
+	// CurrentKey returns the ID and value of the key new checkpoints are encrypted with
+	CurrentKey(ctx context.Context) (string, []byte, error)
+
+	// Key returns the key with the given ID, or ErrKeyNotFound
+	Key(ctx context.Context, keyID string) ([]byte, error)
+}
+
+// StaticKeyProvider is a KeyProvider holding its keys in memory.
+// Rotating a key adds it and makes it current; older keys stay available
+// to decrypt the checkpoints written with them.
+type StaticKeyProvider struct {
+	mu      sync.RWMutex
+	current string
+	keys    map[string][]byte
+}
+
+// NewStaticKeyProvider creates a key provider whose current key is keyID
+func NewStaticKeyProvider(keyID string, key []byte) (*StaticKeyProvider, error) {
+	p := &StaticKeyProvider{keys: make(map[string][]byte)}
+	if err := p.Rotate(keyID, key); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// AddKey makes a key available for decryption without making it current
+func (p *StaticKeyProvider) AddKey(keyID string, key []byte) error {
+	if keyID == "" {
+		return fmt.Errorf("key ID is required")
+	}
+	if err := validateKey(key); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.keys[keyID] = append([]byte(nil), key...)
+	return nil
+}
+
+// Rotate adds a key and encrypts new checkpoints with it
+func (p *StaticKeyProvider) Rotate(keyID string, key []byte) error {
+	if err := p.AddKey(keyID, key); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.current = keyID
+	return nil
+}
+
+// CurrentKey implements KeyProvider
+func (p *StaticKeyProvider) CurrentKey(_ context.Context) (string, []byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.current, p.keys[p.current], nil
+}
+
+// Key implements KeyProvider
+func (p *StaticKeyProvider) Key(_ context.Context, keyID string) ([]byte, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+	return key, nil
+}
+
+// validateKey checks that key has an AES key size
+func validateKey(key []byte) error {
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("invalid key size %d, must be 16, 24 or 32 bytes", len(key))
+	}
+}
+
+// seal encrypts plaintext with AES-GCM, binding it to additionalData.
+// The nonce is prepended to the ciphertext.
+func seal(key, plaintext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext produced by seal
+func open(key, ciphertext, additionalData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ciphertext) < gcm.NonceSize() {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	nonce, sealed := ciphertext[:gcm.NonceSize()], ciphertext[gcm.NonceSize():]
+	plaintext, err := gcm.Open(nil, nonce, sealed, additionalData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt: %w", err)
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM cipher for key
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
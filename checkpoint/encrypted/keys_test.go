@@ -0,0 +1,54 @@
+package encrypted
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStaticKeyProvider(t *testing.T) {
+	ctx := context.Background()
+
+	_, err := NewStaticKeyProvider("k1", []byte("short"))
+	assert.Error(t, err)
+	_, err = NewStaticKeyProvider("", key1)
+	assert.Error(t, err)
+
+	keys, err := NewStaticKeyProvider("k1", key1)
+	assert.NoError(t, err)
+	assert.NoError(t, keys.AddKey("k2", key2))
+
+	id, key, err := keys.CurrentKey(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "k1", id)
+	assert.Equal(t, key1, key)
+
+	assert.NoError(t, keys.Rotate("k2", key2))
+	id, _, err = keys.CurrentKey(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "k2", id)
+
+	key, err = keys.Key(ctx, "k1")
+	assert.NoError(t, err)
+	assert.Equal(t, key1, key)
+
+	_, err = keys.Key(ctx, "k3")
+	assert.ErrorIs(t, err, ErrKeyNotFound)
+}
+
+func TestSealOpen(t *testing.T) {
+	ciphertext, err := seal(key1, []byte("hello"), []byte("cp-1"))
+	assert.NoError(t, err)
+
+	plaintext, err := open(key1, ciphertext, []byte("cp-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, "hello", string(plaintext))
+
+	_, err = open(key1, ciphertext, []byte("cp-2"))
+	assert.Error(t, err)
+	_, err = open(key2, ciphertext, []byte("cp-1"))
+	assert.Error(t, err)
+	_, err = open(key1, ciphertext[:4], []byte("cp-1"))
+	assert.Error(t, err)
+}
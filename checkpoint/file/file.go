@@ -0,0 +1,737 @@
+package file
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+const (
+	// indexFile lists the checkpoints of a thread, oldest first
+	indexFile = "_index.json"
+	// lockFileName serializes writers of a thread, across processes
+	lockFileName = "_lock"
+	// checkpointSuffix ends the file name of a checkpoint
+	checkpointSuffix = ".json"
+	// writesSuffix ends the file name of the pending writes of a checkpoint
+	writesSuffix = ".writes.json"
+	// lookupDir holds one file per checkpoint ID naming the directory of its
+	// thread. Escaped thread names never start with "_i", so it cannot collide.
+	lookupDir = "_ids"
+)
+
+var (
+	_ graph.CheckpointStoreV2          = &FileCheckpointStore{}
+	_ graph.PendingWritesStore         = &FileCheckpointStore{}
+	_ graph.ThreadLister               = &FileCheckpointStore{}
+	_ graph.ConditionalCheckpointStore = &FileCheckpointStore{}
+)
+
+// FileCheckpointStore implements graph.CheckpointStore on the filesystem.
+// Each thread is a directory holding one file per checkpoint and an index,
+// so listing a thread and finding its latest checkpoint only read the index.
+// A lookup directory maps checkpoint IDs to their thread, so loading a
+// checkpoint by ID reads one small file instead of scanning the threads.
+// Files are replaced atomically and writers of a thread hold a file lock,
+// so several processes can share the directory.
+type FileCheckpointStore struct {
+	dir        string
+	serializer graph.Serializer
+}
+
+// FileOptions configuration for the file store
+type FileOptions struct {
+	// Dir is the directory holding the threads, created if missing
+	Dir string
+
+	// Serializer encodes states, default graph.DefaultSerializer()
+	Serializer graph.Serializer
+}
+
+// NewFileCheckpointStore creates a new file checkpoint store
+func NewFileCheckpointStore(opts FileOptions) (*FileCheckpointStore, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("directory is required")
+	}
+	if err := os.MkdirAll(filepath.Join(opts.Dir, lookupDir), 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
+	return &FileCheckpointStore{
+		dir:        opts.Dir,
+		serializer: serializer,
+	}, nil
+}
+
+// record is the content of a checkpoint file. States whose encoding is JSON
+// are embedded as is, to keep the files readable.
+type record struct {
+	*graph.Checkpoint
+	State      json.RawMessage `json:"state,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	Serializer string          `json:"serializer"`
+}
+
+// writeRecord is a pending write in the writes file of a checkpoint
+type writeRecord struct {
+	Node       string          `json:"node"`
+	Value      json.RawMessage `json:"value,omitempty"`
+	Data       []byte          `json:"data,omitempty"`
+	Goto       []string        `json:"goto,omitempty"`
+	Serializer string          `json:"serializer"`
+}
+
+// indexEntry describes a checkpoint in the index of its thread
+type indexEntry struct {
+	ID        string                 `json:"id"`
+	Version   int                    `json:"version"`
+	Timestamp time.Time              `json:"timestamp"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+}
+
+// Save stores a checkpoint
+func (s *FileCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
+	return s.save(checkpoint, nil)
+}
+
+// SaveIfLatest stores a checkpoint if the latest checkpoint of its thread is
+// still expectedID, checked under the lock of the thread
+func (s *FileCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	return s.save(checkpoint, func(entries []indexEntry) error {
+		actualID := ""
+		if len(entries) > 0 {
+			actualID = entries[len(entries)-1].ID
+		}
+		if actualID != expectedID {
+			return &graph.ConcurrentUpdateError{ThreadID: executionID(checkpoint), ExpectedID: expectedID, ActualID: actualID}
+		}
+		return nil
+	})
+}
+
+// save writes a checkpoint and adds it to the index. The optional check runs
+// on the index, oldest first, before anything is written.
+func (s *FileCheckpointStore) save(checkpoint *graph.Checkpoint, check func(entries []indexEntry) error) error {
+	encoded, err := s.encodeValue(checkpoint.State)
+	if err != nil {
+		return fmt.Errorf("failed to marshal state: %w", err)
+	}
+	stored := *checkpoint
+	stored.State = nil
+	data, err := json.MarshalIndent(record{
+		Checkpoint: &stored,
+		State:      encoded.json,
+		Data:       encoded.binary,
+		Serializer: s.serializer.ID(),
+	}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+	}
+
+	threadDir := s.threadDir(executionID(checkpoint))
+	if err := os.MkdirAll(threadDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create thread directory: %w", err)
+	}
+
+	return s.withLock(threadDir, func() error {
+		entries, err := s.readIndex(threadDir)
+		if err != nil {
+			return err
+		}
+		if check != nil {
+			if err := check(entries); err != nil {
+				return err
+			}
+		}
+
+		if err := writeFileAtomic(filepath.Join(threadDir, escapeName(checkpoint.ID)+checkpointSuffix), data); err != nil {
+			return fmt.Errorf("failed to write checkpoint: %w", err)
+		}
+		if err := s.writeLookup(checkpoint.ID, threadDir); err != nil {
+			return err
+		}
+
+		entry := indexEntry{
+			ID:        checkpoint.ID,
+			Version:   checkpoint.Version,
+			Timestamp: checkpoint.Timestamp,
+			Metadata:  checkpoint.Metadata,
+		}
+		replaced := false
+		for i := range entries {
+			if entries[i].ID == checkpoint.ID {
+				entries[i] = entry
+				replaced = true
+			}
+		}
+		if !replaced {
+			entries = append(entries, entry)
+		}
+		return s.writeIndex(threadDir, entries)
+	})
+}
+
+// Load retrieves a checkpoint by ID
+func (s *FileCheckpointStore) Load(_ context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	threadDir, err := s.findThread(checkpointID)
+	if err != nil {
+		return nil, err
+	}
+	return s.readCheckpoint(threadDir, checkpointID)
+}
+
+// List returns all checkpoints for a given execution, oldest first
+func (s *FileCheckpointStore) List(_ context.Context, executionID string) ([]*graph.Checkpoint, error) {
+	threadDir := s.threadDir(executionID)
+	entries, err := s.readIndex(threadDir)
+	if err != nil {
+		return nil, err
+	}
+	return s.readCheckpoints(threadDir, entries)
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *FileCheckpointStore) GetLatest(_ context.Context, threadID string) (*graph.Checkpoint, error) {
+	threadDir := s.threadDir(threadID)
+	entries, err := s.readIndex(threadDir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	return s.readCheckpoint(threadDir, entries[len(entries)-1].ID)
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first.
+// Options are applied to the index, only the selected checkpoints are read.
+func (s *FileCheckpointStore) ListWithOptions(_ context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	threadDir := s.threadDir(threadID)
+	entries, err := s.readIndex(threadDir)
+	if err != nil {
+		return nil, err
+	}
+
+	summaries := make([]*graph.Checkpoint, len(entries))
+	for i, entry := range entries {
+		summaries[i] = &graph.Checkpoint{
+			ID:        entry.ID,
+			Version:   entry.Version,
+			Timestamp: entry.Timestamp,
+			Metadata:  entry.Metadata,
+		}
+	}
+
+	selected := graph.FilterCheckpoints(summaries, opts)
+	checkpoints := make([]*graph.Checkpoint, 0, len(selected))
+	for _, summary := range selected {
+		checkpoint, err := s.readCheckpoint(threadDir, summary.ID)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// ListThreads returns the IDs of the threads that have checkpoints
+func (s *FileCheckpointStore) ListThreads(_ context.Context) ([]string, error) {
+	dirEntries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
+	}
+
+	threads := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() || dirEntry.Name() == lookupDir {
+			continue
+		}
+		entries, err := s.readIndex(filepath.Join(s.dir, dirEntry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			continue
+		}
+		threadID, err := unescapeName(dirEntry.Name())
+		if err != nil {
+			continue
+		}
+		threads = append(threads, threadID)
+	}
+	sort.Strings(threads)
+	return threads, nil
+}
+
+// Delete removes a checkpoint
+func (s *FileCheckpointStore) Delete(_ context.Context, checkpointID string) error {
+	threadDir, err := s.findThread(checkpointID)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.withLock(threadDir, func() error {
+		name := escapeName(checkpointID)
+		for _, path := range []string{name + checkpointSuffix, name + writesSuffix} {
+			if err := os.Remove(filepath.Join(threadDir, path)); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to delete checkpoint: %w", err)
+			}
+		}
+
+		entries, err := s.readIndex(threadDir)
+		if err != nil {
+			return err
+		}
+		kept := entries[:0]
+		for _, entry := range entries {
+			if entry.ID != checkpointID {
+				kept = append(kept, entry)
+			}
+		}
+		if err := s.writeIndex(threadDir, kept); err != nil {
+			return err
+		}
+		return s.removeLookup(checkpointID, threadDir)
+	})
+}
+
+// Clear removes all checkpoints for an execution
+func (s *FileCheckpointStore) Clear(_ context.Context, executionID string) error {
+	threadDir := s.threadDir(executionID)
+	if _, err := os.Stat(threadDir); errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	return s.withLock(threadDir, func() error {
+		entries, err := s.readIndex(threadDir)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := s.removeLookup(entry.ID, threadDir); err != nil {
+				return err
+			}
+		}
+
+		dirEntries, err := os.ReadDir(threadDir)
+		if err != nil {
+			return fmt.Errorf("failed to clear checkpoints: %w", err)
+		}
+		// The lock file stays, other writers may be waiting on it
+		for _, dirEntry := range dirEntries {
+			if dirEntry.Name() == lockFileName {
+				continue
+			}
+			if err := os.Remove(filepath.Join(threadDir, dirEntry.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+				return fmt.Errorf("failed to clear checkpoints: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// PutWrites stores pending writes against a checkpoint
+func (s *FileCheckpointStore) PutWrites(_ context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	threadDir, err := s.findThread(checkpointID)
+	if err != nil {
+		return err
+	}
+
+	records := make([]writeRecord, 0, len(writes))
+	for _, write := range writes {
+		encoded, err := s.encodeValue(write.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
+		records = append(records, writeRecord{
+			Node:       write.Node,
+			Value:      encoded.json,
+			Data:       encoded.binary,
+			Goto:       write.Goto,
+			Serializer: s.serializer.ID(),
+		})
+	}
+
+	path := filepath.Join(threadDir, escapeName(checkpointID)+writesSuffix)
+	return s.withLock(threadDir, func() error {
+		existing, err := readWriteRecords(path)
+		if err != nil {
+			return err
+		}
+
+		byNode := make(map[string]writeRecord, len(existing)+len(records))
+		for _, rec := range append(existing, records...) {
+			byNode[rec.Node] = rec
+		}
+		merged := make([]writeRecord, 0, len(byNode))
+		for _, rec := range byNode {
+			merged = append(merged, rec)
+		}
+		sort.Slice(merged, func(i, j int) bool { return merged[i].Node < merged[j].Node })
+
+		data, err := json.MarshalIndent(merged, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending writes: %w", err)
+		}
+		if err := writeFileAtomic(path, data); err != nil {
+			return fmt.Errorf("failed to save pending writes: %w", err)
+		}
+		return nil
+	})
+}
+
+// GetWrites returns the pending writes stored against a checkpoint, ordered by node
+func (s *FileCheckpointStore) GetWrites(_ context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	threadDir, err := s.findThread(checkpointID)
+	if errors.Is(err, os.ErrNotExist) {
+		return []graph.PendingWrite{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := readWriteRecords(filepath.Join(threadDir, escapeName(checkpointID)+writesSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	writes := make([]graph.PendingWrite, 0, len(records))
+	for _, rec := range records {
+		value, err := s.decodeValue(rec.Serializer, rec.Value, rec.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending write: %w", err)
+		}
+		writes = append(writes, graph.PendingWrite{Node: rec.Node, Value: value, Goto: rec.Goto})
+	}
+	return writes, nil
+}
+
+// threadDir returns the directory of a thread
+func (s *FileCheckpointStore) threadDir(threadID string) string {
+	return filepath.Join(s.dir, escapeName(threadID))
+}
+
+// findThread returns the directory of the thread holding a checkpoint. When
+// an ID was saved in several threads, the thread it was last saved in wins.
+func (s *FileCheckpointStore) findThread(checkpointID string) (string, error) {
+	name := escapeName(checkpointID)
+	data, err := os.ReadFile(filepath.Join(s.dir, lookupDir, name))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return "", fmt.Errorf("failed to find checkpoint: %w", err)
+	}
+	if err == nil {
+		threadName := string(data)
+		threadDir := filepath.Join(s.dir, threadName)
+		if threadName != "" && filepath.Base(threadName) == threadName {
+			if _, err := os.Stat(filepath.Join(threadDir, name+checkpointSuffix)); err == nil {
+				return threadDir, nil
+			}
+		}
+	}
+
+	// Checkpoints saved before the lookup directory existed are searched for once
+	matches, err := filepath.Glob(filepath.Join(s.dir, "*", name+checkpointSuffix))
+	if err != nil {
+		return "", fmt.Errorf("failed to find checkpoint: %w", err)
+	}
+	if len(matches) == 0 {
+		return "", fmt.Errorf("checkpoint not found: %s: %w", checkpointID, os.ErrNotExist)
+	}
+	threadDir := filepath.Dir(matches[0])
+	if err := s.writeLookup(checkpointID, threadDir); err != nil {
+		return "", err
+	}
+	return threadDir, nil
+}
+
+// writeLookup records the thread directory of a checkpoint
+func (s *FileCheckpointStore) writeLookup(checkpointID, threadDir string) error {
+	if err := writeFileAtomic(filepath.Join(s.dir, lookupDir, escapeName(checkpointID)), []byte(filepath.Base(threadDir))); err != nil {
+		return fmt.Errorf("failed to write checkpoint lookup: %w", err)
+	}
+	return nil
+}
+
+// removeLookup forgets the thread directory of a checkpoint, unless the ID
+// was saved in another thread since
+func (s *FileCheckpointStore) removeLookup(checkpointID, threadDir string) error {
+	path := filepath.Join(s.dir, lookupDir, escapeName(checkpointID))
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read checkpoint lookup: %w", err)
+	}
+	if string(data) != filepath.Base(threadDir) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to delete checkpoint lookup: %w", err)
+	}
+	return nil
+}
+
+// withLock runs fn while holding the lock of a thread directory
+func (s *FileCheckpointStore) withLock(threadDir string, fn func() error) (err error) {
+	unlock, err := lockFile(filepath.Join(threadDir, lockFileName))
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if unlockErr := unlock(); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to unlock thread: %w", unlockErr)
+		}
+	}()
+	return fn()
+}
+
+// readIndex returns the index of a thread, oldest first. A missing or
+// unreadable index is rebuilt from the checkpoint files.
+func (s *FileCheckpointStore) readIndex(threadDir string) ([]indexEntry, error) {
+	data, err := os.ReadFile(filepath.Join(threadDir, indexFile))
+	if err == nil {
+		var entries []indexEntry
+		if err := json.Unmarshal(data, &entries); err == nil {
+			return entries, nil
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	return s.rebuildIndex(threadDir)
+}
+
+// rebuildIndex recreates the index of a thread from its checkpoint files
+func (s *FileCheckpointStore) rebuildIndex(threadDir string) ([]indexEntry, error) {
+	dirEntries, err := os.ReadDir(threadDir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read thread directory: %w", err)
+	}
+
+	var entries []indexEntry
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if name == indexFile || !strings.HasSuffix(name, checkpointSuffix) || strings.HasSuffix(name, writesSuffix) {
+			continue
+		}
+		checkpointID, err := unescapeName(strings.TrimSuffix(name, checkpointSuffix))
+		if err != nil {
+			continue
+		}
+		checkpoint, err := s.readCheckpoint(threadDir, checkpointID)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, indexEntry{
+			ID:        checkpoint.ID,
+			Version:   checkpoint.Version,
+			Timestamp: checkpoint.Timestamp,
+			Metadata:  checkpoint.Metadata,
+		})
+	}
+	sortEntries(entries)
+	return entries, nil
+}
+
+// writeIndex replaces the index of a thread
+func (s *FileCheckpointStore) writeIndex(threadDir string, entries []indexEntry) error {
+	sortEntries(entries)
+	if entries == nil {
+		entries = []indexEntry{}
+	}
+	data, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal index: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(threadDir, indexFile), data); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// readCheckpoint reads a checkpoint file
+func (s *FileCheckpointStore) readCheckpoint(threadDir, checkpointID string) (*graph.Checkpoint, error) {
+	data, err := os.ReadFile(filepath.Join(threadDir, escapeName(checkpointID)+checkpointSuffix))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("checkpoint not found: %s: %w", checkpointID, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	rec := record{Checkpoint: &graph.Checkpoint{}}
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+	state, err := s.decodeValue(rec.Serializer, rec.State, rec.Data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	rec.Checkpoint.State = state
+	return rec.Checkpoint, nil
+}
+
+// readCheckpoints reads the checkpoints of index entries, in order
+func (s *FileCheckpointStore) readCheckpoints(threadDir string, entries []indexEntry) ([]*graph.Checkpoint, error) {
+	checkpoints := make([]*graph.Checkpoint, 0, len(entries))
+	for _, entry := range entries {
+		checkpoint, err := s.readCheckpoint(threadDir, entry.ID)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, checkpoint)
+	}
+	return checkpoints, nil
+}
+
+// encodedValue is a serialized value, stored as JSON when it is valid JSON
+type encodedValue struct {
+	json   json.RawMessage
+	binary []byte
+}
+
+// encodeValue serializes a value
+func (s *FileCheckpointStore) encodeValue(value interface{}) (encodedValue, error) {
+	data, err := s.serializer.Marshal(value)
+	if err != nil {
+		return encodedValue{}, err
+	}
+	if json.Valid(data) {
+		return encodedValue{json: data}, nil
+	}
+	return encodedValue{binary: data}, nil
+}
+
+// decodeValue reverses encodeValue
+func (s *FileCheckpointStore) decodeValue(serializer string, jsonData json.RawMessage, binaryData []byte) (interface{}, error) {
+	data := []byte(jsonData)
+	if len(binaryData) > 0 {
+		data = binaryData
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	return graph.Deserialize(s.serializer, serializer, data)
+}
+
+// readWriteRecords reads a writes file, which may not exist
+func readWriteRecords(path string) ([]writeRecord, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read pending writes: %w", err)
+	}
+
+	var records []writeRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal pending writes: %w", err)
+	}
+	return records, nil
+}
+
+// writeFileAtomic replaces path with data. The data is written to a
+// temporary file in the same directory, then renamed over path.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// sortEntries sorts index entries oldest first
+func sortEntries(entries []indexEntry) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].Version != entries[j].Version {
+			return entries[i].Version < entries[j].Version
+		}
+		return entries[i].Timestamp.Before(entries[j].Timestamp)
+	})
+}
+
+// executionID returns the thread a checkpoint is stored under
+func executionID(checkpoint *graph.Checkpoint) string {
+	executionID, _ := checkpoint.Metadata["execution_id"].(string)
+	return executionID
+}
+
+// escapeName turns an ID into a file name. Letters, digits and dashes are
+// kept, other bytes are written as _ followed by their hex value, so names
+// never start with a dot and cannot collide with the index or lock files.
+func escapeName(id string) string {
+	if id == "" {
+		return "_"
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '-' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "_%02x", c)
+		}
+	}
+	return b.String()
+}
+
+// unescapeName reverses escapeName
+func unescapeName(name string) (string, error) {
+	if name == "_" {
+		return "", nil
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(name); i++ {
+		if name[i] != '_' {
+			b.WriteByte(name[i])
+			continue
+		}
+		if i+2 >= len(name) {
+			return "", fmt.Errorf("invalid name %q", name)
+		}
+		c, err := strconv.ParseUint(name[i+1:i+3], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid name %q", name)
+		}
+		b.WriteByte(byte(c))
+		i += 2
+	}
+	return b.String(), nil
+}
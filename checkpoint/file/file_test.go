@@ -0,0 +1,370 @@
+package file
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+func newCheckpoint(id, threadID string, version int) *graph.Checkpoint {
+	return &graph.Checkpoint{
+		ID:        id,
+		NodeName:  "node",
+		State:     map[string]interface{}{"version": version},
+		Timestamp: time.Now(),
+		Version:   version,
+		Metadata:  map[string]interface{}{"execution_id": threadID, "thread_id": threadID},
+	}
+}
+
+func TestFileCheckpointStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+	assert.NoError(t, err)
+
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hello")},
+		"count":    1,
+	}
+	checkpoint := &graph.Checkpoint{
+		ID:        "cp-1",
+		NodeName:  "agent",
+		State:     state,
+		Timestamp: time.Now().Truncate(time.Millisecond),
+		Version:   1,
+		Next:      []string{"tools"},
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	}
+	assert.NoError(t, store.Save(ctx, checkpoint))
+
+	loaded, err := store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, state, loaded.State)
+	assert.Equal(t, []string{"tools"}, loaded.Next)
+	assert.True(t, checkpoint.Timestamp.Equal(loaded.Timestamp))
+
+	_, err = store.Load(ctx, "missing")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+
+	// One file per checkpoint under the directory of its thread
+	assert.FileExists(t, filepath.Join(dir, "thread-1", "cp-1.json"))
+	assert.FileExists(t, filepath.Join(dir, "thread-1", indexFile))
+
+	for i := 2; i <= 4; i++ {
+		cp := newCheckpoint(fmt.Sprintf("cp-%d", i), "thread-1", i)
+		cp.Metadata["source"] = "loop"
+		if i == 3 {
+			cp.Metadata["source"] = "update_state"
+		}
+		assert.NoError(t, store.Save(ctx, cp))
+	}
+	assert.NoError(t, store.Save(ctx, newCheckpoint("other", "thread-2", 1)))
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3", "cp-4"}, checkpointIDs(checkpoints))
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-4", latest.ID)
+
+	latest, err = store.GetLatest(ctx, "thread-3")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+
+	checkpoints, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Before: "cp-4", Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-3", "cp-2"}, checkpointIDs(checkpoints))
+
+	checkpoints, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "loop"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-4", "cp-2"}, checkpointIDs(checkpoints))
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-1", "thread-2"}, threads)
+
+	assert.NoError(t, store.Delete(ctx, "cp-4"))
+	assert.NoError(t, store.Delete(ctx, "cp-4"))
+	latest, err = store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-3", latest.ID)
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	checkpoints, err = store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	threads, err = store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-2"}, threads)
+}
+
+func checkpointIDs(checkpoints []*graph.Checkpoint) []string {
+	ids := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		ids = append(ids, checkpoint.ID)
+	}
+	return ids
+}
+
+func TestFileCheckpointStore_PendingWrites(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: t.TempDir()})
+	assert.NoError(t, err)
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", "thread-1", 1)))
+
+	assert.NoError(t, store.PutWrites(ctx, "cp-1", []graph.PendingWrite{
+		{Node: "b", Value: map[string]interface{}{"count": 1}},
+		{Node: "a", Value: "first", Goto: []string{"c"}},
+	}))
+	assert.NoError(t, store.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "a", Value: "second"}}))
+
+	writes, err := store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "a", Value: "second"},
+		{Node: "b", Value: map[string]interface{}{"count": 1}},
+	}, writes)
+
+	assert.Error(t, store.PutWrites(ctx, "missing", []graph.PendingWrite{{Node: "a"}}))
+
+	assert.NoError(t, store.Delete(ctx, "cp-1"))
+	writes, err = store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+}
+
+func TestFileCheckpointStore_Names(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir, Serializer: graph.GobSerializer{}})
+	assert.NoError(t, err)
+
+	// IDs cannot escape the directory or collide with the index
+	for _, threadID := range []string{"../escape", "a/b", "", "_index"} {
+		assert.NoError(t, store.Save(ctx, newCheckpoint("../"+threadID+"/cp.json", threadID, 1)))
+
+		checkpoints, err := store.List(ctx, threadID)
+		assert.NoError(t, err)
+		assert.Len(t, checkpoints, 1)
+		assert.Equal(t, map[string]interface{}{"version": 1}, checkpoints[0].State)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(dir))
+	assert.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, "escape", entry.Name())
+	}
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"", "../escape", "_index", "a/b"}, threads)
+
+	for _, name := range []string{"", "_", "a.b", "日本", "_5f"} {
+		unescaped, err := unescapeName(escapeName(name))
+		assert.NoError(t, err)
+		assert.Equal(t, name, unescaped)
+	}
+	_, err = unescapeName("_zz")
+	assert.Error(t, err)
+}
+
+func TestFileCheckpointStore_RebuildIndex(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+	assert.NoError(t, err)
+
+	for i := 1; i <= 3; i++ {
+		assert.NoError(t, store.Save(ctx, newCheckpoint(fmt.Sprintf("cp-%d", i), "thread-1", i)))
+	}
+	assert.NoError(t, store.PutWrites(ctx, "cp-3", []graph.PendingWrite{{Node: "a", Value: 1}}))
+
+	// A lost index is rebuilt from the checkpoint files
+	assert.NoError(t, os.Remove(filepath.Join(dir, "thread-1", indexFile)))
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3"}, checkpointIDs(checkpoints))
+
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "thread-1", indexFile), []byte("{corrupt"), 0o644))
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-3", latest.ID)
+}
+
+func TestFileCheckpointStore_Lookup(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+	assert.NoError(t, err)
+
+	// Loading by ID reads the lookup file, not the other threads
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", "thread-1", 1)))
+	assert.FileExists(t, filepath.Join(dir, lookupDir, "cp-1"))
+
+	// An ID saved again in another thread resolves to the last one
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-1", "thread-2", 2)))
+	for i := 0; i < 3; i++ {
+		loaded, err := store.Load(ctx, "cp-1")
+		assert.NoError(t, err)
+		assert.Equal(t, 2, loaded.Version)
+	}
+	assert.NoError(t, store.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "a", Value: 1}}))
+	assert.FileExists(t, filepath.Join(dir, "thread-2", "cp-1"+writesSuffix))
+	assert.NoFileExists(t, filepath.Join(dir, "thread-1", "cp-1"+writesSuffix))
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-1", "thread-2"}, threads)
+
+	// Checkpoints without a lookup file, saved by older versions, are still found
+	assert.NoError(t, store.Save(ctx, newCheckpoint("cp-2", "thread-1", 2)))
+	assert.NoError(t, os.Remove(filepath.Join(dir, lookupDir, "cp-2")))
+	loaded, err := store.Load(ctx, "cp-2")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-2", loaded.ID)
+	assert.FileExists(t, filepath.Join(dir, lookupDir, "cp-2"))
+
+	assert.NoError(t, store.Delete(ctx, "cp-2"))
+	assert.NoFileExists(t, filepath.Join(dir, lookupDir, "cp-2"))
+
+	// Clearing a thread keeps the lookups of IDs last saved elsewhere
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	loaded, err = store.Load(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, loaded.Version)
+	assert.NoError(t, store.Clear(ctx, "thread-2"))
+	assert.NoFileExists(t, filepath.Join(dir, lookupDir, "cp-1"))
+	_, err = store.Load(ctx, "cp-1")
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestFileCheckpointStore_ConcurrentWriters(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Separate stores stand in for separate processes
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				id := fmt.Sprintf("w%d-%d", w, i)
+				assert.NoError(t, store.Save(ctx, newCheckpoint(id, "thread-1", w*10+i)))
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+	assert.NoError(t, err)
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 40)
+
+	// No temporary files are left behind
+	matches, err := filepath.Glob(filepath.Join(dir, "thread-1", ".tmp-*"))
+	assert.NoError(t, err)
+	assert.Empty(t, matches)
+}
+
+func TestFileCheckpointStore_SaveIfLatest(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+	assert.NoError(t, err)
+
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-1", "thread-1", 1), ""))
+	err = store.SaveIfLatest(ctx, newCheckpoint("cp-2", "thread-1", 2), "")
+	var conflict *graph.ConcurrentUpdateError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, graph.ConcurrentUpdateError{ThreadID: "thread-1", ActualID: "cp-1"}, *conflict)
+	}
+	assert.NoFileExists(t, filepath.Join(dir, "thread-1", "cp-2.json"))
+
+	// Of the workers continuing from the same checkpoint, only one wins
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved := 0
+	for w := 0; w < 4; w++ {
+		store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+		assert.NoError(t, err)
+
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			err := store.SaveIfLatest(ctx, newCheckpoint(fmt.Sprintf("w%d", w), "thread-1", 2), "cp-1")
+			if err == nil {
+				mu.Lock()
+				saved++
+				mu.Unlock()
+				return
+			}
+			var conflict *graph.ConcurrentUpdateError
+			assert.ErrorAs(t, err, &conflict)
+		}(w)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, saved)
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+}
+
+func TestFileCheckpointStore_Graph(t *testing.T) {
+	dir := t.TempDir()
+	newRunnable := func() *graph.StateRunnable {
+		store, err := NewFileCheckpointStore(FileOptions{Dir: dir})
+		assert.NoError(t, err)
+
+		g := graph.NewStateGraph()
+		schema := graph.NewMapSchema()
+		schema.RegisterReducer("steps", graph.AppendReducer)
+		g.SetSchema(schema)
+		g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{"a"}}, nil
+		})
+		g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{"b"}}, nil
+		})
+		g.AddEdge("a", "b")
+		g.AddEdge("b", graph.END)
+		g.SetEntryPoint("a")
+
+		runnable, err := g.Compile(graph.WithCheckpointer(store))
+		assert.NoError(t, err)
+		return runnable
+	}
+
+	ctx := context.Background()
+	_, err := newRunnable().InvokeWithConfig(ctx, map[string]interface{}{}, &graph.Config{
+		InterruptBefore: []string{"b"},
+		Configurable:    map[string]interface{}{"thread_id": "thread-1"},
+	})
+	var interrupt *graph.GraphInterrupt
+	assert.ErrorAs(t, err, &interrupt)
+
+	// Another process picks the thread up from disk
+	result, err := newRunnable().InvokeWithConfig(ctx, nil, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, result.(map[string]interface{})["steps"])
+}
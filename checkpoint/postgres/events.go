@@ -0,0 +1,219 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+// PostgresEventLog implements graph.EventLog using PostgreSQL. Runs without
+// new events for the TTL are deleted by later appends.
+type PostgresEventLog struct {
+	pool      DBPool
+	tableName string
+	maxEvents int
+	ttl       time.Duration
+
+	pruneMutex sync.Mutex
+	prunedAt   time.Time
+}
+
+// PostgresEventLogOptions configuration for the Postgres event log
+type PostgresEventLogOptions struct {
+	ConnString string
+	TableName  string        // Default "stream_events"
+	MaxEvents  int           // Events kept per run, default graph.DefaultMaxStreamEvents
+	TTL        time.Duration // Time a run is kept after its last event, default graph.DefaultStreamEventTTL
+}
+
+// NewPostgresEventLog creates a new Postgres event log
+func NewPostgresEventLog(ctx context.Context, opts PostgresEventLogOptions) (*PostgresEventLog, error) {
+	pool, err := pgxpool.New(ctx, opts.ConnString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	log := NewPostgresEventLogWithPool(pool, opts.TableName, opts.MaxEvents)
+	if opts.TTL > 0 {
+		log.ttl = opts.TTL
+	}
+	return log, nil
+}
+
+// NewPostgresEventLogWithPool creates a new Postgres event log with an existing pool
+// Useful for testing with mocks or sharing the pool of a checkpoint store
+func NewPostgresEventLogWithPool(pool DBPool, tableName string, maxEvents int) *PostgresEventLog {
+	if tableName == "" {
+		tableName = "stream_events"
+	}
+	if maxEvents <= 0 {
+		maxEvents = graph.DefaultMaxStreamEvents
+	}
+	return &PostgresEventLog{
+		pool:      pool,
+		tableName: tableName,
+		maxEvents: maxEvents,
+		ttl:       graph.DefaultStreamEventTTL,
+	}
+}
+
+// InitSchema creates the necessary table if it doesn't exist
+func (s *PostgresEventLog) InitSchema(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			run_id TEXT NOT NULL,
+			sequence BIGINT NOT NULL,
+			event TEXT NOT NULL,
+			data JSONB NOT NULL,
+			timestamp TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (run_id, sequence)
+		);
+	`, s.tableName)
+
+	_, err := s.pool.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection pool
+func (s *PostgresEventLog) Close() {
+	s.pool.Close()
+}
+
+// Append stores an event and assigns its sequence. Appends of a run are
+// serialized with a transaction-level advisory lock on the run ID, the
+// sequence is allocated, the event inserted and the log trimmed in one
+// transaction.
+func (s *PostgresEventLog) Append(ctx context.Context, runID string, event graph.StreamEvent) (graph.StreamEvent, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return event, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // A no-op once committed
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", s.tableName+":"+runID); err != nil {
+		return event, fmt.Errorf("failed to lock run: %w", err)
+	}
+
+	var last int64
+	query := fmt.Sprintf("SELECT COALESCE(MAX(sequence), 0) FROM %s WHERE run_id = $1", s.tableName)
+	if err := tx.QueryRow(ctx, query, runID).Scan(&last); err != nil {
+		return event, fmt.Errorf("failed to read last sequence: %w", err)
+	}
+
+	event.RunID = runID
+	event.Sequence = last + 1
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return event, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	query = fmt.Sprintf(`
+		INSERT INTO %s (run_id, sequence, event, data, timestamp)
+		VALUES ($1, $2, $3, $4, $5)
+	`, s.tableName)
+	if _, err := tx.Exec(ctx, query, runID, event.Sequence, string(event.Event), data, timestamp); err != nil {
+		return event, fmt.Errorf("failed to append event: %w", err)
+	}
+
+	// Keep the log bounded
+	query = fmt.Sprintf("DELETE FROM %s WHERE run_id = $1 AND sequence <= $2", s.tableName)
+	if _, err := tx.Exec(ctx, query, runID, event.Sequence-int64(s.maxEvents)); err != nil {
+		return event, fmt.Errorf("failed to trim events: %w", err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return event, fmt.Errorf("failed to commit event: %w", err)
+	}
+
+	if err := s.pruneIfDue(ctx); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// pruneIfDue prunes the expired runs, at most ten times per TTL
+func (s *PostgresEventLog) pruneIfDue(ctx context.Context) error {
+	s.pruneMutex.Lock()
+	defer s.pruneMutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.prunedAt) < s.ttl/10 {
+		return nil
+	}
+	if err := s.Prune(ctx, now.Add(-s.ttl)); err != nil {
+		return err
+	}
+	s.prunedAt = now
+	return nil
+}
+
+// Prune deletes the events of the runs whose last event is older than before
+func (s *PostgresEventLog) Prune(ctx context.Context, before time.Time) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE run_id IN (SELECT run_id FROM %[1]s GROUP BY run_id HAVING MAX(timestamp) < $1)
+	`, s.tableName)
+	if _, err := s.pool.Exec(ctx, query, before); err != nil {
+		return fmt.Errorf("failed to prune events: %w", err)
+	}
+	return nil
+}
+
+// ReadAfter returns the events of a run after the given sequence
+func (s *PostgresEventLog) ReadAfter(ctx context.Context, runID string, afterSequence int64) ([]graph.StreamEvent, error) {
+	query := fmt.Sprintf(`
+		SELECT data
+		FROM %s
+		WHERE run_id = $1 AND sequence > $2
+		ORDER BY sequence ASC
+	`, s.tableName)
+
+	rows, err := s.pool.Query(ctx, query, runID, afterSequence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]graph.StreamEvent, 0)
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+
+		var event graph.StreamEvent
+		if err := json.Unmarshal(data, &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// Clear removes all events of a run
+func (s *PostgresEventLog) Clear(ctx context.Context, runID string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE run_id = $1", s.tableName)
+	_, err := s.pool.Exec(ctx, query, runID)
+	if err != nil {
+		return fmt.Errorf("failed to clear events: %w", err)
+	}
+	return nil
+}
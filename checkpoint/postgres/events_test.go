@@ -0,0 +1,110 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostgresEventLog_Append(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	log := NewPostgresEventLogWithPool(mock, "stream_events", 10)
+
+	timestamp := time.Now()
+	event := graph.StreamEvent{
+		Timestamp: timestamp,
+		NodeName:  "node-a",
+		Event:     graph.NodeEventComplete,
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("stream_events:run-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(sequence), 0) FROM stream_events WHERE run_id = $1")).
+		WithArgs("run-1").
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(int64(11)))
+
+	expected := event
+	expected.RunID = "run-1"
+	expected.Sequence = 12
+	data, _ := json.Marshal(expected)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO stream_events")).
+		WithArgs("run-1", int64(12), "complete", data, timestamp).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM stream_events WHERE run_id = $1 AND sequence <= $2")).
+		WithArgs("run-1", int64(2)).
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+	mock.ExpectCommit()
+	// The first append prunes the runs without events for the TTL
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM stream_events WHERE run_id IN (SELECT run_id FROM stream_events GROUP BY run_id HAVING MAX(timestamp) < $1)")).
+		WithArgs(pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("DELETE", 0))
+
+	appended, err := log.Append(context.Background(), "run-1", event)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(12), appended.Sequence)
+	assert.Equal(t, "run-1", appended.RunID)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresEventLog_AppendRollsBack(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	log := NewPostgresEventLogWithPool(mock, "stream_events", 10)
+
+	// A failed insert leaves no sequence allocated
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("stream_events:run-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT COALESCE(MAX(sequence), 0) FROM stream_events WHERE run_id = $1")).
+		WithArgs("run-1").
+		WillReturnRows(pgxmock.NewRows([]string{"max"}).AddRow(int64(0)))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO stream_events")).
+		WithArgs("run-1", int64(1), "start", pgxmock.AnyArg(), pgxmock.AnyArg()).
+		WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	_, err = log.Append(context.Background(), "run-1", graph.StreamEvent{Event: graph.NodeEventStart})
+	assert.ErrorIs(t, err, assert.AnError)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresEventLog_ReadAfter(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	log := NewPostgresEventLogWithPool(mock, "", 0)
+
+	first, _ := json.Marshal(graph.StreamEvent{RunID: "run-1", Sequence: 3, Event: graph.NodeEventStart, NodeName: "node-a"})
+	second, _ := json.Marshal(graph.StreamEvent{RunID: "run-1", Sequence: 4, Event: graph.NodeEventComplete, NodeName: "node-a"})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT data FROM stream_events WHERE run_id = $1 AND sequence > $2 ORDER BY sequence ASC")).
+		WithArgs("run-1", int64(2)).
+		WillReturnRows(pgxmock.NewRows([]string{"data"}).AddRow(first).AddRow(second))
+
+	events, err := log.ReadAfter(context.Background(), "run-1", 2)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, int64(3), events[0].Sequence)
+	assert.Equal(t, graph.NodeEventComplete, events[1].Event)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgconn"
@@ -16,19 +17,24 @@ type DBPool interface {
 	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
 	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
 	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Begin(ctx context.Context) (pgx.Tx, error)
 	Close()
 }
 
+var _ DBPool = (*pgxpool.Pool)(nil)
+
 // PostgresCheckpointStore implements graph.CheckpointStore using PostgreSQL
 type PostgresCheckpointStore struct {
-	pool      DBPool
-	tableName string
+	pool       DBPool
+	tableName  string
+	serializer graph.Serializer
 }
 
 // PostgresOptions configuration for Postgres connection
 type PostgresOptions struct {
 	ConnString string
-	TableName  string // Default "checkpoints"
+	TableName  string           // Default "checkpoints"
+	Serializer graph.Serializer // Encodes states, default graph.DefaultSerializer()
 }
 
 // NewPostgresCheckpointStore creates a new Postgres checkpoint store
@@ -38,15 +44,11 @@ func NewPostgresCheckpointStore(ctx context.Context, opts PostgresOptions) (*Pos
 		return nil, fmt.Errorf("unable to create connection pool: %w", err)
 	}
 
-	tableName := opts.TableName
-	if tableName == "" {
-		tableName = "checkpoints"
+	store := NewPostgresCheckpointStoreWithPool(pool, opts.TableName)
+	if opts.Serializer != nil {
+		store.serializer = opts.Serializer
 	}
-
-	return &PostgresCheckpointStore{
-		pool:      pool,
-		tableName: tableName,
-	}, nil
+	return store, nil
 }
 
 // NewPostgresCheckpointStoreWithPool creates a new Postgres checkpoint store with an existing pool
@@ -56,11 +58,17 @@ func NewPostgresCheckpointStoreWithPool(pool DBPool, tableName string) *Postgres
 		tableName = "checkpoints"
 	}
 	return &PostgresCheckpointStore{
-		pool:      pool,
-		tableName: tableName,
+		pool:       pool,
+		tableName:  tableName,
+		serializer: graph.DefaultSerializer(),
 	}
 }
 
+// SetSerializer sets the serializer used to encode states
+func (s *PostgresCheckpointStore) SetSerializer(serializer graph.Serializer) {
+	s.serializer = serializer
+}
+
 // InitSchema creates the necessary table if it doesn't exist
 func (s *PostgresCheckpointStore) InitSchema(ctx context.Context) error {
 	query := fmt.Sprintf(`
@@ -71,10 +79,31 @@ func (s *PostgresCheckpointStore) InitSchema(ctx context.Context) error {
 			state JSONB NOT NULL,
 			metadata JSONB,
 			timestamp TIMESTAMPTZ NOT NULL,
-			version INTEGER NOT NULL
+			version INTEGER NOT NULL,
+			next JSONB,
+			parent_id TEXT,
+			serializer TEXT,
+			state_data BYTEA
+		);
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS next JSONB;
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS parent_id TEXT;
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS serializer TEXT;
+		ALTER TABLE %[1]s ADD COLUMN IF NOT EXISTS state_data BYTEA;
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_execution_id ON %[1]s (execution_id);
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_execution_version ON %[1]s (execution_id, version DESC, timestamp DESC);
+		CREATE INDEX IF NOT EXISTS idx_%[1]s_metadata ON %[1]s USING GIN (metadata);
+		CREATE TABLE IF NOT EXISTS %[1]s_writes (
+			checkpoint_id TEXT NOT NULL REFERENCES %[1]s (id) ON DELETE CASCADE,
+			node TEXT NOT NULL,
+			value JSONB NOT NULL,
+			goto JSONB,
+			serializer TEXT,
+			value_data BYTEA,
+			PRIMARY KEY (checkpoint_id, node)
 		);
-		CREATE INDEX IF NOT EXISTS idx_%s_execution_id ON %s (execution_id);
-	`, s.tableName, s.tableName, s.tableName)
+		ALTER TABLE %[1]s_writes ADD COLUMN IF NOT EXISTS serializer TEXT;
+		ALTER TABLE %[1]s_writes ADD COLUMN IF NOT EXISTS value_data BYTEA;
+	`, s.tableName)
 
 	_, err := s.pool.Exec(ctx, query)
 	if err != nil {
@@ -90,34 +119,101 @@ func (s *PostgresCheckpointStore) Close() {
 
 // Save stores a checkpoint
 func (s *PostgresCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
-	stateJSON, err := json.Marshal(checkpoint.State)
+	args, err := s.saveArgs(checkpoint)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		return err
 	}
 
-	metadataJSON, err := json.Marshal(checkpoint.Metadata)
+	_, err = s.pool.Exec(ctx, s.saveQuery(), args...)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return fmt.Errorf("failed to save checkpoint: %w", err)
 	}
 
-	executionID := ""
-	if id, ok := checkpoint.Metadata["execution_id"].(string); ok {
-		executionID = id
+	return nil
+}
+
+// SaveIfLatest stores a checkpoint only if the latest checkpoint of its thread
+// is still expectedID. Conditional saves of a thread are serialized with a
+// transaction-level advisory lock on the thread ID.
+func (s *PostgresCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	args, err := s.saveArgs(checkpoint)
+	if err != nil {
+		return err
 	}
+	threadID, _ := checkpoint.Metadata["execution_id"].(string)
 
-	query := fmt.Sprintf(`
-		INSERT INTO %s (id, execution_id, node_name, state, metadata, timestamp, version)
-		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback(ctx) //nolint:errcheck // A no-op once committed
+
+	if _, err := tx.Exec(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", threadID); err != nil {
+		return fmt.Errorf("failed to lock thread: %w", err)
+	}
+
+	// Read after taking the lock, so the latest checkpoint includes those committed while waiting
+	var actualID string
+	query := fmt.Sprintf("SELECT id FROM %s WHERE execution_id = $1 ORDER BY version DESC, timestamp DESC LIMIT 1", s.tableName)
+	if err := tx.QueryRow(ctx, query, threadID).Scan(&actualID); err != nil && err != pgx.ErrNoRows {
+		return fmt.Errorf("failed to get latest checkpoint: %w", err)
+	}
+	if actualID != expectedID {
+		return &graph.ConcurrentUpdateError{ThreadID: threadID, ExpectedID: expectedID, ActualID: actualID}
+	}
+
+	if _, err := tx.Exec(ctx, s.saveQuery(), args...); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("failed to commit checkpoint: %w", err)
+	}
+	return nil
+}
+
+// saveQuery inserts or overwrites a checkpoint with the values of saveArgs
+func (s *PostgresCheckpointStore) saveQuery() string {
+	return fmt.Sprintf(`
+		INSERT INTO %s (id, execution_id, node_name, state, metadata, timestamp, version, next, parent_id, serializer, state_data)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		ON CONFLICT (id) DO UPDATE SET
 			execution_id = EXCLUDED.execution_id,
 			node_name = EXCLUDED.node_name,
 			state = EXCLUDED.state,
 			metadata = EXCLUDED.metadata,
 			timestamp = EXCLUDED.timestamp,
-			version = EXCLUDED.version
+			version = EXCLUDED.version,
+			next = EXCLUDED.next,
+			parent_id = EXCLUDED.parent_id,
+			serializer = EXCLUDED.serializer,
+			state_data = EXCLUDED.state_data
 	`, s.tableName)
+}
+
+// saveArgs returns the values saved for a checkpoint
+func (s *PostgresCheckpointStore) saveArgs(checkpoint *graph.Checkpoint) ([]interface{}, error) {
+	stateData, err := s.serializer.Marshal(checkpoint.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+	stateJSON, stateBinary := columnData(stateData)
 
-	_, err = s.pool.Exec(ctx, query,
+	metadataJSON, err := json.Marshal(checkpoint.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	nextJSON, err := json.Marshal(checkpoint.Next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal next nodes: %w", err)
+	}
+
+	executionID := ""
+	if id, ok := checkpoint.Metadata["execution_id"].(string); ok {
+		executionID = id
+	}
+
+	return []interface{}{
 		checkpoint.ID,
 		executionID,
 		checkpoint.NodeName,
@@ -125,46 +221,55 @@ func (s *PostgresCheckpointStore) Save(ctx context.Context, checkpoint *graph.Ch
 		metadataJSON,
 		checkpoint.Timestamp,
 		checkpoint.Version,
-	)
+		nextJSON,
+		checkpoint.ParentID,
+		s.serializer.ID(),
+		stateBinary,
+	}, nil
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to save checkpoint: %w", err)
-	}
+// checkpointColumns are the columns read by scanCheckpoint
+const checkpointColumns = "id, node_name, state, metadata, timestamp, version, next, parent_id, serializer, state_data"
 
-	return nil
+// columnData splits serialized data between the JSONB column, which keeps it
+// queryable, and the BYTEA column used for binary encodings
+func columnData(data []byte) (jsonData, binaryData []byte) {
+	if json.Valid(data) {
+		return data, nil
+	}
+	return []byte("null"), data
 }
 
-// Load retrieves a checkpoint by ID
-func (s *PostgresCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
-	query := fmt.Sprintf(`
-		SELECT id, node_name, state, metadata, timestamp, version
-		FROM %s
-		WHERE id = $1
-	`, s.tableName)
-
+// scanCheckpoint reads a checkpoint selected with checkpointColumns
+func (s *PostgresCheckpointStore) scanCheckpoint(row pgx.Row) (*graph.Checkpoint, error) {
 	var cp graph.Checkpoint
 	var stateJSON []byte
 	var metadataJSON []byte
+	var nextJSON []byte
+	var parentID *string
+	var serializer *string
+	var stateBinary []byte
 
-	err := s.pool.QueryRow(ctx, query, checkpointID).Scan(
+	if err := row.Scan(
 		&cp.ID,
 		&cp.NodeName,
 		&stateJSON,
 		&metadataJSON,
 		&cp.Timestamp,
 		&cp.Version,
-	)
-
-	if err != nil {
-		if err == pgx.ErrNoRows {
-			return nil, fmt.Errorf("checkpoint not found: %s", checkpointID)
-		}
-		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+		&nextJSON,
+		&parentID,
+		&serializer,
+		&stateBinary,
+	); err != nil {
+		return nil, err
 	}
 
-	if err := json.Unmarshal(stateJSON, &cp.State); err != nil {
+	state, err := s.deserialize(serializer, stateJSON, stateBinary)
+	if err != nil {
 		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
 	}
+	cp.State = state
 
 	if len(metadataJSON) > 0 {
 		if err := json.Unmarshal(metadataJSON, &cp.Metadata); err != nil {
@@ -172,63 +277,224 @@ func (s *PostgresCheckpointStore) Load(ctx context.Context, checkpointID string)
 		}
 	}
 
+	if len(nextJSON) > 0 {
+		if err := json.Unmarshal(nextJSON, &cp.Next); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal next nodes: %w", err)
+		}
+	}
+
+	if parentID != nil {
+		cp.ParentID = *parentID
+	}
 	return &cp, nil
 }
 
-// List returns all checkpoints for a given execution
+// deserialize decodes data stored by columnData with the recorded serializer
+func (s *PostgresCheckpointStore) deserialize(serializer *string, jsonData, binaryData []byte) (interface{}, error) {
+	id := ""
+	if serializer != nil {
+		id = *serializer
+	}
+	data := jsonData
+	if binaryData != nil {
+		data = binaryData
+	}
+	return graph.Deserialize(s.serializer, id, data)
+}
+
+// Load retrieves a checkpoint by ID
+func (s *PostgresCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = $1", checkpointColumns, s.tableName)
+
+	cp, err := s.scanCheckpoint(s.pool.QueryRow(ctx, query, checkpointID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, fmt.Errorf("checkpoint not found: %s", checkpointID)
+		}
+		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+// List returns all checkpoints for a given execution, oldest first
 func (s *PostgresCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
-	query := fmt.Sprintf(`
-		SELECT id, node_name, state, metadata, timestamp, version
-		FROM %s
-		WHERE execution_id = $1
-		ORDER BY timestamp ASC
-	`, s.tableName)
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE execution_id = $1 ORDER BY version ASC, timestamp ASC", checkpointColumns, s.tableName)
+
+	return s.query(ctx, query, executionID)
+}
+
+// ListThreads returns the IDs of the threads that have checkpoints
+func (s *PostgresCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	query := fmt.Sprintf("SELECT DISTINCT execution_id FROM %s ORDER BY execution_id", s.tableName)
+
+	rows, err := s.pool.Query(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
+	}
+	defer rows.Close()
+
+	threads := make([]string, 0)
+	for rows.Next() {
+		var threadID string
+		if err := rows.Scan(&threadID); err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
+		}
+		threads = append(threads, threadID)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating thread rows: %w", err)
+	}
+
+	return threads, nil
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *PostgresCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE execution_id = $1 ORDER BY version DESC, timestamp DESC LIMIT 1", checkpointColumns, s.tableName)
+
+	cp, err := s.scanCheckpoint(s.pool.QueryRow(ctx, query, threadID))
+	if err != nil {
+		if err == pgx.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first
+func (s *PostgresCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	conditions := []string{"execution_id = $1"}
+	args := []interface{}{threadID}
+
+	if opts.Before != "" {
+		args = append(args, opts.Before)
+		conditions = append(conditions, fmt.Sprintf("(version, timestamp) < (SELECT version, timestamp FROM %s WHERE id = $%d)", s.tableName, len(args)))
+	}
+
+	if len(opts.Metadata) > 0 {
+		filterJSON, err := json.Marshal(opts.Metadata)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata filter: %w", err)
+		}
+		args = append(args, filterJSON)
+		conditions = append(conditions, fmt.Sprintf("metadata @> $%d::jsonb", len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY version DESC, timestamp DESC", checkpointColumns, s.tableName, strings.Join(conditions, " AND "))
+
+	if opts.Limit > 0 {
+		args = append(args, opts.Limit)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	return s.query(ctx, query, args...)
+}
 
-	rows, err := s.pool.Query(ctx, query, executionID)
+// query returns the checkpoints selected with checkpointColumns
+func (s *PostgresCheckpointStore) query(ctx context.Context, query string, args ...interface{}) ([]*graph.Checkpoint, error) {
+	rows, err := s.pool.Query(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
 	}
 	defer rows.Close()
 
-	var checkpoints []*graph.Checkpoint
+	checkpoints := make([]*graph.Checkpoint, 0)
 	for rows.Next() {
-		var cp graph.Checkpoint
-		var stateJSON []byte
-		var metadataJSON []byte
-
-		err := rows.Scan(
-			&cp.ID,
-			&cp.NodeName,
-			&stateJSON,
-			&metadataJSON,
-			&cp.Timestamp,
-			&cp.Version,
-		)
+		cp, err := s.scanCheckpoint(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan checkpoint row: %w", err)
 		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating checkpoint rows: %w", err)
+	}
+
+	return checkpoints, nil
+}
+
+// PutWrites stores pending writes against a checkpoint
+func (s *PostgresCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s_writes (checkpoint_id, node, value, goto, serializer, value_data)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (checkpoint_id, node) DO UPDATE SET
+			value = EXCLUDED.value,
+			goto = EXCLUDED.goto,
+			serializer = EXCLUDED.serializer,
+			value_data = EXCLUDED.value_data
+	`, s.tableName)
+
+	for _, write := range writes {
+		valueData, err := s.serializer.Marshal(write.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
+		valueJSON, valueBinary := columnData(valueData)
 
-		if err := json.Unmarshal(stateJSON, &cp.State); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+		gotoJSON, err := json.Marshal(write.Goto)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write goto: %w", err)
+		}
+
+		if _, err := s.pool.Exec(ctx, query, checkpointID, write.Node, valueJSON, gotoJSON, s.serializer.ID(), valueBinary); err != nil {
+			return fmt.Errorf("failed to save pending write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetWrites returns the pending writes stored against a checkpoint
+func (s *PostgresCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	query := fmt.Sprintf("SELECT node, value, goto, serializer, value_data FROM %s_writes WHERE checkpoint_id = $1 ORDER BY node", s.tableName)
+
+	rows, err := s.pool.Query(ctx, query, checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending writes: %w", err)
+	}
+	defer rows.Close()
+
+	writes := make([]graph.PendingWrite, 0)
+	for rows.Next() {
+		var write graph.PendingWrite
+		var valueJSON []byte
+		var gotoJSON []byte
+		var serializer *string
+		var valueBinary []byte
+
+		if err := rows.Scan(&write.Node, &valueJSON, &gotoJSON, &serializer, &valueBinary); err != nil {
+			return nil, fmt.Errorf("failed to scan pending write: %w", err)
 		}
 
-		if len(metadataJSON) > 0 {
-			if err := json.Unmarshal(metadataJSON, &cp.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		value, err := s.deserialize(serializer, valueJSON, valueBinary)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending write: %w", err)
+		}
+		write.Value = value
+
+		if len(gotoJSON) > 0 {
+			if err := json.Unmarshal(gotoJSON, &write.Goto); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal pending write goto: %w", err)
 			}
 		}
 
-		checkpoints = append(checkpoints, &cp)
+		writes = append(writes, write)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating checkpoint rows: %w", err)
+		return nil, fmt.Errorf("error iterating pending writes: %w", err)
 	}
 
-	return checkpoints, nil
+	return writes, nil
 }
 
-// Delete removes a checkpoint
+// Delete removes a checkpoint, its pending writes are removed by the foreign key
 func (s *PostgresCheckpointStore) Delete(ctx context.Context, checkpointID string) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE id = $1", s.tableName)
 	_, err := s.pool.Exec(ctx, query, checkpointID)
@@ -238,7 +504,7 @@ func (s *PostgresCheckpointStore) Delete(ctx context.Context, checkpointID strin
 	return nil
 }
 
-// Clear removes all checkpoints for an execution
+// Clear removes all checkpoints for an execution, along with their pending writes
 func (s *PostgresCheckpointStore) Clear(ctx context.Context, executionID string) error {
 	query := fmt.Sprintf("DELETE FROM %s WHERE execution_id = $1", s.tableName)
 	_, err := s.pool.Exec(ctx, query, executionID)
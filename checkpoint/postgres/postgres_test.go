@@ -12,6 +12,8 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+var checkpointColumnNames = []string{"id", "node_name", "state", "metadata", "timestamp", "version", "next", "parent_id", "serializer", "state_data"}
+
 func TestPostgresCheckpointStore_Save(t *testing.T) {
 	mock, err := pgxmock.NewPool()
 	assert.NoError(t, err)
@@ -25,6 +27,8 @@ func TestPostgresCheckpointStore_Save(t *testing.T) {
 		State:     map[string]interface{}{"foo": "bar"},
 		Timestamp: time.Now(),
 		Version:   1,
+		Next:      []string{"node-b"},
+		ParentID:  "cp-0",
 		Metadata: map[string]interface{}{
 			"execution_id": "exec-1",
 		},
@@ -32,6 +36,7 @@ func TestPostgresCheckpointStore_Save(t *testing.T) {
 
 	stateJSON, _ := json.Marshal(cp.State)
 	metadataJSON, _ := json.Marshal(cp.Metadata)
+	nextJSON, _ := json.Marshal(cp.Next)
 
 	// Expect INSERT
 	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO checkpoints")).
@@ -43,6 +48,10 @@ func TestPostgresCheckpointStore_Save(t *testing.T) {
 			metadataJSON,
 			cp.Timestamp,
 			cp.Version,
+			nextJSON,
+			cp.ParentID,
+			graph.TypedJSONSerializerID,
+			[]byte(nil),
 		).
 		WillReturnResult(pgxmock.NewResult("INSERT", 1))
 
@@ -66,11 +75,14 @@ func TestPostgresCheckpointStore_Load(t *testing.T) {
 
 	stateJSON, _ := json.Marshal(state)
 	metadataJSON, _ := json.Marshal(metadata)
+	nextJSON, _ := json.Marshal([]string{"node-b"})
+
+	parentID := "cp-0"
 
-	rows := pgxmock.NewRows([]string{"id", "node_name", "state", "metadata", "timestamp", "version"}).
-		AddRow(cpID, "node-a", stateJSON, metadataJSON, timestamp, 1)
+	rows := pgxmock.NewRows(checkpointColumnNames).
+		AddRow(cpID, "node-a", stateJSON, metadataJSON, timestamp, 1, nextJSON, &parentID, (*string)(nil), []byte(nil))
 
-	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, node_name, state, metadata, timestamp, version FROM checkpoints WHERE id = $1")).
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, node_name, state, metadata, timestamp, version, next, parent_id, serializer, state_data FROM checkpoints WHERE id = $1")).
 		WithArgs(cpID).
 		WillReturnRows(rows)
 
@@ -79,6 +91,8 @@ func TestPostgresCheckpointStore_Load(t *testing.T) {
 	assert.Equal(t, cpID, loaded.ID)
 	assert.Equal(t, "node-a", loaded.NodeName)
 	assert.Equal(t, 1, loaded.Version)
+	assert.Equal(t, []string{"node-b"}, loaded.Next)
+	assert.Equal(t, "cp-0", loaded.ParentID)
 
 	// Check state
 	loadedState, ok := loaded.State.(map[string]interface{})
@@ -87,3 +101,224 @@ func TestPostgresCheckpointStore_Load(t *testing.T) {
 
 	assert.NoError(t, mock.ExpectationsWereMet())
 }
+
+func TestPostgresCheckpointStore_GetLatest(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+
+	stateJSON, _ := json.Marshal(map[string]interface{}{"foo": "bar"})
+	metadataJSON, _ := json.Marshal(map[string]interface{}{"execution_id": "thread-1"})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, node_name, state, metadata, timestamp, version, next, parent_id, serializer, state_data FROM checkpoints WHERE execution_id = $1 ORDER BY version DESC, timestamp DESC LIMIT 1")).
+		WithArgs("thread-1").
+		WillReturnRows(pgxmock.NewRows(checkpointColumnNames).
+			AddRow("cp-2", "node-a", stateJSON, metadataJSON, time.Now(), 2, []byte(nil), (*string)(nil), (*string)(nil), []byte(nil)))
+
+	latest, err := store.GetLatest(context.Background(), "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-2", latest.ID)
+	assert.Equal(t, 2, latest.Version)
+	assert.Empty(t, latest.ParentID)
+
+	// A thread without checkpoints has no latest one
+	mock.ExpectQuery(regexp.QuoteMeta("FROM checkpoints WHERE execution_id = $1 ORDER BY version DESC")).
+		WithArgs("thread-2").
+		WillReturnRows(pgxmock.NewRows(checkpointColumnNames))
+
+	latest, err = store.GetLatest(context.Background(), "thread-2")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCheckpointStore_ListWithOptions(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+
+	filterJSON, _ := json.Marshal(map[string]interface{}{"source": "loop"})
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id, node_name, state, metadata, timestamp, version, next, parent_id, serializer, state_data FROM checkpoints WHERE execution_id = $1 AND (version, timestamp) < (SELECT version, timestamp FROM checkpoints WHERE id = $2) AND metadata @> $3::jsonb ORDER BY version DESC, timestamp DESC LIMIT $4")).
+		WithArgs("thread-1", "cp-5", filterJSON, 2).
+		WillReturnRows(pgxmock.NewRows(checkpointColumnNames))
+
+	checkpoints, err := store.ListWithOptions(context.Background(), "thread-1", graph.CheckpointListOptions{
+		Limit:    2,
+		Before:   "cp-5",
+		Metadata: map[string]interface{}{"source": "loop"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCheckpointStore_PendingWrites(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+
+	write := graph.PendingWrite{Node: "a", Value: map[string]interface{}{"count": 1}, Goto: []string{"b"}}
+	valueJSON, _ := graph.DefaultSerializer().Marshal(write.Value)
+	gotoJSON, _ := json.Marshal(write.Goto)
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO checkpoints_writes")).
+		WithArgs("cp-1", "a", valueJSON, gotoJSON, graph.TypedJSONSerializerID, []byte(nil)).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	err = store.PutWrites(context.Background(), "cp-1", []graph.PendingWrite{write})
+	assert.NoError(t, err)
+
+	typedJSON := graph.TypedJSONSerializerID
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT node, value, goto, serializer, value_data FROM checkpoints_writes WHERE checkpoint_id = $1 ORDER BY node")).
+		WithArgs("cp-1").
+		WillReturnRows(pgxmock.NewRows([]string{"node", "value", "goto", "serializer", "value_data"}).
+			AddRow("a", valueJSON, gotoJSON, &typedJSON, []byte(nil)).
+			AddRow("c", []byte(`"done"`), []byte(nil), (*string)(nil), []byte(nil)))
+
+	writes, err := store.GetWrites(context.Background(), "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "a", Value: map[string]interface{}{"count": 1}, Goto: []string{"b"}},
+		{Node: "c", Value: "done"},
+	}, writes)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCheckpointStore_BinarySerializer(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+	store.SetSerializer(graph.GobSerializer{})
+
+	cp := &graph.Checkpoint{
+		ID:        "cp-1",
+		State:     map[string]interface{}{"count": 1},
+		Timestamp: time.Now(),
+		Version:   1,
+		Metadata:  map[string]interface{}{"execution_id": "exec-1"},
+	}
+	stateData, err := graph.GobSerializer{}.Marshal(cp.State)
+	assert.NoError(t, err)
+	metadataJSON, _ := json.Marshal(cp.Metadata)
+
+	// Binary data goes to the BYTEA column, the JSONB column holds null
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO checkpoints")).
+		WithArgs(cp.ID, "exec-1", "", []byte("null"), metadataJSON, cp.Timestamp, 1, []byte("null"), "", graph.GobSerializerID, stateData).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	assert.NoError(t, store.Save(context.Background(), cp))
+
+	gob := graph.GobSerializerID
+	mock.ExpectQuery(regexp.QuoteMeta("FROM checkpoints WHERE id = $1")).
+		WithArgs("cp-1").
+		WillReturnRows(pgxmock.NewRows(checkpointColumnNames).
+			AddRow("cp-1", "", []byte("null"), metadataJSON, cp.Timestamp, 1, []byte(nil), (*string)(nil), &gob, stateData))
+
+	loaded, err := store.Load(context.Background(), "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": 1}, loaded.State)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCheckpointStore_ListThreads(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT DISTINCT execution_id FROM checkpoints ORDER BY execution_id")).
+		WillReturnRows(pgxmock.NewRows([]string{"execution_id"}).AddRow("thread-1").AddRow("thread-2"))
+
+	threads, err := store.ListThreads(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-1", "thread-2"}, threads)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresCheckpointStore_SaveIfLatest(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresCheckpointStoreWithPool(mock, "checkpoints")
+	ctx := context.Background()
+	cp := &graph.Checkpoint{
+		ID:        "cp-2",
+		NodeName:  "node-a",
+		State:     map[string]interface{}{"foo": "bar"},
+		Timestamp: time.Now(),
+		Version:   2,
+		ParentID:  "cp-1",
+		Metadata:  map[string]interface{}{"execution_id": "exec-1"},
+	}
+
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("exec-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM checkpoints WHERE execution_id = $1")).
+		WithArgs("exec-1").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow("cp-1"))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO checkpoints")).
+		WithArgs(anyArgs(11)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.SaveIfLatest(ctx, cp, "cp-1"))
+
+	// Another worker saved cp-3 first
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("exec-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM checkpoints WHERE execution_id = $1")).
+		WithArgs("exec-1").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}).AddRow("cp-3"))
+	mock.ExpectRollback()
+
+	err = store.SaveIfLatest(ctx, cp, "cp-1")
+	var conflict *graph.ConcurrentUpdateError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, "cp-3", conflict.ActualID)
+	}
+
+	// The first checkpoint of a thread expects none
+	mock.ExpectBegin()
+	mock.ExpectExec(regexp.QuoteMeta("SELECT pg_advisory_xact_lock(hashtext($1))")).
+		WithArgs("exec-1").
+		WillReturnResult(pgxmock.NewResult("SELECT", 1))
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT id FROM checkpoints WHERE execution_id = $1")).
+		WithArgs("exec-1").
+		WillReturnRows(pgxmock.NewRows([]string{"id"}))
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO checkpoints")).
+		WithArgs(anyArgs(11)...).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+	mock.ExpectCommit()
+
+	assert.NoError(t, store.SaveIfLatest(ctx, cp, ""))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+// anyArgs matches n arguments of any value
+func anyArgs(n int) []interface{} {
+	args := make([]interface{}, n)
+	for i := range args {
+		args[i] = pgxmock.AnyArg()
+	}
+	return args
+}
@@ -0,0 +1,241 @@
+package postgres
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+var _ graph.Store = &PostgresStore{}
+
+// PostgresStore implements graph.Store using PostgreSQL
+type PostgresStore struct {
+	pool      DBPool
+	tableName string
+	index     *graph.StoreIndex
+}
+
+// PostgresStoreOptions configuration for the Postgres store
+type PostgresStoreOptions struct {
+	ConnString string
+	TableName  string // Default "store"
+
+	// Index embeds values to enable semantic search, if set
+	Index *graph.StoreIndex
+}
+
+// NewPostgresStore creates a new Postgres store
+func NewPostgresStore(ctx context.Context, opts PostgresStoreOptions) (*PostgresStore, error) {
+	pool, err := pgxpool.New(ctx, opts.ConnString)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create connection pool: %w", err)
+	}
+
+	return NewPostgresStoreWithPool(pool, opts.TableName, opts.Index), nil
+}
+
+// NewPostgresStoreWithPool creates a new Postgres store with an existing pool
+// Useful for testing with mocks or sharing the pool of a checkpoint store
+func NewPostgresStoreWithPool(pool DBPool, tableName string, index *graph.StoreIndex) *PostgresStore {
+	if tableName == "" {
+		tableName = "store"
+	}
+	return &PostgresStore{
+		pool:      pool,
+		tableName: tableName,
+		index:     index,
+	}
+}
+
+// InitSchema creates the necessary table if it doesn't exist
+func (s *PostgresStore) InitSchema(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			namespace TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value JSONB NOT NULL,
+			embedding DOUBLE PRECISION[],
+			created_at TIMESTAMPTZ NOT NULL,
+			updated_at TIMESTAMPTZ NOT NULL,
+			PRIMARY KEY (namespace, key)
+		);
+	`, s.tableName)
+
+	_, err := s.pool.Exec(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	return nil
+}
+
+// Close closes the connection pool
+func (s *PostgresStore) Close() {
+	s.pool.Close()
+}
+
+// Put creates or replaces an item
+func (s *PostgresStore) Put(ctx context.Context, namespace []string, key string, value map[string]interface{}) error {
+	if err := graph.ValidateNamespace(namespace); err != nil {
+		return err
+	}
+	nsKey, _ := graph.NamespaceKey(namespace)
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	embedding, err := s.index.Embed(ctx, value)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (namespace, key, value, embedding, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $5)
+		ON CONFLICT (namespace, key) DO UPDATE SET
+			value = EXCLUDED.value,
+			embedding = EXCLUDED.embedding,
+			updated_at = EXCLUDED.updated_at
+	`, s.tableName)
+
+	if _, err := s.pool.Exec(ctx, query, nsKey, key, valueJSON, embedding, time.Now()); err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+// itemColumns are the columns read by scanItem
+const itemColumns = "namespace, key, value, embedding, created_at, updated_at"
+
+// scanItem reads an item selected with itemColumns, and its embedding
+func scanItem(row pgx.Row) (*graph.Item, []float64, error) {
+	var item graph.Item
+	var nsKey string
+	var valueJSON []byte
+	var embedding []float64
+
+	if err := row.Scan(&nsKey, &item.Key, &valueJSON, &embedding, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return nil, nil, err
+	}
+	item.Namespace = graph.SplitNamespaceKey(nsKey)
+
+	if err := json.Unmarshal(valueJSON, &item.Value); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+	return &item, embedding, nil
+}
+
+// Get returns an item, or nil if it does not exist
+func (s *PostgresStore) Get(ctx context.Context, namespace []string, key string) (*graph.Item, error) {
+	nsKey, err := graph.NamespaceKey(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE namespace = $1 AND key = $2", itemColumns, s.tableName)
+	item, _, err := scanItem(s.pool.QueryRow(ctx, query, nsKey, key))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	return item, nil
+}
+
+// Delete removes an item
+func (s *PostgresStore) Delete(ctx context.Context, namespace []string, key string) error {
+	nsKey, err := graph.NamespaceKey(namespace)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE namespace = $1 AND key = $2", s.tableName)
+	if _, err := s.pool.Exec(ctx, query, nsKey, key); err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+// Search returns the items of the namespaces starting with prefix. Filters
+// and ordering by update time run in Postgres, similarity to a query is
+// computed on the matching items.
+func (s *PostgresStore) Search(ctx context.Context, prefix []string, opts graph.SearchOptions) ([]*graph.Item, error) {
+	prefixKey, err := graph.NamespaceKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var queryEmbedding []float64
+	if opts.Query != "" {
+		queryEmbedding, err = s.index.EmbedQuery(ctx, opts.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conditions := []string{"TRUE"}
+	var args []interface{}
+
+	if prefixKey != "" {
+		args = append(args, prefixKey, prefixKey+".")
+		conditions = append(conditions, fmt.Sprintf("(namespace = $%d OR starts_with(namespace, $%d))", len(args)-1, len(args)))
+	}
+
+	if len(opts.Filter) > 0 {
+		filterJSON, err := json.Marshal(opts.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal filter: %w", err)
+		}
+		args = append(args, filterJSON)
+		conditions = append(conditions, fmt.Sprintf("value @> $%d::jsonb", len(args)))
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE %s ORDER BY updated_at DESC, namespace, key", itemColumns, s.tableName, strings.Join(conditions, " AND "))
+
+	// Semantic searches rank every matching item
+	if queryEmbedding == nil {
+		limit := opts.Limit
+		if limit <= 0 {
+			limit = graph.DefaultSearchLimit
+		}
+		args = append(args, limit, opts.Offset)
+		query += fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]*graph.Item, 0)
+	var embeddings [][]float64
+	for rows.Next() {
+		item, embedding, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+		embeddings = append(embeddings, embedding)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating item rows: %w", err)
+	}
+
+	if queryEmbedding == nil {
+		return items, nil
+	}
+	// The filter was applied by the query
+	opts.Filter = nil
+	return graph.SearchItems(items, embeddings, queryEmbedding, opts), nil
+}
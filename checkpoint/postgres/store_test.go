@@ -0,0 +1,147 @@
+package postgres
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/pashagolub/pgxmock/v3"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+var itemColumnNames = []string{"namespace", "key", "value", "embedding", "created_at", "updated_at"}
+
+// fixedEmbedder embeds every text as the same vector
+type fixedEmbedder []float64
+
+func (e fixedEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i := range texts {
+		embeddings[i] = e
+	}
+	return embeddings, nil
+}
+
+func (e fixedEmbedder) EmbedQuery(ctx context.Context, text string) ([]float64, error) {
+	return e, nil
+}
+
+func TestPostgresStore_Put(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresStoreWithPool(mock, "", &graph.StoreIndex{Embedder: fixedEmbedder{1, 0}})
+
+	mock.ExpectExec(regexp.QuoteMeta("INSERT INTO store (namespace, key, value, embedding, created_at, updated_at)")).
+		WithArgs("users.u1", "theme", []byte(`{"mode":"dark"}`), []float64{1, 0}, pgxmock.AnyArg()).
+		WillReturnResult(pgxmock.NewResult("INSERT", 1))
+
+	err = store.Put(context.Background(), []string{"users", "u1"}, "theme", map[string]interface{}{"mode": "dark"})
+	assert.NoError(t, err)
+	assert.Error(t, store.Put(context.Background(), []string{"users.u1"}, "theme", nil))
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_Get(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresStoreWithPool(mock, "store", nil)
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT namespace, key, value, embedding, created_at, updated_at FROM store WHERE namespace = $1 AND key = $2")).
+		WithArgs("users.u1", "theme").
+		WillReturnRows(pgxmock.NewRows(itemColumnNames).
+			AddRow("users.u1", "theme", []byte(`{"mode":"dark"}`), []float64(nil), now, now))
+
+	item, err := store.Get(context.Background(), []string{"users", "u1"}, "theme")
+	assert.NoError(t, err)
+	assert.Equal(t, &graph.Item{
+		Namespace: []string{"users", "u1"},
+		Key:       "theme",
+		Value:     map[string]interface{}{"mode": "dark"},
+		CreatedAt: now,
+		UpdatedAt: now,
+	}, item)
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT namespace, key, value, embedding, created_at, updated_at FROM store")).
+		WithArgs("users.u1", "missing").
+		WillReturnRows(pgxmock.NewRows(itemColumnNames))
+
+	item, err = store.Get(context.Background(), []string{"users", "u1"}, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_Search(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresStoreWithPool(mock, "store", nil)
+	now := time.Now()
+
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT namespace, key, value, embedding, created_at, updated_at FROM store WHERE TRUE AND (namespace = $1 OR starts_with(namespace, $2)) AND value @> $3::jsonb ORDER BY updated_at DESC, namespace, key LIMIT $4 OFFSET $5")).
+		WithArgs("users", "users.", []byte(`{"kind":"drink"}`), graph.DefaultSearchLimit, 0).
+		WillReturnRows(pgxmock.NewRows(itemColumnNames).
+			AddRow("users.u1.facts", "f1", []byte(`{"kind":"drink"}`), []float64(nil), now, now))
+
+	items, err := store.Search(context.Background(), []string{"users"}, graph.SearchOptions{
+		Filter: map[string]interface{}{"kind": "drink"},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, items, 1)
+	assert.Equal(t, []string{"users", "u1", "facts"}, items[0].Namespace)
+
+	_, err = store.Search(context.Background(), nil, graph.SearchOptions{Query: "coffee"})
+	assert.ErrorIs(t, err, graph.ErrStoreNotIndexed)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_SemanticSearch(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresStoreWithPool(mock, "store", &graph.StoreIndex{Embedder: fixedEmbedder{1, 0}})
+	now := time.Now()
+
+	// Every matching item is ranked, then paged
+	mock.ExpectQuery(regexp.QuoteMeta("SELECT namespace, key, value, embedding, created_at, updated_at FROM store WHERE TRUE ORDER BY updated_at DESC, namespace, key")).
+		WillReturnRows(pgxmock.NewRows(itemColumnNames).
+			AddRow("facts", "far", []byte(`{}`), []float64{0, 1}, now, now).
+			AddRow("facts", "near", []byte(`{}`), []float64{2, 1}, now, now).
+			AddRow("facts", "same", []byte(`{}`), []float64{3, 0}, now, now))
+
+	items, err := store.Search(context.Background(), nil, graph.SearchOptions{Query: "coffee", Limit: 2})
+	assert.NoError(t, err)
+	assert.Len(t, items, 2)
+	assert.Equal(t, "same", items[0].Key)
+	assert.Equal(t, "near", items[1].Key)
+	assert.InDelta(t, 1.0, items[0].Score, 1e-9)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestPostgresStore_Delete(t *testing.T) {
+	mock, err := pgxmock.NewPool()
+	assert.NoError(t, err)
+	defer mock.Close()
+
+	store := NewPostgresStoreWithPool(mock, "store", nil)
+
+	mock.ExpectExec(regexp.QuoteMeta("DELETE FROM store WHERE namespace = $1 AND key = $2")).
+		WithArgs("users.u1", "theme").
+		WillReturnResult(pgxmock.NewResult("DELETE", 1))
+
+	assert.NoError(t, store.Delete(context.Background(), []string{"users", "u1"}, "theme"))
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
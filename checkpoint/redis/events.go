@@ -0,0 +1,135 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+// RedisEventLog implements graph.EventLog using Redis sorted sets scored by sequence
+type RedisEventLog struct {
+	client    *redis.Client
+	prefix    string
+	ttl       time.Duration
+	maxEvents int
+}
+
+// RedisEventLogOptions configuration for the Redis event log
+type RedisEventLogOptions struct {
+	Addr      string
+	Password  string
+	DB        int
+	Prefix    string        // Key prefix, default "langgraph:"
+	TTL       time.Duration // Expiration for a run's events after its last event, default graph.DefaultStreamEventTTL
+	MaxEvents int           // Events kept per run, default graph.DefaultMaxStreamEvents
+}
+
+// NewRedisEventLog creates a new Redis event log
+func NewRedisEventLog(opts RedisEventLogOptions) *RedisEventLog {
+	client := redis.NewClient(&redis.Options{
+		Addr:     opts.Addr,
+		Password: opts.Password,
+		DB:       opts.DB,
+	})
+
+	prefix := opts.Prefix
+	if prefix == "" {
+		prefix = "langgraph:"
+	}
+
+	maxEvents := opts.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = graph.DefaultMaxStreamEvents
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = graph.DefaultStreamEventTTL
+	}
+
+	return &RedisEventLog{
+		client:    client,
+		prefix:    prefix,
+		ttl:       ttl,
+		maxEvents: maxEvents,
+	}
+}
+
+func (s *RedisEventLog) eventsKey(runID string) string {
+	return fmt.Sprintf("%sevents:%s", s.prefix, runID)
+}
+
+func (s *RedisEventLog) sequenceKey(runID string) string {
+	return fmt.Sprintf("%sevents:%s:sequence", s.prefix, runID)
+}
+
+// Append stores an event and assigns its sequence
+func (s *RedisEventLog) Append(ctx context.Context, runID string, event graph.StreamEvent) (graph.StreamEvent, error) {
+	sequence, err := s.client.Incr(ctx, s.sequenceKey(runID)).Result()
+	if err != nil {
+		return event, fmt.Errorf("failed to allocate event sequence: %w", err)
+	}
+
+	event.RunID = runID
+	event.Sequence = sequence
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return event, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	key := s.eventsKey(runID)
+	pipe := s.client.Pipeline()
+
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(sequence), Member: data})
+	// Keep the log bounded by dropping the lowest sequences
+	pipe.ZRemRangeByRank(ctx, key, 0, int64(-s.maxEvents-1))
+	pipe.Expire(ctx, key, s.ttl)
+	pipe.Expire(ctx, s.sequenceKey(runID), s.ttl)
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return event, fmt.Errorf("failed to append event to redis: %w", err)
+	}
+
+	return event, nil
+}
+
+// ReadAfter returns the events of a run after the given sequence
+func (s *RedisEventLog) ReadAfter(ctx context.Context, runID string, afterSequence int64) ([]graph.StreamEvent, error) {
+	members, err := s.client.ZRangeByScore(ctx, s.eventsKey(runID), &redis.ZRangeBy{
+		Min: "(" + strconv.FormatInt(afterSequence, 10),
+		Max: "+inf",
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events for run %s: %w", runID, err)
+	}
+
+	events := make([]graph.StreamEvent, 0, len(members))
+	for _, member := range members {
+		var event graph.StreamEvent
+		if err := json.Unmarshal([]byte(member), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	return events, nil
+}
+
+// Clear removes all events of a run
+func (s *RedisEventLog) Clear(ctx context.Context, runID string) error {
+	if err := s.client.Del(ctx, s.eventsKey(runID), s.sequenceKey(runID)).Err(); err != nil {
+		return fmt.Errorf("failed to clear events: %w", err)
+	}
+	return nil
+}
+
+// Close closes the Redis client
+func (s *RedisEventLog) Close() error {
+	return s.client.Close()
+}
@@ -0,0 +1,80 @@
+package redis
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRedisEventLog(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	log := NewRedisEventLog(RedisEventLogOptions{
+		Addr:      mr.Addr(),
+		MaxEvents: 3,
+		TTL:       time.Hour,
+	})
+	defer log.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		event, err := log.Append(ctx, "run-1", graph.StreamEvent{
+			Timestamp: time.Now(),
+			NodeName:  "node-a",
+			Event:     graph.NodeEventComplete,
+			State:     map[string]interface{}{"step": float64(i)},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i+1), event.Sequence)
+	}
+
+	// Bounded to the last 3 events, in order
+	events, err := log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(3), events[0].Sequence)
+	assert.Equal(t, int64(5), events[2].Sequence)
+	state, ok := events[2].State.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, float64(4), state["step"])
+
+	events, err = log.ReadAfter(ctx, "run-1", 4)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(5), events[0].Sequence)
+
+	assert.True(t, mr.TTL("langgraph:events:run-1") > 0)
+
+	// Clear also resets the sequence
+	assert.NoError(t, log.Clear(ctx, "run-1"))
+	events, err = log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	event, err := log.Append(ctx, "run-1", graph.StreamEvent{Event: graph.NodeEventStart})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), event.Sequence)
+}
+
+func TestRedisEventLog_DefaultTTL(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	log := NewRedisEventLog(RedisEventLogOptions{Addr: mr.Addr()})
+	defer log.Close()
+
+	_, err = log.Append(context.Background(), "run-1", graph.StreamEvent{Event: graph.NodeEventStart})
+	assert.NoError(t, err)
+
+	// Runs expire even when no TTL is configured
+	assert.Equal(t, graph.DefaultStreamEventTTL, mr.TTL("langgraph:events:run-1"))
+	assert.Equal(t, graph.DefaultStreamEventTTL, mr.TTL("langgraph:events:run-1:sequence"))
+}
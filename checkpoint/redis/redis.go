@@ -4,6 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/smallnest/langgraphgo/graph"
@@ -12,9 +14,10 @@ import (
 
 // RedisCheckpointStore implements graph.CheckpointStore using Redis
 type RedisCheckpointStore struct {
-	client *redis.Client
-	prefix string
-	ttl    time.Duration
+	client     *redis.Client
+	prefix     string
+	ttl        time.Duration
+	serializer graph.Serializer
 }
 
 // RedisOptions configuration for Redis connection
@@ -24,6 +27,9 @@ type RedisOptions struct {
 	DB       int
 	Prefix   string        // Key prefix, default "langgraph:"
 	TTL      time.Duration // Expiration for checkpoints, default 0 (no expiration)
+
+	// Serializer encodes states, default graph.DefaultSerializer()
+	Serializer graph.Serializer
 }
 
 // NewRedisCheckpointStore creates a new Redis checkpoint store
@@ -39,26 +45,96 @@ func NewRedisCheckpointStore(opts RedisOptions) *RedisCheckpointStore {
 		prefix = "langgraph:"
 	}
 
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
 	return &RedisCheckpointStore{
-		client: client,
-		prefix: prefix,
-		ttl:    opts.TTL,
+		client:     client,
+		prefix:     prefix,
+		ttl:        opts.TTL,
+		serializer: serializer,
+	}
+}
+
+// record is the document stored for a checkpoint. The state is encoded by
+// the serializer into Data; documents written before serializers were
+// recorded hold it as plain JSON in the state field of the checkpoint.
+type record struct {
+	*graph.Checkpoint
+	Serializer string `json:"serializer,omitempty"`
+	Data       []byte `json:"data,omitempty"`
+}
+
+// writeRecord is the document stored for a pending write, encoded like record
+type writeRecord struct {
+	graph.PendingWrite
+	Serializer string `json:"serializer,omitempty"`
+	Data       []byte `json:"data,omitempty"`
+}
+
+// marshal encodes a checkpoint into a record
+func (s *RedisCheckpointStore) marshal(checkpoint *graph.Checkpoint) ([]byte, error) {
+	data, err := s.serializer.Marshal(checkpoint.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	stored := *checkpoint
+	stored.State = nil
+	encoded, err := json.Marshal(record{Checkpoint: &stored, Serializer: s.serializer.ID(), Data: data})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal checkpoint: %w", err)
 	}
+	return encoded, nil
+}
+
+// unmarshal decodes a record
+func (s *RedisCheckpointStore) unmarshal(data []byte) (*graph.Checkpoint, error) {
+	stored := record{Checkpoint: &graph.Checkpoint{}}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+
+	if stored.Serializer != "" {
+		state, err := graph.Deserialize(s.serializer, stored.Serializer, stored.Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+		}
+		stored.Checkpoint.State = state
+	}
+	return stored.Checkpoint, nil
 }
 
 func (s *RedisCheckpointStore) checkpointKey(id string) string {
 	return fmt.Sprintf("%scheckpoint:%s", s.prefix, id)
 }
 
+// writesKey is the hash of pending writes of a checkpoint, by node
+func (s *RedisCheckpointStore) writesKey(id string) string {
+	return fmt.Sprintf("%scheckpoint:%s:writes", s.prefix, id)
+}
+
+// executionKey is the set that indexed checkpoints before history was sorted.
+// It is still read so that existing data stays reachable.
 func (s *RedisCheckpointStore) executionKey(id string) string {
 	return fmt.Sprintf("%sexecution:%s:checkpoints", s.prefix, id)
 }
 
+// historyKey is the sorted set of checkpoint IDs of an execution, scored by version
+func (s *RedisCheckpointStore) historyKey(id string) string {
+	return fmt.Sprintf("%sexecution:%s:history", s.prefix, id)
+}
+
+// listBatchSize is the number of checkpoints fetched per round trip when filtering
+const listBatchSize = 100
+
 // Save stores a checkpoint
 func (s *RedisCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
-	data, err := json.Marshal(checkpoint)
+	data, err := s.marshal(checkpoint)
 	if err != nil {
-		return fmt.Errorf("failed to marshal checkpoint: %w", err)
+		return err
 	}
 
 	key := s.checkpointKey(checkpoint.ID)
@@ -68,10 +144,10 @@ func (s *RedisCheckpointStore) Save(ctx context.Context, checkpoint *graph.Check
 
 	// Index by execution ID if present
 	if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && execID != "" {
-		execKey := s.executionKey(execID)
-		pipe.SAdd(ctx, execKey, checkpoint.ID)
+		historyKey := s.historyKey(execID)
+		pipe.ZAdd(ctx, historyKey, redis.Z{Score: float64(checkpoint.Version), Member: checkpoint.ID})
 		if s.ttl > 0 {
-			pipe.Expire(ctx, execKey, s.ttl)
+			pipe.Expire(ctx, historyKey, s.ttl)
 		}
 	}
 
@@ -83,6 +159,62 @@ func (s *RedisCheckpointStore) Save(ctx context.Context, checkpoint *graph.Check
 	return nil
 }
 
+// saveIfLatestScript saves a checkpoint only if the expected one is still the
+// newest of the history of its execution. Checkpoints sharing the newest
+// version are ordered by timestamp outside the index, so any of them matches.
+// KEYS: checkpoint, history. ARGV: expected ID, data, version, ID, TTL in milliseconds.
+var saveIfLatestScript = redis.NewScript(`
+local newest = redis.call('ZREVRANGE', KEYS[2], 0, 0, 'WITHSCORES')
+local latest = newest[1] or ''
+if latest ~= ARGV[1] and (ARGV[1] == '' or redis.call('ZSCORE', KEYS[2], ARGV[1]) ~= newest[2]) then
+	return {0, latest}
+end
+
+local ttl = tonumber(ARGV[5])
+if ttl > 0 then
+	redis.call('SET', KEYS[1], ARGV[2], 'PX', ttl)
+else
+	redis.call('SET', KEYS[1], ARGV[2])
+end
+redis.call('ZADD', KEYS[2], ARGV[3], ARGV[4])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[2], ttl)
+end
+return {1, ARGV[4]}
+`)
+
+// SaveIfLatest stores a checkpoint only if the latest checkpoint of its
+// execution is still expectedID. The check and the writes run atomically in
+// a Lua script. Checkpoints without an execution ID are saved unconditionally.
+func (s *RedisCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	execID, _ := checkpoint.Metadata["execution_id"].(string)
+	if execID == "" {
+		return s.Save(ctx, checkpoint)
+	}
+
+	data, err := s.marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+
+	if err := s.indexLegacy(ctx, execID); err != nil {
+		return fmt.Errorf("failed to save checkpoint to redis: %w", err)
+	}
+
+	keys := []string{s.checkpointKey(checkpoint.ID), s.historyKey(execID)}
+	result, err := saveIfLatestScript.Run(ctx, s.client, keys,
+		expectedID, data, checkpoint.Version, checkpoint.ID, s.ttl.Milliseconds()).Slice()
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint to redis: %w", err)
+	}
+
+	if saved, _ := result[0].(int64); saved == 0 {
+		actualID, _ := result[1].(string)
+		return &graph.ConcurrentUpdateError{ThreadID: execID, ExpectedID: expectedID, ActualID: actualID}
+	}
+	return nil
+}
+
 // Load retrieves a checkpoint by ID
 func (s *RedisCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
 	key := s.checkpointKey(checkpointID)
@@ -94,64 +226,303 @@ func (s *RedisCheckpointStore) Load(ctx context.Context, checkpointID string) (*
 		return nil, fmt.Errorf("failed to load checkpoint from redis: %w", err)
 	}
 
-	var checkpoint graph.Checkpoint
-	if err := json.Unmarshal(data, &checkpoint); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
-	}
-
-	return &checkpoint, nil
+	return s.unmarshal(data)
 }
 
-// List returns all checkpoints for a given execution
+// List returns all checkpoints for a given execution, oldest first
 func (s *RedisCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
-	execKey := s.executionKey(executionID)
-	checkpointIDs, err := s.client.SMembers(ctx, execKey).Result()
+	checkpointIDs, err := s.checkpointIDs(ctx, executionID)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list checkpoints for execution %s: %w", executionID, err)
 	}
 
+	checkpoints, err := s.fetch(ctx, checkpointIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// Sort newest first, then reverse
+	graph.SortCheckpoints(checkpoints)
+	for i, j := 0, len(checkpoints)-1; i < j; i, j = i+1, j-1 {
+		checkpoints[i], checkpoints[j] = checkpoints[j], checkpoints[i]
+	}
+	return checkpoints, nil
+}
+
+// ListThreads returns the IDs of the threads that have checkpoints
+func (s *RedisCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	seen := make(map[string]bool)
+	threads := make([]string, 0)
+	executionPrefix := s.prefix + "execution:"
+	for _, suffix := range []string{":history", ":checkpoints"} {
+		iter := s.client.Scan(ctx, 0, executionPrefix+"*"+suffix, listBatchSize).Iterator()
+		for iter.Next(ctx) {
+			threadID := strings.TrimSuffix(strings.TrimPrefix(iter.Val(), executionPrefix), suffix)
+			if !seen[threadID] {
+				seen[threadID] = true
+				threads = append(threads, threadID)
+			}
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to list threads: %w", err)
+		}
+	}
+
+	sort.Strings(threads)
+	return threads, nil
+}
+
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *RedisCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
+	checkpoints, err := s.ListWithOptions(ctx, threadID, graph.CheckpointListOptions{Limit: 1})
+	if err != nil {
+		return nil, err
+	}
+	if len(checkpoints) == 0 {
+		return nil, nil
+	}
+	return checkpoints[0], nil
+}
+
+// indexLegacy moves the checkpoints of an execution indexed by the legacy set
+// into the sorted history, so that history queries and conditional saves see
+// executions saved before the sorted index
+func (s *RedisCheckpointStore) indexLegacy(ctx context.Context, executionID string) error {
+	legacyIDs, err := s.client.SMembers(ctx, s.executionKey(executionID)).Result()
+	if err != nil || len(legacyIDs) == 0 {
+		return err
+	}
+
+	checkpoints, err := s.fetch(ctx, legacyIDs)
+	if err != nil {
+		return err
+	}
+
+	historyKey := s.historyKey(executionID)
+	pipe := s.client.TxPipeline()
+	if len(checkpoints) > 0 {
+		members := make([]redis.Z, 0, len(checkpoints))
+		for _, checkpoint := range checkpoints {
+			members = append(members, redis.Z{Score: float64(checkpoint.Version), Member: checkpoint.ID})
+		}
+		pipe.ZAdd(ctx, historyKey, members...)
+		if s.ttl > 0 {
+			pipe.Expire(ctx, historyKey, s.ttl)
+		}
+	}
+	pipe.Del(ctx, s.executionKey(executionID))
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to index legacy checkpoints: %w", err)
+	}
+	return nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first.
+// Metadata filters are applied while walking the sorted index in batches.
+// The index is scored by version, so checkpoints sharing a version are
+// collected and ordered by timestamp before they are returned.
+func (s *RedisCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	maxScore := "+inf"
+	var before *graph.Checkpoint
+	if opts.Before != "" {
+		var err error
+		before, err = s.Load(ctx, opts.Before)
+		if err != nil {
+			return nil, err
+		}
+		// Inclusive, the checkpoints of the same version older than the cursor are kept
+		maxScore = fmt.Sprintf("%d", before.Version)
+	}
+
+	if err := s.indexLegacy(ctx, threadID); err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints for execution %s: %w", threadID, err)
+	}
+
+	checkpoints := make([]*graph.Checkpoint, 0)
+	var group []*graph.Checkpoint
+
+	// flush adds the checkpoints of a version, and reports whether the limit is reached
+	flush := func() bool {
+		graph.SortCheckpoints(group)
+		for _, checkpoint := range group {
+			if before != nil && !olderThan(checkpoint, before) {
+				continue
+			}
+			if !graph.MetadataMatches(checkpoint.Metadata, opts.Metadata) {
+				continue
+			}
+			checkpoints = append(checkpoints, checkpoint)
+			if opts.Limit > 0 && len(checkpoints) == opts.Limit {
+				return true
+			}
+		}
+		group = group[:0]
+		return false
+	}
+
+	var offset int64
+	for {
+		checkpointIDs, err := s.client.ZRevRangeByScore(ctx, s.historyKey(threadID), &redis.ZRangeBy{
+			Min:    "-inf",
+			Max:    maxScore,
+			Offset: offset,
+			Count:  listBatchSize,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to list checkpoints for execution %s: %w", threadID, err)
+		}
+		offset += int64(len(checkpointIDs))
+
+		batch, err := s.fetch(ctx, checkpointIDs)
+		if err != nil {
+			return nil, err
+		}
+		for _, checkpoint := range batch {
+			if len(group) > 0 && group[0].Version != checkpoint.Version && flush() {
+				return checkpoints, nil
+			}
+			group = append(group, checkpoint)
+		}
+
+		if len(checkpointIDs) < listBatchSize {
+			flush()
+			return checkpoints, nil
+		}
+	}
+}
+
+// olderThan reports whether a checkpoint comes before the cursor in the
+// history of a thread, ordered by version then timestamp like graph.SortCheckpoints
+func olderThan(checkpoint, cursor *graph.Checkpoint) bool {
+	if checkpoint.Version != cursor.Version {
+		return checkpoint.Version < cursor.Version
+	}
+	return cursor.Timestamp.After(checkpoint.Timestamp)
+}
+
+// checkpointIDs returns the IDs indexed for an execution
+func (s *RedisCheckpointStore) checkpointIDs(ctx context.Context, executionID string) ([]string, error) {
+	checkpointIDs, err := s.client.ZRange(ctx, s.historyKey(executionID), 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	legacyIDs, err := s.client.SMembers(ctx, s.executionKey(executionID)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(checkpointIDs))
+	for _, id := range checkpointIDs {
+		seen[id] = true
+	}
+	for _, id := range legacyIDs {
+		if !seen[id] {
+			checkpointIDs = append(checkpointIDs, id)
+		}
+	}
+	return checkpointIDs, nil
+}
+
+// fetch loads checkpoints by ID in order, skipping the expired ones
+func (s *RedisCheckpointStore) fetch(ctx context.Context, checkpointIDs []string) ([]*graph.Checkpoint, error) {
+	checkpoints := make([]*graph.Checkpoint, 0, len(checkpointIDs))
 	if len(checkpointIDs) == 0 {
-		return []*graph.Checkpoint{}, nil
+		return checkpoints, nil
 	}
 
-	// Fetch all checkpoints
-	var keys []string
+	keys := make([]string, 0, len(checkpointIDs))
 	for _, id := range checkpointIDs {
 		keys = append(keys, s.checkpointKey(id))
 	}
 
-	// MGet might fail if some keys are missing (expired), so we handle them individually or filter results
-	// But MGet returns nil for missing keys, which is fine.
+	// MGet returns nil for missing (expired) keys
 	results, err := s.client.MGet(ctx, keys...).Result()
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch checkpoints: %w", err)
 	}
 
-	var checkpoints []*graph.Checkpoint
-	for i, result := range results {
-		if result == nil {
-			continue
-		}
-
+	for _, result := range results {
 		strData, ok := result.(string)
 		if !ok {
 			continue
 		}
 
-		var checkpoint graph.Checkpoint
-		if err := json.Unmarshal([]byte(strData), &checkpoint); err != nil {
-			// Log error or skip? Skipping for now
+		checkpoint, err := s.unmarshal([]byte(strData))
+		if err != nil {
+			// Skip corrupted entries
 			continue
 		}
-		checkpoints = append(checkpoints, &checkpoint)
+		checkpoints = append(checkpoints, checkpoint)
+	}
+
+	return checkpoints, nil
+}
+
+// PutWrites stores pending writes against a checkpoint
+func (s *RedisCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	if len(writes) == 0 {
+		return nil
+	}
+
+	values := make([]interface{}, 0, len(writes)*2)
+	for _, write := range writes {
+		value, err := s.serializer.Marshal(write.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
 
-		// Sanity check ID
-		if checkpoint.ID != checkpointIDs[i] {
-			// Should not happen if order is preserved
+		write.Value = nil
+		data, err := json.Marshal(writeRecord{PendingWrite: write, Serializer: s.serializer.ID(), Data: value})
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
 		}
+		values = append(values, write.Node, data)
 	}
 
-	return checkpoints, nil
+	key := s.writesKey(checkpointID)
+	pipe := s.client.Pipeline()
+	pipe.HSet(ctx, key, values...)
+	if s.ttl > 0 {
+		pipe.Expire(ctx, key, s.ttl)
+	}
+
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save pending writes to redis: %w", err)
+	}
+	return nil
+}
+
+// GetWrites returns the pending writes stored against a checkpoint
+func (s *RedisCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	entries, err := s.client.HGetAll(ctx, s.writesKey(checkpointID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending writes from redis: %w", err)
+	}
+
+	nodes := make([]string, 0, len(entries))
+	for node := range entries {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	writes := make([]graph.PendingWrite, 0, len(nodes))
+	for _, node := range nodes {
+		var stored writeRecord
+		if err := json.Unmarshal([]byte(entries[node]), &stored); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending write: %w", err)
+		}
+
+		if stored.Serializer != "" {
+			value, err := graph.Deserialize(s.serializer, stored.Serializer, stored.Data)
+			if err != nil {
+				return nil, fmt.Errorf("failed to unmarshal pending write: %w", err)
+			}
+			stored.Value = value
+		}
+		writes = append(writes, stored.PendingWrite)
+	}
+	return writes, nil
 }
 
 // Delete removes a checkpoint
@@ -165,11 +536,11 @@ func (s *RedisCheckpointStore) Delete(ctx context.Context, checkpointID string)
 	key := s.checkpointKey(checkpointID)
 	pipe := s.client.Pipeline()
 
-	pipe.Del(ctx, key)
+	pipe.Del(ctx, key, s.writesKey(checkpointID))
 
 	if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && execID != "" {
-		execKey := s.executionKey(execID)
-		pipe.SRem(ctx, execKey, checkpointID)
+		pipe.ZRem(ctx, s.historyKey(execID), checkpointID)
+		pipe.SRem(ctx, s.executionKey(execID), checkpointID)
 	}
 
 	_, err = pipe.Exec(ctx)
@@ -182,8 +553,7 @@ func (s *RedisCheckpointStore) Delete(ctx context.Context, checkpointID string)
 
 // Clear removes all checkpoints for an execution
 func (s *RedisCheckpointStore) Clear(ctx context.Context, executionID string) error {
-	execKey := s.executionKey(executionID)
-	checkpointIDs, err := s.client.SMembers(ctx, execKey).Result()
+	checkpointIDs, err := s.checkpointIDs(ctx, executionID)
 	if err != nil {
 		return fmt.Errorf("failed to get checkpoints for clearing: %w", err)
 	}
@@ -194,13 +564,13 @@ func (s *RedisCheckpointStore) Clear(ctx context.Context, executionID string) er
 
 	pipe := s.client.Pipeline()
 
-	// Delete all checkpoint keys
+	// Delete all checkpoint keys and their pending writes
 	for _, id := range checkpointIDs {
-		pipe.Del(ctx, s.checkpointKey(id))
+		pipe.Del(ctx, s.checkpointKey(id), s.writesKey(id))
 	}
 
-	// Delete execution index
-	pipe.Del(ctx, execKey)
+	// Delete execution indexes
+	pipe.Del(ctx, s.historyKey(executionID), s.executionKey(executionID))
 
 	_, err = pipe.Exec(ctx)
 	if err != nil {
@@ -2,12 +2,14 @@ package redis
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/alicebob/miniredis/v2"
 	"github.com/smallnest/langgraphgo/graph"
 	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
 )
 
 func TestRedisCheckpointStore(t *testing.T) {
@@ -86,3 +88,319 @@ func TestRedisCheckpointStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, list, 0)
 }
+
+func TestRedisCheckpointStore_History(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		source := "loop"
+		if i%2 == 0 {
+			source = "update"
+		}
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("cp-%d", i),
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Version:   i,
+			Metadata: map[string]interface{}{
+				"execution_id": "thread-1",
+				"source":       source,
+			},
+		})
+		assert.NoError(t, err)
+	}
+
+	latest, err = store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-5", latest.ID)
+
+	page, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2, Before: "cp-5"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-4", "cp-3"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "update"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-4", "cp-2"}, checkpointIDs(page))
+
+	list, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3", "cp-4", "cp-5"}, checkpointIDs(list))
+
+	// Executions indexed by the legacy set are still listed
+	mr.SAdd("langgraph:execution:thread-1:checkpoints", "cp-0")
+	_ = mr.Set("langgraph:checkpoint:cp-0", `{"id":"cp-0","version":0,"metadata":{"execution_id":"thread-1"}}`)
+	list, err = store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, list, 6)
+	assert.Equal(t, "cp-0", list[0].ID)
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	list, err = store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Empty(t, list)
+}
+
+func checkpointIDs(checkpoints []*graph.Checkpoint) []string {
+	ids := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		ids = append(ids, checkpoint.ID)
+	}
+	return ids
+}
+
+func TestRedisCheckpointStore_PendingWrites(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	err = store.Save(ctx, &graph.Checkpoint{
+		ID:       "cp-1",
+		Version:  1,
+		Next:     []string{"a", "b"},
+		Metadata: map[string]interface{}{"execution_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{
+		{Node: "b", Value: "done", Goto: []string{"c"}},
+		{Node: "a", Value: "first"},
+	})
+	assert.NoError(t, err)
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "a", Value: "second"}})
+	assert.NoError(t, err)
+
+	writes, err := store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "a", Value: "second"},
+		{Node: "b", Value: "done", Goto: []string{"c"}},
+	}, writes)
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	writes, err = store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+}
+
+func TestRedisCheckpointStore_Serializer(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	ctx := context.Background()
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "hello"),
+		},
+		"count": 2,
+	}
+
+	for _, serializer := range []graph.Serializer{graph.DefaultSerializer(), graph.GobSerializer{}} {
+		store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr(), Serializer: serializer})
+
+		id := "cp-" + serializer.ID()
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			State:     state,
+			Timestamp: time.Now(),
+			Version:   1,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		})
+		assert.NoError(t, err)
+
+		loaded, err := store.Load(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, state, loaded.State)
+	}
+
+	// Documents are decoded by the serializer that wrote them
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr(), Serializer: graph.JSONSerializer{}})
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	for _, checkpoint := range checkpoints {
+		assert.Equal(t, state, checkpoint.State)
+	}
+
+	// Documents written before serializers were recorded hold plain JSON
+	mr.Set("langgraph:checkpoint:legacy", `{"id":"legacy","state":{"count":1},"version":1}`)
+	loaded, err := store.Load(ctx, "legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": float64(1)}, loaded.State)
+}
+
+func TestRedisCheckpointStore_ListThreads(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr()})
+	ctx := context.Background()
+	for i, threadID := range []string{"thread:2", "thread:1", "thread:2"} {
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("cp-%d", i),
+			State:     map[string]interface{}{},
+			Timestamp: time.Now(),
+			Version:   i + 1,
+			Metadata:  map[string]interface{}{"execution_id": threadID},
+		})
+		assert.NoError(t, err)
+	}
+	// Threads indexed before history was sorted
+	mr.SAdd("langgraph:execution:legacy:checkpoints", "cp-old")
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"legacy", "thread:1", "thread:2"}, threads)
+
+	// Threads without checkpoints are no longer listed
+	assert.NoError(t, store.Delete(ctx, "cp-1"))
+	threads, err = store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"legacy", "thread:2"}, threads)
+}
+
+func TestRedisCheckpointStore_SaveIfLatest(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr(), TTL: time.Hour})
+	ctx := context.Background()
+	newCheckpoint := func(id string, version int) *graph.Checkpoint {
+		return &graph.Checkpoint{
+			ID:        id,
+			State:     map[string]interface{}{"id": id},
+			Timestamp: time.Now(),
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		}
+	}
+
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-1", 1), ""))
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-2", 2), "cp-1"))
+
+	err = store.SaveIfLatest(ctx, newCheckpoint("cp-3", 3), "cp-1")
+	var conflict *graph.ConcurrentUpdateError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, graph.ConcurrentUpdateError{ThreadID: "thread-1", ExpectedID: "cp-1", ActualID: "cp-2"}, *conflict)
+	}
+	assert.False(t, mr.Exists("langgraph:checkpoint:cp-3"))
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-2", latest.ID)
+	assert.Equal(t, map[string]interface{}{"id": "cp-2"}, latest.State)
+
+	assert.True(t, mr.TTL("langgraph:checkpoint:cp-2") > 0)
+	assert.True(t, mr.TTL("langgraph:execution:thread-1:history") > 0)
+}
+
+func TestRedisCheckpointStore_SaveIfLatestLegacyIndex(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr()})
+	ctx := context.Background()
+
+	// A thread saved before the sorted index only has the set
+	mr.SAdd("langgraph:execution:thread-1:checkpoints", "cp-1", "cp-2")
+	_ = mr.Set("langgraph:checkpoint:cp-1", `{"id":"cp-1","version":1,"metadata":{"execution_id":"thread-1"}}`)
+	_ = mr.Set("langgraph:checkpoint:cp-2", `{"id":"cp-2","version":2,"metadata":{"execution_id":"thread-1"}}`)
+
+	err = store.SaveIfLatest(ctx, &graph.Checkpoint{
+		ID:        "cp-3",
+		State:     map[string]interface{}{},
+		Timestamp: time.Now(),
+		Version:   3,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	}, "")
+	var conflict *graph.ConcurrentUpdateError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, "cp-2", conflict.ActualID)
+	}
+
+	// Resuming from the latest legacy checkpoint succeeds
+	assert.NoError(t, store.SaveIfLatest(ctx, &graph.Checkpoint{
+		ID:        "cp-3",
+		State:     map[string]interface{}{},
+		Timestamp: time.Now(),
+		Version:   3,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	}, "cp-2"))
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-3", latest.ID)
+
+	list, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3"}, checkpointIDs(list))
+	assert.False(t, mr.Exists("langgraph:execution:thread-1:checkpoints"))
+}
+
+func TestRedisCheckpointStore_SameVersionPagination(t *testing.T) {
+	mr, err := miniredis.Run()
+	assert.NoError(t, err)
+	defer mr.Close()
+
+	store := NewRedisCheckpointStore(RedisOptions{Addr: mr.Addr()})
+	ctx := context.Background()
+	now := time.Now()
+	save := func(id string, version int, offset time.Duration) {
+		assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			State:     map[string]interface{}{},
+			Timestamp: now.Add(offset),
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		}))
+	}
+
+	// A manual save shares the version of the automatic ones, IDs don't sort by time
+	save("cp-1", 1, time.Second)
+	save("cp-2c", 2, 2*time.Second)
+	save("cp-2a", 2, 3*time.Second)
+	save("cp-2b", 2, 4*time.Second)
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-2b", latest.ID)
+
+	// The latest of checkpoints sharing a version may be extended
+	assert.NoError(t, store.SaveIfLatest(ctx, &graph.Checkpoint{
+		ID:        "cp-3",
+		State:     map[string]interface{}{},
+		Timestamp: now.Add(5 * time.Second),
+		Version:   3,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	}, "cp-2b"))
+
+	var pages [][]string
+	before := ""
+	for {
+		page, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2, Before: before})
+		assert.NoError(t, err)
+		if len(page) == 0 {
+			break
+		}
+		pages = append(pages, checkpointIDs(page))
+		before = page[len(page)-1].ID
+	}
+	assert.Equal(t, [][]string{{"cp-3", "cp-2b"}, {"cp-2a", "cp-2c"}, {"cp-1"}}, pages)
+}
@@ -0,0 +1,219 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+// SqliteEventLog implements graph.EventLog using SQLite. Runs without new
+// events for the TTL are deleted by later appends.
+type SqliteEventLog struct {
+	db        *sql.DB
+	tableName string
+	maxEvents int
+	ttl       time.Duration
+
+	pruneMutex sync.Mutex
+	prunedAt   time.Time
+}
+
+// SqliteEventLogOptions configuration for the SQLite event log
+type SqliteEventLogOptions struct {
+	Path      string
+	TableName string        // Default "stream_events"
+	MaxEvents int           // Events kept per run, default graph.DefaultMaxStreamEvents
+	TTL       time.Duration // Time a run is kept after its last event, default graph.DefaultStreamEventTTL
+}
+
+// NewSqliteEventLog creates a new SQLite event log
+func NewSqliteEventLog(opts SqliteEventLogOptions) (*SqliteEventLog, error) {
+	db, err := sql.Open("sqlite3", opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+
+	tableName := opts.TableName
+	if tableName == "" {
+		tableName = "stream_events"
+	}
+
+	maxEvents := opts.MaxEvents
+	if maxEvents <= 0 {
+		maxEvents = graph.DefaultMaxStreamEvents
+	}
+
+	ttl := opts.TTL
+	if ttl <= 0 {
+		ttl = graph.DefaultStreamEventTTL
+	}
+
+	log := &SqliteEventLog{
+		db:        db,
+		tableName: tableName,
+		maxEvents: maxEvents,
+		ttl:       ttl,
+	}
+
+	if err := log.InitSchema(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return log, nil
+}
+
+// InitSchema creates the necessary table if it doesn't exist
+func (s *SqliteEventLog) InitSchema(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			run_id TEXT NOT NULL,
+			sequence INTEGER NOT NULL,
+			event TEXT NOT NULL,
+			data TEXT NOT NULL,
+			timestamp DATETIME NOT NULL,
+			PRIMARY KEY (run_id, sequence)
+		);
+	`, s.tableName)
+
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (s *SqliteEventLog) Close() error {
+	return s.db.Close()
+}
+
+// Append stores an event and assigns its sequence
+func (s *SqliteEventLog) Append(ctx context.Context, runID string, event graph.StreamEvent) (graph.StreamEvent, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return event, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback() //nolint:errcheck // Rollback after Commit is a no-op
+
+	var last int64
+	query := fmt.Sprintf("SELECT COALESCE(MAX(sequence), 0) FROM %s WHERE run_id = ?", s.tableName)
+	if err := tx.QueryRowContext(ctx, query, runID).Scan(&last); err != nil {
+		return event, fmt.Errorf("failed to read last sequence: %w", err)
+	}
+
+	event.RunID = runID
+	event.Sequence = last + 1
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return event, fmt.Errorf("failed to marshal event: %w", err)
+	}
+
+	// Timestamps are stored in UTC so that they compare as text
+	timestamp := event.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	query = fmt.Sprintf(`
+		INSERT INTO %s (run_id, sequence, event, data, timestamp)
+		VALUES (?, ?, ?, ?, ?)
+	`, s.tableName)
+	if _, err := tx.ExecContext(ctx, query, runID, event.Sequence, string(event.Event), string(data), timestamp.UTC()); err != nil {
+		return event, fmt.Errorf("failed to append event: %w", err)
+	}
+
+	// Keep the log bounded
+	query = fmt.Sprintf("DELETE FROM %s WHERE run_id = ? AND sequence <= ?", s.tableName)
+	if _, err := tx.ExecContext(ctx, query, runID, event.Sequence-int64(s.maxEvents)); err != nil {
+		return event, fmt.Errorf("failed to trim events: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return event, fmt.Errorf("failed to commit event: %w", err)
+	}
+
+	if err := s.pruneIfDue(ctx); err != nil {
+		return event, err
+	}
+	return event, nil
+}
+
+// pruneIfDue prunes the expired runs, at most ten times per TTL
+func (s *SqliteEventLog) pruneIfDue(ctx context.Context) error {
+	s.pruneMutex.Lock()
+	defer s.pruneMutex.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.prunedAt) < s.ttl/10 {
+		return nil
+	}
+	if err := s.Prune(ctx, now.Add(-s.ttl)); err != nil {
+		return err
+	}
+	s.prunedAt = now
+	return nil
+}
+
+// Prune deletes the events of the runs whose last event is older than before
+func (s *SqliteEventLog) Prune(ctx context.Context, before time.Time) error {
+	query := fmt.Sprintf(`
+		DELETE FROM %[1]s
+		WHERE run_id IN (SELECT run_id FROM %[1]s GROUP BY run_id HAVING MAX(timestamp) < ?)
+	`, s.tableName)
+	if _, err := s.db.ExecContext(ctx, query, before.UTC()); err != nil {
+		return fmt.Errorf("failed to prune events: %w", err)
+	}
+	return nil
+}
+
+// ReadAfter returns the events of a run after the given sequence
+func (s *SqliteEventLog) ReadAfter(ctx context.Context, runID string, afterSequence int64) ([]graph.StreamEvent, error) {
+	query := fmt.Sprintf(`
+		SELECT data
+		FROM %s
+		WHERE run_id = ? AND sequence > ?
+		ORDER BY sequence ASC
+	`, s.tableName)
+
+	rows, err := s.db.QueryContext(ctx, query, runID, afterSequence)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]graph.StreamEvent, 0)
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to scan event row: %w", err)
+		}
+
+		var event graph.StreamEvent
+		if err := json.Unmarshal([]byte(data), &event); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal event: %w", err)
+		}
+		events = append(events, event)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating event rows: %w", err)
+	}
+
+	return events, nil
+}
+
+// Clear removes all events of a run
+func (s *SqliteEventLog) Clear(ctx context.Context, runID string) error {
+	query := fmt.Sprintf("DELETE FROM %s WHERE run_id = ?", s.tableName)
+	_, err := s.db.ExecContext(ctx, query, runID)
+	if err != nil {
+		return fmt.Errorf("failed to clear events: %w", err)
+	}
+	return nil
+}
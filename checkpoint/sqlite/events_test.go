@@ -0,0 +1,99 @@
+package sqlite
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSqliteEventLog(t *testing.T) {
+	log, err := NewSqliteEventLog(SqliteEventLogOptions{
+		Path:      filepath.Join(t.TempDir(), "events.db"),
+		MaxEvents: 3,
+	})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		event, err := log.Append(ctx, "run-1", graph.StreamEvent{
+			Timestamp: time.Now(),
+			NodeName:  "node-a",
+			Event:     graph.NodeEventComplete,
+			State:     map[string]interface{}{"foo": "bar"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i+1), event.Sequence)
+	}
+
+	// Bounded to the last 3 events
+	events, err := log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(3), events[0].Sequence)
+	assert.Equal(t, "run-1", events[0].RunID)
+	assert.Equal(t, "node-a", events[0].NodeName)
+	state, ok := events[0].State.(map[string]interface{})
+	assert.True(t, ok)
+	assert.Equal(t, "bar", state["foo"])
+
+	events, err = log.ReadAfter(ctx, "run-1", 4)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(5), events[0].Sequence)
+
+	// Errors survive as messages
+	event, err := log.Append(ctx, "run-2", graph.StreamEvent{
+		Timestamp: time.Now(),
+		Event:     graph.NodeEventError,
+		Error:     errors.New("boom"),
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), event.Sequence)
+
+	events, err = log.ReadAfter(ctx, "run-2", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.EqualError(t, events[0].Error, "boom")
+
+	// Clear
+	assert.NoError(t, log.Clear(ctx, "run-1"))
+	events, err = log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestSqliteEventLog_TTL(t *testing.T) {
+	log, err := NewSqliteEventLog(SqliteEventLogOptions{
+		Path: filepath.Join(t.TempDir(), "events.db"),
+		TTL:  time.Minute,
+	})
+	assert.NoError(t, err)
+	defer log.Close()
+
+	ctx := context.Background()
+
+	_, err = log.Append(ctx, "run-old", graph.StreamEvent{Timestamp: time.Now().Add(-time.Hour), Event: graph.NodeEventStart})
+	assert.NoError(t, err)
+	_, err = log.Append(ctx, "run-new", graph.StreamEvent{Timestamp: time.Now(), Event: graph.NodeEventStart})
+	assert.NoError(t, err)
+
+	// Appends delete the runs without events for the TTL
+	events, err := log.ReadAfter(ctx, "run-old", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	events, err = log.ReadAfter(ctx, "run-new", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	assert.NoError(t, log.Prune(ctx, time.Now().Add(time.Second)))
+	events, err = log.ReadAfter(ctx, "run-new", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
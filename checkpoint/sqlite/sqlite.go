@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"sort"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/smallnest/langgraphgo/graph"
@@ -12,14 +14,16 @@ import (
 
 // SqliteCheckpointStore implements graph.CheckpointStore using SQLite
 type SqliteCheckpointStore struct {
-	db        *sql.DB
-	tableName string
+	db         *sql.DB
+	tableName  string
+	serializer graph.Serializer
 }
 
 // SqliteOptions configuration for SQLite connection
 type SqliteOptions struct {
-	Path      string
-	TableName string // Default "checkpoints"
+	Path       string
+	TableName  string           // Default "checkpoints"
+	Serializer graph.Serializer // Encodes states, default graph.DefaultSerializer()
 }
 
 // NewSqliteCheckpointStore creates a new SQLite checkpoint store
@@ -34,9 +38,15 @@ func NewSqliteCheckpointStore(opts SqliteOptions) (*SqliteCheckpointStore, error
 		tableName = "checkpoints"
 	}
 
+	serializer := opts.Serializer
+	if serializer == nil {
+		serializer = graph.DefaultSerializer()
+	}
+
 	store := &SqliteCheckpointStore{
-		db:        db,
-		tableName: tableName,
+		db:         db,
+		tableName:  tableName,
+		serializer: serializer,
 	}
 
 	if err := store.InitSchema(context.Background()); err != nil {
@@ -57,18 +67,82 @@ func (s *SqliteCheckpointStore) InitSchema(ctx context.Context) error {
 			state TEXT NOT NULL,
 			metadata TEXT,
 			timestamp DATETIME NOT NULL,
-			version INTEGER NOT NULL
+			version INTEGER NOT NULL,
+			next TEXT,
+			parent_id TEXT,
+			serializer TEXT
 		);
 		CREATE INDEX IF NOT EXISTS idx_%s_execution_id ON %s (execution_id);
-	`, s.tableName, s.tableName, s.tableName)
+		CREATE INDEX IF NOT EXISTS idx_%s_execution_version ON %s (execution_id, version, timestamp);
+		CREATE TABLE IF NOT EXISTS %s (
+			checkpoint_id TEXT NOT NULL,
+			node TEXT NOT NULL,
+			value TEXT NOT NULL,
+			goto TEXT,
+			serializer TEXT,
+			PRIMARY KEY (checkpoint_id, node)
+		);
+	`, s.tableName, s.tableName, s.tableName, s.tableName, s.tableName, s.writesTable())
 
 	_, err := s.db.ExecContext(ctx, query)
 	if err != nil {
 		return fmt.Errorf("failed to create schema: %w", err)
 	}
+
+	// Tables created by older versions lack the newer columns
+	for _, column := range []struct{ table, name string }{
+		{s.tableName, "next"},
+		{s.tableName, "parent_id"},
+		{s.tableName, "serializer"},
+		{s.writesTable(), "serializer"},
+	} {
+		if err := s.addColumnIfMissing(ctx, column.table, column.name, "TEXT"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addColumnIfMissing adds a column to an existing table
+func (s *SqliteCheckpointStore) addColumnIfMissing(ctx context.Context, table, column, definition string) error {
+	rows, err := s.db.QueryContext(ctx, fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return fmt.Errorf("failed to read table info: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			columnType string
+			notNull    int
+			defaultVal sql.NullString
+			primaryKey int
+		)
+		if err := rows.Scan(&cid, &name, &columnType, &notNull, &defaultVal, &primaryKey); err != nil {
+			return fmt.Errorf("failed to scan table info: %w", err)
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating table info: %w", err)
+	}
+
+	query := fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition)
+	if _, err := s.db.ExecContext(ctx, query); err != nil {
+		return fmt.Errorf("failed to add column %s: %w", column, err)
+	}
 	return nil
 }
 
+// writesTable is the table holding pending writes
+func (s *SqliteCheckpointStore) writesTable() string {
+	return s.tableName + "_writes"
+}
+
 // Close closes the database connection
 func (s *SqliteCheckpointStore) Close() error {
 	return s.db.Close()
@@ -76,71 +150,187 @@ func (s *SqliteCheckpointStore) Close() error {
 
 // Save stores a checkpoint
 func (s *SqliteCheckpointStore) Save(ctx context.Context, checkpoint *graph.Checkpoint) error {
-	stateJSON, err := json.Marshal(checkpoint.State)
+	args, err := s.saveArgs(checkpoint)
 	if err != nil {
-		return fmt.Errorf("failed to marshal state: %w", err)
+		return err
 	}
 
-	metadataJSON, err := json.Marshal(checkpoint.Metadata)
+	query := fmt.Sprintf(`
+		INSERT INTO %s (%s)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		%s
+	`, s.tableName, saveColumns, saveConflict)
+
+	_, err = s.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return fmt.Errorf("failed to marshal metadata: %w", err)
+		return fmt.Errorf("failed to save checkpoint: %w", err)
 	}
 
-	executionID := ""
-	if id, ok := checkpoint.Metadata["execution_id"].(string); ok {
-		executionID = id
+	return nil
+}
+
+// SaveIfLatest stores a checkpoint only if the latest checkpoint of its thread
+// is still expectedID. The check and the insert run as a single statement,
+// which SQLite executes under its write lock.
+func (s *SqliteCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	args, err := s.saveArgs(checkpoint)
+	if err != nil {
+		return err
 	}
+	threadID, _ := checkpoint.Metadata["execution_id"].(string)
 
 	query := fmt.Sprintf(`
-		INSERT INTO %s (id, execution_id, node_name, state, metadata, timestamp, version)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-		ON CONFLICT(id) DO UPDATE SET
+		INSERT INTO %[1]s (%[2]s)
+		SELECT ?, ?, ?, ?, ?, ?, ?, ?, ?, ?
+		WHERE COALESCE((
+			SELECT id FROM %[1]s
+			WHERE execution_id = ?
+			ORDER BY version DESC, timestamp DESC
+			LIMIT 1
+		), '') = ?
+		%[3]s
+	`, s.tableName, saveColumns, saveConflict)
+
+	result, err := s.db.ExecContext(ctx, query, append(args, threadID, expectedID)...)
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	saved, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+	if saved > 0 {
+		return nil
+	}
+
+	latest, err := s.GetLatest(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	actualID := ""
+	if latest != nil {
+		actualID = latest.ID
+	}
+	return &graph.ConcurrentUpdateError{ThreadID: threadID, ExpectedID: expectedID, ActualID: actualID}
+}
+
+// saveColumns are the columns written by saveArgs
+const saveColumns = "id, execution_id, node_name, state, metadata, timestamp, version, next, parent_id, serializer"
+
+// saveConflict overwrites a checkpoint saved again with the same ID
+const saveConflict = `ON CONFLICT(id) DO UPDATE SET
 			execution_id = excluded.execution_id,
 			node_name = excluded.node_name,
 			state = excluded.state,
 			metadata = excluded.metadata,
 			timestamp = excluded.timestamp,
-			version = excluded.version
-	`, s.tableName)
+			version = excluded.version,
+			next = excluded.next,
+			parent_id = excluded.parent_id,
+			serializer = excluded.serializer`
+
+// saveArgs returns the values of saveColumns for a checkpoint
+func (s *SqliteCheckpointStore) saveArgs(checkpoint *graph.Checkpoint) ([]interface{}, error) {
+	stateData, err := s.serializer.Marshal(checkpoint.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal state: %w", err)
+	}
+
+	metadataJSON, err := json.Marshal(checkpoint.Metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	nextJSON, err := json.Marshal(checkpoint.Next)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal next nodes: %w", err)
+	}
 
-	_, err = s.db.ExecContext(ctx, query,
+	executionID := ""
+	if id, ok := checkpoint.Metadata["execution_id"].(string); ok {
+		executionID = id
+	}
+
+	return []interface{}{
 		checkpoint.ID,
 		executionID,
 		checkpoint.NodeName,
-		string(stateJSON),
+		storedData(stateData),
 		string(metadataJSON),
 		checkpoint.Timestamp,
 		checkpoint.Version,
-	)
+		string(nextJSON),
+		checkpoint.ParentID,
+		s.serializer.ID(),
+	}, nil
+}
 
-	if err != nil {
-		return fmt.Errorf("failed to save checkpoint: %w", err)
-	}
+// checkpointColumns are the columns read by scanCheckpoint
+const checkpointColumns = "id, node_name, state, metadata, timestamp, version, next, parent_id, serializer"
 
-	return nil
+// rowScanner is implemented by *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
 }
 
-// Load retrieves a checkpoint by ID
-func (s *SqliteCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
-	query := fmt.Sprintf(`
-		SELECT id, node_name, state, metadata, timestamp, version
-		FROM %s
-		WHERE id = ?
-	`, s.tableName)
+// storedData keeps serialized JSON readable in the database, binary data is stored as a BLOB
+func storedData(data []byte) interface{} {
+	if json.Valid(data) {
+		return string(data)
+	}
+	return data
+}
 
+// scanCheckpoint reads a checkpoint selected with checkpointColumns
+func (s *SqliteCheckpointStore) scanCheckpoint(row rowScanner) (*graph.Checkpoint, error) {
 	var cp graph.Checkpoint
-	var stateJSON string
-	var metadataJSON string
+	var stateData []byte
+	var metadataJSON sql.NullString
+	var nextJSON sql.NullString
+	var parentID sql.NullString
+	var serializer sql.NullString
 
-	err := s.db.QueryRowContext(ctx, query, checkpointID).Scan(
+	if err := row.Scan(
 		&cp.ID,
 		&cp.NodeName,
-		&stateJSON,
+		&stateData,
 		&metadataJSON,
 		&cp.Timestamp,
 		&cp.Version,
-	)
+		&nextJSON,
+		&parentID,
+		&serializer,
+	); err != nil {
+		return nil, err
+	}
+
+	state, err := graph.Deserialize(s.serializer, serializer.String, stateData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	}
+	cp.State = state
+
+	if metadataJSON.Valid && metadataJSON.String != "" {
+		if err := json.Unmarshal([]byte(metadataJSON.String), &cp.Metadata); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	if nextJSON.Valid && nextJSON.String != "" {
+		if err := json.Unmarshal([]byte(nextJSON.String), &cp.Next); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal next nodes: %w", err)
+		}
+	}
 
+	cp.ParentID = parentID.String
+	return &cp, nil
+}
+
+// Load retrieves a checkpoint by ID
+func (s *SqliteCheckpointStore) Load(ctx context.Context, checkpointID string) (*graph.Checkpoint, error) {
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", checkpointColumns, s.tableName)
+
+	cp, err := s.scanCheckpoint(s.db.QueryRowContext(ctx, query, checkpointID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, fmt.Errorf("checkpoint not found: %s", checkpointID)
@@ -148,70 +338,203 @@ func (s *SqliteCheckpointStore) Load(ctx context.Context, checkpointID string) (
 		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
 	}
 
-	if err := json.Unmarshal([]byte(stateJSON), &cp.State); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+	return cp, nil
+}
+
+// List returns all checkpoints for a given execution, oldest first
+func (s *SqliteCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE execution_id = ?
+		ORDER BY version ASC, timestamp ASC
+	`, checkpointColumns, s.tableName)
+
+	return s.query(ctx, query, executionID)
+}
+
+// ListThreads returns the IDs of the threads that have checkpoints
+func (s *SqliteCheckpointStore) ListThreads(ctx context.Context) ([]string, error) {
+	query := fmt.Sprintf("SELECT DISTINCT execution_id FROM %s ORDER BY execution_id", s.tableName)
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list threads: %w", err)
 	}
+	defer rows.Close()
 
-	if len(metadataJSON) > 0 {
-		if err := json.Unmarshal([]byte(metadataJSON), &cp.Metadata); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+	threads := make([]string, 0)
+	for rows.Next() {
+		var threadID string
+		if err := rows.Scan(&threadID); err != nil {
+			return nil, fmt.Errorf("failed to scan thread: %w", err)
 		}
+		threads = append(threads, threadID)
 	}
 
-	return &cp, nil
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating thread rows: %w", err)
+	}
+
+	return threads, nil
 }
 
-// List returns all checkpoints for a given execution
-func (s *SqliteCheckpointStore) List(ctx context.Context, executionID string) ([]*graph.Checkpoint, error) {
+// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+func (s *SqliteCheckpointStore) GetLatest(ctx context.Context, threadID string) (*graph.Checkpoint, error) {
 	query := fmt.Sprintf(`
-		SELECT id, node_name, state, metadata, timestamp, version
+		SELECT %s
 		FROM %s
 		WHERE execution_id = ?
-		ORDER BY timestamp ASC
-	`, s.tableName)
+		ORDER BY version DESC, timestamp DESC
+		LIMIT 1
+	`, checkpointColumns, s.tableName)
+
+	cp, err := s.scanCheckpoint(s.db.QueryRowContext(ctx, query, threadID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get latest checkpoint: %w", err)
+	}
+
+	return cp, nil
+}
+
+// ListWithOptions returns the checkpoints of a thread, newest first
+func (s *SqliteCheckpointStore) ListWithOptions(ctx context.Context, threadID string, opts graph.CheckpointListOptions) ([]*graph.Checkpoint, error) {
+	conditions := []string{"execution_id = ?"}
+	args := []interface{}{threadID}
+
+	if opts.Before != "" {
+		conditions = append(conditions, fmt.Sprintf("(version, timestamp) < (SELECT version, timestamp FROM %s WHERE id = ?)", s.tableName))
+		args = append(args, opts.Before)
+	}
+
+	// Compare the JSON values so that numbers, strings and booleans match as encoded
+	keys := make([]string, 0, len(opts.Metadata))
+	for key := range opts.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		valueJSON, err := json.Marshal(opts.Metadata[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal metadata filter: %w", err)
+		}
+		conditions = append(conditions, "json_extract(metadata, '$.' || json_quote(?)) = json_extract(?, '$')")
+		args = append(args, key, string(valueJSON))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY version DESC, timestamp DESC
+	`, checkpointColumns, s.tableName, strings.Join(conditions, " AND "))
 
-	rows, err := s.db.QueryContext(ctx, query, executionID)
+	if opts.Limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, opts.Limit)
+	}
+
+	return s.query(ctx, query, args...)
+}
+
+// query returns the checkpoints selected with checkpointColumns
+func (s *SqliteCheckpointStore) query(ctx context.Context, query string, args ...interface{}) ([]*graph.Checkpoint, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
 	}
 	defer rows.Close()
 
-	var checkpoints []*graph.Checkpoint
+	checkpoints := make([]*graph.Checkpoint, 0)
 	for rows.Next() {
-		var cp graph.Checkpoint
-		var stateJSON string
-		var metadataJSON string
-
-		err := rows.Scan(
-			&cp.ID,
-			&cp.NodeName,
-			&stateJSON,
-			&metadataJSON,
-			&cp.Timestamp,
-			&cp.Version,
-		)
+		cp, err := s.scanCheckpoint(rows)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan checkpoint row: %w", err)
 		}
+		checkpoints = append(checkpoints, cp)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating checkpoint rows: %w", err)
+	}
+
+	return checkpoints, nil
+}
+
+// PutWrites stores pending writes against a checkpoint
+func (s *SqliteCheckpointStore) PutWrites(ctx context.Context, checkpointID string, writes []graph.PendingWrite) error {
+	query := fmt.Sprintf(`
+		INSERT INTO %s (checkpoint_id, node, value, goto, serializer)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT(checkpoint_id, node) DO UPDATE SET
+			value = excluded.value,
+			goto = excluded.goto,
+			serializer = excluded.serializer
+	`, s.writesTable())
+
+	for _, write := range writes {
+		valueData, err := s.serializer.Marshal(write.Value)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write: %w", err)
+		}
+
+		gotoJSON, err := json.Marshal(write.Goto)
+		if err != nil {
+			return fmt.Errorf("failed to marshal pending write goto: %w", err)
+		}
+
+		if _, err := s.db.ExecContext(ctx, query, checkpointID, write.Node, storedData(valueData), string(gotoJSON), s.serializer.ID()); err != nil {
+			return fmt.Errorf("failed to save pending write: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// GetWrites returns the pending writes stored against a checkpoint
+func (s *SqliteCheckpointStore) GetWrites(ctx context.Context, checkpointID string) ([]graph.PendingWrite, error) {
+	query := fmt.Sprintf("SELECT node, value, goto, serializer FROM %s WHERE checkpoint_id = ? ORDER BY node", s.writesTable())
+
+	rows, err := s.db.QueryContext(ctx, query, checkpointID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending writes: %w", err)
+	}
+	defer rows.Close()
+
+	writes := make([]graph.PendingWrite, 0)
+	for rows.Next() {
+		var write graph.PendingWrite
+		var valueData []byte
+		var gotoJSON sql.NullString
+		var serializer sql.NullString
 
-		if err := json.Unmarshal([]byte(stateJSON), &cp.State); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal state: %w", err)
+		if err := rows.Scan(&write.Node, &valueData, &gotoJSON, &serializer); err != nil {
+			return nil, fmt.Errorf("failed to scan pending write: %w", err)
 		}
 
-		if len(metadataJSON) > 0 {
-			if err := json.Unmarshal([]byte(metadataJSON), &cp.Metadata); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		value, err := graph.Deserialize(s.serializer, serializer.String, valueData)
+		if err != nil {
+			return nil, fmt.Errorf("failed to unmarshal pending write: %w", err)
+		}
+		write.Value = value
+
+		if gotoJSON.Valid && gotoJSON.String != "" {
+			if err := json.Unmarshal([]byte(gotoJSON.String), &write.Goto); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal pending write goto: %w", err)
 			}
 		}
 
-		checkpoints = append(checkpoints, &cp)
+		writes = append(writes, write)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating checkpoint rows: %w", err)
+		return nil, fmt.Errorf("error iterating pending writes: %w", err)
 	}
 
-	return checkpoints, nil
+	return writes, nil
 }
 
 // Delete removes a checkpoint
@@ -221,13 +544,25 @@ func (s *SqliteCheckpointStore) Delete(ctx context.Context, checkpointID string)
 	if err != nil {
 		return fmt.Errorf("failed to delete checkpoint: %w", err)
 	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE checkpoint_id = ?", s.writesTable())
+	_, err = s.db.ExecContext(ctx, query, checkpointID)
+	if err != nil {
+		return fmt.Errorf("failed to delete pending writes: %w", err)
+	}
 	return nil
 }
 
 // Clear removes all checkpoints for an execution
 func (s *SqliteCheckpointStore) Clear(ctx context.Context, executionID string) error {
-	query := fmt.Sprintf("DELETE FROM %s WHERE execution_id = ?", s.tableName)
+	query := fmt.Sprintf("DELETE FROM %s WHERE checkpoint_id IN (SELECT id FROM %s WHERE execution_id = ?)", s.writesTable(), s.tableName)
 	_, err := s.db.ExecContext(ctx, query, executionID)
+	if err != nil {
+		return fmt.Errorf("failed to clear pending writes: %w", err)
+	}
+
+	query = fmt.Sprintf("DELETE FROM %s WHERE execution_id = ?", s.tableName)
+	_, err = s.db.ExecContext(ctx, query, executionID)
 	if err != nil {
 		return fmt.Errorf("failed to clear checkpoints: %w", err)
 	}
@@ -2,11 +2,17 @@ package sqlite
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/smallnest/langgraphgo/graph"
 	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
 )
 
 func TestSqliteCheckpointStore(t *testing.T) {
@@ -80,3 +86,306 @@ func TestSqliteCheckpointStore(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Len(t, list, 0)
 }
+
+func TestSqliteCheckpointStore_Next(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+
+	// A table created before the next column existed
+	db, err := sql.Open("sqlite3", path)
+	assert.NoError(t, err)
+	_, err = db.Exec(`
+		CREATE TABLE checkpoints (
+			id TEXT PRIMARY KEY,
+			execution_id TEXT NOT NULL,
+			node_name TEXT NOT NULL,
+			state TEXT NOT NULL,
+			metadata TEXT,
+			timestamp DATETIME NOT NULL,
+			version INTEGER NOT NULL
+		);
+		INSERT INTO checkpoints VALUES ('cp-old', 'thread-1', 'a', '{}', '{}', '2024-01-01 00:00:00', 1);
+	`)
+	assert.NoError(t, err)
+	assert.NoError(t, db.Close())
+
+	store, err := NewSqliteCheckpointStore(SqliteOptions{Path: path})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	old, err := store.Load(ctx, "cp-old")
+	assert.NoError(t, err)
+	assert.Empty(t, old.Next)
+
+	err = store.Save(ctx, &graph.Checkpoint{
+		ID:        "cp-new",
+		NodeName:  "a",
+		State:     map[string]interface{}{},
+		Next:      []string{"b", "c"},
+		Timestamp: time.Now(),
+		Version:   2,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+
+	loaded, err := store.Load(ctx, "cp-new")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"b", "c"}, loaded.Next)
+
+	list, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, list, 2)
+	assert.Equal(t, []string{"b", "c"}, list[1].Next)
+}
+
+func TestSqliteCheckpointStore_History(t *testing.T) {
+	store, err := NewSqliteCheckpointStore(SqliteOptions{Path: ":memory:"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+
+	now := time.Now()
+	parentID := ""
+	for i := 1; i <= 5; i++ {
+		source := "loop"
+		if i == 1 {
+			source = "input"
+		}
+		id := fmt.Sprintf("cp-%d", i)
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			NodeName:  "node",
+			State:     map[string]interface{}{"step": i},
+			ParentID:  parentID,
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Version:   i,
+			Metadata: map[string]interface{}{
+				"execution_id": "thread-1",
+				"source":       source,
+				"step":         i,
+			},
+		})
+		assert.NoError(t, err)
+		parentID = id
+	}
+
+	latest, err = store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cp-5", latest.ID)
+	assert.Equal(t, "cp-4", latest.ParentID)
+
+	// Newest first, paginated with a cursor
+	page, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-5", "cp-4"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Limit: 2, Before: "cp-4"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-3", "cp-2"}, checkpointIDs(page))
+
+	// Metadata filters
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "loop"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-5", "cp-4", "cp-3", "cp-2"}, checkpointIDs(page))
+
+	page, err = store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"source": "loop", "step": 3},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-3"}, checkpointIDs(page))
+
+	// List stays oldest first
+	list, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"cp-1", "cp-2", "cp-3", "cp-4", "cp-5"}, checkpointIDs(list))
+}
+
+func checkpointIDs(checkpoints []*graph.Checkpoint) []string {
+	ids := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		ids = append(ids, checkpoint.ID)
+	}
+	return ids
+}
+
+func TestSqliteCheckpointStore_PendingWrites(t *testing.T) {
+	store, err := NewSqliteCheckpointStore(SqliteOptions{
+		Path: filepath.Join(t.TempDir(), "checkpoints.db"),
+	})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	err = store.Save(ctx, &graph.Checkpoint{
+		ID:        "cp-1",
+		Timestamp: time.Now(),
+		Version:   1,
+		Next:      []string{"a", "b"},
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{
+		{Node: "b", Value: map[string]interface{}{"count": 1}, Goto: []string{"c"}},
+		{Node: "a", Value: "first"},
+	})
+	assert.NoError(t, err)
+
+	// A node's write replaces its earlier one
+	err = store.PutWrites(ctx, "cp-1", []graph.PendingWrite{{Node: "a", Value: "second"}})
+	assert.NoError(t, err)
+
+	writes, err := store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "a", Value: "second"},
+		{Node: "b", Value: map[string]interface{}{"count": 1}, Goto: []string{"c"}},
+	}, writes)
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	writes, err = store.GetWrites(ctx, "cp-1")
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+}
+
+func TestSqliteCheckpointStore_Serializer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "checkpoints.db")
+	ctx := context.Background()
+
+	state := map[string]interface{}{
+		"messages": []llms.MessageContent{
+			{
+				Role: llms.ChatMessageTypeAI,
+				Parts: []llms.ContentPart{llms.ToolCall{
+					ID:           "call-1",
+					Type:         "function",
+					FunctionCall: &llms.FunctionCall{Name: "search", Arguments: "{}"},
+				}},
+			},
+		},
+		"count": 2,
+	}
+
+	for _, serializer := range []graph.Serializer{graph.DefaultSerializer(), graph.GobSerializer{}} {
+		store, err := NewSqliteCheckpointStore(SqliteOptions{Path: path, Serializer: serializer})
+		assert.NoError(t, err)
+
+		id := "cp-" + serializer.ID()
+		err = store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			State:     state,
+			Timestamp: time.Now(),
+			Version:   1,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		})
+		assert.NoError(t, err)
+
+		loaded, err := store.Load(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, state, loaded.State)
+		store.Close()
+	}
+
+	// Rows are decoded by the serializer that wrote them
+	store, err := NewSqliteCheckpointStore(SqliteOptions{Path: path, Serializer: graph.JSONSerializer{}})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	for _, checkpoint := range checkpoints {
+		assert.Equal(t, state, checkpoint.State)
+	}
+
+	// Rows written before serializers were recorded hold plain JSON
+	_, err = store.db.Exec(`INSERT INTO checkpoints (id, execution_id, node_name, state, metadata, timestamp, version)
+		VALUES ('legacy', 'thread-2', 'a', '{"count":1}', '{}', ?, 1)`, time.Now())
+	assert.NoError(t, err)
+
+	loaded, err := store.Load(ctx, "legacy")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": float64(1)}, loaded.State)
+}
+
+func TestSqliteCheckpointStore_ListThreads(t *testing.T) {
+	store, err := NewSqliteCheckpointStore(SqliteOptions{Path: ":memory:"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	for i, threadID := range []string{"thread-2", "thread-1", "thread-2"} {
+		err := store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("cp-%d", i),
+			State:     map[string]interface{}{},
+			Timestamp: time.Now(),
+			Version:   i + 1,
+			Metadata:  map[string]interface{}{"execution_id": threadID},
+		})
+		assert.NoError(t, err)
+	}
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-1", "thread-2"}, threads)
+}
+
+func TestSqliteCheckpointStore_SaveIfLatest(t *testing.T) {
+	store, err := NewSqliteCheckpointStore(SqliteOptions{Path: filepath.Join(t.TempDir(), "checkpoints.db")})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	newCheckpoint := func(id string, version int) *graph.Checkpoint {
+		return &graph.Checkpoint{
+			ID:        id,
+			State:     map[string]interface{}{"id": id},
+			Timestamp: time.Now(),
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		}
+	}
+
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-1", 1), ""))
+	assert.NoError(t, store.SaveIfLatest(ctx, newCheckpoint("cp-2", 2), "cp-1"))
+
+	err = store.SaveIfLatest(ctx, newCheckpoint("cp-3", 3), "cp-1")
+	var conflict *graph.ConcurrentUpdateError
+	assert.ErrorAs(t, err, &conflict)
+	assert.Equal(t, graph.ConcurrentUpdateError{ThreadID: "thread-1", ExpectedID: "cp-1", ActualID: "cp-2"}, *conflict)
+
+	_, err = store.Load(ctx, "cp-3")
+	assert.Error(t, err)
+
+	// Of the workers continuing from the same checkpoint, only one wins
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	saved, conflicts := 0, 0
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := store.SaveIfLatest(ctx, newCheckpoint(fmt.Sprintf("worker-%d", i), 3), "cp-2")
+
+			mu.Lock()
+			defer mu.Unlock()
+			if errors.As(err, &conflict) {
+				conflicts++
+				return
+			}
+			assert.NoError(t, err)
+			saved++
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(t, 1, saved)
+	assert.Equal(t, 7, conflicts)
+}
@@ -0,0 +1,270 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+)
+
+var _ graph.Store = &SqliteStore{}
+
+// SqliteStore implements graph.Store using SQLite
+type SqliteStore struct {
+	db        *sql.DB
+	tableName string
+	index     *graph.StoreIndex
+}
+
+// SqliteStoreOptions configuration for the SQLite store
+type SqliteStoreOptions struct {
+	Path      string
+	TableName string // Default "store"
+
+	// Index embeds values to enable semantic search, if set
+	Index *graph.StoreIndex
+}
+
+// NewSqliteStore creates a new SQLite store
+func NewSqliteStore(opts SqliteStoreOptions) (*SqliteStore, error) {
+	db, err := sql.Open("sqlite3", opts.Path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open database: %w", err)
+	}
+
+	tableName := opts.TableName
+	if tableName == "" {
+		tableName = "store"
+	}
+
+	store := &SqliteStore{
+		db:        db,
+		tableName: tableName,
+		index:     opts.Index,
+	}
+
+	if err := store.InitSchema(context.Background()); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// InitSchema creates the necessary table if it doesn't exist
+func (s *SqliteStore) InitSchema(ctx context.Context) error {
+	query := fmt.Sprintf(`
+		CREATE TABLE IF NOT EXISTS %s (
+			namespace TEXT NOT NULL,
+			key TEXT NOT NULL,
+			value TEXT NOT NULL,
+			embedding TEXT,
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			PRIMARY KEY (namespace, key)
+		);
+	`, s.tableName)
+
+	_, err := s.db.ExecContext(ctx, query)
+	if err != nil {
+		return fmt.Errorf("failed to create schema: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection
+func (s *SqliteStore) Close() error {
+	return s.db.Close()
+}
+
+// Put creates or replaces an item
+func (s *SqliteStore) Put(ctx context.Context, namespace []string, key string, value map[string]interface{}) error {
+	if err := graph.ValidateNamespace(namespace); err != nil {
+		return err
+	}
+	nsKey, _ := graph.NamespaceKey(namespace)
+
+	valueJSON, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value: %w", err)
+	}
+
+	embedding, err := s.index.Embed(ctx, value)
+	if err != nil {
+		return err
+	}
+	var embeddingJSON interface{}
+	if embedding != nil {
+		data, err := json.Marshal(embedding)
+		if err != nil {
+			return fmt.Errorf("failed to marshal embedding: %w", err)
+		}
+		embeddingJSON = string(data)
+	}
+
+	query := fmt.Sprintf(`
+		INSERT INTO %s (namespace, key, value, embedding, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(namespace, key) DO UPDATE SET
+			value = excluded.value,
+			embedding = excluded.embedding,
+			updated_at = excluded.updated_at
+	`, s.tableName)
+
+	now := time.Now()
+	if _, err := s.db.ExecContext(ctx, query, nsKey, key, string(valueJSON), embeddingJSON, now, now); err != nil {
+		return fmt.Errorf("failed to put item: %w", err)
+	}
+	return nil
+}
+
+// itemColumns are the columns read by scanItem
+const itemColumns = "namespace, key, value, embedding, created_at, updated_at"
+
+// scanItem reads an item selected with itemColumns, and its embedding
+func scanItem(row rowScanner) (*graph.Item, []float64, error) {
+	var item graph.Item
+	var nsKey string
+	var valueJSON string
+	var embeddingJSON sql.NullString
+
+	if err := row.Scan(&nsKey, &item.Key, &valueJSON, &embeddingJSON, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return nil, nil, err
+	}
+	item.Namespace = graph.SplitNamespaceKey(nsKey)
+
+	if err := json.Unmarshal([]byte(valueJSON), &item.Value); err != nil {
+		return nil, nil, fmt.Errorf("failed to unmarshal value: %w", err)
+	}
+
+	var embedding []float64
+	if embeddingJSON.Valid && embeddingJSON.String != "" {
+		if err := json.Unmarshal([]byte(embeddingJSON.String), &embedding); err != nil {
+			return nil, nil, fmt.Errorf("failed to unmarshal embedding: %w", err)
+		}
+	}
+	return &item, embedding, nil
+}
+
+// Get returns an item, or nil if it does not exist
+func (s *SqliteStore) Get(ctx context.Context, namespace []string, key string) (*graph.Item, error) {
+	nsKey, err := graph.NamespaceKey(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE namespace = ? AND key = ?", itemColumns, s.tableName)
+	item, _, err := scanItem(s.db.QueryRowContext(ctx, query, nsKey, key))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get item: %w", err)
+	}
+	return item, nil
+}
+
+// Delete removes an item
+func (s *SqliteStore) Delete(ctx context.Context, namespace []string, key string) error {
+	nsKey, err := graph.NamespaceKey(namespace)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("DELETE FROM %s WHERE namespace = ? AND key = ?", s.tableName)
+	if _, err := s.db.ExecContext(ctx, query, nsKey, key); err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return nil
+}
+
+// Search returns the items of the namespaces starting with prefix. Filters
+// and ordering by update time run in SQLite, similarity to a query is
+// computed on the matching items.
+func (s *SqliteStore) Search(ctx context.Context, prefix []string, opts graph.SearchOptions) ([]*graph.Item, error) {
+	prefixKey, err := graph.NamespaceKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var queryEmbedding []float64
+	if opts.Query != "" {
+		queryEmbedding, err = s.index.EmbedQuery(ctx, opts.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	conditions := []string{"1 = 1"}
+	var args []interface{}
+
+	// Labels contain no dot, so the namespaces below prefix sort between "prefix." and "prefix/"
+	if prefixKey != "" {
+		conditions = append(conditions, "(namespace = ? OR (namespace >= ? AND namespace < ?))")
+		args = append(args, prefixKey, prefixKey+".", prefixKey+"/")
+	}
+
+	keys := make([]string, 0, len(opts.Filter))
+	for key := range opts.Filter {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		valueJSON, err := json.Marshal(opts.Filter[key])
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal filter: %w", err)
+		}
+		conditions = append(conditions, "json_extract(value, '$.' || json_quote(?)) = json_extract(?, '$')")
+		args = append(args, key, string(valueJSON))
+	}
+
+	query := fmt.Sprintf(`
+		SELECT %s
+		FROM %s
+		WHERE %s
+		ORDER BY updated_at DESC, namespace, key
+	`, itemColumns, s.tableName, strings.Join(conditions, " AND "))
+
+	// Semantic searches rank every matching item
+	if queryEmbedding == nil {
+		limit := opts.Limit
+		if limit <= 0 {
+			limit = graph.DefaultSearchLimit
+		}
+		query += " LIMIT ? OFFSET ?"
+		args = append(args, limit, opts.Offset)
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]*graph.Item, 0)
+	var embeddings [][]float64
+	for rows.Next() {
+		item, embedding, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		items = append(items, item)
+		embeddings = append(embeddings, embedding)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating item rows: %w", err)
+	}
+
+	if queryEmbedding == nil {
+		return items, nil
+	}
+	// The filter was applied by the query
+	opts.Filter = nil
+	return graph.SearchItems(items, embeddings, queryEmbedding, opts), nil
+}
@@ -0,0 +1,143 @@
+package sqlite
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+// keywordEmbedder embeds texts by counting the words of a vocabulary
+type keywordEmbedder []string
+
+func (e keywordEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = e.EmbedQuery(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func (e keywordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float64, error) {
+	embedding := make([]float64, len(e))
+	for i, word := range e {
+		embedding[i] = float64(strings.Count(text, word))
+	}
+	return embedding, nil
+}
+
+func TestSqliteStore(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.db")
+	store, err := NewSqliteStore(SqliteStoreOptions{Path: path})
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	namespace := []string{"users", "u1", "preferences"}
+
+	item, err := store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	assert.NoError(t, store.Put(ctx, namespace, "theme", map[string]interface{}{"mode": "dark"}))
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Equal(t, namespace, item.Namespace)
+	assert.Equal(t, map[string]interface{}{"mode": "dark"}, item.Value)
+	created := item.CreatedAt
+
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, namespace, "theme", map[string]interface{}{"mode": "light", "size": 2}))
+	store.Close()
+
+	// Items outlive the process
+	store, err = NewSqliteStore(SqliteStoreOptions{Path: path})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"mode": "light", "size": float64(2)}, item.Value)
+	assert.True(t, created.Equal(item.CreatedAt))
+	assert.True(t, item.UpdatedAt.After(created))
+
+	assert.NoError(t, store.Delete(ctx, namespace, "theme"))
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	assert.Error(t, store.Put(ctx, []string{"a.b"}, "key", nil))
+}
+
+func TestSqliteStore_Search(t *testing.T) {
+	store, err := NewSqliteStore(SqliteStoreOptions{Path: ":memory:"})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1", "facts"}, "f1", map[string]interface{}{"kind": "drink", "rank": 1}))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1", "facts"}, "f2", map[string]interface{}{"kind": "pet", "rank": 2}))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1"}, "profile", map[string]interface{}{"name": "Ada"}))
+	assert.NoError(t, store.Put(ctx, []string{"users", "u10"}, "profile", map[string]interface{}{"name": "Grace"}))
+
+	// Prefixes match whole labels, most recent first
+	items, err := store.Search(ctx, []string{"users", "u1"}, graph.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"profile", "f2", "f1"}, itemKeys(items))
+
+	items, err = store.Search(ctx, nil, graph.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+
+	items, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{Filter: map[string]interface{}{"kind": "pet", "rank": 2}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f2"}, itemKeys(items))
+
+	items, err = store.Search(ctx, []string{"users", "u1"}, graph.SearchOptions{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f2"}, itemKeys(items))
+
+	_, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{Query: "coffee"})
+	assert.ErrorIs(t, err, graph.ErrStoreNotIndexed)
+}
+
+func TestSqliteStore_SemanticSearch(t *testing.T) {
+	store, err := NewSqliteStore(SqliteStoreOptions{
+		Path:  ":memory:",
+		Index: &graph.StoreIndex{Embedder: keywordEmbedder{"coffee", "tea", "cat"}, Fields: []string{"text"}},
+	})
+	assert.NoError(t, err)
+	defer store.Close()
+
+	ctx := context.Background()
+	namespace := []string{"users", "u1", "facts"}
+	assert.NoError(t, store.Put(ctx, namespace, "f1", map[string]interface{}{"text": "likes coffee", "kind": "drink"}))
+	assert.NoError(t, store.Put(ctx, namespace, "f2", map[string]interface{}{"text": "has a cat", "kind": "pet"}))
+	assert.NoError(t, store.Put(ctx, namespace, "f3", map[string]interface{}{"text": "drinks tea and coffee", "kind": "drink"}))
+
+	items, err := store.Search(ctx, []string{"users"}, graph.SearchOptions{Query: "coffee"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f1", "f3", "f2"}, itemKeys(items))
+	assert.InDelta(t, 1.0, items[0].Score, 1e-9)
+
+	items, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{
+		Query:  "tea",
+		Filter: map[string]interface{}{"kind": "drink"},
+		Limit:  1,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f3"}, itemKeys(items))
+}
+
+func itemKeys(items []*graph.Item) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
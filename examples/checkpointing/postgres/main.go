@@ -17,6 +17,18 @@ type ProcessState struct {
 	History []string
 }
 
+// toProcessState converts the state to ProcessState.
+// States loaded from the store come back from JSON as map[string]interface{}.
+func toProcessState(state interface{}) ProcessState {
+	if s, ok := state.(ProcessState); ok {
+		return s
+	}
+	var s ProcessState
+	data, _ := json.Marshal(state)
+	_ = json.Unmarshal(data, &s)
+	return s
+}
+
 func main() {
 	// Check for Postgres connection string
 	connString := os.Getenv("POSTGRES_CONN_STRING")
@@ -56,7 +68,7 @@ func main() {
 
 	// Add processing nodes
 	g.AddNode("step1", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 1
 		s.Data = s.Data + " → Step1"
 		s.History = append(s.History, "Completed Step 1")
@@ -66,7 +78,7 @@ func main() {
 	})
 
 	g.AddNode("step2", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 2
 		s.Data = s.Data + " → Step2"
 		s.History = append(s.History, "Completed Step 2")
@@ -76,7 +88,7 @@ func main() {
 	})
 
 	g.AddNode("step3", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 3
 		s.Data = s.Data + " → Step3"
 		s.History = append(s.History, "Completed Step 3")
@@ -136,12 +148,7 @@ func main() {
 		if err != nil {
 			fmt.Printf("Error resuming: %v\n", err)
 		} else {
-			// Since data is loaded from JSON, it comes back as map[string]interface{}
-			// We need to convert it back to ProcessState
-			var resumed ProcessState
-
-			importJSON, _ := json.Marshal(resumedState)
-			json.Unmarshal(importJSON, &resumed)
+			resumed := toProcessState(resumedState)
 
 			fmt.Printf("Resumed at Step: %d\n", resumed.Step)
 			fmt.Printf("Resumed Data: %s\n", resumed.Data)
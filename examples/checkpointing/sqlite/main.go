@@ -17,6 +17,18 @@ type ProcessState struct {
 	History []string
 }
 
+// toProcessState converts the state to ProcessState.
+// States loaded from the store come back from JSON as map[string]interface{}.
+func toProcessState(state interface{}) ProcessState {
+	if s, ok := state.(ProcessState); ok {
+		return s
+	}
+	var s ProcessState
+	data, _ := json.Marshal(state)
+	_ = json.Unmarshal(data, &s)
+	return s
+}
+
 func main() {
 	// Check for Sqlite DB path
 	dbPath := os.Getenv("SQLITE_DB_PATH")
@@ -49,7 +61,7 @@ func main() {
 
 	// Add processing nodes
 	g.AddNode("step1", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 1
 		s.Data = s.Data + " → Step1"
 		s.History = append(s.History, "Completed Step 1")
@@ -59,7 +71,7 @@ func main() {
 	})
 
 	g.AddNode("step2", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 2
 		s.Data = s.Data + " → Step2"
 		s.History = append(s.History, "Completed Step 2")
@@ -69,7 +81,7 @@ func main() {
 	})
 
 	g.AddNode("step3", func(ctx context.Context, state interface{}) (interface{}, error) {
-		s := state.(ProcessState)
+		s := toProcessState(state)
 		s.Step = 3
 		s.Data = s.Data + " → Step3"
 		s.History = append(s.History, "Completed Step 3")
@@ -129,14 +141,7 @@ func main() {
 		if err != nil {
 			fmt.Printf("Error resuming: %v\n", err)
 		} else {
-			// Since data is loaded from JSON, it comes back as map[string]interface{}
-			// We need to convert it back to ProcessState
-			var resumed ProcessState
-
-			// Helper to convert map to struct via JSON
-			// In a real app, you might use mapstructure or similar
-			importJSON, _ := json.Marshal(resumedState)
-			json.Unmarshal(importJSON, &resumed)
+			resumed := toProcessState(resumedState)
 
 			fmt.Printf("Resumed at Step: %d\n", resumed.Step)
 			fmt.Printf("Resumed Data: %s\n", resumed.Data)
@@ -2,18 +2,36 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"time"
 
+	"github.com/smallnest/langgraphgo/checkpoint/sqlite"
 	"github.com/smallnest/langgraphgo/graph"
 )
 
 // This example demonstrates "Time Travel" / Human-in-the-loop (HITL) workflow.
 // We run a graph, interrupt it, update the state manually, and resume.
+// Then we go back to an earlier checkpoint and fork a new branch from it.
 
 func main() {
+	dbPath := flag.String("sqlite", "", "store checkpoints in this SQLite database instead of memory")
+	flag.Parse()
+
 	// 1. Setup Checkpointable Graph
-	g := graph.NewCheckpointableMessageGraph()
+	// Any CheckpointStore works, the runtime records parents and pending nodes itself
+	checkpointConfig := graph.DefaultCheckpointConfig()
+	if *dbPath != "" {
+		store, err := sqlite.NewSqliteCheckpointStore(sqlite.SqliteOptions{Path: *dbPath})
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer store.Close()
+		checkpointConfig.Store = store
+	}
+	g := graph.NewCheckpointableMessageGraphWithConfig(checkpointConfig)
 
 	// Schema with integer reducer
 	schema := graph.NewMapSchema()
@@ -21,7 +39,7 @@ func main() {
 		if curr == nil {
 			return new, nil
 		}
-		return curr.(int) + new.(int), nil
+		return toInt(curr) + toInt(new), nil
 	})
 	g.SetSchema(schema)
 
@@ -41,74 +59,113 @@ func main() {
 	g.AddEdge("A", "B")
 	g.AddEdge("B", graph.END)
 
-	// Configure interrupt before B
-	config := &graph.Config{
-		InterruptBefore: []string{"B"},
-		Configurable: map[string]interface{}{
-			"thread_id": "thread_1",
-		},
-	}
-
 	runnable, err := g.CompileCheckpointable()
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	ctx := context.Background()
+	threadID := fmt.Sprintf("thread_%d", time.Now().UnixNano())
+
+	// Configure interrupt before B
+	config := &graph.Config{
+		InterruptBefore: []string{"B"},
+		Configurable: map[string]interface{}{
+			"thread_id": threadID,
+		},
+	}
 
 	// 2. Run Initial (Interrupts before B)
 	fmt.Println("--- Run 1 (Start) ---")
 	res, err := runnable.InvokeWithConfig(ctx, map[string]interface{}{"count": 0}, config)
-	// Expect interrupt error or partial result?
-	// Invoke returns state at interrupt.
-	// Note: Invoke returns (state, error). If interrupted, error is GraphInterrupt.
-	if err != nil {
-		if _, ok := err.(*graph.GraphInterrupt); ok {
-			fmt.Println("Graph Interrupted as expected.")
-		} else {
-			log.Fatal(err)
-		}
+	var interrupt *graph.GraphInterrupt
+	if !errors.As(err, &interrupt) {
+		log.Fatalf("expected an interrupt, got %v", err)
 	}
-	fmt.Printf("State at Interrupt: %v\n", res) // Should be count=1 (0+1)
+	fmt.Println("Graph Interrupted as expected.")
+	fmt.Printf("State at Interrupt: %v\n", res) // count=1 (0+1)
 
 	// 3. Update State (Human Intervention)
-	// We decide to change the count to 100 before B runs.
+	// The reducer adds, so passing 50 turns 1 into 51
 	fmt.Println("\n--- Human Update ---")
-	// UpdateState merges. We want to set it to 100.
-	// Since our reducer adds, if we pass 99, 1+99=100.
-	// Or if we want to OVERWRITE, we need a different reducer or schema logic.
-	// For this example, let's just add 50.
-	newConfig, err := runnable.UpdateState(ctx, config, map[string]interface{}{"count": 50}, "human")
-	if err != nil {
+	if _, err := runnable.UpdateState(ctx, config, map[string]interface{}{"count": 50}, "human"); err != nil {
 		log.Fatal(err)
 	}
 	fmt.Println("State Updated. New Checkpoint created.")
 
 	// 4. Resume Execution
-	// We resume from the new checkpoint.
-	// Note: We need to clear InterruptBefore to let it proceed, or use ResumeFrom?
-	// If we just Invoke with new config (pointing to new checkpoint), it should continue?
-	// But we need to tell it to start at B?
-	// The checkpoint knows "Next" nodes? Currently our Checkpoint struct doesn't store Next nodes explicitly.
-	// But `Invoke` logic determines next nodes from current.
-	// If we resume, we usually need to specify `ResumeFrom` or rely on saved state.
-	// For now, let's use `ResumeFrom` = "B".
-
-	resumeConfig := &graph.Config{
-		Configurable: newConfig.Configurable, // Use the checkpoint ID from UpdateState
-		ResumeFrom:   []string{"B"},
+	// A nil input continues the thread with the nodes pending in its latest checkpoint
+	fmt.Println("\n--- Run 2 (Resume) ---")
+	finalRes, err := runnable.InvokeWithConfig(ctx, nil, &graph.Config{Configurable: config.Configurable})
+	if err != nil {
+		log.Fatal(err)
 	}
+	// 0 -> A: 1 -> Update: 51 -> B: 61
+	fmt.Printf("Final Result: %v\n", finalRes)
 
-	fmt.Println("\n--- Run 2 (Resume) ---")
-	finalRes, err := runnable.InvokeWithConfig(ctx, nil, resumeConfig) // State loaded from checkpoint
+	// 5. Time Travel
+	// Find the checkpoint taken right after A, before the human update
+	fmt.Println("\n--- History ---")
+	var afterA *graph.StateSnapshot
+	for snapshot, err := range runnable.GetStateHistory(ctx, config) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		printSnapshot(snapshot)
+		if snapshot.Metadata["source"] == "loop" && len(snapshot.Next) == 1 && snapshot.Next[0] == "B" {
+			afterA = snapshot
+		}
+	}
+	if afterA == nil {
+		log.Fatal("checkpoint after A not found")
+	}
+
+	// Fork: apply a different update to that checkpoint and run B again
+	fmt.Println("\n--- Run 3 (Fork from the checkpoint after A) ---")
+	forkConfig, err := runnable.UpdateState(ctx, &afterA.Config, map[string]interface{}{"count": 100}, "human")
+	if err != nil {
+		log.Fatal(err)
+	}
+	forkRes, err := runnable.InvokeWithConfig(ctx, nil, forkConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
+	// 0 -> A: 1 -> Update: 101 -> B: 111
+	fmt.Printf("Fork Result: %v\n", forkRes)
 
-	// Final result should be:
-	// Initial: 0
-	// A: +1 -> 1
-	// Update: +50 -> 51
-	// B: +10 -> 61
-	fmt.Printf("Final Result: %v\n", finalRes)
+	// The fork is now the head of the thread, walk it back to the start.
+	// The original branch stays in the history, next to it.
+	fmt.Println("\n--- Forked Branch ---")
+	head, err := runnable.GetState(ctx, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	for snapshot, err := range runnable.GetStateHistory(ctx, &head.Config) {
+		if err != nil {
+			log.Fatal(err)
+		}
+		printSnapshot(snapshot)
+	}
+}
+
+func printSnapshot(snapshot *graph.StateSnapshot) {
+	values, _ := snapshot.Values.(map[string]interface{})
+	fmt.Printf("  %s (parent: %q) source=%v count=%v next=%v\n",
+		snapshot.Config.Configurable["checkpoint_id"],
+		snapshot.ParentID,
+		snapshot.Metadata["source"],
+		values["count"],
+		snapshot.Next)
+}
+
+// toInt reads counts restored from JSON as well as in-memory ones
+func toInt(v interface{}) int {
+	switch n := v.(type) {
+	case int:
+		return n
+	case float64:
+		return int(n)
+	default:
+		return 0
+	}
 }
@@ -0,0 +1,166 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+// BudgetLimit names a limit of a Budget
+type BudgetLimit string
+
+const (
+	// BudgetLimitTokens limits the total tokens of the LLM calls of a run
+	BudgetLimitTokens BudgetLimit = "tokens"
+	// BudgetLimitCost limits the estimated cost of the LLM calls of a run
+	BudgetLimitCost BudgetLimit = "cost"
+	// BudgetLimitLLMCalls limits the number of LLM calls of a run
+	BudgetLimitLLMCalls BudgetLimit = "llm_calls"
+	// BudgetLimitDuration limits the wall-clock time of a run
+	BudgetLimitDuration BudgetLimit = "duration"
+	// BudgetLimitSteps limits the number of supersteps of a run
+	BudgetLimitSteps BudgetLimit = "steps"
+)
+
+// budgetUsageKey is the checkpoint metadata key of the consumption of a run
+const budgetUsageKey = "budget_usage"
+
+// Budget limits the resources a run may consume. Zero limits are unlimited.
+//
+// Budgets are checked between supersteps: once a limit is used up, the run
+// stops before its next superstep with a *BudgetExceededError, so a superstep
+// that started within the budget always completes. Tokens, cost and LLM calls
+// are counted from the usage recorded with RecordUsage, including the calls
+// of subgraphs. A run resumed from its checkpoint keeps the consumption of
+// the invocations before it, so resume with a raised budget to continue.
+type Budget struct {
+	MaxTokens   int           `json:"max_tokens,omitempty"`
+	MaxCost     float64       `json:"max_cost,omitempty"`
+	MaxLLMCalls int           `json:"max_llm_calls,omitempty"`
+	MaxDuration time.Duration `json:"max_duration,omitempty"`
+	MaxSteps    int           `json:"max_steps,omitempty"`
+
+	// Prices estimates the cost of LLM calls when the run context carries no
+	// usage tracker. Otherwise the prices of the tracker are used.
+	Prices PriceTable `json:"prices,omitempty"`
+}
+
+// BudgetUsage is the consumption of a run counted against its budget
+type BudgetUsage struct {
+	Tokens   int           `json:"tokens"`
+	Cost     float64       `json:"cost"`
+	LLMCalls int           `json:"llm_calls"`
+	Duration time.Duration `json:"duration"`
+	Steps    int           `json:"steps"`
+}
+
+// exceeded returns the first limit of the budget used up by usage
+func (b Budget) exceeded(usage BudgetUsage) (BudgetLimit, bool) {
+	switch {
+	case b.MaxTokens > 0 && usage.Tokens >= b.MaxTokens:
+		return BudgetLimitTokens, true
+	case b.MaxCost > 0 && usage.Cost >= b.MaxCost:
+		return BudgetLimitCost, true
+	case b.MaxLLMCalls > 0 && usage.LLMCalls >= b.MaxLLMCalls:
+		return BudgetLimitLLMCalls, true
+	case b.MaxDuration > 0 && usage.Duration >= b.MaxDuration:
+		return BudgetLimitDuration, true
+	case b.MaxSteps > 0 && usage.Steps >= b.MaxSteps:
+		return BudgetLimitSteps, true
+	default:
+		return "", false
+	}
+}
+
+// budgetMeter counts the consumption of a run with a budget. The meters of
+// nested runs also count their LLM calls against the runs that invoked them.
+type budgetMeter struct {
+	budget  Budget
+	parent  *budgetMeter
+	started time.Time
+
+	mu sync.Mutex
+	// used is the consumption so far, without the time of the current invocation
+	used BudgetUsage
+}
+
+// newBudgetMeter starts counting a run, continuing from the consumption of
+// the invocations it resumes
+func newBudgetMeter(budget Budget, restored BudgetUsage, parent *budgetMeter) *budgetMeter {
+	return &budgetMeter{
+		budget:  budget,
+		parent:  parent,
+		started: time.Now(),
+		used:    restored,
+	}
+}
+
+// addUsage counts an LLM call against the run and the runs containing it
+func (m *budgetMeter) addUsage(record UsageRecord) {
+	tokens := record.Usage.TotalTokens
+	if sum := record.Usage.PromptTokens + record.Usage.CompletionTokens; sum > tokens {
+		tokens = sum
+	}
+
+	for ; m != nil; m = m.parent {
+		m.mu.Lock()
+		m.used.Tokens += tokens
+		m.used.Cost += record.Cost
+		m.used.LLMCalls++
+		m.mu.Unlock()
+	}
+}
+
+// addStep counts a superstep of the run
+func (m *budgetMeter) addStep() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.used.Steps++
+}
+
+// usage returns the consumption of the run so far
+func (m *budgetMeter) usage() BudgetUsage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	usage := m.used
+	usage.Duration += time.Since(m.started)
+	return usage
+}
+
+// exceeded returns the first limit the run used up, with its consumption
+func (m *budgetMeter) exceeded() (BudgetLimit, BudgetUsage, bool) {
+	usage := m.usage()
+	limit, exceeded := m.budget.exceeded(usage)
+	return limit, usage, exceeded
+}
+
+type budgetMeterKey struct{}
+
+// withBudgetMeter adds the budget meter of a run to the context
+func withBudgetMeter(ctx context.Context, meter *budgetMeter) context.Context {
+	return context.WithValue(ctx, budgetMeterKey{}, meter)
+}
+
+// budgetMeterFromContext returns the budget meter of the innermost run with a budget, or nil
+func budgetMeterFromContext(ctx context.Context) *budgetMeter {
+	meter, _ := ctx.Value(budgetMeterKey{}).(*budgetMeter)
+	return meter
+}
+
+// budgetUsageFromMetadata returns the consumption stored in checkpoint
+// metadata. Stores that serialize metadata return it as a generic map.
+func budgetUsageFromMetadata(metadata map[string]interface{}) BudgetUsage {
+	switch v := metadata[budgetUsageKey].(type) {
+	case BudgetUsage:
+		return v
+	case nil:
+		return BudgetUsage{}
+	default:
+		var usage BudgetUsage
+		if data, err := json.Marshal(v); err == nil {
+			_ = json.Unmarshal(data, &usage)
+		}
+		return usage
+	}
+}
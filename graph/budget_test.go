@@ -0,0 +1,137 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newLoopGraph returns a graph whose node makes an LLM call of 100 tokens and
+// counts its runs, looping until it ran `rounds` times
+func newLoopGraph(rounds int) *graph.StateGraph {
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("rounds", graph.AppendReducer)
+	g.SetSchema(schema)
+
+	g.AddNode("loop", func(ctx context.Context, state interface{}) (interface{}, error) {
+		graph.RecordUsage(ctx, "gpt-4o", graph.TokenUsage{PromptTokens: 80, CompletionTokens: 20, TotalTokens: 100})
+		return map[string]interface{}{"rounds": []string{"loop"}}, nil
+	})
+	g.AddConditionalEdge("loop", func(ctx context.Context, state interface{}) string {
+		if len(state.(map[string]interface{})["rounds"].([]string)) >= rounds {
+			return graph.END
+		}
+		return "loop"
+	})
+	g.SetEntryPoint("loop")
+	return g
+}
+
+func TestBudget_Limits(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		budget graph.Budget
+		limit  graph.BudgetLimit
+		rounds int
+	}{
+		{"tokens", graph.Budget{MaxTokens: 250}, graph.BudgetLimitTokens, 3},
+		{"llm calls", graph.Budget{MaxLLMCalls: 2}, graph.BudgetLimitLLMCalls, 2},
+		{"steps", graph.Budget{MaxSteps: 4}, graph.BudgetLimitSteps, 4},
+		{
+			"cost",
+			graph.Budget{MaxCost: 0.002, Prices: graph.PriceTable{"gpt-4o": {PromptPerMillion: 10, CompletionPerMillion: 10}}},
+			graph.BudgetLimitCost,
+			2,
+		},
+		{"duration", graph.Budget{MaxDuration: time.Nanosecond}, graph.BudgetLimitDuration, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runnable, err := newLoopGraph(100).Compile()
+			require.NoError(t, err)
+
+			budget := tt.budget
+			res, err := runnable.InvokeWithConfig(context.Background(), map[string]interface{}{}, &graph.Config{Budget: &budget})
+
+			var budgetErr *graph.BudgetExceededError
+			require.True(t, errors.As(err, &budgetErr), "expected a budget error, got %v", err)
+			assert.Equal(t, tt.limit, budgetErr.Limit)
+			assert.Equal(t, tt.rounds, budgetErr.Used.Steps)
+			assert.Equal(t, []string{"loop"}, budgetErr.NextNodes)
+			assert.Equal(t, res, budgetErr.State)
+			assert.Contains(t, err.Error(), string(tt.limit))
+		})
+	}
+}
+
+func TestBudget_CheckpointAndResume(t *testing.T) {
+	t.Parallel()
+
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := newLoopGraph(5).Compile(graph.WithCheckpointer(store))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	config := threadConfig("budget")
+	config.Budget = &graph.Budget{MaxLLMCalls: 2}
+
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, config)
+	var budgetErr *graph.BudgetExceededError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, 2, budgetErr.Used.LLMCalls)
+	assert.Equal(t, 200, budgetErr.Used.Tokens)
+
+	// The consumption carries over, resuming with the same budget stops again
+	_, err = runnable.InvokeWithConfig(ctx, nil, config)
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, 2, budgetErr.Used.LLMCalls)
+	assert.Len(t, budgetErr.State.(map[string]interface{})["rounds"], 2)
+
+	// A raised budget resumes the run where it stopped
+	config.Budget = &graph.Budget{MaxLLMCalls: 10}
+	res, err := runnable.InvokeWithConfig(ctx, nil, config)
+	require.NoError(t, err)
+	assert.Len(t, res.(map[string]interface{})["rounds"], 5)
+}
+
+func TestBudget_CountsSubgraphCalls(t *testing.T) {
+	t.Parallel()
+
+	child, err := newLoopGraph(3).Compile()
+	require.NoError(t, err)
+
+	g := graph.NewStateGraph()
+	g.AddNode("child", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return child.Invoke(ctx, map[string]interface{}{})
+	})
+	g.AddNode("next", func(ctx context.Context, state interface{}) (interface{}, error) {
+		t.Error("next should not run once the budget is used up")
+		return state, nil
+	})
+	g.AddEdge("child", "next")
+	g.AddEdge("next", graph.END)
+	g.SetEntryPoint("child")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	tracker := graph.NewUsageTracker(nil)
+	ctx := graph.WithUsageTracker(context.Background(), tracker)
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, &graph.Config{Budget: &graph.Budget{MaxTokens: 300}})
+
+	var budgetErr *graph.BudgetExceededError
+	require.True(t, errors.As(err, &budgetErr))
+	assert.Equal(t, graph.BudgetLimitTokens, budgetErr.Limit)
+	assert.Equal(t, 3, budgetErr.Used.LLMCalls)
+	assert.Equal(t, []string{"next"}, budgetErr.NextNodes)
+	assert.Equal(t, 300, tracker.Summary().Total.TotalTokens)
+}
@@ -36,6 +36,19 @@ type GraphCallbackHandler interface {
 	OnGraphStep(ctx context.Context, stepNode string, state interface{})
 }
 
+// NodeCallbackHandler extends CallbackHandler with the lifecycle of graph
+// nodes. Handlers implementing it are notified of nodes through these
+// callbacks instead of the tool callbacks.
+type NodeCallbackHandler interface {
+	CallbackHandler
+	// OnNodeStart is called when a node starts, with the state it receives
+	OnNodeStart(ctx context.Context, nodeName string, input interface{}, runID string, parentRunID *string, tags []string, metadata map[string]interface{})
+	// OnNodeEnd is called when a node completes, with the update it returned
+	OnNodeEnd(ctx context.Context, nodeName string, output interface{}, runID string)
+	// OnNodeError is called when a node fails or interrupts the run
+	OnNodeError(ctx context.Context, nodeName string, err error, runID string)
+}
+
 // Config represents configuration for graph invocation
 // This matches Python's config dict pattern
 type Config struct {
@@ -68,6 +81,9 @@ type Config struct {
 
 	// ResumeValue provides the value to return from an Interrupt() call when resuming
 	ResumeValue interface{} `json:"resume_value"`
+
+	// Budget limits the tokens, cost, LLM calls, time and supersteps of the run
+	Budget *Budget `json:"budget,omitempty"`
 }
 
 // NoOpCallbackHandler provides a no-op implementation of CallbackHandler
@@ -91,3 +107,49 @@ func (n *NoOpCallbackHandler) OnRetrieverStart(ctx context.Context, serialized m
 func (n *NoOpCallbackHandler) OnRetrieverEnd(ctx context.Context, documents []interface{}, runID string) {
 }
 func (n *NoOpCallbackHandler) OnRetrieverError(ctx context.Context, err error, runID string) {}
+
+// notifyNodeStart notifies the callbacks of a run that a node started
+func notifyNodeStart(ctx context.Context, config *Config, nodeName string, input interface{}, nodeRunID, runID string) {
+	if config == nil {
+		return
+	}
+	serialized := map[string]interface{}{
+		"name": nodeName,
+		"type": "tool",
+	}
+	for _, cb := range config.Callbacks {
+		if ncb, ok := cb.(NodeCallbackHandler); ok {
+			ncb.OnNodeStart(ctx, nodeName, input, nodeRunID, &runID, config.Tags, config.Metadata)
+		} else {
+			cb.OnToolStart(ctx, serialized, convertStateToString(input), nodeRunID, &runID, config.Tags, config.Metadata)
+		}
+	}
+}
+
+// notifyNodeEnd notifies the callbacks of a run that a node completed
+func notifyNodeEnd(ctx context.Context, config *Config, nodeName string, output interface{}, nodeRunID string) {
+	if config == nil {
+		return
+	}
+	for _, cb := range config.Callbacks {
+		if ncb, ok := cb.(NodeCallbackHandler); ok {
+			ncb.OnNodeEnd(ctx, nodeName, output, nodeRunID)
+		} else {
+			cb.OnToolEnd(ctx, convertStateToString(output), nodeRunID)
+		}
+	}
+}
+
+// notifyNodeError notifies the callbacks of a run that a node failed
+func notifyNodeError(ctx context.Context, config *Config, nodeName string, err error, nodeRunID string) {
+	if config == nil {
+		return
+	}
+	for _, cb := range config.Callbacks {
+		if ncb, ok := cb.(NodeCallbackHandler); ok {
+			ncb.OnNodeError(ctx, nodeName, err, nodeRunID)
+		} else {
+			cb.OnToolError(ctx, err, nodeRunID)
+		}
+	}
+}
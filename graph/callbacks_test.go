@@ -0,0 +1,277 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callbackRecord is a callback received by lifecycleRecorder
+type callbackRecord struct {
+	event       string
+	name        string
+	value       interface{}
+	runID       string
+	parentRunID string
+}
+
+// lifecycleRecorder records chain and node callbacks, and the runs of nodes, in order
+type lifecycleRecorder struct {
+	graph.NoOpCallbackHandler
+	mu      sync.Mutex
+	records []callbackRecord
+}
+
+func (r *lifecycleRecorder) add(record callbackRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+}
+
+func parentOf(parentRunID *string) string {
+	if parentRunID == nil {
+		return ""
+	}
+	return *parentRunID
+}
+
+func (r *lifecycleRecorder) OnChainStart(ctx context.Context, serialized map[string]interface{}, inputs map[string]interface{}, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	r.add(callbackRecord{event: "chain_start", runID: runID, parentRunID: parentOf(parentRunID)})
+}
+
+func (r *lifecycleRecorder) OnChainEnd(ctx context.Context, outputs map[string]interface{}, runID string) {
+	r.add(callbackRecord{event: "chain_end", runID: runID})
+}
+
+func (r *lifecycleRecorder) OnNodeStart(ctx context.Context, nodeName string, input interface{}, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	r.add(callbackRecord{event: "node_start", name: nodeName, value: input, runID: runID, parentRunID: parentOf(parentRunID)})
+}
+
+func (r *lifecycleRecorder) OnNodeEnd(ctx context.Context, nodeName string, output interface{}, runID string) {
+	r.add(callbackRecord{event: "node_end", name: nodeName, value: output, runID: runID})
+}
+
+func (r *lifecycleRecorder) OnNodeError(ctx context.Context, nodeName string, err error, runID string) {
+	r.add(callbackRecord{event: "node_error", name: nodeName, value: err.Error(), runID: runID})
+}
+
+// OnToolStart records tool callbacks, which handlers with node callbacks don't receive for nodes
+func (r *lifecycleRecorder) OnToolStart(ctx context.Context, serialized map[string]interface{}, inputStr string, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	r.add(callbackRecord{event: "tool_start"})
+}
+
+// events returns the recorded events
+func (r *lifecycleRecorder) events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := make([]string, len(r.records))
+	for i, record := range r.records {
+		events[i] = record.event + " " + record.name
+	}
+	return events
+}
+
+func TestNodeCallbacks_Lifecycle(t *testing.T) {
+	t.Parallel()
+
+	newNode := func(recorder *lifecycleRecorder, name string) func(ctx context.Context, state interface{}) (interface{}, error) {
+		return func(ctx context.Context, state interface{}) (interface{}, error) {
+			recorder.add(callbackRecord{event: "run", name: name})
+			return fmt.Sprintf("%v+%s", state, name), nil
+		}
+	}
+
+	tests := []struct {
+		name    string
+		compile func(recorder *lifecycleRecorder) (func(context.Context, interface{}, *graph.Config) (interface{}, error), error)
+	}{
+		{
+			name: "runnable",
+			compile: func(recorder *lifecycleRecorder) (func(context.Context, interface{}, *graph.Config) (interface{}, error), error) {
+				g := graph.NewMessageGraph()
+				g.AddNode("a", newNode(recorder, "a"))
+				g.AddNode("b", newNode(recorder, "b"))
+				g.AddEdge("a", "b")
+				g.AddEdge("b", graph.END)
+				g.SetEntryPoint("a")
+				runnable, err := g.Compile()
+				if err != nil {
+					return nil, err
+				}
+				return runnable.InvokeWithConfig, nil
+			},
+		},
+		{
+			name: "state runnable",
+			compile: func(recorder *lifecycleRecorder) (func(context.Context, interface{}, *graph.Config) (interface{}, error), error) {
+				g := graph.NewStateGraph()
+				g.AddNode("a", newNode(recorder, "a"))
+				g.AddNode("b", newNode(recorder, "b"))
+				g.AddEdge("a", "b")
+				g.AddEdge("b", graph.END)
+				g.SetEntryPoint("a")
+				runnable, err := g.Compile()
+				if err != nil {
+					return nil, err
+				}
+				return runnable.InvokeWithConfig, nil
+			},
+		},
+		{
+			name: "listenable runnable",
+			compile: func(recorder *lifecycleRecorder) (func(context.Context, interface{}, *graph.Config) (interface{}, error), error) {
+				g := graph.NewListenableMessageGraph()
+				g.AddNode("a", newNode(recorder, "a"))
+				g.AddNode("b", newNode(recorder, "b"))
+				g.AddEdge("a", "b")
+				g.AddEdge("b", graph.END)
+				g.SetEntryPoint("a")
+				runnable, err := g.CompileListenable()
+				if err != nil {
+					return nil, err
+				}
+				return runnable.InvokeWithConfig, nil
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := &lifecycleRecorder{}
+			invoke, err := tt.compile(recorder)
+			require.NoError(t, err)
+
+			_, err = invoke(context.Background(), "in", &graph.Config{Callbacks: []graph.CallbackHandler{recorder}})
+			require.NoError(t, err)
+
+			// Node callbacks replace the tool callbacks, at the real times
+			assert.Equal(t, []string{
+				"chain_start ",
+				"node_start a", "run a", "node_end a",
+				"node_start b", "run b", "node_end b",
+				"chain_end ",
+			}, recorder.events())
+
+			records := recorder.records
+			runID := records[0].runID
+			assert.Empty(t, records[0].parentRunID)
+			assert.Equal(t, "in", records[1].value)
+			assert.Equal(t, "in+a", records[3].value)
+			assert.Equal(t, "in+a", records[4].value)
+			assert.Equal(t, "in+a+b", records[6].value)
+			for _, i := range []int{1, 4} {
+				assert.Equal(t, runID, records[i].parentRunID)
+				assert.NotEqual(t, runID, records[i].runID)
+				assert.Equal(t, records[i].runID, records[i+2].runID)
+			}
+		})
+	}
+}
+
+func TestNodeCallbacks_Error(t *testing.T) {
+	t.Parallel()
+
+	g := graph.NewStateGraph()
+	g.AddNode("fail", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	g.AddEdge("fail", graph.END)
+	g.SetEntryPoint("fail")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	recorder := &lifecycleRecorder{}
+	_, err = runnable.InvokeWithConfig(context.Background(), "in", &graph.Config{Callbacks: []graph.CallbackHandler{recorder}})
+	require.Error(t, err)
+
+	assert.Equal(t, []string{"chain_start ", "node_start fail", "node_error fail"}, recorder.events())
+	assert.Equal(t, "boom", recorder.records[2].value)
+}
+
+func TestNodeCallbacks_SubgraphParentRunIDs(t *testing.T) {
+	t.Parallel()
+
+	inner := graph.NewMessageGraph()
+	inner.AddNode("inner", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	inner.AddEdge("inner", graph.END)
+	inner.SetEntryPoint("inner")
+
+	g := graph.NewMessageGraph()
+	require.NoError(t, g.AddSubgraph("sub", inner))
+	g.AddEdge("sub", graph.END)
+	g.SetEntryPoint("sub")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	recorder := &lifecycleRecorder{}
+	_, err = runnable.InvokeWithConfig(context.Background(), "in", &graph.Config{Callbacks: []graph.CallbackHandler{recorder}})
+	require.NoError(t, err)
+
+	// The subgraph reports to the callbacks of its parent, under the node invoking it
+	assert.Equal(t, []string{
+		"chain_start ",
+		"node_start sub",
+		"chain_start ", "node_start inner", "node_end inner", "chain_end ",
+		"node_end sub",
+		"chain_end ",
+	}, recorder.events())
+
+	records := recorder.records
+	root, sub, subgraphRun, innerNode := records[0], records[1], records[2], records[3]
+	assert.Equal(t, root.runID, sub.parentRunID)
+	assert.Equal(t, sub.runID, subgraphRun.parentRunID)
+	assert.Equal(t, subgraphRun.runID, innerNode.parentRunID)
+}
+
+// toolRecorder records the tool callbacks of handlers without node callbacks
+type toolRecorder struct {
+	graph.NoOpCallbackHandler
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *toolRecorder) OnToolStart(ctx context.Context, serialized map[string]interface{}, inputStr string, runID string, parentRunID *string, tags []string, metadata map[string]interface{}) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, fmt.Sprintf("start %v %s", serialized["name"], inputStr))
+}
+
+func (r *toolRecorder) OnToolEnd(ctx context.Context, output string, runID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, "end "+output)
+}
+
+func TestNodeCallbacks_ToolCallbacks(t *testing.T) {
+	t.Parallel()
+
+	recorder := &toolRecorder{}
+	g := graph.NewMessageGraph()
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		recorder.mu.Lock()
+		recorder.events = append(recorder.events, "run")
+		recorder.mu.Unlock()
+		return "out", nil
+	})
+	g.AddEdge("a", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	_, err = runnable.InvokeWithConfig(context.Background(), "in", &graph.Config{Callbacks: []graph.CallbackHandler{recorder}})
+	require.NoError(t, err)
+
+	// Handlers without node callbacks see nodes as tools, with their real input
+	assert.Equal(t, []string{`start a "in"`, "run", `end "out"`}, recorder.events)
+}
@@ -0,0 +1,404 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CompileOptions configures a compiled graph
+type CompileOptions struct {
+	// Checkpointer persists the state after every superstep of runs whose
+	// config carries Configurable["thread_id"], so that later invocations on
+	// the same thread continue from the latest checkpoint
+	Checkpointer CheckpointStore
+
+	// Retention prunes the checkpoints of a thread after every save
+	Retention *RetentionPolicy
+
+	// Store is the long-term memory shared across threads, reached by
+	// nodes with GetStore(ctx)
+	Store Store
+}
+
+// CompileOption is a function that configures CompileOptions
+type CompileOption func(*CompileOptions)
+
+// WithCheckpointer sets the checkpoint store used to make runs durable
+func WithCheckpointer(store CheckpointStore) CompileOption {
+	return func(o *CompileOptions) {
+		o.Checkpointer = store
+	}
+}
+
+// WithRetention prunes the checkpoints of a thread after every save
+func WithRetention(policy RetentionPolicy) CompileOption {
+	return func(o *CompileOptions) {
+		o.Retention = &policy
+	}
+}
+
+// WithStore sets the long-term memory store available to nodes
+func WithStore(store Store) CompileOption {
+	return func(o *CompileOptions) {
+		o.Store = store
+	}
+}
+
+func newCompileOptions(opts []CompileOption) CompileOptions {
+	var options CompileOptions
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return options
+}
+
+// threadIDFromConfig returns Configurable["thread_id"], if set
+func threadIDFromConfig(config *Config) string {
+	if config == nil || config.Configurable == nil {
+		return ""
+	}
+	threadID, _ := config.Configurable["thread_id"].(string)
+	return threadID
+}
+
+// checkpointIDFromConfig returns Configurable["checkpoint_id"], if set
+func checkpointIDFromConfig(config *Config) string {
+	if config == nil || config.Configurable == nil {
+		return ""
+	}
+	checkpointID, _ := config.Configurable["checkpoint_id"].(string)
+	return checkpointID
+}
+
+// checkpointThreadID returns the thread a checkpoint belongs to
+func checkpointThreadID(checkpoint *Checkpoint) string {
+	if threadID, ok := checkpoint.Metadata["thread_id"].(string); ok && threadID != "" {
+		return threadID
+	}
+	executionID, _ := checkpoint.Metadata["execution_id"].(string)
+	return executionID
+}
+
+// latestCheckpoint returns the newest checkpoint of a thread, or nil if it has none.
+// Checkpoints are ordered by version, then by timestamp.
+func latestCheckpoint(ctx context.Context, store CheckpointStore, threadID string) (*Checkpoint, error) {
+	if v2, ok := store.(CheckpointStoreV2); ok {
+		latest, err := v2.GetLatest(ctx, threadID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get latest checkpoint: %w", err)
+		}
+		return latest, nil
+	}
+
+	checkpoints, err := store.List(ctx, threadID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	var latest *Checkpoint
+	for _, checkpoint := range checkpoints {
+		if latest == nil || checkpointNewer(checkpoint, latest) {
+			latest = checkpoint
+		}
+	}
+	return latest, nil
+}
+
+// threadCheckpointer persists the supersteps of one invocation on a thread.
+// A nil threadCheckpointer disables checkpointing.
+type threadCheckpointer struct {
+	store    CheckpointStore
+	threadID string
+	// explicit is true when the caller selected the thread or checkpoint,
+	// in which case the invocation continues from the stored state
+	explicit bool
+	// parent is the checkpoint the next save descends from
+	parent *Checkpoint
+	// version is the version of the next save, one past the newest of the thread
+	version int
+	// latestID is the newest checkpoint of the thread as far as this
+	// invocation knows, saves fail if another writer added one since
+	latestID string
+	// recorded reports whether parent describes the current position of
+	// this invocation, either because it saved it or resumed from it
+	recorded bool
+	// retention prunes the thread after every save, if set
+	retention *RetentionPolicy
+	// interval skips the checkpoints of supersteps within this duration of
+	// the last save, 0 saves every superstep
+	interval time.Duration
+	// lastSave is the time of the last save
+	lastSave time.Time
+	// migrations upgrades the checkpoint the invocation continues from, if set
+	migrations *Migrations
+	// meter is the budget meter of the invocation, whose consumption is
+	// stored with every checkpoint, if the run has a budget
+	meter *budgetMeter
+}
+
+// runStart describes where an invocation starts
+type runStart struct {
+	state interface{}
+	nodes []string
+	// resumed is true when the nodes come from a checkpoint's pending next nodes
+	resumed bool
+	// finished is true when the thread has nothing left to run
+	finished bool
+	// writes holds the results of resumed nodes that already completed, by node
+	writes map[string]PendingWrite
+	// budgetUsage is the budget consumption of the invocations before a resumed run
+	budgetUsage BudgetUsage
+}
+
+// threadCheckpointer returns the checkpointer for the invocation, or nil when
+// checkpointing is disabled or the run has no thread
+func (e *executor) threadCheckpointer(config *Config) *threadCheckpointer {
+	if e.checkpointer == nil {
+		return nil
+	}
+
+	threadID := threadIDFromConfig(config)
+	explicit := threadID != "" || checkpointIDFromConfig(config) != ""
+	if threadID == "" {
+		threadID = e.defaultThreadID
+	}
+	if threadID == "" && !explicit {
+		return nil
+	}
+
+	return &threadCheckpointer{
+		store:      e.checkpointer,
+		threadID:   threadID,
+		explicit:   explicit,
+		retention:  e.retention,
+		interval:   e.saveInterval,
+		migrations: e.migrations,
+	}
+}
+
+// restore determines the starting state and nodes of an invocation.
+// With a checkpoint to continue from, a nil input resumes the pending next
+// nodes, while a non-nil input is merged into the stored state and the graph
+// runs again from its entry point.
+func (tc *threadCheckpointer) restore(ctx context.Context, e *executor, input interface{}, config *Config) (runStart, error) {
+	start := runStart{
+		state: input,
+		nodes: []string{e.entryPoint},
+	}
+
+	if tc != nil {
+		var base *Checkpoint
+		if checkpointID := checkpointIDFromConfig(config); checkpointID != "" {
+			var err error
+			base, err = tc.store.Load(ctx, checkpointID)
+			if err != nil {
+				return start, fmt.Errorf("failed to load checkpoint: %w", err)
+			}
+			// Forks stay on the thread of the checkpoint unless the config names one
+			if threadID := checkpointThreadID(base); threadID != "" && threadIDFromConfig(config) == "" {
+				tc.threadID = threadID
+			}
+		}
+
+		// Versions keep growing on the thread, even when continuing from an older checkpoint
+		latest, err := latestCheckpoint(ctx, tc.store, tc.threadID)
+		if err != nil {
+			return start, err
+		}
+		if base == nil {
+			base = latest
+		}
+
+		// The stored checkpoint keeps its schema version, the invocation
+		// continues from the upgraded state
+		from := 1
+		if base != nil {
+			from = SchemaVersion(base)
+		}
+		base, err = tc.migrations.Migrate(ctx, base)
+		if err != nil {
+			return start, err
+		}
+		tc.parent = base
+		tc.version = 1
+		if latest != nil {
+			tc.version = latest.Version + 1
+			tc.latestID = latest.ID
+		}
+
+		if base != nil && tc.explicit {
+			switch {
+			case input == nil && len(base.Next) > 0:
+				start.state = base.State
+				start.nodes = append([]string(nil), base.Next...)
+				start.resumed = true
+				tc.recorded = true
+				start.budgetUsage = budgetUsageFromMetadata(base.Metadata)
+
+				writes, err := tc.pendingWrites(ctx, base, from)
+				if err != nil {
+					return start, err
+				}
+				start.writes = writes
+			case input == nil:
+				start.state = base.State
+				start.finished = true
+			case e.schema != nil:
+				merged, err := e.schema.Update(base.State, input)
+				if err != nil {
+					return start, fmt.Errorf("failed to merge input into checkpoint: %w", err)
+				}
+				start.state = merged
+			}
+		}
+	}
+
+	// Explicit ResumeFrom always wins
+	if config != nil && len(config.ResumeFrom) > 0 {
+		start.nodes = config.ResumeFrom
+		start.resumed = false
+		start.finished = false
+		start.writes = nil
+	}
+
+	return start, nil
+}
+
+// save stores a checkpoint for the superstep that ran nodes, recording the next nodes
+func (tc *threadCheckpointer) save(ctx context.Context, nodes []string, state interface{}, next []string, source string) error {
+	if tc == nil {
+		return nil
+	}
+
+	parentID := ""
+	if tc.parent != nil {
+		parentID = tc.parent.ID
+	}
+
+	// END is not a node to resume, a checkpoint without next nodes is final
+	var pending []string
+	for _, node := range next {
+		if node != END {
+			pending = append(pending, node)
+		}
+	}
+
+	// Final checkpoints are always saved. A skipped superstep is saved by
+	// ensureSaved if the invocation stops before the next save.
+	if tc.interval > 0 && source == "loop" && len(pending) > 0 && time.Since(tc.lastSave) < tc.interval {
+		tc.recorded = false
+		return nil
+	}
+
+	checkpoint := &Checkpoint{
+		ID:        generateCheckpointID(),
+		NodeName:  strings.Join(nodes, ","),
+		State:     state,
+		Next:      pending,
+		ParentID:  parentID,
+		Timestamp: time.Now(),
+		Version:   tc.version,
+		Metadata: map[string]interface{}{
+			"execution_id": tc.threadID,
+			"thread_id":    tc.threadID,
+			"source":       source,
+			"event":        "step",
+		},
+	}
+	tc.migrations.stamp(checkpoint.Metadata)
+	if tc.meter != nil {
+		checkpoint.Metadata[budgetUsageKey] = tc.meter.usage()
+	}
+
+	if err := tc.traceSave(ctx, checkpoint); err != nil {
+		return fmt.Errorf("failed to save checkpoint: %w", err)
+	}
+
+	tc.parent = checkpoint
+	tc.latestID = checkpoint.ID
+	tc.version++
+	tc.recorded = true
+	tc.lastSave = checkpoint.Timestamp
+
+	if tc.retention != nil {
+		if _, err := PruneCheckpoints(ctx, tc.store, tc.threadID, *tc.retention); err != nil {
+			return fmt.Errorf("failed to prune checkpoints: %w", err)
+		}
+	}
+	return nil
+}
+
+// traceSave saves a checkpoint, in a span of the run if the graph is traced
+func (tc *threadCheckpointer) traceSave(ctx context.Context, checkpoint *Checkpoint) error {
+	tracer := TracerFromContext(ctx)
+	if tracer == nil {
+		return saveCheckpoint(ctx, tc.store, checkpoint, tc.latestID)
+	}
+
+	span := tracer.StartSpanWithMetadata(ctx, TraceEventCheckpointStart, "", map[string]interface{}{
+		"checkpoint_id": checkpoint.ID,
+		"source":        checkpoint.Metadata["source"],
+	})
+	err := saveCheckpoint(ctx, tc.store, checkpoint, tc.latestID)
+	tracer.EndSpan(ctx, span, nil, err)
+	return err
+}
+
+// ensureSaved records the pending nodes when the invocation stops, or starts
+// storing pending writes, before completing its first superstep, unless the
+// latest checkpoint already does
+func (tc *threadCheckpointer) ensureSaved(ctx context.Context, state interface{}, next []string) error {
+	if tc == nil || tc.recorded {
+		return nil
+	}
+	return tc.save(ctx, nil, state, next, "input")
+}
+
+// pendingWrites returns the writes of the nodes of checkpoint's superstep that
+// already completed, upgraded from the schema version the checkpoint was stored with
+func (tc *threadCheckpointer) pendingWrites(ctx context.Context, checkpoint *Checkpoint, from int) (map[string]PendingWrite, error) {
+	store, ok := tc.store.(PendingWritesStore)
+	if !ok {
+		return nil, nil
+	}
+
+	writes, err := store.GetWrites(ctx, checkpoint.ID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get pending writes: %w", err)
+	}
+	writes, err = tc.migrations.migrateWrites(ctx, from, writes)
+	if err != nil {
+		return nil, err
+	}
+
+	byNode := make(map[string]PendingWrite, len(writes))
+	for _, write := range writes {
+		for _, node := range checkpoint.Next {
+			if write.Node == node {
+				byNode[node] = write
+			}
+		}
+	}
+	return byNode, nil
+}
+
+// recordsWrites reports whether the results of a superstep running nodes are
+// stored as they complete. A superstep with a single node completes with it.
+func (tc *threadCheckpointer) recordsWrites(nodes []string) bool {
+	if tc == nil || len(nodes) < 2 {
+		return false
+	}
+	_, ok := tc.store.(PendingWritesStore)
+	return ok
+}
+
+// putWrite stores the result of a node against the checkpoint its superstep started from
+func (tc *threadCheckpointer) putWrite(ctx context.Context, node string, result interface{}) error {
+	store := tc.store.(PendingWritesStore)
+	if err := store.PutWrites(ctx, tc.parent.ID, []PendingWrite{newPendingWrite(node, result)}); err != nil {
+		return fmt.Errorf("failed to save pending write: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,587 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"iter"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func threadConfig(threadID string) *graph.Config {
+	return &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": threadID},
+	}
+}
+
+func sortByVersion(checkpoints []*graph.Checkpoint) {
+	sort.Slice(checkpoints, func(i, j int) bool {
+		return checkpoints[i].Version < checkpoints[j].Version
+	})
+}
+
+func newStepsGraph() *graph.StateGraph {
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return map[string]interface{}{"steps": []string{"a"}}, nil
+	})
+	g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return map[string]interface{}{"steps": []string{"b"}}, nil
+	})
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.SetEntryPoint("a")
+	return g
+}
+
+func TestCheckpointer_ThreadContinuation(t *testing.T) {
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := newStepsGraph().Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	input := map[string]interface{}{"steps": []string{"start"}}
+
+	_, err = runnable.InvokeWithConfig(ctx, input, threadConfig("thread-1"))
+	assert.NoError(t, err)
+
+	// The second run continues from the stored state of the thread
+	res, err := runnable.InvokeWithConfig(ctx, input, threadConfig("thread-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b", "start", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	// Other threads are independent
+	res, err = runnable.InvokeWithConfig(ctx, input, threadConfig("thread-2"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 4)
+	sortByVersion(checkpoints)
+	for i, checkpoint := range checkpoints {
+		assert.Equal(t, i+1, checkpoint.Version)
+		assert.Equal(t, "thread-1", checkpoint.Metadata["thread_id"])
+	}
+	assert.Equal(t, "a", checkpoints[0].NodeName)
+	assert.Equal(t, []string{"b"}, checkpoints[0].Next)
+	assert.Empty(t, checkpoints[1].Next)
+
+	// Runs without a thread are not persisted
+	_, err = runnable.Invoke(ctx, input)
+	assert.NoError(t, err)
+	checkpoints, err = store.List(ctx, "")
+	assert.NoError(t, err)
+	assert.Empty(t, checkpoints)
+}
+
+func TestCheckpointer_ResumeAfterInterruptBefore(t *testing.T) {
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := newStepsGraph().Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	config := threadConfig("thread-1")
+	config.InterruptBefore = []string{"b"}
+
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{"steps": []string{"start"}}, config)
+	var interrupt *graph.GraphInterrupt
+	assert.True(t, errors.As(err, &interrupt))
+	assert.Equal(t, []string{"b"}, interrupt.NextNodes)
+
+	// A nil input resumes the pending nodes without interrupting again
+	res, err := runnable.InvokeWithConfig(ctx, nil, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	// Resuming a finished thread returns its state
+	res, err = runnable.InvokeWithConfig(ctx, nil, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b"}, res.(map[string]interface{})["steps"])
+}
+
+func TestCheckpointer_ResumeNodeInterrupt(t *testing.T) {
+	g := graph.NewMessageGraph()
+	g.AddNode("ask", func(ctx context.Context, state interface{}) (interface{}, error) {
+		answer, err := graph.Interrupt(ctx, "name?")
+		if err != nil {
+			return nil, err
+		}
+		return "hello " + answer.(string), nil
+	})
+	g.AddEdge("ask", graph.END)
+	g.SetEntryPoint("ask")
+
+	runnable, err := g.Compile(graph.WithCheckpointer(graph.NewMemoryCheckpointStore()))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, "input", threadConfig("thread-1"))
+	var interrupt *graph.GraphInterrupt
+	assert.True(t, errors.As(err, &interrupt))
+	assert.Equal(t, "name?", interrupt.InterruptValue)
+
+	config := threadConfig("thread-1")
+	config.ResumeValue = "bob"
+	res, err := runnable.InvokeWithConfig(ctx, nil, config)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello bob", res)
+}
+
+func TestCheckpointer_ResumeFromCheckpointID(t *testing.T) {
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := newStepsGraph().Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{"steps": []string{"start"}}, threadConfig("thread-1"))
+	assert.NoError(t, err)
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	sortByVersion(checkpoints)
+
+	// Re-run b from the checkpoint taken after a
+	config := threadConfig("thread-1")
+	config.Configurable["checkpoint_id"] = checkpoints[0].ID
+	res, err := runnable.InvokeWithConfig(ctx, nil, config)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	checkpoints, err = store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 3)
+}
+
+type failingCheckpointStore struct {
+	*graph.MemoryCheckpointStore
+}
+
+func (s *failingCheckpointStore) Save(_ context.Context, _ *graph.Checkpoint) error {
+	return errors.New("disk full")
+}
+
+func TestCheckpointer_SaveError(t *testing.T) {
+	store := &failingCheckpointStore{graph.NewMemoryCheckpointStore()}
+	runnable, err := newStepsGraph().Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	_, err = runnable.InvokeWithConfig(context.Background(), map[string]interface{}{}, threadConfig("thread-1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "disk full")
+}
+
+func TestCheckpointableRunnable_ResumeFromCheckpoint(t *testing.T) {
+	g := graph.NewCheckpointableMessageGraph()
+	calls := map[string]int{}
+	for _, name := range []string{"a", "b"} {
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+			calls[name]++
+			return state.(string) + name, nil
+		})
+	}
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.CompileCheckpointable()
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, "", &graph.Config{InterruptBefore: []string{"b"}})
+	assert.Error(t, err)
+
+	checkpoints, err := runnable.ListCheckpoints(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 1)
+	assert.Equal(t, []string{"b"}, checkpoints[0].Next)
+
+	snapshot, err := runnable.GetState(ctx, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, "a", snapshot.Values)
+	assert.Equal(t, []string{"b"}, snapshot.Next)
+
+	res, err := runnable.ResumeFromCheckpoint(ctx, checkpoints[0].ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "ab", res)
+	assert.Equal(t, 1, calls["a"])
+	assert.Equal(t, 1, calls["b"])
+}
+
+func TestCheckpointer_History(t *testing.T) {
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := newStepsGraph().Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{"steps": []string{"start"}}, threadConfig("thread-1"))
+	assert.NoError(t, err)
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, latest.Version)
+	assert.Equal(t, "b", latest.NodeName)
+
+	// Each checkpoint links to the one it was taken after
+	history, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, history, 2)
+	assert.Equal(t, latest.ID, history[0].ID)
+	assert.Equal(t, history[1].ID, history[0].ParentID)
+	assert.Empty(t, history[1].ParentID)
+
+	page, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{Before: latest.ID, Limit: 1})
+	assert.NoError(t, err)
+	assert.Len(t, page, 1)
+	assert.Equal(t, history[1].ID, page[0].ID)
+
+	filtered, err := store.ListWithOptions(ctx, "thread-1", graph.CheckpointListOptions{
+		Metadata: map[string]interface{}{"thread_id": "thread-2"},
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, filtered)
+
+	latest, err = store.GetLatest(ctx, "missing")
+	assert.NoError(t, err)
+	assert.Nil(t, latest)
+}
+
+func TestCheckpointer_PendingWrites(t *testing.T) {
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+
+	calls := map[string]int{}
+	var mu sync.Mutex
+	step := func(name string, fail bool) func(ctx context.Context, state interface{}) (interface{}, error) {
+		return func(ctx context.Context, state interface{}) (interface{}, error) {
+			mu.Lock()
+			calls[name]++
+			n := calls[name]
+			mu.Unlock()
+			if fail && n == 1 {
+				return nil, errors.New("rate limited")
+			}
+			return map[string]interface{}{"steps": []string{name}}, nil
+		}
+	}
+	g.AddNode("start", step("start", false))
+	g.AddNode("fast", step("fast", false))
+	g.AddNode("flaky", step("flaky", true))
+	g.AddNode("join", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return &graph.Command{Update: map[string]interface{}{"steps": []string{"join"}}, Goto: graph.END}, nil
+	})
+	g.AddEdge("start", "fast")
+	g.AddEdge("start", "flaky")
+	g.AddEdge("fast", "join")
+	g.AddEdge("flaky", "join")
+	g.SetEntryPoint("start")
+
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, threadConfig("thread-1"))
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "rate limited")
+
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"fast", "flaky"}, latest.Next)
+
+	writes, err := store.GetWrites(ctx, latest.ID)
+	assert.NoError(t, err)
+	assert.Len(t, writes, 1)
+	assert.Equal(t, "fast", writes[0].Node)
+
+	// Resuming only runs the node that failed
+	res, err := runnable.InvokeWithConfig(ctx, nil, threadConfig("thread-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "fast", "flaky", "join"}, res.(map[string]interface{})["steps"])
+	assert.Equal(t, 1, calls["fast"])
+	assert.Equal(t, 2, calls["flaky"])
+
+	assert.NoError(t, store.Clear(ctx, "thread-1"))
+	writes, err = store.GetWrites(ctx, latest.ID)
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+}
+
+func TestCheckpointableRunnable_TimeTravel(t *testing.T) {
+	g := graph.NewCheckpointableMessageGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+	for _, name := range []string{"a", "b"} {
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{name}}, nil
+		})
+	}
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.CompileCheckpointable()
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{"steps": []string{"start"}}, threadConfig("thread-1"))
+	assert.NoError(t, err)
+
+	history := stateHistory(t, runnable, threadConfig("thread-1"))
+	assert.Len(t, history, 2)
+	afterA := history[1].Config.Configurable["checkpoint_id"].(string)
+
+	// Replaying from the checkpoint after a forks a new branch
+	forkConfig := threadConfig("thread-1")
+	forkConfig.Configurable["checkpoint_id"] = afterA
+	res, err := runnable.InvokeWithConfig(ctx, nil, forkConfig)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	// So does editing it
+	updated, err := runnable.UpdateState(ctx, &graph.Config{
+		Configurable: map[string]interface{}{"checkpoint_id": afterA},
+	}, map[string]interface{}{"steps": []string{"edit"}}, "human")
+	assert.NoError(t, err)
+	assert.Equal(t, "thread-1", updated.Configurable["thread_id"])
+
+	res, err = runnable.InvokeWithConfig(ctx, nil, updated)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"start", "a", "edit", "b"}, res.(map[string]interface{})["steps"])
+
+	// The whole tree: the original run, the replay and the edited branch
+	history = stateHistory(t, runnable, threadConfig("thread-1"))
+	assert.Len(t, history, 5)
+	children := 0
+	for _, snapshot := range history {
+		if snapshot.ParentID == afterA {
+			children++
+		}
+	}
+	assert.Equal(t, 3, children)
+
+	// A single branch, from its head back to the first checkpoint
+	branch := stateHistory(t, runnable, &graph.Config{Configurable: history[0].Config.Configurable})
+	assert.Len(t, branch, 3)
+	assert.Equal(t, []string{"start", "a", "edit", "b"}, branch[0].Values.(map[string]interface{})["steps"])
+	assert.Equal(t, "human", branch[1].Metadata["updated_by"])
+	assert.Equal(t, afterA, branch[2].Config.Configurable["checkpoint_id"])
+	assert.Empty(t, branch[2].ParentID)
+
+	// Iteration stops when the caller does
+	count := 0
+	for _, err := range runnable.GetStateHistory(ctx, threadConfig("thread-1")) {
+		assert.NoError(t, err)
+		count++
+		break
+	}
+	assert.Equal(t, 1, count)
+}
+
+// stateHistorian is a runnable with the history of its threads
+type stateHistorian interface {
+	GetStateHistory(ctx context.Context, config *graph.Config) iter.Seq2[*graph.StateSnapshot, error]
+}
+
+func stateHistory(t *testing.T, runnable stateHistorian, config *graph.Config) []*graph.StateSnapshot {
+	t.Helper()
+	var history []*graph.StateSnapshot
+	for snapshot, err := range runnable.GetStateHistory(context.Background(), config) {
+		assert.NoError(t, err)
+		history = append(history, snapshot)
+	}
+	return history
+}
+
+// conditionalCheckpointStore is a memory store that supports conditional saves
+type conditionalCheckpointStore struct {
+	*graph.MemoryCheckpointStore
+	mu sync.Mutex
+}
+
+func (s *conditionalCheckpointStore) SaveIfLatest(ctx context.Context, checkpoint *graph.Checkpoint, expectedID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	threadID, _ := checkpoint.Metadata["execution_id"].(string)
+	latest, err := s.GetLatest(ctx, threadID)
+	if err != nil {
+		return err
+	}
+	actualID := ""
+	if latest != nil {
+		actualID = latest.ID
+	}
+	if actualID != expectedID {
+		return &graph.ConcurrentUpdateError{ThreadID: threadID, ExpectedID: expectedID, ActualID: actualID}
+	}
+	return s.Save(ctx, checkpoint)
+}
+
+func TestCheckpointer_ConcurrentUpdate(t *testing.T) {
+	ctx := context.Background()
+	store := &conditionalCheckpointStore{MemoryCheckpointStore: graph.NewMemoryCheckpointStore()}
+
+	g := newStepsGraph()
+	interfere := true
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		// Another worker updates the thread while this one runs
+		if interfere {
+			interfere = false
+			err := store.Save(ctx, &graph.Checkpoint{
+				ID:       "other-worker",
+				State:    map[string]interface{}{"steps": []string{"other"}},
+				Version:  1,
+				Metadata: map[string]interface{}{"execution_id": "thread-1", "thread_id": "thread-1"},
+			})
+			assert.NoError(t, err)
+		}
+		return map[string]interface{}{"steps": []string{"a"}}, nil
+	})
+	runnable, err := g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, threadConfig("thread-1"))
+	var conflict *graph.ConcurrentUpdateError
+	if assert.ErrorAs(t, err, &conflict) {
+		assert.Equal(t, graph.ConcurrentUpdateError{ThreadID: "thread-1", ActualID: "other-worker"}, *conflict)
+	}
+
+	// Retrying continues from the checkpoint of the other worker
+	res, err := runnable.InvokeWithConfig(ctx, map[string]interface{}{}, threadConfig("thread-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"other", "a", "b"}, res.(map[string]interface{})["steps"])
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 3)
+}
+
+// threadRunnable is a runnable compiled with a checkpointer
+type threadRunnable interface {
+	stateHistorian
+	InvokeWithConfig(ctx context.Context, input interface{}, config *graph.Config) (interface{}, error)
+	GetState(ctx context.Context, config *graph.Config) (*graph.StateSnapshot, error)
+	UpdateState(ctx context.Context, config *graph.Config, values interface{}, asNode string) (*graph.Config, error)
+}
+
+func TestRunnables_ThreadState(t *testing.T) {
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	step := func(name string) func(ctx context.Context, state interface{}) (interface{}, error) {
+		return func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{name}}, nil
+		}
+	}
+	build := func(g *graph.MessageGraph) {
+		g.SetSchema(schema)
+		g.AddNode("a", step("a"))
+		g.AddNode("b", step("b"))
+		g.AddEdge("a", "b")
+		g.AddEdge("b", graph.END)
+		g.SetEntryPoint("a")
+	}
+
+	tests := []struct {
+		name    string
+		compile func(store graph.CheckpointStore) (threadRunnable, error)
+	}{
+		{"state runnable", func(store graph.CheckpointStore) (threadRunnable, error) {
+			return newStepsGraph().Compile(graph.WithCheckpointer(store))
+		}},
+		{"runnable", func(store graph.CheckpointStore) (threadRunnable, error) {
+			g := graph.NewMessageGraph()
+			build(g)
+			return g.Compile(graph.WithCheckpointer(store))
+		}},
+		{"listenable runnable", func(store graph.CheckpointStore) (threadRunnable, error) {
+			g := graph.NewListenableMessageGraph()
+			build(g.MessageGraph)
+			return g.CompileListenable(graph.WithCheckpointer(store))
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			runnable, err := tt.compile(graph.NewMemoryCheckpointStore())
+			require.NoError(t, err)
+
+			ctx := context.Background()
+			_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{"steps": []string{"start"}}, threadConfig("thread-1"))
+			require.NoError(t, err)
+
+			state, err := runnable.GetState(ctx, threadConfig("thread-1"))
+			require.NoError(t, err)
+			assert.Equal(t, []string{"start", "a", "b"}, state.Values.(map[string]interface{})["steps"])
+			assert.Empty(t, state.Next)
+
+			history := stateHistory(t, runnable, threadConfig("thread-1"))
+			require.Len(t, history, 2)
+
+			// Editing the checkpoint after a forks the thread
+			forkConfig := threadConfig("thread-1")
+			forkConfig.Configurable["checkpoint_id"] = history[1].Config.Configurable["checkpoint_id"]
+			updated, err := runnable.UpdateState(ctx, forkConfig, map[string]interface{}{"steps": []string{"edit"}}, "human")
+			require.NoError(t, err)
+
+			res, err := runnable.InvokeWithConfig(ctx, nil, updated)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"start", "a", "edit", "b"}, res.(map[string]interface{})["steps"])
+			assert.Len(t, stateHistory(t, runnable, threadConfig("thread-1")), 4)
+		})
+	}
+}
+
+func TestRunnables_ThreadStateErrors(t *testing.T) {
+	ctx := context.Background()
+
+	runnable, err := newStepsGraph().Compile()
+	require.NoError(t, err)
+	_, err = runnable.GetState(ctx, threadConfig("thread-1"))
+	assert.ErrorIs(t, err, graph.ErrCheckpointerNotConfigured)
+	_, err = runnable.UpdateState(ctx, threadConfig("thread-1"), map[string]interface{}{}, "human")
+	assert.ErrorIs(t, err, graph.ErrCheckpointerNotConfigured)
+	for _, err := range runnable.GetStateHistory(ctx, threadConfig("thread-1")) {
+		assert.ErrorIs(t, err, graph.ErrCheckpointerNotConfigured)
+	}
+
+	// Runnables have no default thread
+	runnable, err = newStepsGraph().Compile(graph.WithCheckpointer(graph.NewMemoryCheckpointStore()))
+	require.NoError(t, err)
+	_, err = runnable.GetState(ctx, nil)
+	assert.Error(t, err)
+	_, err = runnable.UpdateState(ctx, &graph.Config{}, map[string]interface{}{}, "human")
+	assert.Error(t, err)
+}
+
+func TestStreamingRunnable_ThreadState(t *testing.T) {
+	g := graph.NewStreamingMessageGraph()
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil })
+	g.AddEdge("a", graph.END)
+	g.SetEntryPoint("a")
+
+	runnable, err := g.CompileStreaming(graph.WithCheckpointer(graph.NewMemoryCheckpointStore()))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.UpdateState(ctx, threadConfig("thread-1"), map[string]interface{}{"answer": 42}, "human")
+	require.NoError(t, err)
+
+	state, err := runnable.GetState(ctx, threadConfig("thread-1"))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"answer": 42}, state.Values)
+	assert.Len(t, stateHistory(t, runnable, threadConfig("thread-1")), 1)
+}
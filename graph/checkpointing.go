@@ -3,8 +3,11 @@ package graph
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"sort"
 	"sync"
 	"time"
 )
@@ -17,6 +20,10 @@ type Checkpoint struct {
 	Metadata  map[string]interface{} `json:"metadata"`
 	Timestamp time.Time              `json:"timestamp"`
 	Version   int                    `json:"version"`
+	// Next lists the nodes scheduled to run after this checkpoint
+	Next []string `json:"next,omitempty"`
+	// ParentID is the ID of the checkpoint this one was derived from
+	ParentID string `json:"parent_checkpoint_id,omitempty"`
 }
 
 // CheckpointStore defines the interface for checkpoint persistence
@@ -37,9 +44,174 @@ type CheckpointStore interface {
 	Clear(ctx context.Context, executionID string) error
 }
 
+// CheckpointListOptions filters and paginates checkpoint listings
+type CheckpointListOptions struct {
+	// Limit caps the number of checkpoints returned, 0 means no limit
+	Limit int
+
+	// Before only returns checkpoints older than the checkpoint with this ID
+	Before string
+
+	// Metadata only returns checkpoints whose metadata contains all these entries
+	Metadata map[string]interface{}
+}
+
+// CheckpointStoreV2 is a CheckpointStore that can query the history of a thread.
+// Checkpoints of a thread are ordered by version, then by timestamp.
+type CheckpointStoreV2 interface {
+	CheckpointStore
+
+	// GetLatest returns the newest checkpoint of a thread, or nil if it has none
+	GetLatest(ctx context.Context, threadID string) (*Checkpoint, error)
+
+	// ListWithOptions returns the checkpoints of a thread, newest first
+	ListWithOptions(ctx context.Context, threadID string, opts CheckpointListOptions) ([]*Checkpoint, error)
+}
+
+// PendingWrite is the result of a node whose superstep has not completed yet
+type PendingWrite struct {
+	// Node is the name of the node that produced the write
+	Node string `json:"node"`
+
+	// Value is the state update returned by the node
+	Value interface{} `json:"value"`
+
+	// Goto lists the nodes selected by a Command returned by the node
+	Goto []string `json:"goto,omitempty"`
+}
+
+// PendingWritesStore is a CheckpointStore that keeps the results of nodes that
+// completed in an unfinished superstep, so that resuming it only runs the
+// nodes that did not complete
+type PendingWritesStore interface {
+	CheckpointStore
+
+	// PutWrites stores writes against the checkpoint the superstep started from.
+	// A write replaces the earlier write of the same node.
+	PutWrites(ctx context.Context, checkpointID string, writes []PendingWrite) error
+
+	// GetWrites returns the pending writes stored against a checkpoint
+	GetWrites(ctx context.Context, checkpointID string) ([]PendingWrite, error)
+}
+
+// ConditionalCheckpointStore is a CheckpointStore that can save a checkpoint
+// only if no other writer has added one to its thread in the meantime
+type ConditionalCheckpointStore interface {
+	CheckpointStore
+
+	// SaveIfLatest stores checkpoint only if the latest checkpoint of its
+	// thread is still expectedID, empty for a thread without checkpoints.
+	// Otherwise it returns a *ConcurrentUpdateError.
+	SaveIfLatest(ctx context.Context, checkpoint *Checkpoint, expectedID string) error
+}
+
+// saveCheckpoint stores checkpoint, on the condition that the latest checkpoint
+// of its thread is still expectedID when the store supports it
+func saveCheckpoint(ctx context.Context, store CheckpointStore, checkpoint *Checkpoint, expectedID string) error {
+	if conditional, ok := store.(ConditionalCheckpointStore); ok {
+		return conditional.SaveIfLatest(ctx, checkpoint, expectedID)
+	}
+	return store.Save(ctx, checkpoint)
+}
+
+// newPendingWrite records the result of a node, unpacking commands
+func newPendingWrite(node string, result interface{}) PendingWrite {
+	write := PendingWrite{Node: node, Value: result}
+	if cmd, ok := result.(*Command); ok {
+		write.Value = cmd.Update
+		switch g := cmd.Goto.(type) {
+		case string:
+			write.Goto = []string{g}
+		case []string:
+			write.Goto = g
+		}
+	}
+	return write
+}
+
+// result returns the node result the write was recorded from
+func (w PendingWrite) result() interface{} {
+	if len(w.Goto) > 0 {
+		return &Command{Update: w.Value, Goto: w.Goto}
+	}
+	return w.Value
+}
+
+// SortCheckpoints sorts checkpoints newest first
+func SortCheckpoints(checkpoints []*Checkpoint) {
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpointNewer(checkpoints[i], checkpoints[j])
+	})
+}
+
+// checkpointNewer reports whether a comes after b in the history of a thread
+func checkpointNewer(a, b *Checkpoint) bool {
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+	return a.Timestamp.After(b.Timestamp)
+}
+
+// FilterCheckpoints applies list options to the checkpoints of a thread.
+// It is meant for stores that cannot filter natively and returns them newest first.
+func FilterCheckpoints(checkpoints []*Checkpoint, opts CheckpointListOptions) []*Checkpoint {
+	sorted := make([]*Checkpoint, len(checkpoints))
+	copy(sorted, checkpoints)
+	SortCheckpoints(sorted)
+
+	var before *Checkpoint
+	if opts.Before != "" {
+		for _, checkpoint := range sorted {
+			if checkpoint.ID == opts.Before {
+				before = checkpoint
+				break
+			}
+		}
+		if before == nil {
+			return []*Checkpoint{}
+		}
+	}
+
+	result := make([]*Checkpoint, 0)
+	for _, checkpoint := range sorted {
+		if before != nil && !checkpointNewer(before, checkpoint) {
+			continue
+		}
+		if !MetadataMatches(checkpoint.Metadata, opts.Metadata) {
+			continue
+		}
+		result = append(result, checkpoint)
+		if opts.Limit > 0 && len(result) == opts.Limit {
+			break
+		}
+	}
+	return result
+}
+
+// MetadataMatches reports whether metadata contains every entry of filter.
+// Values are compared by their JSON encoding, so 1 matches a float64 1 loaded from JSON.
+func MetadataMatches(metadata, filter map[string]interface{}) bool {
+	for key, want := range filter {
+		got, ok := metadata[key]
+		if !ok {
+			return false
+		}
+		wantJSON, err := json.Marshal(want)
+		if err != nil {
+			return false
+		}
+		gotJSON, err := json.Marshal(got)
+		if err != nil || string(gotJSON) != string(wantJSON) {
+			return false
+		}
+	}
+	return true
+}
+
 // MemoryCheckpointStore provides in-memory checkpoint storage
 type MemoryCheckpointStore struct {
 	checkpoints map[string]*Checkpoint
+	writes      map[string][]PendingWrite
 	mutex       sync.RWMutex
 }
 
@@ -47,6 +219,7 @@ type MemoryCheckpointStore struct {
 func NewMemoryCheckpointStore() *MemoryCheckpointStore {
 	return &MemoryCheckpointStore{
 		checkpoints: make(map[string]*Checkpoint),
+		writes:      make(map[string][]PendingWrite),
 	}
 }
 
@@ -77,14 +250,64 @@ func (m *MemoryCheckpointStore) List(_ context.Context, executionID string) ([]*
 	m.mutex.RLock()
 	defer m.mutex.RUnlock()
 
+	checkpoints := m.thread(executionID)
+
+	// Oldest first
+	sort.SliceStable(checkpoints, func(i, j int) bool {
+		return checkpointNewer(checkpoints[j], checkpoints[i])
+	})
+	return checkpoints, nil
+}
+
+// GetLatest implements CheckpointStoreV2 interface
+func (m *MemoryCheckpointStore) GetLatest(_ context.Context, threadID string) (*Checkpoint, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	var latest *Checkpoint
+	for _, checkpoint := range m.thread(threadID) {
+		if latest == nil || checkpointNewer(checkpoint, latest) {
+			latest = checkpoint
+		}
+	}
+	return latest, nil
+}
+
+// ListWithOptions implements CheckpointStoreV2 interface
+func (m *MemoryCheckpointStore) ListWithOptions(_ context.Context, threadID string, opts CheckpointListOptions) ([]*Checkpoint, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return FilterCheckpoints(m.thread(threadID), opts), nil
+}
+
+// ListThreads implements ThreadLister interface
+func (m *MemoryCheckpointStore) ListThreads(_ context.Context) ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	seen := make(map[string]bool)
+	var threads []string
+	for _, checkpoint := range m.checkpoints {
+		if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && !seen[execID] {
+			seen[execID] = true
+			threads = append(threads, execID)
+		}
+	}
+	sort.Strings(threads)
+	return threads, nil
+}
+
+// thread returns the checkpoints of a thread in no particular order.
+// The caller must hold the mutex.
+func (m *MemoryCheckpointStore) thread(threadID string) []*Checkpoint {
 	var checkpoints []*Checkpoint
 	for _, checkpoint := range m.checkpoints {
-		if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && execID == executionID {
+		if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && execID == threadID {
 			checkpoints = append(checkpoints, checkpoint)
 		}
 	}
-
-	return checkpoints, nil
+	return checkpoints
 }
 
 // Delete implements CheckpointStore interface
@@ -93,6 +316,7 @@ func (m *MemoryCheckpointStore) Delete(_ context.Context, checkpointID string) e
 	defer m.mutex.Unlock()
 
 	delete(m.checkpoints, checkpointID)
+	delete(m.writes, checkpointID)
 	return nil
 }
 
@@ -104,13 +328,46 @@ func (m *MemoryCheckpointStore) Clear(_ context.Context, executionID string) err
 	for id, checkpoint := range m.checkpoints {
 		if execID, ok := checkpoint.Metadata["execution_id"].(string); ok && execID == executionID {
 			delete(m.checkpoints, id)
+			delete(m.writes, id)
 		}
 	}
 
 	return nil
 }
 
-// FileCheckpointStore provides file-based checkpoint storage
+// PutWrites implements PendingWritesStore interface
+func (m *MemoryCheckpointStore) PutWrites(_ context.Context, checkpointID string, writes []PendingWrite) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	existing := m.writes[checkpointID]
+	for _, write := range writes {
+		replaced := false
+		for i := range existing {
+			if existing[i].Node == write.Node {
+				existing[i] = write
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			existing = append(existing, write)
+		}
+	}
+	m.writes[checkpointID] = existing
+	return nil
+}
+
+// GetWrites implements PendingWritesStore interface
+func (m *MemoryCheckpointStore) GetWrites(_ context.Context, checkpointID string) ([]PendingWrite, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return append([]PendingWrite(nil), m.writes[checkpointID]...), nil
+}
+
+// FileCheckpointStore provides file-based checkpoint storage over a single writer and reader.
+// Use the checkpoint/file package for a durable store backed by a directory.
 type FileCheckpointStore struct {
 	writer io.Writer
 	reader io.Reader
@@ -193,11 +450,16 @@ type CheckpointConfig struct {
 	// AutoSave enables automatic checkpointing after each node
 	AutoSave bool
 
-	// SaveInterval specifies how often to save (when AutoSave is false)
+	// SaveInterval specifies how often to save (when AutoSave is false).
+	// Final checkpoints and the position of interrupted runs are always saved.
 	SaveInterval time.Duration
 
-	// MaxCheckpoints limits the number of checkpoints to keep
+	// MaxCheckpoints limits the number of checkpoints to keep per branch of a thread
 	MaxCheckpoints int
+
+	// Retention prunes checkpoints after every save. Its MaxCheckpoints
+	// defaults to the MaxCheckpoints of the config.
+	Retention *RetentionPolicy
 }
 
 // DefaultCheckpointConfig returns a default checkpoint configuration
@@ -227,27 +489,58 @@ func NewCheckpointableRunnable(runnable *ListenableRunnable, config CheckpointCo
 	}
 }
 
+// SetTracer sets a tracer for observability
+func (cr *CheckpointableRunnable) SetTracer(tracer *Tracer) {
+	cr.runnable.SetTracer(tracer)
+}
+
 // Invoke executes the graph with checkpointing
 func (cr *CheckpointableRunnable) Invoke(ctx context.Context, initialState interface{}) (interface{}, error) {
 	return cr.InvokeWithConfig(ctx, initialState, nil)
 }
 
-// InvokeWithConfig executes the graph with checkpointing and config
+// InvokeWithConfig executes the graph with checkpointing and config.
+// With AutoSave a checkpoint is stored after every superstep, otherwise at
+// most once per SaveInterval. Runs without a thread_id are saved under the
+// execution ID of the runnable.
 func (cr *CheckpointableRunnable) InvokeWithConfig(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
-	// Create checkpointing listener
-	checkpointListener := &CheckpointListener{
-		store:       cr.config.Store,
-		executionID: cr.executionID,
-		autoSave:    cr.config.AutoSave,
-	}
+	return cr.executor().invoke(ctx, initialState, config)
+}
+
+// InvokeWithUsage executes the graph with checkpointing like InvokeWithConfig
+// and returns the usage tracker of the run. It is the tracker of the context
+// if it has one, otherwise a new tracker recording only this run.
+func (cr *CheckpointableRunnable) InvokeWithUsage(ctx context.Context, initialState interface{}, config *Config) (interface{}, *UsageTracker, error) {
+	return cr.executor().invokeWithUsage(ctx, initialState, config)
+}
 
-	// Add checkpoint listener to config callbacks
-	if config == nil {
-		config = &Config{}
+// executor returns the superstep executor of the runnable, saving checkpoints as configured
+func (cr *CheckpointableRunnable) executor() *executor {
+	exec := cr.runnable.executor()
+	if cr.config.AutoSave || cr.config.SaveInterval > 0 {
+		exec.checkpointer = cr.config.Store
+		exec.defaultThreadID = cr.executionID
+		exec.retention = cr.retention()
+		if !cr.config.AutoSave {
+			exec.saveInterval = cr.config.SaveInterval
+		}
 	}
-	config.Callbacks = append(config.Callbacks, checkpointListener)
+	return exec
+}
 
-	return cr.runnable.InvokeWithConfig(ctx, initialState, config)
+// retention returns the retention policy of the config, or nil if it keeps every checkpoint
+func (cr *CheckpointableRunnable) retention() *RetentionPolicy {
+	var policy RetentionPolicy
+	if cr.config.Retention != nil {
+		policy = *cr.config.Retention
+	}
+	if policy.MaxCheckpoints == 0 {
+		policy.MaxCheckpoints = cr.config.MaxCheckpoints
+	}
+	if policy == (RetentionPolicy{}) {
+		return nil
+	}
+	return &policy
 }
 
 // SaveCheckpoint manually saves a checkpoint
@@ -262,6 +555,7 @@ func (cr *CheckpointableRunnable) SaveCheckpoint(ctx context.Context, nodeName s
 			"execution_id": cr.executionID,
 		},
 	}
+	cr.runnable.graph.migrations.stamp(checkpoint.Metadata)
 
 	return cr.config.Store.Save(ctx, checkpoint)
 }
@@ -283,10 +577,22 @@ func (cr *CheckpointableRunnable) ResumeFromCheckpoint(ctx context.Context, chec
 		return nil, fmt.Errorf("failed to load checkpoint: %w", err)
 	}
 
-	// Resume execution from the checkpointed state
-	// This would require the graph to support starting from a specific node
-	// For now, we'll return the checkpointed state
-	return checkpoint.State, nil
+	threadID := checkpointThreadID(checkpoint)
+	if threadID == "" {
+		threadID = cr.executionID
+	}
+
+	// Continue with the nodes that were pending when the checkpoint was taken.
+	// A checkpoint without pending nodes returns its state as is.
+	exec := cr.runnable.executor()
+	exec.checkpointer = cr.config.Store
+	exec.retention = cr.retention()
+	return exec.invoke(ctx, nil, &Config{
+		Configurable: map[string]interface{}{
+			"thread_id":     threadID,
+			"checkpoint_id": checkpoint.ID,
+		},
+	})
 }
 
 // ClearCheckpoints removes all checkpoints for this execution
@@ -294,45 +600,6 @@ func (cr *CheckpointableRunnable) ClearCheckpoints(ctx context.Context) error {
 	return cr.config.Store.Clear(ctx, cr.executionID)
 }
 
-// CheckpointListener automatically creates checkpoints during execution
-type CheckpointListener struct {
-	store       CheckpointStore
-	executionID string
-	autoSave    bool
-	// Embed NoOpCallbackHandler to satisfy other CallbackHandler methods
-	NoOpCallbackHandler
-}
-
-// OnGraphStep implements GraphCallbackHandler
-func (cl *CheckpointListener) OnGraphStep(ctx context.Context, stepNode string, state interface{}) {
-	if !cl.autoSave {
-		return
-	}
-
-	checkpoint := &Checkpoint{
-		ID:        generateCheckpointID(),
-		NodeName:  stepNode,
-		State:     state,
-		Timestamp: time.Now(),
-		Version:   1,
-		Metadata: map[string]interface{}{
-			"execution_id": cl.executionID,
-			"event":        "step",
-		},
-	}
-
-	// Save checkpoint asynchronously
-	go func(ctx context.Context) {
-		if saveErr := cl.store.Save(ctx, checkpoint); saveErr != nil {
-			_ = saveErr
-		}
-	}(ctx)
-}
-
-// OnNodeEvent is no longer used for saving state, but kept if needed for interface compatibility
-// or we can remove it if we don't use it as NodeListener anymore.
-// CheckpointableRunnable currently adds it as NodeListener. We should change that.
-
 // CheckpointableMessageGraph extends ListenableMessageGraph with checkpointing
 type CheckpointableMessageGraph struct {
 	*ListenableMessageGraph
@@ -394,42 +661,167 @@ type StateSnapshot struct {
 	ParentID  string
 }
 
+// ErrCheckpointerNotConfigured is returned when reading or updating the
+// threads of a graph compiled without a checkpointer
+var ErrCheckpointerNotConfigured = errors.New("checkpointer not configured")
+
+// errThreadIDRequired is returned when a config has no thread to read or update
+var errThreadIDRequired = errors.New("thread_id is required in Configurable")
+
+// threadStates reads and updates the threads of a checkpoint store. It
+// implements GetState, GetStateHistory and UpdateState for every runnable.
+type threadStates struct {
+	store      CheckpointStore
+	schema     StateSchema
+	migrations *Migrations
+
+	// defaultThreadID is used for configs without a thread_id
+	defaultThreadID string
+}
+
+// threadID returns the thread of a config
+func (ts threadStates) threadID(config *Config) string {
+	if threadID := threadIDFromConfig(config); threadID != "" {
+		return threadID
+	}
+	return ts.defaultThreadID
+}
+
 // GetState retrieves the state for the given config
 func (cr *CheckpointableRunnable) GetState(ctx context.Context, config *Config) (*StateSnapshot, error) {
-	var threadID string
-	var checkpointID string
+	return cr.threadStates().getState(ctx, config)
+}
 
-	if config != nil && config.Configurable != nil {
-		if tid, ok := config.Configurable["thread_id"].(string); ok {
-			threadID = tid
-		}
-		if cid, ok := config.Configurable["checkpoint_id"].(string); ok {
-			checkpointID = cid
-		}
+// GetStateHistory iterates over the checkpoints of a thread, newest first,
+// with their states upgraded to the current schema version.
+// With Configurable["checkpoint_id"] it walks the parent links of that
+// checkpoint back to the start of the thread, i.e. a single branch. Otherwise
+// it yields the checkpoints of every branch; their ParentID links form the tree.
+func (cr *CheckpointableRunnable) GetStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	return cr.threadStates().getStateHistory(ctx, config)
+}
+
+// UpdateState updates the state for the given config.
+// The update applies to the latest checkpoint of the thread, or to
+// Configurable["checkpoint_id"], which forks a new branch from that checkpoint.
+func (cr *CheckpointableRunnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	return cr.threadStates().updateState(ctx, config, values, asNode)
+}
+
+// threadStates returns the threads of the runnable, defaulting to its execution
+func (cr *CheckpointableRunnable) threadStates() threadStates {
+	return threadStates{
+		store:           cr.config.Store,
+		schema:          cr.runnable.graph.Schema,
+		migrations:      cr.runnable.graph.migrations,
+		defaultThreadID: cr.executionID,
 	}
+}
 
-	// Default to current execution ID if thread_id not provided
-	if threadID == "" {
-		threadID = cr.executionID
+// GetState retrieves the state of the thread of config, from the
+// checkpointer set with WithCheckpointer
+func (r *Runnable) GetState(ctx context.Context, config *Config) (*StateSnapshot, error) {
+	return r.threadStates().getState(ctx, config)
+}
+
+// GetStateHistory iterates over the checkpoints of the thread of config, newest first
+func (r *Runnable) GetStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	return r.threadStates().getStateHistory(ctx, config)
+}
+
+// UpdateState updates the state of the thread of config, forking it from
+// Configurable["checkpoint_id"] if set
+func (r *Runnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	return r.threadStates().updateState(ctx, config, values, asNode)
+}
+
+func (r *Runnable) threadStates() threadStates {
+	return threadStates{store: r.checkpointer, schema: r.graph.Schema, migrations: r.graph.migrations}
+}
+
+// GetState retrieves the state of the thread of config, from the
+// checkpointer set with WithCheckpointer
+func (r *StateRunnable) GetState(ctx context.Context, config *Config) (*StateSnapshot, error) {
+	return r.threadStates().getState(ctx, config)
+}
+
+// GetStateHistory iterates over the checkpoints of the thread of config, newest first
+func (r *StateRunnable) GetStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	return r.threadStates().getStateHistory(ctx, config)
+}
+
+// UpdateState updates the state of the thread of config, forking it from
+// Configurable["checkpoint_id"] if set
+func (r *StateRunnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	return r.threadStates().updateState(ctx, config, values, asNode)
+}
+
+func (r *StateRunnable) threadStates() threadStates {
+	return threadStates{store: r.checkpointer, schema: r.graph.Schema, migrations: r.graph.migrations}
+}
+
+// GetState retrieves the state of the thread of config, from the
+// checkpointer set with WithCheckpointer
+func (lr *ListenableRunnable) GetState(ctx context.Context, config *Config) (*StateSnapshot, error) {
+	return lr.threadStates().getState(ctx, config)
+}
+
+// GetStateHistory iterates over the checkpoints of the thread of config, newest first
+func (lr *ListenableRunnable) GetStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	return lr.threadStates().getStateHistory(ctx, config)
+}
+
+// UpdateState updates the state of the thread of config, forking it from
+// Configurable["checkpoint_id"] if set
+func (lr *ListenableRunnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	return lr.threadStates().updateState(ctx, config, values, asNode)
+}
+
+func (lr *ListenableRunnable) threadStates() threadStates {
+	return threadStates{store: lr.checkpointer, schema: lr.graph.Schema, migrations: lr.graph.migrations}
+}
+
+// GetState retrieves the state of the thread of config, from the
+// checkpointer set with WithCheckpointer
+func (sr *StreamingRunnable) GetState(ctx context.Context, config *Config) (*StateSnapshot, error) {
+	return sr.runnable.GetState(ctx, config)
+}
+
+// GetStateHistory iterates over the checkpoints of the thread of config, newest first
+func (sr *StreamingRunnable) GetStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	return sr.runnable.GetStateHistory(ctx, config)
+}
+
+// UpdateState updates the state of the thread of config, forking it from
+// Configurable["checkpoint_id"] if set
+func (sr *StreamingRunnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	return sr.runnable.UpdateState(ctx, config, values, asNode)
+}
+
+// getState retrieves the state of the latest checkpoint of the thread, or of
+// Configurable["checkpoint_id"]
+func (ts threadStates) getState(ctx context.Context, config *Config) (*StateSnapshot, error) {
+	if ts.store == nil {
+		return nil, ErrCheckpointerNotConfigured
 	}
+	threadID := ts.threadID(config)
+	checkpointID := checkpointIDFromConfig(config)
 
 	var checkpoint *Checkpoint
 	var err error
 
 	if checkpointID != "" {
-		checkpoint, err = cr.config.Store.Load(ctx, checkpointID)
+		checkpoint, err = ts.store.Load(ctx, checkpointID)
+	} else if threadID == "" {
+		return nil, errThreadIDRequired
 	} else {
-		// Get latest checkpoint for the thread
-		// Note: List returns all checkpoints. We need to find the latest one.
-		// This is inefficient for large histories. Real implementations should have GetLatest.
-		checkpoints, err := cr.config.Store.List(ctx, threadID)
-		if err == nil && len(checkpoints) > 0 {
-			// Assuming List returns in some order, or we sort.
-			// For now, assume the last one is latest (based on implementation of MemoryStore)
-			checkpoint = checkpoints[len(checkpoints)-1]
-		}
+		checkpoint, err = latestCheckpoint(ctx, ts.store, threadID)
 	}
 
+	if err != nil {
+		return nil, err
+	}
+	checkpoint, err = ts.migrations.Migrate(ctx, checkpoint)
 	if err != nil {
 		return nil, err
 	}
@@ -437,15 +829,107 @@ func (cr *CheckpointableRunnable) GetState(ctx context.Context, config *Config)
 	if checkpoint == nil {
 		return &StateSnapshot{
 			Values: nil,
-			Config: *config,
+			Config: Config{
+				Configurable: map[string]interface{}{
+					"thread_id": threadID,
+				},
+			},
 		}, nil
 	}
 
-	// Construct snapshot
-	snapshot := &StateSnapshot{
+	return newStateSnapshot(checkpoint, threadID), nil
+}
+
+// historyPageSize is the number of checkpoints fetched at a time by GetStateHistory
+const historyPageSize = 50
+
+// getStateHistory iterates over the checkpoints of a thread, newest first,
+// with their states upgraded to the current schema version.
+// With Configurable["checkpoint_id"] it walks the parent links of that
+// checkpoint back to the start of the thread, i.e. a single branch. Otherwise
+// it yields the checkpoints of every branch; their ParentID links form the tree.
+func (ts threadStates) getStateHistory(ctx context.Context, config *Config) iter.Seq2[*StateSnapshot, error] {
+	threadID := ts.threadID(config)
+	checkpointID := checkpointIDFromConfig(config)
+	migrations := ts.migrations
+
+	return func(yield func(*StateSnapshot, error) bool) {
+		if ts.store == nil {
+			yield(nil, ErrCheckpointerNotConfigured)
+			return
+		}
+		if threadID == "" && checkpointID == "" {
+			yield(nil, errThreadIDRequired)
+			return
+		}
+
+		snapshot := func(checkpoint *Checkpoint) bool {
+			migrated, err := migrations.Migrate(ctx, checkpoint)
+			if err != nil {
+				yield(nil, err)
+				return false
+			}
+			return yield(newStateSnapshot(migrated, threadID), nil)
+		}
+
+		if checkpointID != "" {
+			for id := checkpointID; id != ""; {
+				checkpoint, err := ts.store.Load(ctx, id)
+				if err != nil {
+					yield(nil, fmt.Errorf("failed to load checkpoint: %w", err))
+					return
+				}
+				if !snapshot(checkpoint) {
+					return
+				}
+				id = checkpoint.ParentID
+			}
+			return
+		}
+
+		v2, ok := ts.store.(CheckpointStoreV2)
+		if !ok {
+			checkpoints, err := ts.store.List(ctx, threadID)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to list checkpoints: %w", err))
+				return
+			}
+			for _, checkpoint := range FilterCheckpoints(checkpoints, CheckpointListOptions{}) {
+				if !snapshot(checkpoint) {
+					return
+				}
+			}
+			return
+		}
+
+		opts := CheckpointListOptions{Limit: historyPageSize}
+		for {
+			page, err := v2.ListWithOptions(ctx, threadID, opts)
+			if err != nil {
+				yield(nil, fmt.Errorf("failed to list checkpoints: %w", err))
+				return
+			}
+			for _, checkpoint := range page {
+				if !snapshot(checkpoint) {
+					return
+				}
+			}
+			if len(page) < historyPageSize {
+				return
+			}
+			opts.Before = page[len(page)-1].ID
+		}
+	}
+}
+
+// newStateSnapshot describes a checkpoint of a thread
+func newStateSnapshot(checkpoint *Checkpoint, threadID string) *StateSnapshot {
+	return &StateSnapshot{
 		Values:    checkpoint.State,
+		Next:      checkpoint.Next,
 		CreatedAt: checkpoint.Timestamp,
 		Metadata:  checkpoint.Metadata,
+		ParentID:  checkpoint.ParentID,
 		Config: Config{
 			Configurable: map[string]interface{}{
 				"thread_id":     threadID,
@@ -453,52 +937,79 @@ func (cr *CheckpointableRunnable) GetState(ctx context.Context, config *Config)
 			},
 		},
 	}
-
-	// Determine "Next" nodes
-	// This is tricky without re-running the graph logic or storing it in the checkpoint.
-	// For now, we might leave it empty or try to infer it if we stored it.
-	// In the future, Checkpoint should store "Next" nodes.
-
-	return snapshot, nil
 }
 
-// UpdateState updates the state for the given config
-func (cr *CheckpointableRunnable) UpdateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
-	var threadID string
-	if config != nil && config.Configurable != nil {
-		if tid, ok := config.Configurable["thread_id"].(string); ok {
-			threadID = tid
+// updateState saves a checkpoint merging values into the latest checkpoint
+// of the thread, or into Configurable["checkpoint_id"] to fork a new branch
+func (ts threadStates) updateState(ctx context.Context, config *Config, values interface{}, asNode string) (*Config, error) {
+	if ts.store == nil {
+		return nil, ErrCheckpointerNotConfigured
+	}
+	threadID := threadIDFromConfig(config)
+	checkpointID := checkpointIDFromConfig(config)
+
+	// 1. Get current state
+	// We need to find the checkpoint to merge against
+	var base *Checkpoint
+	var err error
+	if checkpointID != "" {
+		base, err = ts.store.Load(ctx, checkpointID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load checkpoint: %w", err)
+		}
+		if threadID == "" {
+			threadID = checkpointThreadID(base)
 		}
 	}
 
 	if threadID == "" {
-		threadID = cr.executionID
+		threadID = ts.defaultThreadID
+	}
+	if threadID == "" {
+		return nil, errThreadIDRequired
+	}
+
+	// Versions keep growing on the thread, even when forking an older checkpoint
+	latest, err := latestCheckpoint(ctx, ts.store, threadID)
+	if err != nil {
+		return nil, err
+	}
+	if base == nil {
+		base = latest
+	}
+	base, err = ts.migrations.Migrate(ctx, base)
+	if err != nil {
+		return nil, err
 	}
 
-	// 1. Get current state
-	// We need to find the latest checkpoint for this thread to merge against
-	checkpoints, err := cr.config.Store.List(ctx, threadID)
 	var currentState interface{}
 	var currentVersion int
+	var next []string
+	var parentID string
+	var latestID string
 
-	if err == nil && len(checkpoints) > 0 {
-		// Assume last is latest
-		latest := checkpoints[len(checkpoints)-1]
-		currentState = latest.State
+	if latest != nil {
 		currentVersion = latest.Version
+		latestID = latest.ID
+	}
+	if base != nil {
+		parentID = base.ID
+		currentState = base.State
+		// The pending nodes still run when the thread resumes
+		next = base.Next
 	} else {
 		// No existing state, initialize if schema exists
-		if cr.runnable.graph.Schema != nil {
-			currentState = cr.runnable.graph.Schema.Init()
+		if ts.schema != nil {
+			currentState = ts.schema.Init()
 		}
 	}
 
 	// 2. Merge values
 	newState := values
-	if cr.runnable.graph.Schema != nil {
+	if ts.schema != nil {
 		// If we have a current state, merge into it
 		if currentState != nil {
-			newState, err = cr.runnable.graph.Schema.Update(currentState, values)
+			newState, err = ts.schema.Update(currentState, values)
 			if err != nil {
 				return nil, fmt.Errorf("failed to merge state: %w", err)
 			}
@@ -506,8 +1017,8 @@ func (cr *CheckpointableRunnable) UpdateState(ctx context.Context, config *Confi
 			// If no current state, maybe Init + Update?
 			// Or just use values if it matches schema?
 			// Let's try Init + Update
-			initial := cr.runnable.graph.Schema.Init()
-			newState, err = cr.runnable.graph.Schema.Update(initial, values)
+			initial := ts.schema.Init()
+			newState, err = ts.schema.Update(initial, values)
 			if err != nil {
 				return nil, fmt.Errorf("failed to initialize and merge state: %w", err)
 			}
@@ -537,16 +1048,20 @@ func (cr *CheckpointableRunnable) UpdateState(ctx context.Context, config *Confi
 		ID:        generateCheckpointID(),
 		NodeName:  asNode, // The node that "made" this update
 		State:     newState,
+		Next:      next,
+		ParentID:  parentID,
 		Timestamp: time.Now(),
 		Version:   currentVersion + 1,
 		Metadata: map[string]interface{}{
 			"execution_id": threadID,
+			"thread_id":    threadID,
 			"source":       "update_state",
 			"updated_by":   asNode,
 		},
 	}
+	ts.migrations.stamp(checkpoint.Metadata)
 
-	if err := cr.config.Store.Save(ctx, checkpoint); err != nil {
+	if err := saveCheckpoint(ctx, ts.store, checkpoint, latestID); err != nil {
 		return nil, err
 	}
 
@@ -4,6 +4,14 @@ import "context"
 
 type resumeValueKey struct{}
 
+type runIDKey struct{}
+
+type stepKey struct{}
+
+type nodeNameKey struct{}
+
+type nodeRunIDKey struct{}
+
 // WithResumeValue adds a resume value to the context.
 // This value will be returned by Interrupt() when re-executing a node.
 func WithResumeValue(ctx context.Context, value interface{}) context.Context {
@@ -14,3 +22,50 @@ func WithResumeValue(ctx context.Context, value interface{}) context.Context {
 func GetResumeValue(ctx context.Context) interface{} {
 	return ctx.Value(resumeValueKey{})
 }
+
+// withRunID adds the ID of the current run to the context
+func withRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, runIDKey{}, runID)
+}
+
+// GetRunID returns the ID of the graph run executing the node, or "" outside a run.
+// It is the run ID passed to callbacks.
+func GetRunID(ctx context.Context) string {
+	runID, _ := ctx.Value(runIDKey{}).(string)
+	return runID
+}
+
+// withStep adds the superstep of the current node to the context
+func withStep(ctx context.Context, step int) context.Context {
+	return context.WithValue(ctx, stepKey{}, step)
+}
+
+// GetStep returns the superstep executing the node, starting at 1, or 0 outside a run
+func GetStep(ctx context.Context) int {
+	step, _ := ctx.Value(stepKey{}).(int)
+	return step
+}
+
+// withNodeName adds the name of the current node to the context
+func withNodeName(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, nodeNameKey{}, name)
+}
+
+// GetNodeName returns the name of the node being executed, or "" outside a node
+func GetNodeName(ctx context.Context) string {
+	name, _ := ctx.Value(nodeNameKey{}).(string)
+	return name
+}
+
+// withNodeRunID adds the run ID of the current node to the context
+func withNodeRunID(ctx context.Context, runID string) context.Context {
+	return context.WithValue(ctx, nodeRunIDKey{}, runID)
+}
+
+// GetNodeRunID returns the run ID of the node being executed, or "" outside a node.
+// It is the run ID passed to node callbacks, and the parent run ID of subgraphs
+// invoked by the node.
+func GetNodeRunID(ctx context.Context) string {
+	runID, _ := ctx.Value(nodeRunIDKey{}).(string)
+	return runID
+}
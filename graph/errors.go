@@ -1,6 +1,26 @@
 package graph
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
+
+// ConcurrentUpdateError is returned when a checkpoint is saved on a thread
+// whose latest checkpoint changed since it was read, e.g. because another
+// worker resumed the same thread. Callers can retry the invocation, which
+// continues from the new latest checkpoint, or reject it.
+type ConcurrentUpdateError struct {
+	// ThreadID is the thread that was updated concurrently
+	ThreadID string
+	// ExpectedID is the latest checkpoint the writer read, empty for a new thread
+	ExpectedID string
+	// ActualID is the latest checkpoint found when saving
+	ActualID string
+}
+
+func (e *ConcurrentUpdateError) Error() string {
+	return fmt.Sprintf("concurrent update of thread %s: expected latest checkpoint %q, found %q", e.ThreadID, e.ExpectedID, e.ActualID)
+}
 
 // NodeInterrupt is returned when a node requests an interrupt (e.g. waiting for human input).
 type NodeInterrupt struct {
@@ -13,3 +33,37 @@ type NodeInterrupt struct {
 func (e *NodeInterrupt) Error() string {
 	return fmt.Sprintf("interrupt at node %s: %v", e.Node, e.Value)
 }
+
+// BudgetExceededError is returned when a run used up a limit of its Budget.
+// The run stops between supersteps and, on a checkpointed thread, saves its
+// position, so that invoking the thread again with a nil input and a raised
+// budget resumes it.
+type BudgetExceededError struct {
+	// Limit is the limit that was used up
+	Limit BudgetLimit
+	// Budget is the budget of the run
+	Budget Budget
+	// Used is the consumption of the run when it stopped
+	Used BudgetUsage
+	// State is the state of the run when it stopped
+	State interface{}
+	// NextNodes are the nodes that would have run next
+	NextNodes []string
+}
+
+func (e *BudgetExceededError) Error() string {
+	var used, max interface{}
+	switch e.Limit {
+	case BudgetLimitTokens:
+		used, max = e.Used.Tokens, e.Budget.MaxTokens
+	case BudgetLimitCost:
+		used, max = fmt.Sprintf("%.4f", e.Used.Cost), fmt.Sprintf("%.4f", e.Budget.MaxCost)
+	case BudgetLimitLLMCalls:
+		used, max = e.Used.LLMCalls, e.Budget.MaxLLMCalls
+	case BudgetLimitDuration:
+		used, max = e.Used.Duration.Round(time.Millisecond), e.Budget.MaxDuration
+	case BudgetLimitSteps:
+		used, max = e.Used.Steps, e.Budget.MaxSteps
+	}
+	return fmt.Sprintf("budget exceeded: used %v %s of %v before nodes %v", used, e.Limit, max, e.NextNodes)
+}
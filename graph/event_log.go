@@ -0,0 +1,271 @@
+package graph
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxStreamEvents is the default number of events an event log keeps per run
+const DefaultMaxStreamEvents = 1000
+
+// DefaultMaxStreamRuns is the default number of runs a memory event log keeps
+const DefaultMaxStreamRuns = 1000
+
+// DefaultStreamEventTTL is the default time a memory event log keeps a run after its last event
+const DefaultStreamEventTTL = time.Hour
+
+// ErrEventLogNotConfigured is returned when a stream is resumed without an event log
+var ErrEventLogNotConfigured = errors.New("event log not configured")
+
+// EventLog persists the stream events of a run so that a client which reconnects
+// can replay the events it missed before following the live stream again.
+// Implementations are bounded: only the most recent events of each run are kept.
+type EventLog interface {
+	// Append stores an event for the run and returns it with its Sequence assigned.
+	// Sequences start at 1 and increase by one for every appended event.
+	Append(ctx context.Context, runID string, event StreamEvent) (StreamEvent, error)
+
+	// ReadAfter returns the retained events of the run whose Sequence is greater
+	// than afterSequence, oldest first
+	ReadAfter(ctx context.Context, runID string, afterSequence int64) ([]StreamEvent, error)
+
+	// Clear removes all events of the run
+	Clear(ctx context.Context, runID string) error
+}
+
+// streamEventJSON is the wire format of a StreamEvent.
+// Errors are stored as their message since error values cannot be decoded.
+type streamEventJSON struct {
+	RunID     string                 `json:"run_id,omitempty"`
+	Sequence  int64                  `json:"sequence,omitempty"`
+	Timestamp time.Time              `json:"timestamp"`
+	NodeName  string                 `json:"node_name,omitempty"`
+	Event     NodeEvent              `json:"event"`
+	State     interface{}            `json:"state,omitempty"`
+	Error     string                 `json:"error,omitempty"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Duration  time.Duration          `json:"duration,omitempty"`
+}
+
+// MarshalJSON implements json.Marshaler
+func (e StreamEvent) MarshalJSON() ([]byte, error) {
+	data := streamEventJSON{
+		RunID:     e.RunID,
+		Sequence:  e.Sequence,
+		Timestamp: e.Timestamp,
+		NodeName:  e.NodeName,
+		Event:     e.Event,
+		State:     e.State,
+		Metadata:  e.Metadata,
+		Duration:  e.Duration,
+	}
+	if e.Error != nil {
+		data.Error = e.Error.Error()
+	}
+	return json.Marshal(data)
+}
+
+// UnmarshalJSON implements json.Unmarshaler
+func (e *StreamEvent) UnmarshalJSON(b []byte) error {
+	var data streamEventJSON
+	if err := json.Unmarshal(b, &data); err != nil {
+		return err
+	}
+
+	*e = StreamEvent{
+		RunID:     data.RunID,
+		Sequence:  data.Sequence,
+		Timestamp: data.Timestamp,
+		NodeName:  data.NodeName,
+		Event:     data.Event,
+		State:     data.State,
+		Metadata:  data.Metadata,
+		Duration:  data.Duration,
+	}
+	if data.Error != "" {
+		e.Error = errors.New(data.Error)
+	}
+	return nil
+}
+
+// MemoryEventLog provides in-memory event log storage. Runs without new
+// events for the TTL are evicted, as are the least recently appended runs
+// beyond MaxRuns.
+type MemoryEventLog struct {
+	runs      map[string]*list.Element
+	recent    *list.List // of *memoryRunLog, most recently appended first
+	maxEvents int
+	maxRuns   int
+	ttl       time.Duration
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+type memoryRunLog struct {
+	runID        string
+	events       []StreamEvent
+	lastSequence int64
+	updated      time.Time
+}
+
+// MemoryEventLogOptions configuration for the in-memory event log
+type MemoryEventLogOptions struct {
+	MaxEvents int           // Events kept per run, default DefaultMaxStreamEvents
+	MaxRuns   int           // Runs kept, default DefaultMaxStreamRuns
+	TTL       time.Duration // Time a run is kept after its last event, default DefaultStreamEventTTL
+}
+
+// NewMemoryEventLog creates a new in-memory event log keeping at most maxEvents per run.
+// A non-positive maxEvents uses DefaultMaxStreamEvents.
+func NewMemoryEventLog(maxEvents int) *MemoryEventLog {
+	return NewMemoryEventLogWithOptions(MemoryEventLogOptions{MaxEvents: maxEvents})
+}
+
+// NewMemoryEventLogWithOptions creates a new in-memory event log.
+// Non-positive options use their defaults.
+func NewMemoryEventLogWithOptions(opts MemoryEventLogOptions) *MemoryEventLog {
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = DefaultMaxStreamEvents
+	}
+	if opts.MaxRuns <= 0 {
+		opts.MaxRuns = DefaultMaxStreamRuns
+	}
+	if opts.TTL <= 0 {
+		opts.TTL = DefaultStreamEventTTL
+	}
+	return &MemoryEventLog{
+		runs:      make(map[string]*list.Element),
+		recent:    list.New(),
+		maxEvents: opts.MaxEvents,
+		maxRuns:   opts.MaxRuns,
+		ttl:       opts.TTL,
+		now:       time.Now,
+	}
+}
+
+// Append implements EventLog interface
+func (m *MemoryEventLog) Append(_ context.Context, runID string, event StreamEvent) (StreamEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := m.now()
+	var run *memoryRunLog
+	if element, ok := m.runs[runID]; ok {
+		run = element.Value.(*memoryRunLog)
+		m.recent.MoveToFront(element)
+	} else {
+		run = &memoryRunLog{runID: runID}
+		m.runs[runID] = m.recent.PushFront(run)
+	}
+	run.updated = now
+
+	run.lastSequence++
+	event.RunID = runID
+	event.Sequence = run.lastSequence
+
+	run.events = append(run.events, event)
+	if len(run.events) > m.maxEvents {
+		// Copy to release the trimmed events instead of re-slicing forever
+		trimmed := make([]StreamEvent, m.maxEvents)
+		copy(trimmed, run.events[len(run.events)-m.maxEvents:])
+		run.events = trimmed
+	}
+
+	m.evict(now)
+
+	return event, nil
+}
+
+// evict removes the runs idle for longer than the TTL and the least
+// recently appended runs beyond the cap
+func (m *MemoryEventLog) evict(now time.Time) {
+	for element := m.recent.Back(); element != nil; element = m.recent.Back() {
+		run := element.Value.(*memoryRunLog)
+		if m.recent.Len() <= m.maxRuns && now.Sub(run.updated) <= m.ttl {
+			return
+		}
+		m.recent.Remove(element)
+		delete(m.runs, run.runID)
+	}
+}
+
+// ReadAfter implements EventLog interface
+func (m *MemoryEventLog) ReadAfter(_ context.Context, runID string, afterSequence int64) ([]StreamEvent, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.evict(m.now())
+	element, ok := m.runs[runID]
+	if !ok {
+		return []StreamEvent{}, nil
+	}
+	run := element.Value.(*memoryRunLog)
+
+	events := make([]StreamEvent, 0)
+	for _, event := range run.events {
+		if event.Sequence > afterSequence {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Clear implements EventLog interface
+func (m *MemoryEventLog) Clear(_ context.Context, runID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if element, ok := m.runs[runID]; ok {
+		m.recent.Remove(element)
+		delete(m.runs, runID)
+	}
+	return nil
+}
+
+// LastEventID extracts the last sequence a reconnecting client has seen.
+// It reads the standard Server-Sent Events "Last-Event-ID" header and falls
+// back to the "last_event_id" query parameter for clients that cannot set headers.
+// It returns 0 (replay everything) when neither is present or valid.
+func LastEventID(r *http.Request) int64 {
+	value := r.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = r.URL.Query().Get("last_event_id")
+	}
+
+	sequence, err := strconv.ParseInt(strings.TrimSpace(value), 10, 64)
+	if err != nil || sequence < 0 {
+		return 0
+	}
+	return sequence
+}
+
+// WriteSSE writes the event to w in Server-Sent Events format, using its
+// Sequence as the event id so browsers send it back as Last-Event-ID
+func WriteSSE(w io.Writer, event StreamEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal stream event: %w", err)
+	}
+
+	if event.Sequence > 0 {
+		if _, err := fmt.Fprintf(w, "id: %d\n", event.Sequence); err != nil {
+			return err
+		}
+	}
+	if event.Event != "" {
+		if _, err := fmt.Fprintf(w, "event: %s\n", event.Event); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+	return err
+}
@@ -0,0 +1,333 @@
+package graph
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryEventLog(t *testing.T) {
+	log := NewMemoryEventLog(3)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		event, err := log.Append(ctx, "run-1", StreamEvent{Event: NodeEventComplete, NodeName: "A"})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(i+1), event.Sequence)
+		assert.Equal(t, "run-1", event.RunID)
+	}
+
+	// Only the last 3 events are retained
+	events, err := log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 3)
+	assert.Equal(t, int64(3), events[0].Sequence)
+	assert.Equal(t, int64(5), events[2].Sequence)
+
+	events, err = log.ReadAfter(ctx, "run-1", 4)
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, int64(5), events[0].Sequence)
+
+	// Runs are independent
+	event, err := log.Append(ctx, "run-2", StreamEvent{Event: NodeEventStart})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), event.Sequence)
+
+	assert.NoError(t, log.Clear(ctx, "run-1"))
+	events, err = log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func TestMemoryEventLog_Eviction(t *testing.T) {
+	log := NewMemoryEventLogWithOptions(MemoryEventLogOptions{MaxRuns: 2, TTL: time.Minute})
+	now := time.Now()
+	log.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	_, err := log.Append(ctx, "run-1", StreamEvent{Event: NodeEventStart})
+	assert.NoError(t, err)
+	_, err = log.Append(ctx, "run-2", StreamEvent{Event: NodeEventStart})
+	assert.NoError(t, err)
+	_, err = log.Append(ctx, "run-1", StreamEvent{Event: NodeEventComplete})
+	assert.NoError(t, err)
+
+	// The least recently appended run is evicted beyond the cap
+	_, err = log.Append(ctx, "run-3", StreamEvent{Event: NodeEventStart})
+	assert.NoError(t, err)
+	events, err := log.ReadAfter(ctx, "run-2", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	events, err = log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	// Runs without new events for the TTL are evicted
+	now = now.Add(30 * time.Second)
+	_, err = log.Append(ctx, "run-3", StreamEvent{Event: NodeEventComplete})
+	assert.NoError(t, err)
+	now = now.Add(45 * time.Second)
+	events, err = log.ReadAfter(ctx, "run-1", 0)
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+	events, err = log.ReadAfter(ctx, "run-3", 0)
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Len(t, log.runs, 1)
+}
+
+func TestStreamEventJSON(t *testing.T) {
+	event := StreamEvent{
+		Timestamp: time.Now().UTC().Truncate(time.Millisecond),
+		NodeName:  "A",
+		Event:     NodeEventError,
+		State:     map[string]interface{}{"count": float64(1)},
+		Error:     errors.New("boom"),
+		Duration:  time.Second,
+		RunID:     "run-1",
+		Sequence:  7,
+	}
+
+	data, err := json.Marshal(event)
+	assert.NoError(t, err)
+
+	var decoded StreamEvent
+	assert.NoError(t, json.Unmarshal(data, &decoded))
+	assert.Equal(t, event.Timestamp, decoded.Timestamp)
+	assert.Equal(t, event.NodeName, decoded.NodeName)
+	assert.Equal(t, event.Event, decoded.Event)
+	assert.Equal(t, event.State, decoded.State)
+	assert.EqualError(t, decoded.Error, "boom")
+	assert.Equal(t, event.Duration, decoded.Duration)
+	assert.Equal(t, event.RunID, decoded.RunID)
+	assert.Equal(t, event.Sequence, decoded.Sequence)
+}
+
+func TestWriteSSEAndLastEventID(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteSSE(&buf, StreamEvent{Event: NodeEventComplete, NodeName: "A", Sequence: 42})
+	assert.NoError(t, err)
+	assert.Contains(t, buf.String(), "id: 42\n")
+	assert.Contains(t, buf.String(), "event: complete\n")
+	assert.Contains(t, buf.String(), "data: {")
+
+	req := httptest.NewRequest("GET", "/stream", nil)
+	req.Header.Set("Last-Event-ID", "42")
+	assert.Equal(t, int64(42), LastEventID(req))
+
+	req = httptest.NewRequest("GET", "/stream?last_event_id=7", nil)
+	assert.Equal(t, int64(7), LastEventID(req))
+
+	req = httptest.NewRequest("GET", "/stream", nil)
+	assert.Equal(t, int64(0), LastEventID(req))
+}
+
+func TestStreamingRunnable_ResumeAfterCompletion(t *testing.T) {
+	g := NewStreamingMessageGraph()
+	g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return "A", nil
+	})
+	g.AddNode("B", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return "B", nil
+	})
+	g.SetEntryPoint("A")
+	g.AddEdge("A", "B")
+	g.AddEdge("B", END)
+
+	config := DefaultStreamConfig()
+	config.EventLog = NewMemoryEventLog(0)
+	g.SetStreamConfig(config)
+
+	runnable, err := g.CompileStreaming()
+	assert.NoError(t, err)
+
+	res := runnable.Stream(context.Background(), "start")
+	var events []StreamEvent
+	for event := range res.Events {
+		events = append(events, event)
+	}
+	assert.NotEmpty(t, events)
+	for i, event := range events {
+		assert.Equal(t, res.RunID, event.RunID)
+		assert.Equal(t, int64(i+1), event.Sequence)
+	}
+
+	// Client saw only the first two events before disconnecting
+	resumed := runnable.Resume(context.Background(), res.RunID, 2)
+	var replayed []StreamEvent
+	for event := range resumed.Events {
+		replayed = append(replayed, event)
+	}
+	assert.Len(t, replayed, len(events)-2)
+	assert.Equal(t, int64(3), replayed[0].Sequence)
+	assert.Equal(t, events[len(events)-1].Sequence, replayed[len(replayed)-1].Sequence)
+}
+
+func TestStreamingRunnable_ResumeFollowsLiveRun(t *testing.T) {
+	release := make(chan struct{})
+
+	g := NewStreamingMessageGraph()
+	g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return "A", nil
+	})
+	g.AddNode("B", func(ctx context.Context, state interface{}) (interface{}, error) {
+		<-release
+		return "B", nil
+	})
+	g.SetEntryPoint("A")
+	g.AddEdge("A", "B")
+	g.AddEdge("B", END)
+
+	config := DefaultStreamConfig()
+	config.EventLog = NewMemoryEventLog(0)
+	g.SetStreamConfig(config)
+
+	runnable, err := g.CompileStreaming()
+	assert.NoError(t, err)
+
+	res := runnable.Stream(context.Background(), "start")
+
+	// Wait until B has started, then "disconnect"
+	var lastSeen int64
+	for event := range res.Events {
+		lastSeen = event.Sequence
+		if event.NodeName == "B" && event.Event == NodeEventStart {
+			break
+		}
+	}
+
+	resumed := runnable.Resume(context.Background(), res.RunID, 1)
+	close(release)
+
+	var replayed []StreamEvent
+	for event := range resumed.Events {
+		replayed = append(replayed, event)
+	}
+
+	assert.NotEmpty(t, replayed)
+	assert.Equal(t, int64(2), replayed[0].Sequence)
+	for i := 1; i < len(replayed); i++ {
+		assert.Equal(t, replayed[i-1].Sequence+1, replayed[i].Sequence, "events must be gapless and in order")
+	}
+	assert.Greater(t, replayed[len(replayed)-1].Sequence, lastSeen)
+
+	completedB := false
+	for _, event := range replayed {
+		if event.NodeName == "B" && event.Event == NodeEventComplete {
+			completedB = true
+		}
+	}
+	assert.True(t, completedB)
+}
+
+func TestStreamingRunnable_ResumeFillsDroppedEvents(t *testing.T) {
+	release := make(chan struct{})
+
+	g := NewStreamingMessageGraph()
+	g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) {
+		<-release
+		return "A", nil
+	})
+	previous := "A"
+	for i := 0; i < 20; i++ {
+		name := fmt.Sprintf("N%d", i)
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+			return name, nil
+		})
+		g.AddEdge(previous, name)
+		previous = name
+	}
+	g.AddEdge(previous, END)
+	g.SetEntryPoint("A")
+
+	config := DefaultStreamConfig()
+	config.BufferSize = 1
+	config.EventLog = NewMemoryEventLog(0)
+	g.SetStreamConfig(config)
+
+	runnable, err := g.CompileStreaming()
+	assert.NoError(t, err)
+
+	res := runnable.Stream(context.Background(), "start")
+	<-res.Events
+
+	// The replayed first event means the resumed stream follows the run
+	resumed := runnable.Resume(context.Background(), res.RunID, 0)
+	first := <-resumed.Events
+	assert.Equal(t, int64(1), first.Sequence)
+
+	// The run ends while the resumed client reads nothing, overflowing its subscription
+	close(release)
+	<-res.Done
+
+	last := first.Sequence
+	for event := range resumed.Events {
+		assert.Equal(t, last+1, event.Sequence, "events must be gapless and in order")
+		last = event.Sequence
+	}
+
+	logged, err := config.EventLog.ReadAfter(context.Background(), res.RunID, 0)
+	assert.NoError(t, err)
+	assert.Greater(t, len(logged), 40)
+	assert.Equal(t, logged[len(logged)-1].Sequence, last)
+}
+
+// failingEventLog is an event log whose appends fail
+type failingEventLog struct {
+	*MemoryEventLog
+}
+
+func (l failingEventLog) Append(context.Context, string, StreamEvent) (StreamEvent, error) {
+	return StreamEvent{}, errors.New("log unavailable")
+}
+
+func TestStreamingRunnable_ReportsEventLogErrors(t *testing.T) {
+	g := NewStreamingMessageGraph()
+	g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return "A", nil
+	})
+	g.SetEntryPoint("A")
+	g.AddEdge("A", END)
+
+	config := DefaultStreamConfig()
+	config.EventLog = failingEventLog{NewMemoryEventLog(0)}
+	g.SetStreamConfig(config)
+
+	runnable, err := g.CompileStreaming()
+	assert.NoError(t, err)
+
+	// Events still stream live and the run completes, the log failure is reported
+	res := runnable.Stream(context.Background(), "start")
+	var events []StreamEvent
+	for event := range res.Events {
+		events = append(events, event)
+	}
+	assert.NotEmpty(t, events)
+	assert.Equal(t, "A", <-res.Result)
+	err = <-res.Errors
+	assert.ErrorContains(t, err, "failed to log stream events: log unavailable")
+}
+
+func TestStreamingRunnable_ResumeWithoutEventLog(t *testing.T) {
+	g := NewStreamingMessageGraph()
+	g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return "A", nil
+	})
+	g.SetEntryPoint("A")
+	g.AddEdge("A", END)
+
+	runnable, err := g.CompileStreaming()
+	assert.NoError(t, err)
+
+	resumed := runnable.Resume(context.Background(), "unknown", 0)
+	err = <-resumed.Errors
+	assert.ErrorIs(t, err, ErrEventLogNotConfigured)
+}
@@ -0,0 +1,628 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// executor runs the superstep loop shared by every compiled graph type.
+// All nodes scheduled for a superstep run in parallel, their results are merged
+// into the state, and the next nodes are chosen from commands or edges.
+type executor struct {
+	nodes            map[string]Node
+	edges            []Edge
+	conditionalEdges map[string]func(ctx context.Context, state interface{}) string
+	entryPoint       string
+	schema           StateSchema
+	stateMerger      StateMerger
+	retryPolicy      *RetryPolicy
+	tracer           *Tracer
+
+	// checkpointer persists the state after every superstep when the run has a thread
+	checkpointer CheckpointStore
+	// defaultThreadID is used when the config carries no thread_id
+	defaultThreadID string
+	// retention prunes the checkpoints of a thread after every save, if set
+	retention *RetentionPolicy
+	// saveInterval is the minimum time between two checkpoints of a run, 0 saves every superstep
+	saveInterval time.Duration
+	// migrations upgrades the states of older checkpoints, if set
+	migrations *Migrations
+	// store is the long-term memory added to the context of nodes, if set
+	store Store
+
+	// runNode executes a single attempt of a node, e.g. to notify listeners.
+	// Defaults to calling the node function.
+	runNode func(ctx context.Context, node Node, state interface{}) (interface{}, error)
+}
+
+// invoke executes the graph with the given input state and config
+func (e *executor) invoke(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
+	state, _, err := e.invokeWithUsage(ctx, initialState, config)
+	return state, err
+}
+
+// invokeWithUsage executes the graph and returns the usage tracker of the run
+func (e *executor) invokeWithUsage(ctx context.Context, initialState interface{}, config *Config) (interface{}, *UsageTracker, error) {
+	if config != nil {
+		// Inject config into context
+		ctx = WithConfig(ctx, config)
+
+		// Inject ResumeValue
+		if config.ResumeValue != nil {
+			ctx = WithResumeValue(ctx, config.ResumeValue)
+		}
+	}
+
+	if e.store != nil {
+		ctx = ContextWithStore(ctx, e.store)
+	}
+
+	// Every run records its token usage
+	ctx, usage := withRunUsage(ctx, config)
+
+	// Graphs invoked from a node without a config report to the callbacks of
+	// their parent, the config of the parent stays the one nodes see
+	if config == nil && GetNodeRunID(ctx) != "" {
+		if parent := GetConfig(ctx); parent != nil && len(parent.Callbacks) > 0 {
+			config = &Config{Callbacks: parent.Callbacks, Tags: parent.Tags, Metadata: parent.Metadata}
+		}
+	}
+
+	// Graphs invoked from a traced node share the tracer of their parent
+	if e.tracer == nil {
+		e.tracer = TracerFromContext(ctx)
+	}
+	if e.tracer == nil {
+		state, err := e.run(ctx, initialState, config)
+		return state, usage, err
+	}
+	ctx = ContextWithTracer(ctx, e.tracer)
+
+	// The run span ends however the run does, node spans are its children
+	metadata := make(map[string]interface{})
+	if threadID := threadIDFromConfig(config); threadID != "" {
+		metadata["thread_id"] = threadID
+	}
+	graphSpan := e.tracer.StartSpanWithMetadata(ctx, TraceEventGraphStart, "graph", metadata)
+	graphSpan.State = initialState
+	state, err := e.run(ContextWithSpan(ctx, graphSpan), initialState, config)
+	e.tracer.EndSpan(ctx, graphSpan, state, err)
+	return state, usage, err
+}
+
+// withRunUsage returns the usage tracker of the context, or attaches a new
+// one priced by the budget of the run
+func withRunUsage(ctx context.Context, config *Config) (context.Context, *UsageTracker) {
+	if usage := GetUsageTracker(ctx); usage != nil {
+		return ctx, usage
+	}
+	var prices PriceTable
+	if config != nil && config.Budget != nil {
+		prices = config.Budget.Prices
+	}
+	usage := NewUsageTracker(prices)
+	return WithUsageTracker(ctx, usage), usage
+}
+
+// run executes the supersteps of an invocation
+//
+//nolint:gocognit,cyclop // The superstep loop is inherently branchy
+func (e *executor) run(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
+	// Restore the thread from its checkpoint, if any
+	tc := e.threadCheckpointer(config)
+	start, err := tc.restore(ctx, e, initialState, config)
+	if err != nil {
+		return nil, err
+	}
+	if start.finished {
+		return start.state, nil
+	}
+
+	state := start.state
+	currentNodes := start.nodes
+	// Nodes resumed from a checkpoint were already interrupted before, don't stop again
+	skipInterruptBefore := start.resumed
+	// Nodes of the resumed superstep that completed before it stopped are not run again
+	completed := start.writes
+
+	// Generate run ID for callbacks and nodes, subgraphs are children of the node invoking them
+	var parentRunID *string
+	if nodeRunID := GetNodeRunID(ctx); nodeRunID != "" {
+		parentRunID = &nodeRunID
+	}
+	runID := generateRunID()
+	ctx = withRunID(ctx, runID)
+
+	// Count the consumption of runs with a budget, continuing from the invocations they resume
+	var meter *budgetMeter
+	if config != nil && config.Budget != nil {
+		meter = newBudgetMeter(*config.Budget, start.budgetUsage, budgetMeterFromContext(ctx))
+		ctx = withBudgetMeter(ctx, meter)
+		if tc != nil {
+			tc.meter = meter
+		}
+	}
+
+	// Notify callbacks of graph start
+	if config != nil && len(config.Callbacks) > 0 {
+		serialized := map[string]interface{}{
+			"name": "graph",
+			"type": "chain",
+		}
+		inputs := convertStateToMap(initialState)
+
+		for _, cb := range config.Callbacks {
+			cb.OnChainStart(ctx, serialized, inputs, runID, parentRunID, config.Tags, config.Metadata)
+		}
+	}
+
+	step := 0
+	for len(currentNodes) > 0 {
+		// Filter out END nodes
+		activeNodes := make([]string, 0, len(currentNodes))
+		for _, node := range currentNodes {
+			if node != END {
+				activeNodes = append(activeNodes, node)
+			}
+		}
+		currentNodes = activeNodes
+
+		if len(currentNodes) == 0 {
+			break
+		}
+
+		// Stop between supersteps once a budget is used up
+		if meter != nil {
+			if limit, used, exceeded := meter.exceeded(); exceeded {
+				if err := tc.ensureSaved(ctx, state, currentNodes); err != nil {
+					return nil, err
+				}
+				budgetErr := &BudgetExceededError{
+					Limit:     limit,
+					Budget:    meter.budget,
+					Used:      used,
+					State:     state,
+					NextNodes: currentNodes,
+				}
+				if len(config.Callbacks) > 0 {
+					for _, cb := range config.Callbacks {
+						cb.OnChainError(ctx, budgetErr, runID)
+					}
+				}
+				return state, budgetErr
+			}
+		}
+
+		// Check InterruptBefore
+		if config != nil && len(config.InterruptBefore) > 0 && !skipInterruptBefore {
+			for _, node := range currentNodes {
+				for _, interrupt := range config.InterruptBefore {
+					if node == interrupt {
+						if err := tc.ensureSaved(ctx, state, currentNodes); err != nil {
+							return nil, err
+						}
+						return state, &GraphInterrupt{Node: node, State: state, NextNodes: currentNodes}
+					}
+				}
+			}
+		}
+		skipInterruptBefore = false
+		step++
+		if meter != nil {
+			meter.addStep()
+		}
+
+		// Store each result as it completes, so that a failing sibling doesn't lose it
+		recordWrites := tc.recordsWrites(currentNodes)
+		if recordWrites {
+			if err := tc.ensureSaved(ctx, state, currentNodes); err != nil {
+				return nil, err
+			}
+		}
+
+		// Execute nodes in parallel
+		var wg sync.WaitGroup
+		results := make([]interface{}, len(currentNodes))
+		errorsList := make([]error, len(currentNodes))
+		panics := make([]interface{}, len(currentNodes))
+
+		for i, nodeName := range currentNodes {
+			if write, ok := completed[nodeName]; ok {
+				results[i] = write.result()
+				continue
+			}
+
+			node, ok := e.nodes[nodeName]
+			if !ok {
+				return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeName)
+			}
+
+			wg.Add(1)
+			go func(index int, n Node, name string) {
+				defer wg.Done()
+
+				// Re-raised on the caller's goroutine once the superstep finishes
+				defer func() {
+					if p := recover(); p != nil {
+						panics[index] = p
+					}
+				}()
+
+				// Start node tracing, spans started by the node are its children
+				nodeRunID := generateRunID()
+				nodeCtx := withNodeRunID(withNodeName(withStep(ctx, step), name), nodeRunID)
+				var nodeSpan *TraceSpan
+				if e.tracer != nil {
+					nodeSpan = e.tracer.StartSpanWithMetadata(ctx, TraceEventNodeStart, name, map[string]interface{}{"step": step})
+					nodeSpan.State = state
+					nodeCtx = ContextWithSpan(nodeCtx, nodeSpan)
+				}
+
+				notifyNodeStart(nodeCtx, config, name, state, nodeRunID, runID)
+
+				// Pass the current state to the node
+				// Note: If state is mutable and shared, this is not thread-safe unless handled by user.
+				res, err := e.executeNodeWithRetry(nodeCtx, n, state)
+
+				// End node tracing, failed nodes end as TraceEventNodeError
+				if nodeSpan != nil {
+					e.tracer.EndSpan(ctx, nodeSpan, res, err)
+				}
+
+				if err != nil {
+					var nodeInterrupt *NodeInterrupt
+					if errors.As(err, &nodeInterrupt) {
+						nodeInterrupt.Node = name
+					}
+					notifyNodeError(nodeCtx, config, name, err, nodeRunID)
+					errorsList[index] = fmt.Errorf("error in node %s: %w", name, err)
+					return
+				}
+
+				if recordWrites {
+					if err := tc.putWrite(ctx, name, res); err != nil {
+						notifyNodeError(nodeCtx, config, name, err, nodeRunID)
+						errorsList[index] = err
+						return
+					}
+				}
+
+				results[index] = res
+
+				// Callbacks see the update of the node, without the routing of commands
+				output := res
+				if cmd, ok := res.(*Command); ok {
+					output = cmd.Update
+				}
+				notifyNodeEnd(nodeCtx, config, name, output, nodeRunID)
+			}(i, node, nodeName)
+		}
+
+		wg.Wait()
+		completed = nil
+
+		for _, p := range panics {
+			if p != nil {
+				panic(p)
+			}
+		}
+
+		// Check for errors
+		for _, err := range errorsList {
+			if err != nil {
+				// Check for NodeInterrupt
+				var nodeInterrupt *NodeInterrupt
+				if errors.As(err, &nodeInterrupt) {
+					// The superstep re-runs on resume, except for nodes with pending writes
+					if saveErr := tc.ensureSaved(ctx, state, currentNodes); saveErr != nil {
+						return nil, saveErr
+					}
+					return state, &GraphInterrupt{
+						Node:           nodeInterrupt.Node,
+						State:          state,
+						InterruptValue: nodeInterrupt.Value,
+						NextNodes:      []string{nodeInterrupt.Node},
+					}
+				}
+
+				// Notify callbacks of error
+				if config != nil && len(config.Callbacks) > 0 {
+					for _, cb := range config.Callbacks {
+						cb.OnChainError(ctx, err, runID)
+					}
+				}
+				return nil, err
+			}
+		}
+
+		// Process results and check for Commands
+		var nextNodesFromCommands []string
+		processedResults := make([]interface{}, len(results))
+
+		for i, res := range results {
+			if cmd, ok := res.(*Command); ok {
+				// It's a Command
+				processedResults[i] = cmd.Update
+
+				if cmd.Goto != nil {
+					var targets []string
+					switch g := cmd.Goto.(type) {
+					case string:
+						targets = []string{g}
+					case []string:
+						targets = g
+					}
+					for _, target := range targets {
+						e.traceEdge(ctx, currentNodes[i], target)
+					}
+					nextNodesFromCommands = append(nextNodesFromCommands, targets...)
+				}
+			} else {
+				// Regular result
+				processedResults[i] = res
+			}
+		}
+
+		// Merge results (using processedResults)
+		state, err = e.mergeResults(ctx, state, processedResults)
+		if err != nil {
+			return nil, err
+		}
+
+		// Determine next nodes
+		nextNodesList, err := e.nextNodes(ctx, currentNodes, state, nextNodesFromCommands)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep track of nodes that ran for callbacks
+		nodesRan := make([]string, len(currentNodes))
+		copy(nodesRan, currentNodes)
+
+		// Update currentNodes
+		currentNodes = nextNodesList
+
+		// Cleanup ephemeral state if supported
+		if cleaningSchema, ok := e.schema.(CleaningStateSchema); ok {
+			state = cleaningSchema.Cleanup(state)
+		}
+
+		// Persist the superstep before anything can observe or interrupt it
+		if err := tc.save(ctx, nodesRan, state, nextNodesList, "loop"); err != nil {
+			return nil, err
+		}
+
+		// Check InterruptAfter
+		if config != nil && len(config.InterruptAfter) > 0 {
+			for _, node := range nodesRan {
+				for _, interrupt := range config.InterruptAfter {
+					if node == interrupt {
+						return state, &GraphInterrupt{
+							Node:      node,
+							State:     state,
+							NextNodes: nextNodesList,
+						}
+					}
+				}
+			}
+		}
+
+		// Notify callbacks of step completion
+		if config != nil && len(config.Callbacks) > 0 {
+			for _, cb := range config.Callbacks {
+				if gcb, ok := cb.(GraphCallbackHandler); ok {
+					nodeName := fmt.Sprintf("step:%v", nodesRan)
+					gcb.OnGraphStep(ctx, nodeName, state)
+				}
+			}
+		}
+	}
+
+	// Notify callbacks of graph end
+	if config != nil && len(config.Callbacks) > 0 {
+		outputs := convertStateToMap(state)
+		for _, cb := range config.Callbacks {
+			cb.OnChainEnd(ctx, outputs, runID)
+		}
+	}
+
+	return state, nil
+}
+
+// mergeResults folds the results of a superstep into the state
+func (e *executor) mergeResults(ctx context.Context, state interface{}, results []interface{}) (interface{}, error) {
+	if e.schema != nil {
+		// If Schema is defined, use it to update state with results
+		for _, res := range results {
+			var err error
+			state, err = e.schema.Update(state, res)
+			if err != nil {
+				return nil, fmt.Errorf("schema update failed: %w", err)
+			}
+		}
+		return state, nil
+	}
+
+	if e.stateMerger != nil {
+		merged, err := e.stateMerger(ctx, state, results)
+		if err != nil {
+			return nil, fmt.Errorf("state merge failed: %w", err)
+		}
+		return merged, nil
+	}
+
+	// Default behavior
+	if len(results) > 0 {
+		return results[len(results)-1], nil
+	}
+	return state, nil
+}
+
+// nextNodes determines the nodes of the next superstep
+func (e *executor) nextNodes(ctx context.Context, currentNodes []string, state interface{}, fromCommands []string) ([]string, error) {
+	var nextNodesList []string
+
+	if len(fromCommands) > 0 {
+		// Command.Goto overrides static edges
+		// We deduplicate
+		seen := make(map[string]bool)
+		for _, n := range fromCommands {
+			if !seen[n] && n != END {
+				seen[n] = true
+				nextNodesList = append(nextNodesList, n)
+			}
+		}
+		return nextNodesList, nil
+	}
+
+	// Use static edges
+	nextNodesSet := make(map[string]bool)
+
+	for _, nodeName := range currentNodes {
+		// First check for conditional edges
+		nextNodeFn, hasConditional := e.conditionalEdges[nodeName]
+		if hasConditional {
+			nextNode := nextNodeFn(ctx, state)
+			if nextNode == "" {
+				return nil, fmt.Errorf("conditional edge returned empty next node from %s", nodeName)
+			}
+			e.traceEdge(ctx, nodeName, nextNode)
+			nextNodesSet[nextNode] = true
+		} else {
+			// Then check regular edges
+			foundNext := false
+			for _, edge := range e.edges {
+				if edge.From == nodeName {
+					e.traceEdge(ctx, nodeName, edge.To)
+					nextNodesSet[edge.To] = true
+					foundNext = true
+					// Do NOT break here, to allow fan-out (multiple edges from same node)
+				}
+			}
+
+			if !foundNext {
+				return nil, fmt.Errorf("%w: %s", ErrNoOutgoingEdge, nodeName)
+			}
+		}
+	}
+
+	for node := range nextNodesSet {
+		nextNodesList = append(nextNodesList, node)
+	}
+	// Sort so the schedule (and the checkpointed next nodes) are deterministic
+	sort.Strings(nextNodesList)
+
+	return nextNodesList, nil
+}
+
+// traceEdge records the traversal of an edge, if the graph is traced
+func (e *executor) traceEdge(ctx context.Context, from, to string) {
+	if e.tracer != nil {
+		e.tracer.TraceEdgeTraversal(ctx, from, to)
+	}
+}
+
+// executeNodeWithRetry executes a node with retry logic based on the retry policy.
+// With a retry policy each attempt is traced as a child of the node span.
+func (e *executor) executeNodeWithRetry(ctx context.Context, node Node, state interface{}) (interface{}, error) {
+	var lastErr error
+
+	maxRetries := 1 // Default: no retries
+	if e.retryPolicy != nil {
+		maxRetries = e.retryPolicy.MaxRetries + 1 // +1 for initial attempt
+	}
+
+	for attempt := 0; attempt < maxRetries; attempt++ {
+		run := func(ctx context.Context) (interface{}, error) {
+			if e.runNode != nil {
+				return e.runNode(ctx, node, state)
+			}
+			return node.Function(ctx, state)
+		}
+
+		var result interface{}
+		var err error
+		if e.retryPolicy != nil {
+			result, err = traceAttempt(ctx, node.Name, attempt+1, run)
+		} else {
+			result, err = run(ctx)
+		}
+		if err == nil {
+			return result, nil
+		}
+
+		lastErr = err
+
+		// Check if error is retryable
+		if e.retryPolicy != nil && attempt < maxRetries-1 {
+			if e.isRetryableError(err) {
+				// Apply backoff strategy
+				delay := e.calculateBackoffDelay(attempt)
+				if delay > 0 {
+					select {
+					case <-time.After(delay):
+						// Continue with retry after delay
+					case <-ctx.Done():
+						// Context cancelled, return immediately
+						return nil, ctx.Err()
+					}
+				}
+				continue
+			}
+		}
+
+		// If not retryable or max retries reached, return error
+		break
+	}
+
+	return nil, lastErr
+}
+
+// isRetryableError checks if an error is retryable based on the retry policy
+func (e *executor) isRetryableError(err error) bool {
+	if e.retryPolicy == nil {
+		return false
+	}
+
+	// Interrupts wait for human input, retrying them is pointless
+	var nodeInterrupt *NodeInterrupt
+	if errors.As(err, &nodeInterrupt) {
+		return false
+	}
+
+	errorStr := err.Error()
+	for _, retryablePattern := range e.retryPolicy.RetryableErrors {
+		if contains(errorStr, retryablePattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// calculateBackoffDelay calculates the delay for retry based on the backoff strategy
+func (e *executor) calculateBackoffDelay(attempt int) time.Duration {
+	if e.retryPolicy == nil {
+		return 0
+	}
+
+	baseDelay := time.Second // Default 1 second base delay
+
+	switch e.retryPolicy.BackoffStrategy {
+	case FixedBackoff:
+		return baseDelay
+	case ExponentialBackoff:
+		// Exponential backoff: 1s, 2s, 4s, 8s, ...
+		return baseDelay * time.Duration(1<<attempt)
+	case LinearBackoff:
+		// Linear backoff: 1s, 2s, 3s, 4s, ...
+		return baseDelay * time.Duration(attempt+1)
+	default:
+		return baseDelay
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"errors"
 	"fmt"
-	"sync"
 )
 
 // END is a special constant used to represent the end node in the graph.
@@ -90,6 +89,12 @@ type MessageGraph struct {
 
 	// Schema defines the state structure and update logic
 	Schema StateSchema
+
+	// migrations upgrades the states of older checkpoints, if set
+	migrations *Migrations
+
+	// structure records subgraphs, node groups and conditional branches for drawing
+	structure graphStructure
 }
 
 // NewMessageGraph creates a new instance of MessageGraph.
@@ -106,6 +111,7 @@ func (g *MessageGraph) AddNode(name string, fn func(ctx context.Context, state i
 		Name:     name,
 		Function: fn,
 	}
+	g.structure.forgetNode(name)
 }
 
 // AddEdge adds a new edge to the message graph between the "from" and "to" nodes.
@@ -120,6 +126,15 @@ func (g *MessageGraph) AddEdge(from, to string) {
 // The condition function receives the current state and returns the name of the next node.
 func (g *MessageGraph) AddConditionalEdge(from string, condition func(ctx context.Context, state interface{}) string) {
 	g.conditionalEdges[from] = condition
+	g.structure.forgetBranches(from)
+}
+
+// AddConditionalEdgeWithBranches adds a conditional edge whose condition returns
+// the name of a branch, routed to the node the branch maps to. Names missing
+// from branches are routed as node names. The xray drawing shows the branches.
+func (g *MessageGraph) AddConditionalEdgeWithBranches(from string, condition func(ctx context.Context, state interface{}) string, branches map[string]string) {
+	g.AddConditionalEdge(from, routeBranches(condition, branches))
+	g.structure.setBranches(from, branches)
 }
 
 // SetEntryPoint sets the entry point node name for the message graph.
@@ -137,24 +152,46 @@ func (g *MessageGraph) SetSchema(schema StateSchema) {
 	g.Schema = schema
 }
 
+// SetMigrations declares the schema version of the state and how checkpoints
+// of older versions are upgraded when they are loaded.
+func (g *MessageGraph) SetMigrations(migrations *Migrations) {
+	g.migrations = migrations
+}
+
 // Runnable represents a compiled message graph that can be invoked.
 type Runnable struct {
 	// graph is the underlying MessageGraph object.
 	graph *MessageGraph
 	// tracer is the optional tracer for observability
 	tracer *Tracer
+	// checkpointer is the optional store that makes threads durable
+	checkpointer CheckpointStore
+	// retention prunes the checkpoints of a thread, if set
+	retention *RetentionPolicy
+	// store is the long-term memory available to nodes, if set
+	store Store
 }
 
 // Compile compiles the message graph and returns a Runnable instance.
 // It returns an error if the entry point is not set.
-func (g *MessageGraph) Compile() (*Runnable, error) {
+func (g *MessageGraph) Compile(opts ...CompileOption) (*Runnable, error) {
 	if g.entryPoint == "" {
 		return nil, ErrEntryPointNotSet
 	}
 
+	if g.migrations != nil {
+		if err := g.migrations.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid migrations: %w", err)
+		}
+	}
+
+	options := newCompileOptions(opts)
 	return &Runnable{
-		graph:  g,
-		tracer: nil, // Initialize with no tracer
+		graph:        g,
+		tracer:       nil, // Initialize with no tracer
+		checkpointer: options.Checkpointer,
+		retention:    options.Retention,
+		store:        options.Store,
 	}, nil
 }
 
@@ -166,8 +203,11 @@ func (r *Runnable) SetTracer(tracer *Tracer) {
 // WithTracer returns a new Runnable with the given tracer
 func (r *Runnable) WithTracer(tracer *Tracer) *Runnable {
 	return &Runnable{
-		graph:  r.graph,
-		tracer: tracer,
+		graph:        r.graph,
+		tracer:       tracer,
+		checkpointer: r.checkpointer,
+		retention:    r.retention,
+		store:        r.store,
 	}
 }
 
@@ -180,312 +220,29 @@ func (r *Runnable) Invoke(ctx context.Context, initialState interface{}) (interf
 // InvokeWithConfig executes the compiled message graph with the given input state and config.
 // It returns the resulting state and an error if any occurs during the execution.
 func (r *Runnable) InvokeWithConfig(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
-	state := initialState
-	currentNodes := []string{r.graph.entryPoint}
-
-	// Handle ResumeFrom
-	if config != nil && len(config.ResumeFrom) > 0 {
-		currentNodes = config.ResumeFrom
-	}
-
-	// Generate run ID for callbacks
-	runID := generateRunID()
-
-	// Notify callbacks of graph start
-	if config != nil {
-		// Inject config into context
-		ctx = WithConfig(ctx, config)
-
-		// Inject ResumeValue
-		if config.ResumeValue != nil {
-			ctx = WithResumeValue(ctx, config.ResumeValue)
-		}
-
-		if len(config.Callbacks) > 0 {
-			serialized := map[string]interface{}{
-				"name": "graph",
-				"type": "chain",
-			}
-			inputs := convertStateToMap(initialState)
-
-			for _, cb := range config.Callbacks {
-				cb.OnChainStart(ctx, serialized, inputs, runID, nil, config.Tags, config.Metadata)
-			}
-		}
-	}
-
-	// Start graph tracing if tracer is set
-	var graphSpan *TraceSpan
-	if r.tracer != nil {
-		graphSpan = r.tracer.StartSpan(ctx, TraceEventGraphStart, "graph")
-		graphSpan.State = initialState
+	return r.executor().invoke(ctx, initialState, config)
+}
+
+// InvokeWithUsage executes the compiled message graph like InvokeWithConfig and
+// returns the usage tracker of the run. It is the tracker of the context if
+// it has one, otherwise a new tracker recording only this run.
+func (r *Runnable) InvokeWithUsage(ctx context.Context, initialState interface{}, config *Config) (interface{}, *UsageTracker, error) {
+	return r.executor().invokeWithUsage(ctx, initialState, config)
+}
+
+// executor returns the superstep executor for the compiled graph
+func (r *Runnable) executor() *executor {
+	return &executor{
+		nodes:            r.graph.nodes,
+		edges:            r.graph.edges,
+		conditionalEdges: r.graph.conditionalEdges,
+		entryPoint:       r.graph.entryPoint,
+		schema:           r.graph.Schema,
+		stateMerger:      r.graph.stateMerger,
+		tracer:           r.tracer,
+		checkpointer:     r.checkpointer,
+		retention:        r.retention,
+		store:            r.store,
+		migrations:       r.graph.migrations,
 	}
-
-	for len(currentNodes) > 0 {
-		// Filter out END nodes
-		activeNodes := make([]string, 0, len(currentNodes))
-		for _, node := range currentNodes {
-			if node != END {
-				activeNodes = append(activeNodes, node)
-			}
-		}
-		currentNodes = activeNodes
-
-		if len(currentNodes) == 0 {
-			break
-		}
-
-		// Check InterruptBefore
-		if config != nil && len(config.InterruptBefore) > 0 {
-			for _, node := range currentNodes {
-				for _, interrupt := range config.InterruptBefore {
-					if node == interrupt {
-						return state, &GraphInterrupt{Node: node, State: state}
-					}
-				}
-			}
-		}
-
-		// Execute nodes in parallel
-		var wg sync.WaitGroup
-		results := make([]interface{}, len(currentNodes))
-		errorsList := make([]error, len(currentNodes))
-
-		for i, nodeName := range currentNodes {
-			node, ok := r.graph.nodes[nodeName]
-			if !ok {
-				return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeName)
-			}
-
-			wg.Add(1)
-			go func(index int, n Node, name string) {
-				defer wg.Done()
-
-				// Start node tracing
-				var nodeSpan *TraceSpan
-				if r.tracer != nil {
-					nodeSpan = r.tracer.StartSpan(ctx, TraceEventNodeStart, name)
-					nodeSpan.State = state
-				}
-
-				var err error
-				var res interface{}
-
-				// Pass the current state to the node
-				// Note: If state is mutable and shared, this is not thread-safe unless handled by user.
-				res, err = n.Function(ctx, state)
-
-				// End node tracing
-				if r.tracer != nil && nodeSpan != nil {
-					if err != nil {
-						r.tracer.EndSpan(ctx, nodeSpan, res, err)
-						// Also emit error event
-						errorSpan := r.tracer.StartSpan(ctx, TraceEventNodeError, name)
-						errorSpan.Error = err
-						errorSpan.State = res
-						r.tracer.EndSpan(ctx, errorSpan, res, err)
-					} else {
-						r.tracer.EndSpan(ctx, nodeSpan, res, nil)
-					}
-				}
-
-				if err != nil {
-					var nodeInterrupt *NodeInterrupt
-					if errors.As(err, &nodeInterrupt) {
-						nodeInterrupt.Node = name
-					}
-					errorsList[index] = fmt.Errorf("error in node %s: %w", name, err)
-					return
-				}
-
-				results[index] = res
-
-				// Notify callbacks of node execution (as tool)
-				if config != nil && len(config.Callbacks) > 0 {
-					nodeRunID := generateRunID()
-					serialized := map[string]interface{}{
-						"name": name,
-						"type": "tool",
-					}
-					for _, cb := range config.Callbacks {
-						cb.OnToolStart(ctx, serialized, convertStateToString(res), nodeRunID, &runID, config.Tags, config.Metadata)
-						cb.OnToolEnd(ctx, convertStateToString(res), nodeRunID)
-					}
-				}
-			}(i, node, nodeName)
-		}
-
-		wg.Wait()
-
-		// Check for errors
-		for _, err := range errorsList {
-			if err != nil {
-				// Check for NodeInterrupt
-				var nodeInterrupt *NodeInterrupt
-				if errors.As(err, &nodeInterrupt) {
-					return state, &GraphInterrupt{
-						Node:           nodeInterrupt.Node,
-						State:          state,
-						InterruptValue: nodeInterrupt.Value,
-						NextNodes:      []string{nodeInterrupt.Node},
-					}
-				}
-
-				// Notify callbacks of error
-				if config != nil && len(config.Callbacks) > 0 {
-					for _, cb := range config.Callbacks {
-						cb.OnChainError(ctx, err, runID)
-					}
-				}
-				return nil, err
-			}
-		}
-
-		// Process results and check for Commands
-		var nextNodesFromCommands []string
-		processedResults := make([]interface{}, len(results))
-
-		for i, res := range results {
-			if cmd, ok := res.(*Command); ok {
-				// It's a Command
-				processedResults[i] = cmd.Update
-
-				if cmd.Goto != nil {
-					switch g := cmd.Goto.(type) {
-					case string:
-						nextNodesFromCommands = append(nextNodesFromCommands, g)
-					case []string:
-						nextNodesFromCommands = append(nextNodesFromCommands, g...)
-					}
-				}
-			} else {
-				// Regular result
-				processedResults[i] = res
-			}
-		}
-
-		// Merge results (using processedResults)
-		if r.graph.Schema != nil {
-			// If Schema is defined, use it to update state with results
-			for _, res := range processedResults {
-				var err error
-				state, err = r.graph.Schema.Update(state, res)
-				if err != nil {
-					return nil, fmt.Errorf("schema update failed: %w", err)
-				}
-			}
-		} else if r.graph.stateMerger != nil {
-			var err error
-			state, err = r.graph.stateMerger(ctx, state, processedResults)
-			if err != nil {
-				return nil, fmt.Errorf("state merge failed: %w", err)
-			}
-		} else {
-			// Default behavior
-			if len(processedResults) > 0 {
-				state = processedResults[len(processedResults)-1]
-			}
-		}
-
-		// Determine next nodes
-		var nextNodesList []string
-
-		if len(nextNodesFromCommands) > 0 {
-			// Command.Goto overrides static edges
-			// We deduplicate
-			seen := make(map[string]bool)
-			for _, n := range nextNodesFromCommands {
-				if !seen[n] && n != END {
-					seen[n] = true
-					nextNodesList = append(nextNodesList, n)
-				}
-			}
-		} else {
-			// Use static edges
-			nextNodesSet := make(map[string]bool)
-
-			for _, nodeName := range currentNodes {
-				// First check for conditional edges
-				nextNodeFn, hasConditional := r.graph.conditionalEdges[nodeName]
-				if hasConditional {
-					nextNode := nextNodeFn(ctx, state)
-					if nextNode == "" {
-						return nil, fmt.Errorf("conditional edge returned empty next node from %s", nodeName)
-					}
-					nextNodesSet[nextNode] = true
-				} else {
-					// Then check regular edges
-					foundNext := false
-					for _, edge := range r.graph.edges {
-						if edge.From == nodeName {
-							nextNodesSet[edge.To] = true
-							foundNext = true
-							// Do NOT break here, to allow fan-out (multiple edges from same node)
-						}
-					}
-
-					if !foundNext {
-						return nil, fmt.Errorf("%w: %s", ErrNoOutgoingEdge, nodeName)
-					}
-				}
-			}
-
-			// Update currentNodes
-			for node := range nextNodesSet {
-				nextNodesList = append(nextNodesList, node)
-			}
-		}
-
-		// Check InterruptAfter
-		if config != nil && len(config.InterruptAfter) > 0 {
-			for _, node := range currentNodes {
-				for _, interrupt := range config.InterruptAfter {
-					if node == interrupt {
-						return state, &GraphInterrupt{
-							Node:      node,
-							State:     state,
-							NextNodes: nextNodesList,
-						}
-					}
-				}
-			}
-		}
-
-		// Keep track of nodes that ran for callbacks
-		nodesRan := make([]string, len(currentNodes))
-		copy(nodesRan, currentNodes)
-
-		// Update currentNodes
-		currentNodes = nextNodesList
-
-		// Cleanup ephemeral state if supported
-		if cleaningSchema, ok := r.graph.Schema.(CleaningStateSchema); ok {
-			state = cleaningSchema.Cleanup(state)
-		}
-
-		// Notify callbacks of step completion
-		if config != nil && len(config.Callbacks) > 0 {
-			for _, cb := range config.Callbacks {
-				if gcb, ok := cb.(GraphCallbackHandler); ok {
-					nodeName := fmt.Sprintf("step:%v", nodesRan)
-					gcb.OnGraphStep(ctx, nodeName, state)
-				}
-			}
-		}
-	}
-
-	// End graph tracing
-	if r.tracer != nil && graphSpan != nil {
-		r.tracer.EndSpan(ctx, graphSpan, state, nil)
-	}
-
-	// Notify callbacks of graph end
-	if config != nil && len(config.Callbacks) > 0 {
-		outputs := convertStateToMap(state)
-		for _, cb := range config.Callbacks {
-			cb.OnChainEnd(ctx, outputs, runID)
-		}
-	}
-
-	return state, nil
 }
@@ -46,6 +46,9 @@ const (
 
 	// EventCustom indicates a custom user-defined event
 	EventCustom NodeEvent = "custom"
+
+	// EventUsage indicates the token usage of an LLM call was recorded
+	EventUsage NodeEvent = "usage"
 )
 
 // NodeListener defines the interface for node event listeners
@@ -84,6 +87,13 @@ type StreamEvent struct {
 
 	// Duration is how long the node took (only for Complete events)
 	Duration time.Duration
+
+	// RunID identifies the streamed run the event belongs to
+	RunID string
+
+	// Sequence is the position of the event in the run's event log.
+	// It is only assigned when the stream is backed by an EventLog.
+	Sequence int64
 }
 
 // ListenableNode extends Node with listener capabilities
@@ -237,20 +247,43 @@ func (g *ListenableMessageGraph) RemoveGlobalListener(listener NodeListener) {
 type ListenableRunnable struct {
 	graph           *ListenableMessageGraph
 	listenableNodes map[string]*ListenableNode
+	// checkpointer is the optional store that makes threads durable
+	checkpointer CheckpointStore
+	// retention prunes the checkpoints of a thread, if set
+	retention *RetentionPolicy
+	// store is the long-term memory available to nodes, if set
+	store Store
+	// tracer is the optional tracer for observability
+	tracer *Tracer
 }
 
 // NewListenableRunnable creates a runnable with listener support
-func (g *ListenableMessageGraph) CompileListenable() (*ListenableRunnable, error) {
+func (g *ListenableMessageGraph) CompileListenable(opts ...CompileOption) (*ListenableRunnable, error) {
 	if g.entryPoint == "" {
 		return nil, ErrEntryPointNotSet
 	}
 
+	if g.migrations != nil {
+		if err := g.migrations.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid migrations: %w", err)
+		}
+	}
+
+	options := newCompileOptions(opts)
 	return &ListenableRunnable{
 		graph:           g,
 		listenableNodes: g.listenableNodes,
+		checkpointer:    options.Checkpointer,
+		retention:       options.Retention,
+		store:           options.Store,
 	}, nil
 }
 
+// SetTracer sets a tracer for observability
+func (lr *ListenableRunnable) SetTracer(tracer *Tracer) {
+	lr.tracer = tracer
+}
+
 // Invoke executes the graph with listener notifications
 func (lr *ListenableRunnable) Invoke(ctx context.Context, initialState interface{}) (interface{}, error) {
 	return lr.InvokeWithConfig(ctx, initialState, nil)
@@ -258,77 +291,42 @@ func (lr *ListenableRunnable) Invoke(ctx context.Context, initialState interface
 
 // InvokeWithConfig executes the graph with listener notifications and config
 func (lr *ListenableRunnable) InvokeWithConfig(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
-	if config != nil {
-		ctx = WithConfig(ctx, config)
-	}
-
-	state := initialState
-	currentNode := lr.graph.entryPoint
-
-	for {
-		if currentNode == END {
-			break
-		}
-
-		listenableNode, ok := lr.listenableNodes[currentNode]
-		if !ok {
-			return nil, ErrNodeNotFound
-		}
-
-		// Execute the node function
-		result, err := listenableNode.Execute(ctx, state)
-		if err != nil {
-			return nil, err
-		}
-
-		// Update state using Schema if available
-		if lr.graph.Schema != nil {
-			state, err = lr.graph.Schema.Update(state, result)
-			if err != nil {
-				return nil, fmt.Errorf("schema update failed: %w", err)
-			}
-		} else {
-			// Default behavior: replace state
-			state = result
-		}
-
-		// Find next node
-		var nextNode string
-		foundNext := false
-		for _, edge := range lr.graph.edges {
-			if edge.From == currentNode {
-				nextNode = edge.To
-				foundNext = true
-				break
-			}
-		}
-
-		if !foundNext {
-			return nil, ErrNoOutgoingEdge
-		}
+	return lr.executor().invoke(ctx, initialState, config)
+}
 
-		// Cleanup ephemeral state if supported
-		if cleaningSchema, ok := lr.graph.Schema.(CleaningStateSchema); ok {
-			state = cleaningSchema.Cleanup(state)
-		}
+// InvokeWithUsage executes the graph with listener notifications like
+// InvokeWithConfig and returns the usage tracker of the run. It is the
+// tracker of the context if it has one, otherwise a new tracker recording
+// only this run.
+func (lr *ListenableRunnable) InvokeWithUsage(ctx context.Context, initialState interface{}, config *Config) (interface{}, *UsageTracker, error) {
+	return lr.executor().invokeWithUsage(ctx, initialState, config)
+}
 
-		// Notify callbacks of step completion
-		if config != nil && len(config.Callbacks) > 0 {
-			for _, cb := range config.Callbacks {
-				if gcb, ok := cb.(GraphCallbackHandler); ok {
-					nodeName := fmt.Sprintf("step:[%s]", currentNode) // Format to match []string format roughly
-					gcb.OnGraphStep(ctx, nodeName, state)
-				}
+// executor returns the superstep executor for the compiled graph,
+// running nodes through their listenable wrappers
+func (lr *ListenableRunnable) executor() *executor {
+	return &executor{
+		nodes:            lr.graph.nodes,
+		edges:            lr.graph.edges,
+		conditionalEdges: lr.graph.conditionalEdges,
+		entryPoint:       lr.graph.entryPoint,
+		schema:           lr.graph.Schema,
+		stateMerger:      lr.graph.stateMerger,
+		tracer:           lr.tracer,
+		checkpointer:     lr.checkpointer,
+		retention:        lr.retention,
+		store:            lr.store,
+		migrations:       lr.graph.migrations,
+		runNode: func(ctx context.Context, node Node, state interface{}) (interface{}, error) {
+			if listenableNode, ok := lr.listenableNodes[node.Name]; ok {
+				return listenableNode.Execute(ctx, state)
 			}
-		}
-
-		currentNode = nextNode
+			return node.Function(ctx, state)
+		},
 	}
-
-	return state, nil
 }
 
 // GetGraph returns a Exporter for visualization
 func (lr *ListenableRunnable) GetGraph() *Exporter {
-	return NewExporter(lr.graph.MessageGraph)
+	return NewExporter(lr.graph)
 }
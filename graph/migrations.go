@@ -0,0 +1,269 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// SchemaVersionKey is the checkpoint metadata key holding the schema version
+// of the checkpoint's state. Checkpoints without it have schema version 1.
+const SchemaVersionKey = "schema_version"
+
+// ErrSchemaVersionTooNew is returned when a checkpoint was written by a newer
+// version of the graph than the one loading it
+var ErrSchemaVersionTooNew = errors.New("checkpoint schema version is newer than the graph")
+
+// MigrationFunc upgrades a state from one schema version to the next.
+// It also receives the pending writes of interrupted supersteps, which hold
+// partial states. Top-level keys of map states may be changed in place,
+// nested values must be copied before they are modified.
+type MigrationFunc func(ctx context.Context, state interface{}) (interface{}, error)
+
+// Migrations declares the schema version of a graph's state and the functions
+// that upgrade the states of older checkpoints to it
+type Migrations struct {
+	version int
+	steps   map[int]MigrationFunc
+}
+
+// NewMigrations creates migrations to the given schema version. Versions start at 1.
+func NewMigrations(version int) *Migrations {
+	if version < 1 {
+		version = 1
+	}
+	return &Migrations{
+		version: version,
+		steps:   make(map[int]MigrationFunc),
+	}
+}
+
+// Register adds the migration from schema version from to from+1
+func (m *Migrations) Register(from int, fn MigrationFunc) *Migrations {
+	m.steps[from] = fn
+	return m
+}
+
+// Version returns the current schema version
+func (m *Migrations) Version() int {
+	return m.version
+}
+
+// Validate checks that every older schema version has a migration to the next
+func (m *Migrations) Validate() error {
+	var froms []int
+	for from := range m.steps {
+		froms = append(froms, from)
+	}
+	sort.Ints(froms)
+	for _, from := range froms {
+		if from < 1 || from >= m.version {
+			return fmt.Errorf("migration from schema version %d is outside versions 1 to %d", from, m.version)
+		}
+	}
+
+	for from := 1; from < m.version; from++ {
+		if m.steps[from] == nil {
+			return fmt.Errorf("missing migration from schema version %d to %d", from, from+1)
+		}
+	}
+	return nil
+}
+
+// Migrate returns checkpoint with its state upgraded to the current schema
+// version. Current checkpoints are returned as is, others are copied.
+// A nil Migrations returns every checkpoint as is.
+func (m *Migrations) Migrate(ctx context.Context, checkpoint *Checkpoint) (*Checkpoint, error) {
+	if m == nil || checkpoint == nil {
+		return checkpoint, nil
+	}
+
+	from := SchemaVersion(checkpoint)
+	if from == m.version {
+		return checkpoint, nil
+	}
+
+	state, err := m.migrate(ctx, from, checkpoint.State)
+	if err != nil {
+		return nil, fmt.Errorf("failed to migrate checkpoint %s: %w", checkpoint.ID, err)
+	}
+
+	migrated := *checkpoint
+	migrated.State = state
+	migrated.Metadata = make(map[string]interface{}, len(checkpoint.Metadata)+1)
+	for k, v := range checkpoint.Metadata {
+		migrated.Metadata[k] = v
+	}
+	migrated.Metadata[SchemaVersionKey] = m.version
+	return &migrated, nil
+}
+
+// migrate upgrades a state from schema version from to the current one
+func (m *Migrations) migrate(ctx context.Context, from int, state interface{}) (interface{}, error) {
+	if from > m.version {
+		return nil, fmt.Errorf("%w: %d is newer than %d", ErrSchemaVersionTooNew, from, m.version)
+	}
+
+	// Stores may hand out the map they keep, which must stay untouched
+	if mapState, ok := state.(map[string]interface{}); ok && from < m.version {
+		copied := make(map[string]interface{}, len(mapState))
+		for k, v := range mapState {
+			copied[k] = v
+		}
+		state = copied
+	}
+
+	for version := from; version < m.version; version++ {
+		step := m.steps[version]
+		if step == nil {
+			return nil, fmt.Errorf("missing migration from schema version %d to %d", version, version+1)
+		}
+
+		var err error
+		state, err = step(ctx, state)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate from schema version %d to %d: %w", version, version+1, err)
+		}
+	}
+	return state, nil
+}
+
+// migrateWrites upgrades the values of pending writes stored against a
+// checkpoint of schema version from
+func (m *Migrations) migrateWrites(ctx context.Context, from int, writes []PendingWrite) ([]PendingWrite, error) {
+	if m == nil || from == m.version {
+		return writes, nil
+	}
+
+	migrated := make([]PendingWrite, len(writes))
+	for i, write := range writes {
+		migrated[i] = write
+		if write.Value == nil {
+			continue
+		}
+
+		value, err := m.migrate(ctx, from, write.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to migrate pending write of node %s: %w", write.Node, err)
+		}
+		migrated[i].Value = value
+	}
+	return migrated, nil
+}
+
+// stamp records the current schema version in the metadata of a new checkpoint
+func (m *Migrations) stamp(metadata map[string]interface{}) {
+	if m != nil {
+		metadata[SchemaVersionKey] = m.version
+	}
+}
+
+// SchemaVersion returns the schema version of a checkpoint's state
+func SchemaVersion(checkpoint *Checkpoint) int {
+	// Serializers without Go types decode numbers as floats
+	switch v := checkpoint.Metadata[SchemaVersionKey].(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case float64:
+		return int(v)
+	case interface{ Int64() (int64, error) }:
+		if n, err := v.Int64(); err == nil {
+			return int(n)
+		}
+	}
+	return 1
+}
+
+// MigrateThread upgrades the stored checkpoints of a thread to the current
+// schema version in place, along with their pending writes, and returns how
+// many were upgraded. When any checkpoint needs upgrading, every checkpoint
+// of the thread is saved again oldest first, so that stores keeping
+// checkpoints relative to their parent stay consistent.
+func MigrateThread(ctx context.Context, store CheckpointStore, threadID string, migrations *Migrations) (int, error) {
+	if migrations == nil {
+		return 0, nil
+	}
+
+	checkpoints, err := store.List(ctx, threadID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	stale := 0
+	for _, checkpoint := range checkpoints {
+		if SchemaVersion(checkpoint) != migrations.version {
+			stale++
+		}
+	}
+	if stale == 0 {
+		return 0, nil
+	}
+
+	sorted := make([]*Checkpoint, len(checkpoints))
+	copy(sorted, checkpoints)
+	SortCheckpoints(sorted)
+
+	writesStore, hasWrites := store.(PendingWritesStore)
+	migrated := 0
+	for i := len(sorted) - 1; i >= 0; i-- {
+		checkpoint := sorted[i]
+		from := SchemaVersion(checkpoint)
+
+		upgraded, err := migrations.Migrate(ctx, checkpoint)
+		if err != nil {
+			return migrated, err
+		}
+		if err := store.Save(ctx, upgraded); err != nil {
+			return migrated, fmt.Errorf("failed to save checkpoint %s: %w", checkpoint.ID, err)
+		}
+		if upgraded == checkpoint {
+			continue
+		}
+
+		if hasWrites {
+			writes, err := writesStore.GetWrites(ctx, checkpoint.ID)
+			if err != nil {
+				return migrated, fmt.Errorf("failed to get pending writes: %w", err)
+			}
+			if len(writes) > 0 {
+				writes, err = migrations.migrateWrites(ctx, from, writes)
+				if err != nil {
+					return migrated, fmt.Errorf("failed to migrate checkpoint %s: %w", checkpoint.ID, err)
+				}
+				if err := writesStore.PutWrites(ctx, checkpoint.ID, writes); err != nil {
+					return migrated, fmt.Errorf("failed to save pending writes: %w", err)
+				}
+			}
+		}
+		migrated++
+	}
+	return migrated, nil
+}
+
+// MigrateCheckpoints upgrades the checkpoints of every thread of store and
+// returns how many were upgraded. It continues with the next thread on errors.
+func MigrateCheckpoints(ctx context.Context, store ThreadLister, migrations *Migrations) (int, error) {
+	threads, err := store.ListThreads(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list threads: %w", err)
+	}
+
+	migrated := 0
+	var errs []error
+	for _, threadID := range threads {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		n, err := MigrateThread(ctx, store, threadID, migrations)
+		migrated += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("thread %s: %w", threadID, err))
+		}
+	}
+	return migrated, errors.Join(errs...)
+}
@@ -0,0 +1,242 @@
+package graph_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+// renameMigrations renames the "name" key to "user" in version 2 and wraps it in version 3
+func renameMigrations() *graph.Migrations {
+	return graph.NewMigrations(3).
+		Register(1, func(ctx context.Context, state interface{}) (interface{}, error) {
+			m := state.(map[string]interface{})
+			if name, ok := m["name"]; ok {
+				m["user"] = name
+				delete(m, "name")
+			}
+			return m, nil
+		}).
+		Register(2, func(ctx context.Context, state interface{}) (interface{}, error) {
+			m := state.(map[string]interface{})
+			if user, ok := m["user"]; ok {
+				m["user"] = map[string]interface{}{"name": user}
+			}
+			return m, nil
+		})
+}
+
+// saveV1Checkpoint stores a checkpoint written before the graph declared a schema version
+func saveV1Checkpoint(t *testing.T, store graph.CheckpointStore, id, threadID string, next []string) {
+	err := store.Save(context.Background(), &graph.Checkpoint{
+		ID:        id,
+		NodeName:  "a",
+		State:     map[string]interface{}{"name": "ada", "steps": []string{"a"}},
+		Next:      next,
+		Timestamp: time.Now(),
+		Version:   1,
+		Metadata:  map[string]interface{}{"execution_id": threadID, "thread_id": threadID},
+	})
+	assert.NoError(t, err)
+}
+
+func TestMigrations_Validate(t *testing.T) {
+	noop := func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil }
+
+	assert.NoError(t, graph.NewMigrations(1).Validate())
+	assert.NoError(t, renameMigrations().Validate())
+	assert.ErrorContains(t, graph.NewMigrations(3).Register(1, noop).Validate(), "missing migration from schema version 2 to 3")
+	assert.ErrorContains(t, graph.NewMigrations(2).Register(1, noop).Register(2, noop).Validate(), "outside versions")
+
+	g := graph.NewStateGraph()
+	g.AddNode("a", noop)
+	g.SetEntryPoint("a")
+	g.SetMigrations(graph.NewMigrations(2))
+	_, err := g.Compile()
+	assert.ErrorContains(t, err, "invalid migrations")
+}
+
+func TestMigrations_Migrate(t *testing.T) {
+	ctx := context.Background()
+	migrations := renameMigrations()
+
+	original := &graph.Checkpoint{
+		ID:       "cp-1",
+		State:    map[string]interface{}{"name": "ada"},
+		Metadata: map[string]interface{}{"thread_id": "thread-1"},
+	}
+	migrated, err := migrations.Migrate(ctx, original)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"user": map[string]interface{}{"name": "ada"}}, migrated.State)
+	assert.Equal(t, 3, graph.SchemaVersion(migrated))
+
+	// The loaded checkpoint is left untouched
+	assert.Equal(t, map[string]interface{}{"name": "ada"}, original.State)
+	assert.Equal(t, 1, graph.SchemaVersion(original))
+
+	// Versions decoded as floats from JSON
+	current := &graph.Checkpoint{ID: "cp-2", Metadata: map[string]interface{}{graph.SchemaVersionKey: float64(3)}}
+	same, err := migrations.Migrate(ctx, current)
+	assert.NoError(t, err)
+	assert.Same(t, current, same)
+
+	newer := &graph.Checkpoint{ID: "cp-3", Metadata: map[string]interface{}{graph.SchemaVersionKey: 4}}
+	_, err = migrations.Migrate(ctx, newer)
+	assert.ErrorIs(t, err, graph.ErrSchemaVersionTooNew)
+
+	failing := graph.NewMigrations(2).Register(1, func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	_, err = failing.Migrate(ctx, original)
+	assert.ErrorContains(t, err, "failed to migrate checkpoint cp-1")
+
+	var none *graph.Migrations
+	same, err = none.Migrate(ctx, original)
+	assert.NoError(t, err)
+	assert.Same(t, original, same)
+}
+
+func TestMigrations_ResumeThread(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	saveV1Checkpoint(t, store, "old", "thread-1", []string{"b", "c"})
+
+	// Node c completed before the interruption, with a write in the old shape
+	assert.NoError(t, store.PutWrites(ctx, "old", []graph.PendingWrite{
+		{Node: "c", Value: map[string]interface{}{"name": "grace"}},
+	}))
+
+	var seen interface{}
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return map[string]interface{}{"steps": []string{"a"}}, nil
+	})
+	g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+		seen = state.(map[string]interface{})["user"]
+		return map[string]interface{}{"steps": []string{"b"}}, nil
+	})
+	g.AddNode("c", func(ctx context.Context, state interface{}) (interface{}, error) {
+		t.Fatal("completed node c ran again")
+		return nil, nil
+	})
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.AddEdge("c", graph.END)
+	g.SetEntryPoint("a")
+	g.SetMigrations(renameMigrations())
+
+	runnable, err := g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+
+	result, err := runnable.InvokeWithConfig(ctx, nil, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "ada"}, seen)
+
+	state := result.(map[string]interface{})
+	assert.Equal(t, map[string]interface{}{"name": "grace"}, state["user"])
+	assert.NotContains(t, state, "name")
+
+	// New checkpoints carry the schema version, the old one is left as stored
+	latest, err := store.GetLatest(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, graph.SchemaVersion(latest))
+	assert.Equal(t, "old", latest.ParentID)
+
+	old, err := store.Load(ctx, "old")
+	assert.NoError(t, err)
+	assert.Equal(t, 1, graph.SchemaVersion(old))
+	assert.Contains(t, old.State, "name")
+
+	// An older graph refuses checkpoints it cannot read
+	g.SetMigrations(graph.NewMigrations(1))
+	runnable, err = g.Compile(graph.WithCheckpointer(store))
+	assert.NoError(t, err)
+	_, err = runnable.InvokeWithConfig(ctx, nil, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.ErrorIs(t, err, graph.ErrSchemaVersionTooNew)
+}
+
+func TestMigrations_CheckpointableRunnable(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	saveV1Checkpoint(t, store, "old", "thread-1", nil)
+
+	g := graph.NewCheckpointableMessageGraphWithConfig(graph.CheckpointConfig{Store: store, AutoSave: true})
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil })
+	g.AddEdge("a", graph.END)
+	g.SetEntryPoint("a")
+	g.SetMigrations(renameMigrations())
+
+	runnable, err := g.CompileCheckpointable()
+	assert.NoError(t, err)
+
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	snapshot, err := runnable.GetState(ctx, config)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"name": "ada"}, snapshot.Values.(map[string]interface{})["user"])
+
+	updated, err := runnable.UpdateState(ctx, config, map[string]interface{}{"note": "hi"}, "human")
+	assert.NoError(t, err)
+
+	for snapshot, err := range runnable.GetStateHistory(ctx, config) {
+		assert.NoError(t, err)
+		assert.Contains(t, snapshot.Values, "user")
+		assert.NotContains(t, snapshot.Values, "name")
+	}
+
+	checkpoint, err := store.Load(ctx, updated.Configurable["checkpoint_id"].(string))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, graph.SchemaVersion(checkpoint))
+	assert.Equal(t, "hi", checkpoint.State.(map[string]interface{})["note"])
+}
+
+func TestMigrateCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	saveV1Checkpoint(t, store, "t1-old", "thread-1", []string{"b"})
+	assert.NoError(t, store.PutWrites(ctx, "t1-old", []graph.PendingWrite{
+		{Node: "b", Value: map[string]interface{}{"name": "grace"}},
+	}))
+	assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+		ID:        "t1-new",
+		State:     map[string]interface{}{"user": map[string]interface{}{"name": "ada"}},
+		ParentID:  "t1-old",
+		Timestamp: time.Now(),
+		Version:   2,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1", "thread_id": "thread-1", graph.SchemaVersionKey: 3},
+	}))
+	saveV1Checkpoint(t, store, "t2-old", "thread-2", nil)
+
+	migrations := renameMigrations()
+	migrated, err := graph.MigrateCheckpoints(ctx, store, migrations)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, migrated)
+
+	for _, id := range []string{"t1-old", "t1-new", "t2-old"} {
+		checkpoint, err := store.Load(ctx, id)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, graph.SchemaVersion(checkpoint))
+		assert.Equal(t, "ada", checkpoint.State.(map[string]interface{})["user"].(map[string]interface{})["name"])
+	}
+
+	writes, err := store.GetWrites(ctx, "t1-old")
+	assert.NoError(t, err)
+	assert.Equal(t, []graph.PendingWrite{
+		{Node: "b", Value: map[string]interface{}{"user": map[string]interface{}{"name": "grace"}}},
+	}, writes)
+
+	// Migrating again finds nothing to do
+	migrated, err = graph.MigrateThread(ctx, store, "thread-1", migrations)
+	assert.NoError(t, err)
+	assert.Zero(t, migrated)
+}
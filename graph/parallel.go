@@ -86,7 +86,9 @@ func (pn *ParallelNode) Execute(ctx context.Context, state interface{}) (interfa
 func (g *MessageGraph) AddParallelNodes(groupName string, nodes map[string]func(context.Context, interface{}) (interface{}, error)) {
 	// Create parallel node group
 	parallelNodes := make([]Node, 0, len(nodes))
+	members := make([]string, 0, len(nodes))
 	for name, fn := range nodes {
+		members = append(members, name)
 		parallelNodes = append(parallelNodes, Node{
 			Name:     name,
 			Function: fn,
@@ -96,6 +98,7 @@ func (g *MessageGraph) AddParallelNodes(groupName string, nodes map[string]func(
 	// Add as a single parallel node
 	parallelNode := NewParallelNode(groupName, parallelNodes...)
 	g.AddNode(groupName, parallelNode.Execute)
+	g.structure.setGroup(groupName, nodeGroupParallel, members)
 }
 
 // MapReduceNode executes nodes in parallel and reduces results
@@ -139,7 +142,9 @@ func (g *MessageGraph) AddMapReduceNode(
 ) {
 	// Create map nodes
 	mapNodes := make([]Node, 0, len(mapFunctions))
+	members := make([]string, 0, len(mapFunctions))
 	for nodeName, fn := range mapFunctions {
+		members = append(members, nodeName)
 		mapNodes = append(mapNodes, Node{
 			Name:     nodeName,
 			Function: fn,
@@ -149,6 +154,7 @@ func (g *MessageGraph) AddMapReduceNode(
 	// Create and add map-reduce node
 	mrNode := NewMapReduceNode(name, reducer, mapNodes...)
 	g.AddNode(name, mrNode.Execute)
+	g.structure.setGroup(name, nodeGroupMapReduce, members)
 }
 
 // FanOutFanIn creates a fan-out/fan-in pattern
@@ -0,0 +1,237 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// RetentionPolicy decides which checkpoints of a thread are kept.
+// The zero value keeps every checkpoint.
+//
+// The checkpoints of a thread form branches: every checkpoint descends from
+// its parent, and forks start new branches. A branch is live until its newest
+// checkpoint expires. Pruning never deletes a checkpoint that a live branch
+// still descends from within its MaxCheckpoints newest checkpoints, so forks
+// keep the history they share with other branches.
+type RetentionPolicy struct {
+	// MaxCheckpoints keeps the newest checkpoints of every live branch, 0 keeps them all
+	MaxCheckpoints int
+
+	// MaxAge expires branches whose newest checkpoint is older than this, 0 never expires them.
+	// A thread without live branches is removed entirely.
+	MaxAge time.Duration
+
+	// FinalOnly keeps only the newest checkpoint of finished threads, those
+	// whose branches all end in a checkpoint without pending nodes. Threads
+	// with a branch still pending follow the other limits.
+	FinalOnly bool
+}
+
+// ThreadLister is a CheckpointStore that can enumerate its threads
+type ThreadLister interface {
+	CheckpointStore
+
+	// ListThreads returns the IDs of the threads that have checkpoints
+	ListThreads(ctx context.Context) ([]string, error)
+}
+
+// PruneCheckpoints deletes the checkpoints of a thread that the policy does
+// not keep and returns how many were deleted
+func PruneCheckpoints(ctx context.Context, store CheckpointStore, threadID string, policy RetentionPolicy) (int, error) {
+	if policy == (RetentionPolicy{}) {
+		return 0, nil
+	}
+
+	checkpoints, err := store.List(ctx, threadID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list checkpoints: %w", err)
+	}
+
+	sorted := make([]*Checkpoint, len(checkpoints))
+	copy(sorted, checkpoints)
+	SortCheckpoints(sorted)
+	retained := retainedCheckpoints(sorted, policy, time.Now())
+
+	// Newest first, so that stores rebuilding descendants of a deleted
+	// checkpoint only rebuild those that are kept
+	deleted := 0
+	for _, checkpoint := range sorted {
+		if retained[checkpoint.ID] {
+			continue
+		}
+		if err := store.Delete(ctx, checkpoint.ID); err != nil {
+			return deleted, fmt.Errorf("failed to delete checkpoint %s: %w", checkpoint.ID, err)
+		}
+		deleted++
+	}
+	return deleted, nil
+}
+
+// retainedCheckpoints returns the IDs of the checkpoints the policy keeps.
+// Checkpoints are sorted newest first.
+func retainedCheckpoints(sorted []*Checkpoint, policy RetentionPolicy, now time.Time) map[string]bool {
+	retained := make(map[string]bool)
+	if len(sorted) == 0 {
+		return retained
+	}
+
+	// Checkpoints saved without a parent descend from the previous one of the thread
+	byID := make(map[string]*Checkpoint, len(sorted))
+	for _, checkpoint := range sorted {
+		byID[checkpoint.ID] = checkpoint
+	}
+	parents := make(map[string]*Checkpoint, len(sorted))
+	hasChildren := make(map[string]bool, len(sorted))
+	for i, checkpoint := range sorted {
+		var parent *Checkpoint
+		switch {
+		case checkpoint.ParentID != "":
+			parent = byID[checkpoint.ParentID]
+		case i+1 < len(sorted):
+			parent = sorted[i+1]
+		}
+		if parent != nil {
+			parents[checkpoint.ID] = parent
+			hasChildren[parent.ID] = true
+		}
+	}
+
+	heads := make([]*Checkpoint, 0)
+	finished := true
+	for _, checkpoint := range sorted {
+		if !hasChildren[checkpoint.ID] {
+			heads = append(heads, checkpoint)
+			finished = finished && len(checkpoint.Next) == 0
+		}
+	}
+
+	if policy.FinalOnly && finished {
+		retained[sorted[0].ID] = true
+		return retained
+	}
+
+	for _, head := range heads {
+		if policy.MaxAge > 0 && now.Sub(head.Timestamp) > policy.MaxAge {
+			continue
+		}
+
+		visited := make(map[string]bool)
+		for checkpoint := head; checkpoint != nil && !visited[checkpoint.ID]; checkpoint = parents[checkpoint.ID] {
+			if policy.MaxCheckpoints > 0 && len(visited) == policy.MaxCheckpoints {
+				break
+			}
+			visited[checkpoint.ID] = true
+			retained[checkpoint.ID] = true
+		}
+	}
+	return retained
+}
+
+// CheckpointJanitor periodically prunes the checkpoints of every thread of a store
+type CheckpointJanitor struct {
+	store    ThreadLister
+	policy   RetentionPolicy
+	interval time.Duration
+	onError  func(error)
+
+	mu     sync.Mutex
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// JanitorOptions configuration for a CheckpointJanitor
+type JanitorOptions struct {
+	// Policy decides which checkpoints are kept
+	Policy RetentionPolicy
+
+	// Interval between two runs, default 10 minutes
+	Interval time.Duration
+
+	// OnError is called with the errors of a run, which continues with the next thread
+	OnError func(error)
+}
+
+// NewCheckpointJanitor creates a janitor for the threads of store
+func NewCheckpointJanitor(store ThreadLister, opts JanitorOptions) *CheckpointJanitor {
+	interval := opts.Interval
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+
+	return &CheckpointJanitor{
+		store:    store,
+		policy:   opts.Policy,
+		interval: interval,
+		onError:  opts.OnError,
+	}
+}
+
+// Start runs the janitor in the background until Stop is called or ctx is done
+func (j *CheckpointJanitor) Start(ctx context.Context) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.cancel != nil {
+		return
+	}
+
+	ctx, j.cancel = context.WithCancel(ctx)
+	j.done = make(chan struct{})
+	go func() {
+		defer close(j.done)
+
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if _, err := j.RunOnce(ctx); err != nil && j.onError != nil {
+					j.onError(err)
+				}
+			}
+		}
+	}()
+}
+
+// Stop stops the janitor and waits for a running prune to finish
+func (j *CheckpointJanitor) Stop() {
+	j.mu.Lock()
+	cancel, done := j.cancel, j.done
+	j.cancel, j.done = nil, nil
+	j.mu.Unlock()
+
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+}
+
+// RunOnce prunes every thread of the store and returns the number of deleted checkpoints
+func (j *CheckpointJanitor) RunOnce(ctx context.Context) (int, error) {
+	threads, err := j.store.ListThreads(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list threads: %w", err)
+	}
+
+	deleted := 0
+	var errs []error
+	for _, threadID := range threads {
+		if ctx.Err() != nil {
+			errs = append(errs, ctx.Err())
+			break
+		}
+
+		n, err := PruneCheckpoints(ctx, j.store, threadID, j.policy)
+		deleted += n
+		if err != nil {
+			errs = append(errs, fmt.Errorf("thread %s: %w", threadID, err))
+		}
+	}
+	return deleted, errors.Join(errs...)
+}
@@ -0,0 +1,283 @@
+package graph_test
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+// saveChain saves a branch of checkpoints descending from parentID, one per name
+func saveChain(t *testing.T, store graph.CheckpointStore, threadID, parentID string, version int, at time.Time, names ...string) {
+	for _, name := range names {
+		err := store.Save(context.Background(), &graph.Checkpoint{
+			ID:        name,
+			State:     map[string]interface{}{"name": name},
+			Next:      []string{"next"},
+			ParentID:  parentID,
+			Timestamp: at,
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": threadID, "thread_id": threadID},
+		})
+		assert.NoError(t, err)
+		parentID = name
+		version++
+		at = at.Add(time.Second)
+	}
+}
+
+func remainingIDs(t *testing.T, store graph.CheckpointStore, threadID string) []string {
+	checkpoints, err := store.List(context.Background(), threadID)
+	assert.NoError(t, err)
+	ids := make([]string, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		ids = append(ids, checkpoint.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestPruneCheckpoints_MaxCheckpoints(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	now := time.Now()
+
+	// c1 - c2 - c3 - c4 - c5 - c6
+	//       \
+	//        f1
+	saveChain(t, store, "thread-1", "", 1, now, "c1", "c2", "c3", "c4", "c5", "c6")
+	saveChain(t, store, "thread-1", "c2", 7, now.Add(time.Minute), "f1")
+	assert.NoError(t, store.PutWrites(ctx, "c3", []graph.PendingWrite{{Node: "a", Value: 1}}))
+
+	deleted, err := graph.PruneCheckpoints(ctx, store, "thread-1", graph.RetentionPolicy{MaxCheckpoints: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+
+	// The fork keeps the checkpoint it descends from
+	assert.Equal(t, []string{"c2", "c5", "c6", "f1"}, remainingIDs(t, store, "thread-1"))
+
+	writes, err := store.GetWrites(ctx, "c3")
+	assert.NoError(t, err)
+	assert.Empty(t, writes)
+
+	// Pruning is idempotent
+	deleted, err = graph.PruneCheckpoints(ctx, store, "thread-1", graph.RetentionPolicy{MaxCheckpoints: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+func TestPruneCheckpoints_MaxAge(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	now := time.Now()
+	old := now.Add(-2 * time.Hour)
+
+	// The main branch is old, the fork from c2 is recent
+	saveChain(t, store, "thread-1", "", 1, old, "c1", "c2", "c3", "c4")
+	saveChain(t, store, "thread-1", "c2", 5, now, "f1", "f2")
+	// A thread that is entirely expired
+	saveChain(t, store, "thread-2", "", 1, old, "d1", "d2")
+
+	policy := graph.RetentionPolicy{MaxAge: time.Hour}
+	deleted, err := graph.PruneCheckpoints(ctx, store, "thread-1", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Equal(t, []string{"c1", "c2", "f1", "f2"}, remainingIDs(t, store, "thread-1"))
+
+	deleted, err = graph.PruneCheckpoints(ctx, store, "thread-2", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	assert.Empty(t, remainingIDs(t, store, "thread-2"))
+}
+
+func TestPruneCheckpoints_FinalOnly(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	saveChain(t, store, "thread-1", "", 1, time.Now(), "c1", "c2", "c3")
+
+	// The thread is still running
+	policy := graph.RetentionPolicy{FinalOnly: true}
+	deleted, err := graph.PruneCheckpoints(ctx, store, "thread-1", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+
+	assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+		ID:        "final",
+		ParentID:  "c3",
+		Timestamp: time.Now(),
+		Version:   4,
+		Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+	}))
+	deleted, err = graph.PruneCheckpoints(ctx, store, "thread-1", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Equal(t, []string{"final"}, remainingIDs(t, store, "thread-1"))
+
+	// A finished fork doesn't finish the thread while another branch is pending
+	now := time.Now()
+	saveChain(t, store, "thread-2", "", 1, now, "c1", "c2", "c3")
+	finish := func(id, parentID string, version int, at time.Time) {
+		assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+			ID:        id,
+			ParentID:  parentID,
+			Timestamp: at,
+			Version:   version,
+			Metadata:  map[string]interface{}{"execution_id": "thread-2"},
+		}))
+	}
+	finish("c4", "c2", 3, now.Add(time.Minute))
+	deleted, err = graph.PruneCheckpoints(ctx, store, "thread-2", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+	assert.Equal(t, []string{"c1", "c2", "c3", "c4"}, remainingIDs(t, store, "thread-2"))
+
+	// Once every branch finished, only the newest checkpoint is kept
+	finish("c5", "c3", 4, now.Add(2*time.Minute))
+	deleted, err = graph.PruneCheckpoints(ctx, store, "thread-2", policy)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, deleted)
+	assert.Equal(t, []string{"c5"}, remainingIDs(t, store, "thread-2"))
+}
+
+func TestPruneCheckpoints_WithoutParents(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	now := time.Now()
+	for i := 1; i <= 5; i++ {
+		assert.NoError(t, store.Save(ctx, &graph.Checkpoint{
+			ID:        fmt.Sprintf("c%d", i),
+			Timestamp: now.Add(time.Duration(i) * time.Second),
+			Version:   1,
+			Metadata:  map[string]interface{}{"execution_id": "thread-1"},
+		}))
+	}
+
+	// Checkpoints saved without parents form a single branch
+	deleted, err := graph.PruneCheckpoints(ctx, store, "thread-1", graph.RetentionPolicy{MaxCheckpoints: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Equal(t, []string{"c4", "c5"}, remainingIDs(t, store, "thread-1"))
+}
+
+func TestWithRetention(t *testing.T) {
+	g := graph.NewStateGraph()
+	schema := graph.NewMapSchema()
+	schema.RegisterReducer("steps", graph.AppendReducer)
+	g.SetSchema(schema)
+	names := []string{"a", "b", "c", "d"}
+	for i, name := range names {
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+			return map[string]interface{}{"steps": []string{name}}, nil
+		})
+		if i > 0 {
+			g.AddEdge(names[i-1], name)
+		}
+	}
+	g.AddEdge("d", graph.END)
+	g.SetEntryPoint("a")
+
+	store := graph.NewMemoryCheckpointStore()
+	runnable, err := g.Compile(graph.WithCheckpointer(store), graph.WithRetention(graph.RetentionPolicy{MaxCheckpoints: 2}))
+	assert.NoError(t, err)
+
+	ctx := context.Background()
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, &graph.Config{
+		InterruptBefore: []string{"c"},
+		Configurable:    map[string]interface{}{"thread_id": "thread-1"},
+	})
+	var interrupt *graph.GraphInterrupt
+	assert.ErrorAs(t, err, &interrupt)
+
+	checkpoints, err := store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+
+	// The thread resumes from its latest checkpoint
+	result, err := runnable.InvokeWithConfig(ctx, nil, threadConfig("thread-1"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b", "c", "d"}, result.(map[string]interface{})["steps"])
+
+	checkpoints, err = store.List(ctx, "thread-1")
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+}
+
+func TestCheckpointableRunnable_Retention(t *testing.T) {
+	newRunnable := func(config graph.CheckpointConfig) *graph.CheckpointableRunnable {
+		g := graph.NewCheckpointableMessageGraphWithConfig(config)
+		g.SetSchema(graph.NewMapSchema())
+		for _, name := range []string{"a", "b", "c"} {
+			g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) {
+				return map[string]interface{}{"last": name}, nil
+			})
+		}
+		g.AddEdge("a", "b")
+		g.AddEdge("b", "c")
+		g.AddEdge("c", graph.END)
+		g.SetEntryPoint("a")
+
+		runnable, err := g.CompileCheckpointable()
+		assert.NoError(t, err)
+		return runnable
+	}
+	ctx := context.Background()
+
+	// MaxCheckpoints is enforced
+	config := graph.DefaultCheckpointConfig()
+	config.MaxCheckpoints = 2
+	runnable := newRunnable(config)
+	_, err := runnable.Invoke(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	checkpoints, err := runnable.ListCheckpoints(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+
+	// Without AutoSave, supersteps within SaveInterval of the last save are skipped,
+	// the final state is always saved
+	config = graph.DefaultCheckpointConfig()
+	config.AutoSave = false
+	config.SaveInterval = time.Hour
+	config.MaxCheckpoints = 0
+	runnable = newRunnable(config)
+	_, err = runnable.Invoke(ctx, map[string]interface{}{})
+	assert.NoError(t, err)
+	checkpoints, err = runnable.ListCheckpoints(ctx)
+	assert.NoError(t, err)
+	assert.Len(t, checkpoints, 2)
+	assert.Equal(t, "a", checkpoints[0].NodeName)
+	assert.Equal(t, "c", checkpoints[1].NodeName)
+}
+
+func TestCheckpointJanitor(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryCheckpointStore()
+	saveChain(t, store, "thread-1", "", 1, time.Now(), "a1", "a2", "a3")
+	saveChain(t, store, "thread-2", "", 1, time.Now(), "b1", "b2")
+
+	threads, err := store.ListThreads(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"thread-1", "thread-2"}, threads)
+
+	janitor := graph.NewCheckpointJanitor(store, graph.JanitorOptions{
+		Policy:   graph.RetentionPolicy{MaxCheckpoints: 1},
+		Interval: 10 * time.Millisecond,
+	})
+	deleted, err := janitor.RunOnce(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, deleted)
+	assert.Equal(t, []string{"a3"}, remainingIDs(t, store, "thread-1"))
+	assert.Equal(t, []string{"b2"}, remainingIDs(t, store, "thread-2"))
+
+	// In the background
+	saveChain(t, store, "thread-2", "b2", 3, time.Now(), "b3")
+	janitor.Start(ctx)
+	defer janitor.Stop()
+	assert.Eventually(t, func() bool {
+		ids := remainingIDs(t, store, "thread-2")
+		return len(ids) == 1 && ids[0] == "b3"
+	}, time.Second, 10*time.Millisecond)
+}
@@ -61,8 +61,10 @@ func (rn *RetryNode) Execute(ctx context.Context, state interface{}) (interface{
 		default:
 		}
 
-		// Execute the node
-		result, err := rn.node.Function(ctx, state)
+		// Execute the node, traced as an attempt under the node span
+		result, err := traceAttempt(ctx, rn.node.Name, attempt, func(ctx context.Context) (interface{}, error) {
+			return rn.node.Function(ctx, state)
+		})
 		if err == nil {
 			return result, nil
 		}
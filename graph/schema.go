@@ -169,17 +169,45 @@ func AppendReducer(current, new interface{}) (interface{}, error) {
 
 	if newVal.Kind() == reflect.Slice {
 		// Append slice to slice
-		// Check if types are compatible? reflect.AppendSlice handles it or panics.
-		// We should probably check types to avoid panics.
-		if currVal.Type().Elem() != newVal.Type().Elem() {
-			// Try to append as generic interface slice?
-			// For now, let's assume types match or rely on reflect to panic/convert if possible.
-			// Actually reflect.AppendSlice requires exact match.
+		if currVal.Type().Elem() == newVal.Type().Elem() {
 			return reflect.AppendSlice(currVal, newVal).Interface(), nil
 		}
-		return reflect.AppendSlice(currVal, newVal).Interface(), nil
+
+		// Element types differ, e.g. a []interface{} restored from a JSON checkpoint.
+		// Append element by element so that compatible values still merge.
+		result := reflect.MakeSlice(currVal.Type(), currVal.Len(), currVal.Len()+newVal.Len())
+		reflect.Copy(result, currVal)
+		for i := 0; i < newVal.Len(); i++ {
+			item, err := appendableElem(currVal.Type().Elem(), newVal.Index(i))
+			if err != nil {
+				return nil, err
+			}
+			result = reflect.Append(result, item)
+		}
+		return result.Interface(), nil
 	}
 
 	// Append single element
-	return reflect.Append(currVal, newVal).Interface(), nil
+	item, err := appendableElem(currVal.Type().Elem(), newVal)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.Append(currVal, item).Interface(), nil
+}
+
+// appendableElem returns the value to append to a slice of elemType
+func appendableElem(elemType reflect.Type, value reflect.Value) (reflect.Value, error) {
+	if value.IsValid() && value.Kind() == reflect.Interface {
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		if elemType.Kind() == reflect.Interface {
+			return reflect.Zero(elemType), nil
+		}
+		return value, fmt.Errorf("cannot append nil to slice of %v", elemType)
+	}
+	if !value.Type().AssignableTo(elemType) {
+		return value, fmt.Errorf("cannot append %v to slice of %v", value.Type(), elemType)
+	}
+	return value, nil
 }
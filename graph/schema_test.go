@@ -53,6 +53,23 @@ func TestMapSchema_Update(t *testing.T) {
 	assert.Equal(t, []string{"hello", "world", "!"}, state3["messages"])
 }
 
+func TestAppendReducer_MixedTypes(t *testing.T) {
+	// Slices restored from JSON checkpoints are []interface{}
+	res, err := AppendReducer([]interface{}{"a"}, []string{"b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []interface{}{"a", "b"}, res)
+
+	res, err = AppendReducer([]string{"a"}, []interface{}{"b"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, res)
+
+	_, err = AppendReducer([]string{"a"}, []int{1})
+	assert.Error(t, err)
+
+	_, err = AppendReducer([]string{"a"}, 1)
+	assert.Error(t, err)
+}
+
 func TestStateGraph_Schema(t *testing.T) {
 	g := NewStateGraph()
 
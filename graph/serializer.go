@@ -0,0 +1,594 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tmc/langchaingo/llms"
+)
+
+// Serializer encodes checkpoint states and pending writes for storage.
+// Stores record the ID next to the data so that it is decoded by the same
+// serializer, even after the store switched to another one.
+type Serializer interface {
+	// ID identifies the encoding
+	ID() string
+
+	// Marshal encodes a value
+	Marshal(value interface{}) ([]byte, error)
+
+	// Unmarshal decodes a value encoded by Marshal
+	Unmarshal(data []byte) (interface{}, error)
+}
+
+// Serializer IDs of the built-in serializers
+const (
+	JSONSerializerID      = "json"
+	TypedJSONSerializerID = "typed_json"
+	GobSerializerID       = "gob"
+)
+
+var (
+	serializersMu sync.RWMutex
+	serializers   = map[string]Serializer{}
+)
+
+// RegisterSerializer makes a serializer available to decode data stored with its ID
+func RegisterSerializer(serializer Serializer) {
+	serializersMu.Lock()
+	defer serializersMu.Unlock()
+	serializers[serializer.ID()] = serializer
+}
+
+// SerializerByID returns the registered serializer with the given ID.
+// Data stored without an ID was encoded as plain JSON.
+func SerializerByID(id string) (Serializer, error) {
+	if id == "" {
+		id = JSONSerializerID
+	}
+
+	serializersMu.RLock()
+	defer serializersMu.RUnlock()
+	serializer, ok := serializers[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown serializer: %s", id)
+	}
+	return serializer, nil
+}
+
+// DefaultSerializer returns the serializer stores use unless configured otherwise
+func DefaultSerializer() Serializer {
+	return NewTypedJSONSerializer(nil)
+}
+
+// Deserialize decodes data stored with the serializer ID. The preferred
+// serializer is used when its ID matches, e.g. one with a custom type registry.
+func Deserialize(preferred Serializer, id string, data []byte) (interface{}, error) {
+	serializer := preferred
+	if serializer == nil || serializer.ID() != id {
+		var err error
+		serializer, err = SerializerByID(id)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	value, err := serializer.Unmarshal(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to deserialize with %s: %w", serializer.ID(), err)
+	}
+	return value, nil
+}
+
+// JSONSerializer encodes values as plain JSON.
+// Structs come back as maps and numbers as float64.
+type JSONSerializer struct{}
+
+// ID implements Serializer interface
+func (JSONSerializer) ID() string {
+	return JSONSerializerID
+}
+
+// Marshal implements Serializer interface
+func (JSONSerializer) Marshal(value interface{}) ([]byte, error) {
+	return json.Marshal(value)
+}
+
+// Unmarshal implements Serializer interface
+func (JSONSerializer) Unmarshal(data []byte) (interface{}, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// TypeRegistry names the Go types that serializers restore.
+// Registered types are encoded with encoding/json, so a struct whose fields
+// hold interface values needs its own JSON methods, as llms.MessageContent has.
+type TypeRegistry struct {
+	mu     sync.RWMutex
+	byName map[string]reflect.Type
+	byType map[reflect.Type]string
+}
+
+// DefaultTypeRegistry is used by serializers created without a registry.
+// It knows the basic Go types and the langchaingo message types.
+var DefaultTypeRegistry = NewTypeRegistry()
+
+// NewTypeRegistry creates a registry of the basic Go types and the langchaingo message types
+func NewTypeRegistry() *TypeRegistry {
+	r := &TypeRegistry{
+		byName: make(map[string]reflect.Type),
+		byType: make(map[reflect.Type]string),
+	}
+
+	builtins := map[string]interface{}{
+		"bool":    false,
+		"string":  "",
+		"int":     0,
+		"int8":    int8(0),
+		"int16":   int16(0),
+		"int32":   int32(0),
+		"int64":   int64(0),
+		"uint":    uint(0),
+		"uint8":   uint8(0),
+		"uint16":  uint16(0),
+		"uint32":  uint32(0),
+		"uint64":  uint64(0),
+		"float32": float32(0),
+		"float64": float64(0),
+		"bytes":   []byte(nil),
+
+		"time.Time":     time.Time{},
+		"time.Duration": time.Duration(0),
+
+		"llms.MessageContent":   llms.MessageContent{},
+		"llms.ChatMessageType":  llms.ChatMessageType(""),
+		"llms.TextContent":      llms.TextContent{},
+		"llms.ImageURLContent":  llms.ImageURLContent{},
+		"llms.BinaryContent":    llms.BinaryContent{},
+		"llms.ToolCall":         llms.ToolCall{},
+		"llms.FunctionCall":     llms.FunctionCall{},
+		"llms.ToolCallResponse": llms.ToolCallResponse{},
+		"llms.ContentResponse":  llms.ContentResponse{},
+		"llms.ContentChoice":    llms.ContentChoice{},
+	}
+	for name, sample := range builtins {
+		r.registerType(name, reflect.TypeOf(sample))
+	}
+	r.registerType("interface{}", reflect.TypeOf((*interface{})(nil)).Elem())
+	r.registerType("llms.ContentPart", reflect.TypeOf((*llms.ContentPart)(nil)).Elem())
+
+	// Containers that are commonly stored in interface values
+	registerGob(mapType)
+	registerGob(sliceType)
+	registerGob(reflect.TypeOf([]llms.ContentPart(nil)))
+
+	return r
+}
+
+// Register names the type of sample so that its values keep their type.
+// The name is stored with the data and must not change.
+func (r *TypeRegistry) Register(name string, sample interface{}) error {
+	if name == "" || strings.ContainsAny(name, "[]*{}") {
+		return fmt.Errorf("invalid type name: %q", name)
+	}
+
+	t := reflect.TypeOf(sample)
+	if t == nil {
+		return fmt.Errorf("cannot register nil as %s", name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.byName[name]; ok && existing != t {
+		return fmt.Errorf("type name %s is already registered for %s", name, existing)
+	}
+	r.registerTypeLocked(name, t)
+	return nil
+}
+
+// RegisterType registers a type with the DefaultTypeRegistry
+func RegisterType(name string, sample interface{}) error {
+	return DefaultTypeRegistry.Register(name, sample)
+}
+
+func (r *TypeRegistry) registerType(name string, t reflect.Type) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.registerTypeLocked(name, t)
+}
+
+func (r *TypeRegistry) registerTypeLocked(name string, t reflect.Type) {
+	r.byName[name] = t
+	r.byType[t] = name
+
+	if t.Kind() != reflect.Interface {
+		registerGob(t)
+		registerGob(reflect.SliceOf(t))
+		registerGob(reflect.MapOf(reflect.TypeOf(""), t))
+	}
+}
+
+// registerGob lets gob encode values of t held in interfaces
+func registerGob(t reflect.Type) {
+	defer func() {
+		// Already registered under another name by the application
+		_ = recover()
+	}()
+	gob.Register(reflect.Zero(t).Interface())
+}
+
+// typeName returns the name of a registered type, or of a slice, string
+// keyed map or pointer of nameable types
+func (r *TypeRegistry) typeName(t reflect.Type) (string, bool) {
+	r.mu.RLock()
+	name, ok := r.byType[t]
+	r.mu.RUnlock()
+	if ok {
+		return name, true
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if elem, ok := r.typeName(t.Elem()); ok {
+			return "[]" + elem, true
+		}
+	case reflect.Map:
+		if t.Key() == reflect.TypeOf("") {
+			if elem, ok := r.typeName(t.Elem()); ok {
+				return "map[string]" + elem, true
+			}
+		}
+	case reflect.Ptr:
+		if elem, ok := r.typeName(t.Elem()); ok {
+			return "*" + elem, true
+		}
+	}
+	return "", false
+}
+
+// lookup parses a name produced by typeName
+func (r *TypeRegistry) lookup(name string) (reflect.Type, error) {
+	r.mu.RLock()
+	t, ok := r.byName[name]
+	r.mu.RUnlock()
+	if ok {
+		return t, nil
+	}
+
+	var elem reflect.Type
+	var err error
+	switch {
+	case strings.HasPrefix(name, "[]"):
+		if elem, err = r.lookup(name[2:]); err == nil {
+			return reflect.SliceOf(elem), nil
+		}
+	case strings.HasPrefix(name, "map[string]"):
+		if elem, err = r.lookup(name[len("map[string]"):]); err == nil {
+			return reflect.MapOf(reflect.TypeOf(""), elem), nil
+		}
+	case strings.HasPrefix(name, "*"):
+		if elem, err = r.lookup(name[1:]); err == nil {
+			return reflect.PointerTo(elem), nil
+		}
+	default:
+		err = fmt.Errorf("unregistered type: %s", name)
+	}
+	return nil, err
+}
+
+// registered reports whether t itself was registered, as opposed to composed
+func (r *TypeRegistry) registered(t reflect.Type) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.byType[t]
+	return ok
+}
+
+// Keys of the objects that carry the type of a value in typed JSON
+const (
+	typedJSONTypeKey  = "__type__"
+	typedJSONValueKey = "__value__"
+)
+
+var (
+	mapType   = reflect.TypeOf(map[string]interface{}(nil))
+	sliceType = reflect.TypeOf([]interface{}(nil))
+)
+
+// TypedJSONSerializer encodes values as JSON annotated with the registered
+// names of their types, so that e.g. []llms.MessageContent, ints and
+// registered structs keep their types. Values of unregistered types are
+// stored as plain JSON.
+type TypedJSONSerializer struct {
+	registry *TypeRegistry
+}
+
+// NewTypedJSONSerializer creates a typed JSON serializer.
+// A nil registry uses DefaultTypeRegistry.
+func NewTypedJSONSerializer(registry *TypeRegistry) *TypedJSONSerializer {
+	if registry == nil {
+		registry = DefaultTypeRegistry
+	}
+	return &TypedJSONSerializer{registry: registry}
+}
+
+// ID implements Serializer interface
+func (s *TypedJSONSerializer) ID() string {
+	return TypedJSONSerializerID
+}
+
+// Marshal implements Serializer interface
+func (s *TypedJSONSerializer) Marshal(value interface{}) ([]byte, error) {
+	encoded, err := s.encodeDynamic(reflect.ValueOf(value))
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(encoded)
+}
+
+// Unmarshal implements Serializer interface
+func (s *TypedJSONSerializer) Unmarshal(data []byte) (interface{}, error) {
+	return s.decodeDynamic(data)
+}
+
+// encodeDynamic encodes a value held in an interface, recording its type
+// unless JSON restores it as is
+func (s *TypedJSONSerializer) encodeDynamic(v reflect.Value) (interface{}, error) {
+	if !v.IsValid() {
+		return nil, nil
+	}
+
+	t := v.Type()
+	switch t {
+	case reflect.TypeOf(""), reflect.TypeOf(false), reflect.TypeOf(float64(0)), sliceType:
+		return s.encodeValue(v, t)
+	case mapType:
+		// Maps that look like typed values are wrapped like any other type
+		if v.MapIndex(reflect.ValueOf(typedJSONTypeKey)).IsValid() {
+			break
+		}
+		return s.encodeValue(v, t)
+	}
+
+	name, ok := s.registry.typeName(t)
+	if !ok {
+		// Stored as plain JSON, the type is lost
+		return v.Interface(), nil
+	}
+
+	encoded, err := s.encodeValue(v, t)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		typedJSONTypeKey:  name,
+		typedJSONValueKey: encoded,
+	}, nil
+}
+
+// encodeValue encodes a value of the static type t
+func (s *TypedJSONSerializer) encodeValue(v reflect.Value, t reflect.Type) (interface{}, error) {
+	if t.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil, nil
+		}
+		return s.encodeDynamic(v.Elem())
+	}
+
+	if s.registry.registered(t) {
+		return v.Interface(), nil
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		if v.IsNil() {
+			return nil, nil
+		}
+		items := make([]interface{}, v.Len())
+		for i := range items {
+			item, err := s.encodeValue(v.Index(i), t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			items[i] = item
+		}
+		return items, nil
+	case reflect.Map:
+		if v.IsNil() {
+			return nil, nil
+		}
+		entries := make(map[string]interface{}, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			entry, err := s.encodeValue(iter.Value(), t.Elem())
+			if err != nil {
+				return nil, err
+			}
+			entries[iter.Key().String()] = entry
+		}
+		return entries, nil
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, nil
+		}
+		return s.encodeValue(v.Elem(), t.Elem())
+	}
+
+	return v.Interface(), nil
+}
+
+// decodeDynamic decodes a value encoded by encodeDynamic
+func (s *TypedJSONSerializer) decodeDynamic(data []byte) (interface{}, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("unexpected end of JSON input")
+	}
+
+	switch data[0] {
+	case '{':
+		var object map[string]json.RawMessage
+		if err := json.Unmarshal(data, &object); err != nil {
+			return nil, err
+		}
+
+		if rawName, ok := object[typedJSONTypeKey]; ok {
+			var name string
+			if err := json.Unmarshal(rawName, &name); err != nil {
+				return nil, fmt.Errorf("invalid type name: %w", err)
+			}
+			t, err := s.registry.lookup(name)
+			if err != nil {
+				return nil, err
+			}
+			value, err := s.decodeValue(object[typedJSONValueKey], t)
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %s: %w", name, err)
+			}
+			return value.Interface(), nil
+		}
+
+		value, err := s.decodeValue(data, mapType)
+		if err != nil {
+			return nil, err
+		}
+		return value.Interface(), nil
+	case '[':
+		value, err := s.decodeValue(data, sliceType)
+		if err != nil {
+			return nil, err
+		}
+		return value.Interface(), nil
+	}
+
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// decodeValue decodes a value of the static type t encoded by encodeValue
+func (s *TypedJSONSerializer) decodeValue(data json.RawMessage, t reflect.Type) (reflect.Value, error) {
+	value := reflect.New(t).Elem()
+	if len(data) == 0 || string(data) == "null" {
+		return value, nil
+	}
+
+	if t.Kind() == reflect.Interface {
+		decoded, err := s.decodeDynamic(data)
+		if err != nil {
+			return value, err
+		}
+		if decoded != nil {
+			dv := reflect.ValueOf(decoded)
+			if !dv.Type().AssignableTo(t) {
+				return value, fmt.Errorf("%s does not implement %s", dv.Type(), t)
+			}
+			value.Set(dv)
+		}
+		return value, nil
+	}
+
+	if s.registry.registered(t) {
+		err := json.Unmarshal(data, value.Addr().Interface())
+		return value, err
+	}
+
+	switch t.Kind() {
+	case reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return value, err
+		}
+		value.Set(reflect.MakeSlice(t, len(items), len(items)))
+		for i, item := range items {
+			decoded, err := s.decodeValue(item, t.Elem())
+			if err != nil {
+				return value, err
+			}
+			value.Index(i).Set(decoded)
+		}
+		return value, nil
+	case reflect.Map:
+		var entries map[string]json.RawMessage
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return value, err
+		}
+		value.Set(reflect.MakeMapWithSize(t, len(entries)))
+		keys := make([]string, 0, len(entries))
+		for key := range entries {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			decoded, err := s.decodeValue(entries[key], t.Elem())
+			if err != nil {
+				return value, err
+			}
+			value.SetMapIndex(reflect.ValueOf(key).Convert(t.Key()), decoded)
+		}
+		return value, nil
+	case reflect.Ptr:
+		decoded, err := s.decodeValue(data, t.Elem())
+		if err != nil {
+			return value, err
+		}
+		ptr := reflect.New(t.Elem())
+		ptr.Elem().Set(decoded)
+		value.Set(ptr)
+		return value, nil
+	}
+
+	err := json.Unmarshal(data, value.Addr().Interface())
+	return value, err
+}
+
+// GobSerializer is a compact binary serializer built on encoding/gob.
+// It restores the types registered with the TypeRegistry, which registers
+// them with gob as well.
+type GobSerializer struct{}
+
+// gobEnvelope lets gob encode a value of any registered type
+type gobEnvelope struct {
+	Value interface{}
+}
+
+// ID implements Serializer interface
+func (GobSerializer) ID() string {
+	return GobSerializerID
+}
+
+// Marshal implements Serializer interface
+func (GobSerializer) Marshal(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(gobEnvelope{Value: value}); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements Serializer interface
+func (GobSerializer) Unmarshal(data []byte) (interface{}, error) {
+	var envelope gobEnvelope
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&envelope); err != nil {
+		return nil, err
+	}
+	return envelope.Value, nil
+}
+
+func init() {
+	RegisterSerializer(JSONSerializer{})
+	RegisterSerializer(NewTypedJSONSerializer(nil))
+	RegisterSerializer(GobSerializer{})
+}
@@ -0,0 +1,128 @@
+package graph_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/tmc/langchaingo/llms"
+)
+
+type serializerTestOrder struct {
+	ID    string
+	Items []string
+	Total float64
+}
+
+func serializerTestState() map[string]interface{} {
+	return map[string]interface{}{
+		"messages": []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "What's the weather?"),
+			{
+				Role: llms.ChatMessageTypeAI,
+				Parts: []llms.ContentPart{
+					llms.ToolCall{
+						ID:           "call-1",
+						Type:         "function",
+						FunctionCall: &llms.FunctionCall{Name: "weather", Arguments: `{"city":"Paris"}`},
+					},
+				},
+			},
+			{
+				Role:  llms.ChatMessageTypeTool,
+				Parts: []llms.ContentPart{llms.ToolCallResponse{ToolCallID: "call-1", Name: "weather", Content: "sunny"}},
+			},
+		},
+		"count":   3,
+		"ratio":   0.5,
+		"done":    true,
+		"name":    "agent",
+		"nothing": nil,
+		"steps":   []string{"a", "b"},
+		"order":   serializerTestOrder{ID: "o-1", Items: []string{"x"}, Total: 9.5},
+		"orders":  []*serializerTestOrder{{ID: "o-2"}},
+		"at":      time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC),
+		"nested": map[string]interface{}{
+			"values":   []interface{}{1, "two", map[string]interface{}{"three": int64(3)}},
+			"__type__": "not a type",
+		},
+	}
+}
+
+func TestTypedJSONSerializer_RoundTrip(t *testing.T) {
+	assert.NoError(t, graph.RegisterType("graph_test.serializerTestOrder", serializerTestOrder{}))
+
+	serializer := graph.NewTypedJSONSerializer(nil)
+	state := serializerTestState()
+
+	data, err := serializer.Marshal(state)
+	assert.NoError(t, err)
+
+	restored, err := serializer.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, state, restored)
+
+	// Scalars that JSON restores as is stay plain
+	data, err = serializer.Marshal("hello")
+	assert.NoError(t, err)
+	assert.JSONEq(t, `"hello"`, string(data))
+}
+
+func TestTypedJSONSerializer_UnregisteredType(t *testing.T) {
+	type unregistered struct {
+		Name string
+	}
+
+	serializer := graph.NewTypedJSONSerializer(graph.NewTypeRegistry())
+	data, err := serializer.Marshal(map[string]interface{}{"value": unregistered{Name: "x"}})
+	assert.NoError(t, err)
+
+	// Falls back to plain JSON
+	restored, err := serializer.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"value": map[string]interface{}{"Name": "x"}}, restored)
+
+	_, err = serializer.Unmarshal([]byte(`{"__type__":"missing.Type","__value__":{}}`))
+	assert.Error(t, err)
+}
+
+func TestTypeRegistry_Register(t *testing.T) {
+	registry := graph.NewTypeRegistry()
+	assert.NoError(t, registry.Register("app.Order", serializerTestOrder{}))
+	assert.NoError(t, registry.Register("app.Order", serializerTestOrder{}))
+	assert.Error(t, registry.Register("app.Order", ""))
+	assert.Error(t, registry.Register("[]app.Order", serializerTestOrder{}))
+	assert.Error(t, registry.Register("app.Nil", nil))
+}
+
+func TestGobSerializer_RoundTrip(t *testing.T) {
+	assert.NoError(t, graph.RegisterType("graph_test.serializerTestOrder", serializerTestOrder{}))
+
+	serializer := graph.GobSerializer{}
+	state := serializerTestState()
+	delete(state, "orders")
+
+	data, err := serializer.Marshal(state)
+	assert.NoError(t, err)
+
+	restored, err := serializer.Unmarshal(data)
+	assert.NoError(t, err)
+	assert.Equal(t, state, restored)
+}
+
+func TestDeserialize(t *testing.T) {
+	// Rows written before serializers were recorded hold plain JSON
+	value, err := graph.Deserialize(graph.DefaultSerializer(), "", []byte(`{"count":1}`))
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": float64(1)}, value)
+
+	data, err := graph.GobSerializer{}.Marshal(map[string]interface{}{"count": 1})
+	assert.NoError(t, err)
+	value, err = graph.Deserialize(graph.DefaultSerializer(), graph.GobSerializerID, data)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"count": 1}, value)
+
+	_, err = graph.Deserialize(nil, "unknown", data)
+	assert.Error(t, err)
+}
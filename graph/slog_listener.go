@@ -0,0 +1,234 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RedactedValue replaces the values removed by redactors
+const RedactedValue = "[REDACTED]"
+
+// Redactor rewrites values before they are logged. Redact is called for
+// every key of the logged state, at every depth, and for the error message
+// under the key "error". Elements of lists are passed with the key of the list.
+type Redactor interface {
+	Redact(key string, value interface{}) interface{}
+}
+
+// RedactorFunc is a function adapter for Redactor
+type RedactorFunc func(key string, value interface{}) interface{}
+
+// Redact implements the Redactor interface
+func (f RedactorFunc) Redact(key string, value interface{}) interface{} {
+	return f(key, value)
+}
+
+// RedactKeys removes the values of the given keys, compared case-insensitively,
+// including nested maps and lists under them
+func RedactKeys(keys ...string) Redactor {
+	redacted := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		redacted[strings.ToLower(key)] = true
+	}
+	return RedactorFunc(func(key string, value interface{}) interface{} {
+		if redacted[strings.ToLower(key)] {
+			return RedactedValue
+		}
+		return value
+	})
+}
+
+// RedactPatterns replaces the matches of the patterns in string values,
+// such as API keys or email addresses in messages
+func RedactPatterns(patterns ...*regexp.Regexp) Redactor {
+	return RedactorFunc(func(_ string, value interface{}) interface{} {
+		s, ok := value.(string)
+		if !ok {
+			return value
+		}
+		for _, pattern := range patterns {
+			s = pattern.ReplaceAllString(s, RedactedValue)
+		}
+		return s
+	})
+}
+
+// Redactors applies redactors in order
+func Redactors(redactors ...Redactor) Redactor {
+	return RedactorFunc(func(key string, value interface{}) interface{} {
+		for _, redactor := range redactors {
+			value = redactor.Redact(key, value)
+		}
+		return value
+	})
+}
+
+// SlogListenerOptions configuration for the slog listener
+type SlogListenerOptions struct {
+	// Logger receives the records. Default is slog.Default()
+	Logger *slog.Logger
+
+	// Levels overrides the level of event types. By default starts and
+	// progress are logged at Debug, errors at Error and other events at Info
+	Levels map[NodeEvent]slog.Level
+
+	// IncludeState logs the state of node events under "state"
+	IncludeState bool
+
+	// Redactor rewrites the state and error messages before they are logged, if set
+	Redactor Redactor
+}
+
+// SlogListener logs node events as structured log/slog records, with the
+// run ID, thread ID, node, step, duration and error as attributes
+type SlogListener struct {
+	logger       *slog.Logger
+	levels       map[NodeEvent]slog.Level
+	includeState bool
+	redactor     Redactor
+
+	mu sync.Mutex
+	// started holds the start time of running nodes, by run, step and node
+	started map[string]time.Time
+}
+
+// defaultSlogLevels are the levels of events without an override
+var defaultSlogLevels = map[NodeEvent]slog.Level{
+	NodeEventStart:    slog.LevelDebug,
+	NodeEventProgress: slog.LevelDebug,
+	NodeEventError:    slog.LevelError,
+}
+
+// NewSlogListener creates a new slog listener
+func NewSlogListener(opts SlogListenerOptions) *SlogListener {
+	logger := opts.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	levels := make(map[NodeEvent]slog.Level, len(defaultSlogLevels)+len(opts.Levels))
+	for event, level := range defaultSlogLevels {
+		levels[event] = level
+	}
+	for event, level := range opts.Levels {
+		levels[event] = level
+	}
+
+	return &SlogListener{
+		logger:       logger,
+		levels:       levels,
+		includeState: opts.IncludeState,
+		redactor:     opts.Redactor,
+		started:      make(map[string]time.Time),
+	}
+}
+
+// OnNodeEvent implements the NodeListener interface
+func (sl *SlogListener) OnNodeEvent(ctx context.Context, event NodeEvent, nodeName string, state interface{}, err error) {
+	duration, hasDuration := sl.track(ctx, event, nodeName)
+
+	level, ok := sl.levels[event]
+	if !ok {
+		level = slog.LevelInfo
+	}
+	if !sl.logger.Enabled(ctx, level) {
+		return
+	}
+
+	attrs := []slog.Attr{slog.String("node", nodeName)}
+	if runID := GetRunID(ctx); runID != "" {
+		attrs = append(attrs, slog.String("run_id", runID))
+	}
+	if threadID := threadIDFromConfig(GetConfig(ctx)); threadID != "" {
+		attrs = append(attrs, slog.String("thread_id", threadID))
+	}
+	if step := GetStep(ctx); step > 0 {
+		attrs = append(attrs, slog.Int("step", step))
+	}
+	if hasDuration {
+		attrs = append(attrs, slog.Duration("duration", duration))
+	}
+	if err != nil {
+		attrs = append(attrs, slog.Any("error", sl.redact("error", err.Error())))
+	}
+	if sl.includeState && state != nil {
+		attrs = append(attrs, slog.Any("state", sl.redactState(state)))
+	}
+
+	sl.logger.LogAttrs(ctx, level, "node "+string(event), attrs...)
+}
+
+// track records the start of a node, and returns its duration when it ends
+func (sl *SlogListener) track(ctx context.Context, event NodeEvent, nodeName string) (time.Duration, bool) {
+	key := fmt.Sprintf("%s/%d/%s", GetRunID(ctx), GetStep(ctx), nodeName)
+
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	switch event {
+	case NodeEventStart:
+		sl.started[key] = time.Now()
+	case NodeEventComplete, NodeEventError:
+		if start, ok := sl.started[key]; ok {
+			delete(sl.started, key)
+			return time.Since(start), true
+		}
+	}
+	return 0, false
+}
+
+// redact applies the redactor to a value, if set
+func (sl *SlogListener) redact(key string, value interface{}) interface{} {
+	if sl.redactor == nil {
+		return value
+	}
+	return sl.redactor.Redact(key, value)
+}
+
+// redactState returns a copy of the state with the redactor applied to every
+// key. States are converted through their JSON encoding, so that the fields
+// of structs are redacted like the keys of maps.
+func (sl *SlogListener) redactState(state interface{}) interface{} {
+	if sl.redactor == nil {
+		return state
+	}
+
+	var value interface{}
+	data, err := json.Marshal(state)
+	if err == nil {
+		err = json.Unmarshal(data, &value)
+	}
+	if err != nil {
+		// A state that can't be inspected can't be redacted
+		return RedactedValue
+	}
+	return sl.redactValue("state", value)
+}
+
+// redactValue redacts a value under key, then the keys and elements it contains
+func (sl *SlogListener) redactValue(key string, value interface{}) interface{} {
+	value = sl.redactor.Redact(key, value)
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(v))
+		for k, item := range v {
+			redacted[k] = sl.redactValue(k, item)
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			redacted[i] = sl.redactValue(key, item)
+		}
+		return redacted
+	default:
+		return value
+	}
+}
@@ -0,0 +1,173 @@
+package graph_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeRecords parses the records written by a slog JSON handler
+func decodeRecords(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var records []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestSlogListener_Run(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	listener := graph.NewSlogListener(graph.SlogListenerOptions{Logger: logger})
+
+	g := graph.NewListenableMessageGraph()
+	g.AddNode("a", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddNode("b", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	g.AddEdge("a", "b")
+	g.AddEdge("b", graph.END)
+	g.SetEntryPoint("a")
+	g.AddGlobalListener(listener)
+
+	runnable, err := g.CompileListenable()
+	require.NoError(t, err)
+
+	config := &graph.Config{Configurable: map[string]interface{}{"thread_id": "thread-1"}}
+	_, err = runnable.InvokeWithConfig(context.Background(), "input", config)
+	require.Error(t, err)
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 4)
+
+	expected := []struct {
+		msg   string
+		level string
+		node  string
+		step  float64
+	}{
+		{"node start", "DEBUG", "a", 1},
+		{"node complete", "INFO", "a", 1},
+		{"node start", "DEBUG", "b", 2},
+		{"node error", "ERROR", "b", 2},
+	}
+	runID := records[0]["run_id"]
+	assert.NotEmpty(t, runID)
+	for i, want := range expected {
+		record := records[i]
+		assert.Equal(t, want.msg, record["msg"])
+		assert.Equal(t, want.level, record["level"])
+		assert.Equal(t, want.node, record["node"])
+		assert.Equal(t, want.step, record["step"])
+		assert.Equal(t, runID, record["run_id"])
+		assert.Equal(t, "thread-1", record["thread_id"])
+		assert.NotContains(t, record, "state")
+	}
+	assert.Contains(t, records[1], "duration")
+	assert.Contains(t, records[3], "duration")
+	assert.Equal(t, "boom", records[3]["error"])
+}
+
+func TestSlogListener_Redaction(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	listener := graph.NewSlogListener(graph.SlogListenerOptions{
+		Logger:       logger,
+		IncludeState: true,
+		Redactor: graph.Redactors(
+			graph.RedactKeys("api_key", "profile"),
+			graph.RedactPatterns(regexp.MustCompile(`sk-[A-Za-z0-9]+`)),
+		),
+	})
+
+	state := map[string]interface{}{
+		"API_KEY":  "secret",
+		"profile":  map[string]interface{}{"email": "user@example.com"},
+		"messages": []interface{}{"my key is sk-abc123", "hello"},
+		"count":    2,
+	}
+	listener.OnNodeEvent(context.Background(), graph.NodeEventError, "node", state, errors.New("invalid key sk-abc123"))
+
+	output := buf.String()
+	assert.NotContains(t, output, "secret")
+	assert.NotContains(t, output, "user@example.com")
+	assert.NotContains(t, output, "sk-abc123")
+
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "invalid key "+graph.RedactedValue, records[0]["error"])
+	assert.Equal(t, map[string]interface{}{
+		"API_KEY":  graph.RedactedValue,
+		"profile":  graph.RedactedValue,
+		"messages": []interface{}{"my key is " + graph.RedactedValue, "hello"},
+		"count":    float64(2),
+	}, records[0]["state"])
+}
+
+func TestSlogListener_RedactsStructState(t *testing.T) {
+	t.Parallel()
+
+	type credentials struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+
+	var buf bytes.Buffer
+	listener := graph.NewSlogListener(graph.SlogListenerOptions{
+		Logger:       slog.New(slog.NewJSONHandler(&buf, nil)),
+		IncludeState: true,
+		Redactor:     graph.RedactKeys("password"),
+	})
+
+	listener.OnNodeEvent(context.Background(), graph.NodeEventComplete, "login", credentials{User: "ann", Password: "hunter2"}, nil)
+
+	assert.NotContains(t, buf.String(), "hunter2")
+	assert.Contains(t, buf.String(), `"user":"ann"`)
+}
+
+func TestSlogListener_Levels(t *testing.T) {
+	t.Parallel()
+
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+	listener := graph.NewSlogListener(graph.SlogListenerOptions{
+		Logger: logger,
+		Levels: map[graph.NodeEvent]slog.Level{
+			graph.NodeEventStart:    slog.LevelInfo,
+			graph.NodeEventComplete: slog.LevelDebug,
+		},
+	})
+
+	ctx := context.Background()
+	listener.OnNodeEvent(ctx, graph.NodeEventStart, "node", nil, nil)
+	listener.OnNodeEvent(ctx, graph.NodeEventComplete, "node", nil, nil)
+	listener.OnNodeEvent(ctx, graph.NodeEventProgress, "node", nil, nil)
+
+	// Only the start reaches the Info handler
+	records := decodeRecords(t, &buf)
+	require.Len(t, records, 1)
+	assert.Equal(t, "node start", records[0]["msg"])
+	assert.Equal(t, "INFO", records[0]["level"])
+}
@@ -3,7 +3,6 @@ package graph
 import (
 	"context"
 	"fmt"
-	"sync"
 	"time"
 )
 
@@ -29,6 +28,12 @@ type StateGraph struct {
 
 	// Schema defines the state structure and update logic
 	Schema StateSchema
+
+	// migrations upgrades the states of older checkpoints, if set
+	migrations *Migrations
+
+	// structure records conditional branches for drawing
+	structure graphStructure
 }
 
 // RetryPolicy defines how to handle node failures
@@ -61,6 +66,7 @@ func (g *StateGraph) AddNode(name string, fn func(ctx context.Context, state int
 		Name:     name,
 		Function: fn,
 	}
+	g.structure.forgetNode(name)
 }
 
 // AddEdge adds a new edge to the state graph between the "from" and "to" nodes
@@ -74,6 +80,15 @@ func (g *StateGraph) AddEdge(from, to string) {
 // AddConditionalEdge adds a conditional edge where the target node is determined at runtime
 func (g *StateGraph) AddConditionalEdge(from string, condition func(ctx context.Context, state interface{}) string) {
 	g.conditionalEdges[from] = condition
+	g.structure.forgetBranches(from)
+}
+
+// AddConditionalEdgeWithBranches adds a conditional edge whose condition returns
+// the name of a branch, routed to the node the branch maps to. Names missing
+// from branches are routed as node names. The xray drawing shows the branches.
+func (g *StateGraph) AddConditionalEdgeWithBranches(from string, condition func(ctx context.Context, state interface{}) string, branches map[string]string) {
+	g.AddConditionalEdge(from, routeBranches(condition, branches))
+	g.structure.setBranches(from, branches)
 }
 
 // SetEntryPoint sets the entry point node name for the state graph
@@ -96,22 +111,51 @@ func (g *StateGraph) SetSchema(schema StateSchema) {
 	g.Schema = schema
 }
 
+// SetMigrations declares the schema version of the state and how checkpoints
+// of older versions are upgraded when they are loaded
+func (g *StateGraph) SetMigrations(migrations *Migrations) {
+	g.migrations = migrations
+}
+
 // StateRunnable represents a compiled state graph that can be invoked
 type StateRunnable struct {
 	graph *StateGraph
+	// checkpointer is the optional store that makes threads durable
+	checkpointer CheckpointStore
+	// retention prunes the checkpoints of a thread, if set
+	retention *RetentionPolicy
+	// store is the long-term memory available to nodes, if set
+	store Store
+	// tracer is the optional tracer for observability
+	tracer *Tracer
 }
 
 // Compile compiles the state graph and returns a StateRunnable instance
-func (g *StateGraph) Compile() (*StateRunnable, error) {
+func (g *StateGraph) Compile(opts ...CompileOption) (*StateRunnable, error) {
 	if g.entryPoint == "" {
 		return nil, ErrEntryPointNotSet
 	}
 
+	if g.migrations != nil {
+		if err := g.migrations.Validate(); err != nil {
+			return nil, fmt.Errorf("invalid migrations: %w", err)
+		}
+	}
+
+	options := newCompileOptions(opts)
 	return &StateRunnable{
-		graph: g,
+		graph:        g,
+		checkpointer: options.Checkpointer,
+		retention:    options.Retention,
+		store:        options.Store,
 	}, nil
 }
 
+// SetTracer sets a tracer for observability
+func (r *StateRunnable) SetTracer(tracer *Tracer) {
+	r.tracer = tracer
+}
+
 // Invoke executes the compiled state graph with the given input state
 func (r *StateRunnable) Invoke(ctx context.Context, initialState interface{}) (interface{}, error) {
 	return r.InvokeWithConfig(ctx, initialState, nil)
@@ -119,239 +163,32 @@ func (r *StateRunnable) Invoke(ctx context.Context, initialState interface{}) (i
 
 // InvokeWithConfig executes the compiled state graph with the given input state and config
 func (r *StateRunnable) InvokeWithConfig(ctx context.Context, initialState interface{}, config *Config) (interface{}, error) {
-	if config != nil {
-		ctx = WithConfig(ctx, config)
-	}
-
-	state := initialState
-	currentNodes := []string{r.graph.entryPoint}
-
-	for len(currentNodes) > 0 {
-		// Filter out END nodes
-		activeNodes := make([]string, 0, len(currentNodes))
-		for _, node := range currentNodes {
-			if node != END {
-				activeNodes = append(activeNodes, node)
-			}
-		}
-		currentNodes = activeNodes
-
-		if len(currentNodes) == 0 {
-			break
-		}
-
-		// Execute nodes in parallel
-		var wg sync.WaitGroup
-		results := make([]interface{}, len(currentNodes))
-		errorsList := make([]error, len(currentNodes))
-
-		for i, nodeName := range currentNodes {
-			node, ok := r.graph.nodes[nodeName]
-			if !ok {
-				return nil, fmt.Errorf("%w: %s", ErrNodeNotFound, nodeName)
-			}
-
-			wg.Add(1)
-			go func(index int, n Node, name string) {
-				defer wg.Done()
-
-				// Execute node with retry logic
-				res, err := r.executeNodeWithRetry(ctx, n, state)
-				if err != nil {
-					errorsList[index] = fmt.Errorf("error in node %s: %w", name, err)
-					return
-				}
-				results[index] = res
-			}(i, node, nodeName)
-		}
-
-		wg.Wait()
-
-		// Check for errors
-		for _, err := range errorsList {
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		// Process results and check for Commands
-		var nextNodesFromCommands []string
-		processedResults := make([]interface{}, len(results))
-
-		for i, res := range results {
-			if cmd, ok := res.(*Command); ok {
-				// It's a Command
-				processedResults[i] = cmd.Update
-
-				if cmd.Goto != nil {
-					switch g := cmd.Goto.(type) {
-					case string:
-						nextNodesFromCommands = append(nextNodesFromCommands, g)
-					case []string:
-						nextNodesFromCommands = append(nextNodesFromCommands, g...)
-					}
-				}
-			} else {
-				// Regular result
-				processedResults[i] = res
-			}
-		}
-
-		// Merge results
-		if r.graph.Schema != nil {
-			// If Schema is defined, use it to update state with results
-			for _, res := range processedResults {
-				var err error
-				state, err = r.graph.Schema.Update(state, res)
-				if err != nil {
-					return nil, fmt.Errorf("schema update failed: %w", err)
-				}
-			}
-		} else if r.graph.stateMerger != nil {
-			var err error
-			state, err = r.graph.stateMerger(ctx, state, processedResults)
-			if err != nil {
-				return nil, fmt.Errorf("state merge failed: %w", err)
-			}
-		} else {
-			if len(processedResults) > 0 {
-				state = processedResults[len(processedResults)-1]
-			}
-		}
-
-		// Determine next nodes
-		var nextNodesList []string
-
-		if len(nextNodesFromCommands) > 0 {
-			// Command.Goto overrides static edges
-			// We deduplicate
-			seen := make(map[string]bool)
-			for _, n := range nextNodesFromCommands {
-				if !seen[n] && n != END {
-					seen[n] = true
-					nextNodesList = append(nextNodesList, n)
-				}
-			}
-		} else {
-			// Use static edges
-			nextNodesSet := make(map[string]bool)
-
-			for _, nodeName := range currentNodes {
-				// First check for conditional edges
-				nextNodeFn, hasConditional := r.graph.conditionalEdges[nodeName]
-				if hasConditional {
-					nextNode := nextNodeFn(ctx, state)
-					if nextNode == "" {
-						return nil, fmt.Errorf("conditional edge returned empty next node from %s", nodeName)
-					}
-					nextNodesSet[nextNode] = true
-				} else {
-					// Then check regular edges
-					foundNext := false
-					for _, edge := range r.graph.edges {
-						if edge.From == nodeName {
-							nextNodesSet[edge.To] = true
-							foundNext = true
-							// Do NOT break here, to allow fan-out (multiple edges from same node)
-						}
-					}
-
-					if !foundNext {
-						return nil, fmt.Errorf("%w: %s", ErrNoOutgoingEdge, nodeName)
-					}
-				}
-			}
-
-			// Update nextNodesList from set
-			for node := range nextNodesSet {
-				nextNodesList = append(nextNodesList, node)
-			}
-		}
-
-		// Keep track of nodes that ran for callbacks
-		nodesRan := make([]string, len(currentNodes))
-		copy(nodesRan, currentNodes)
-
-		currentNodes = nextNodesList
-
-		// Cleanup ephemeral state if supported
-		if cleaningSchema, ok := r.graph.Schema.(CleaningStateSchema); ok {
-			state = cleaningSchema.Cleanup(state)
-		}
-
-		// Notify callbacks of step completion
-		if config != nil && len(config.Callbacks) > 0 {
-			for _, cb := range config.Callbacks {
-				if gcb, ok := cb.(GraphCallbackHandler); ok {
-					// We emit one event for the step, listing all nodes that ran
-					// Or we could emit one per node? But state is merged.
-					// Let's emit one event for the super-step.
-					nodeName := fmt.Sprintf("step:%v", nodesRan)
-					gcb.OnGraphStep(ctx, nodeName, state)
-				}
-			}
-		}
-	}
-
-	return state, nil
+	return r.executor().invoke(ctx, initialState, config)
 }
 
-// executeNodeWithRetry executes a node with retry logic based on the retry policy
-func (r *StateRunnable) executeNodeWithRetry(ctx context.Context, node Node, state interface{}) (interface{}, error) {
-	var lastErr error
-
-	maxRetries := 1 // Default: no retries
-	if r.graph.retryPolicy != nil {
-		maxRetries = r.graph.retryPolicy.MaxRetries + 1 // +1 for initial attempt
-	}
-
-	for attempt := 0; attempt < maxRetries; attempt++ {
-		result, err := node.Function(ctx, state)
-		if err == nil {
-			return result, nil
-		}
-
-		lastErr = err
-
-		// Check if error is retryable
-		if r.graph.retryPolicy != nil && attempt < maxRetries-1 {
-			if r.isRetryableError(err) {
-				// Apply backoff strategy
-				delay := r.calculateBackoffDelay(attempt)
-				if delay > 0 {
-					select {
-					case <-time.After(delay):
-						// Continue with retry after delay
-					case <-ctx.Done():
-						// Context cancelled, return immediately
-						return nil, ctx.Err()
-					}
-				}
-				continue
-			}
-		}
-
-		// If not retryable or max retries reached, return error
-		break
-	}
-
-	return nil, lastErr
+// InvokeWithUsage executes the compiled state graph like InvokeWithConfig and
+// returns the usage tracker of the run. It is the tracker of the context if
+// it has one, otherwise a new tracker recording only this run.
+func (r *StateRunnable) InvokeWithUsage(ctx context.Context, initialState interface{}, config *Config) (interface{}, *UsageTracker, error) {
+	return r.executor().invokeWithUsage(ctx, initialState, config)
 }
 
-// isRetryableError checks if an error is retryable based on the retry policy
-func (r *StateRunnable) isRetryableError(err error) bool {
-	if r.graph.retryPolicy == nil {
-		return false
-	}
-
-	errorStr := err.Error()
-	for _, retryablePattern := range r.graph.retryPolicy.RetryableErrors {
-		if contains(errorStr, retryablePattern) {
-			return true
-		}
+// executor returns the superstep executor for the compiled graph
+func (r *StateRunnable) executor() *executor {
+	return &executor{
+		nodes:            r.graph.nodes,
+		edges:            r.graph.edges,
+		conditionalEdges: r.graph.conditionalEdges,
+		entryPoint:       r.graph.entryPoint,
+		schema:           r.graph.Schema,
+		stateMerger:      r.graph.stateMerger,
+		retryPolicy:      r.graph.retryPolicy,
+		tracer:           r.tracer,
+		checkpointer:     r.checkpointer,
+		retention:        r.retention,
+		store:            r.store,
+		migrations:       r.graph.migrations,
 	}
-
-	return false
 }
 
 // contains is a simple string contains check
@@ -372,28 +209,6 @@ func findSubstring(s, substr string) bool {
 	return false
 }
 
-// calculateBackoffDelay calculates the delay for retry based on the backoff strategy
-func (r *StateRunnable) calculateBackoffDelay(attempt int) time.Duration {
-	if r.graph.retryPolicy == nil {
-		return 0
-	}
-
-	baseDelay := time.Second // Default 1 second base delay
-
-	switch r.graph.retryPolicy.BackoffStrategy {
-	case FixedBackoff:
-		return baseDelay
-	case ExponentialBackoff:
-		// Exponential backoff: 1s, 2s, 4s, 8s, ...
-		return baseDelay * time.Duration(1<<attempt)
-	case LinearBackoff:
-		// Linear backoff: 1s, 2s, 3s, 4s, ...
-		return baseDelay * time.Duration(attempt+1)
-	default:
-		return baseDelay
-	}
-}
-
 // ListenableStateGraph extends StateGraph with listener capabilities
 type ListenableStateGraph struct {
 	*StateGraph
@@ -0,0 +1,420 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSearchLimit is the number of items returned by Store.Search when no limit is set
+const DefaultSearchLimit = 10
+
+// ErrStoreNotIndexed is returned by semantic searches of a store created without an index
+var ErrStoreNotIndexed = errors.New("semantic search requires a store index")
+
+// Item is a value kept in a Store
+type Item struct {
+	Namespace []string               `json:"namespace"`
+	Key       string                 `json:"key"`
+	Value     map[string]interface{} `json:"value"`
+	CreatedAt time.Time              `json:"created_at"`
+	UpdatedAt time.Time              `json:"updated_at"`
+	// Score is the similarity of the item to the query of a semantic search
+	Score float64 `json:"score,omitempty"`
+}
+
+// SearchOptions selects the items returned by Store.Search
+type SearchOptions struct {
+	// Filter keeps the items whose value contains every entry, compared like MetadataMatches
+	Filter map[string]interface{}
+	// Query orders the items by the similarity of their embedding to the
+	// query's, most similar first. It requires a store created with an index.
+	Query string
+	// Limit is the maximum number of items, default DefaultSearchLimit
+	Limit int
+	// Offset skips the first items, for pagination
+	Offset int
+}
+
+// Store keeps long-term memory shared across threads, such as user
+// preferences or facts learned by an agent. Items are grouped in
+// hierarchical namespaces, e.g. []string{"users", userID, "preferences"},
+// and searched by namespace prefix.
+// Nodes reach the store of the graph with GetStore(ctx).
+type Store interface {
+	// Put creates or replaces an item
+	Put(ctx context.Context, namespace []string, key string, value map[string]interface{}) error
+	// Get returns an item, or nil if it does not exist
+	Get(ctx context.Context, namespace []string, key string) (*Item, error)
+	// Delete removes an item
+	Delete(ctx context.Context, namespace []string, key string) error
+	// Search returns the items of the namespaces starting with prefix,
+	// most recently updated first unless opts.Query is set
+	Search(ctx context.Context, prefix []string, opts SearchOptions) ([]*Item, error)
+}
+
+// Embedder generates embeddings for text. Implementations of prebuilt.Embedder satisfy it.
+type Embedder interface {
+	EmbedDocuments(ctx context.Context, texts []string) ([][]float64, error)
+	EmbedQuery(ctx context.Context, text string) ([]float64, error)
+}
+
+// StoreIndex enables semantic search in a Store. Values are embedded when
+// they are put, so the embedder must stay the same for the life of the store.
+type StoreIndex struct {
+	Embedder Embedder
+
+	// Fields are the value keys whose content is embedded, default the whole value
+	Fields []string
+}
+
+// Embed returns the embedding of a value, or nil when the index is nil or
+// the value has none of the indexed fields
+func (i *StoreIndex) Embed(ctx context.Context, value map[string]interface{}) ([]float64, error) {
+	if i == nil || i.Embedder == nil {
+		return nil, nil
+	}
+
+	text, err := i.text(value)
+	if err != nil || text == "" {
+		return nil, err
+	}
+	embeddings, err := i.Embedder.EmbedDocuments(ctx, []string{text})
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed value: %w", err)
+	}
+	if len(embeddings) != 1 {
+		return nil, fmt.Errorf("failed to embed value: got %d embeddings", len(embeddings))
+	}
+	return embeddings[0], nil
+}
+
+// EmbedQuery returns the embedding of a search query
+func (i *StoreIndex) EmbedQuery(ctx context.Context, query string) ([]float64, error) {
+	if i == nil || i.Embedder == nil {
+		return nil, ErrStoreNotIndexed
+	}
+
+	embedding, err := i.Embedder.EmbedQuery(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to embed query: %w", err)
+	}
+	return embedding, nil
+}
+
+// text returns the content of the indexed fields of a value. Strings are
+// used as is, other values as JSON.
+func (i *StoreIndex) text(value map[string]interface{}) (string, error) {
+	if len(i.Fields) == 0 {
+		if len(value) == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal value: %w", err)
+		}
+		return string(data), nil
+	}
+
+	parts := make([]string, 0, len(i.Fields))
+	for _, field := range i.Fields {
+		v, ok := value[field]
+		if !ok || v == nil {
+			continue
+		}
+		if s, ok := v.(string); ok {
+			parts = append(parts, s)
+			continue
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal field %s: %w", field, err)
+		}
+		parts = append(parts, string(data))
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// ValidateNamespace checks that a namespace has labels, none of them empty
+// or containing a dot
+func ValidateNamespace(namespace []string) error {
+	if len(namespace) == 0 {
+		return fmt.Errorf("namespace is required")
+	}
+	return validateLabels(namespace)
+}
+
+// validateLabels checks the labels of a namespace or namespace prefix
+func validateLabels(labels []string) error {
+	for _, label := range labels {
+		if label == "" || strings.Contains(label, ".") {
+			return fmt.Errorf("invalid namespace label %q: labels must be non-empty and contain no dot", label)
+		}
+	}
+	return nil
+}
+
+// NamespaceKey joins the labels of a namespace with dots, so that stores
+// can keep it in a single column and match prefixes
+func NamespaceKey(namespace []string) (string, error) {
+	if err := validateLabels(namespace); err != nil {
+		return "", err
+	}
+	return strings.Join(namespace, "."), nil
+}
+
+// SplitNamespaceKey reverses NamespaceKey
+func SplitNamespaceKey(key string) []string {
+	if key == "" {
+		return []string{}
+	}
+	return strings.Split(key, ".")
+}
+
+// namespaceHasPrefix reports whether a namespace key starts with the labels of prefix
+func namespaceHasPrefix(namespace, prefix string) bool {
+	return prefix == "" || namespace == prefix || strings.HasPrefix(namespace, prefix+".")
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors, 0 when
+// their lengths differ or either is zero
+func CosineSimilarity(a, b []float64) float64 {
+	if len(a) != len(b) || len(a) == 0 {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// SearchItems applies search options to candidate items. embeddings[i] is
+// the embedding of items[i], and query the embedding of opts.Query, if any.
+// Items are filtered, ordered by similarity to query, then most recently
+// updated first, and paged. Stores that cannot compare embeddings natively
+// use it on the items of the searched namespaces.
+func SearchItems(items []*Item, embeddings [][]float64, query []float64, opts SearchOptions) []*Item {
+	type candidate struct {
+		item      *Item
+		embedding []float64
+	}
+	candidates := make([]candidate, 0, len(items))
+	for i, item := range items {
+		if !MetadataMatches(item.Value, opts.Filter) {
+			continue
+		}
+		var embedding []float64
+		if i < len(embeddings) {
+			embedding = embeddings[i]
+		}
+		candidates = append(candidates, candidate{item: item, embedding: embedding})
+	}
+
+	if query != nil {
+		for _, c := range candidates {
+			c.item.Score = CosineSimilarity(query, c.embedding)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i].item, candidates[j].item
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if !a.UpdatedAt.Equal(b.UpdatedAt) {
+			return a.UpdatedAt.After(b.UpdatedAt)
+		}
+		// Ties are ordered by location, for stable pages
+		if nsA, nsB := strings.Join(a.Namespace, "."), strings.Join(b.Namespace, "."); nsA != nsB {
+			return nsA < nsB
+		}
+		return a.Key < b.Key
+	})
+
+	limit := opts.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	result := make([]*Item, 0, limit)
+	for i := opts.Offset; i < len(candidates) && len(result) < limit; i++ {
+		result = append(result, candidates[i].item)
+	}
+	return result
+}
+
+type storeKey struct{}
+
+// ContextWithStore adds a store to the context. Compiled graphs add the
+// store set with WithStore to the context of their nodes.
+func ContextWithStore(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, storeKey{}, store)
+}
+
+// GetStore retrieves the store from the context, or nil if there is none
+func GetStore(ctx context.Context) Store {
+	if store, ok := ctx.Value(storeKey{}).(Store); ok {
+		return store
+	}
+	return nil
+}
+
+// memoryItem is an item of a MemoryStore with its embedding
+type memoryItem struct {
+	item      Item
+	embedding []float64
+}
+
+// MemoryStore provides in-memory long-term memory
+type MemoryStore struct {
+	// items are keyed by namespace key, then item key
+	items map[string]map[string]*memoryItem
+	index *StoreIndex
+	mutex sync.RWMutex
+}
+
+// NewMemoryStore creates a new in-memory store without semantic search
+func NewMemoryStore() *MemoryStore {
+	return NewMemoryStoreWithIndex(nil)
+}
+
+// NewMemoryStoreWithIndex creates a new in-memory store. Values are embedded
+// with the index, if set, to enable semantic search.
+func NewMemoryStoreWithIndex(index *StoreIndex) *MemoryStore {
+	return &MemoryStore{
+		items: make(map[string]map[string]*memoryItem),
+		index: index,
+	}
+}
+
+// Put implements Store interface
+func (m *MemoryStore) Put(ctx context.Context, namespace []string, key string, value map[string]interface{}) error {
+	if err := ValidateNamespace(namespace); err != nil {
+		return err
+	}
+	nsKey, _ := NamespaceKey(namespace)
+
+	embedding, err := m.index.Embed(ctx, value)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	now := time.Now()
+	items := m.items[nsKey]
+	if items == nil {
+		items = make(map[string]*memoryItem)
+		m.items[nsKey] = items
+	}
+	createdAt := now
+	if existing, ok := items[key]; ok {
+		createdAt = existing.item.CreatedAt
+	}
+	items[key] = &memoryItem{
+		item: Item{
+			Namespace: append([]string(nil), namespace...),
+			Key:       key,
+			Value:     copyValue(value),
+			CreatedAt: createdAt,
+			UpdatedAt: now,
+		},
+		embedding: embedding,
+	}
+	return nil
+}
+
+// Get implements Store interface
+func (m *MemoryStore) Get(_ context.Context, namespace []string, key string) (*Item, error) {
+	nsKey, err := NamespaceKey(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stored, ok := m.items[nsKey][key]
+	if !ok {
+		return nil, nil
+	}
+	return stored.copy(), nil
+}
+
+// Delete implements Store interface
+func (m *MemoryStore) Delete(_ context.Context, namespace []string, key string) error {
+	nsKey, err := NamespaceKey(namespace)
+	if err != nil {
+		return err
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.items[nsKey], key)
+	if len(m.items[nsKey]) == 0 {
+		delete(m.items, nsKey)
+	}
+	return nil
+}
+
+// Search implements Store interface
+func (m *MemoryStore) Search(ctx context.Context, prefix []string, opts SearchOptions) ([]*Item, error) {
+	prefixKey, err := NamespaceKey(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var query []float64
+	if opts.Query != "" {
+		query, err = m.index.EmbedQuery(ctx, opts.Query)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	m.mutex.RLock()
+	var items []*Item
+	var embeddings [][]float64
+	for nsKey, stored := range m.items {
+		if !namespaceHasPrefix(nsKey, prefixKey) {
+			continue
+		}
+		for _, s := range stored {
+			items = append(items, s.copy())
+			embeddings = append(embeddings, s.embedding)
+		}
+	}
+	m.mutex.RUnlock()
+
+	return SearchItems(items, embeddings, query, opts), nil
+}
+
+// copy returns a copy of the item, which callers may modify
+func (s *memoryItem) copy() *Item {
+	item := s.item
+	item.Namespace = append([]string(nil), s.item.Namespace...)
+	item.Value = copyValue(s.item.Value)
+	return &item
+}
+
+// copyValue returns a shallow copy of a value
+func copyValue(value map[string]interface{}) map[string]interface{} {
+	copied := make(map[string]interface{}, len(value))
+	for k, v := range value {
+		copied[k] = v
+	}
+	return copied
+}
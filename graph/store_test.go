@@ -0,0 +1,172 @@
+package graph_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+)
+
+// keywordEmbedder embeds texts by counting the words of a vocabulary
+type keywordEmbedder struct {
+	vocabulary []string
+}
+
+func (e keywordEmbedder) EmbedDocuments(ctx context.Context, texts []string) ([][]float64, error) {
+	embeddings := make([][]float64, len(texts))
+	for i, text := range texts {
+		embeddings[i], _ = e.EmbedQuery(ctx, text)
+	}
+	return embeddings, nil
+}
+
+func (e keywordEmbedder) EmbedQuery(ctx context.Context, text string) ([]float64, error) {
+	embedding := make([]float64, len(e.vocabulary))
+	for i, word := range e.vocabulary {
+		embedding[i] = float64(strings.Count(strings.ToLower(text), word))
+	}
+	return embedding, nil
+}
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryStore()
+	namespace := []string{"users", "u1", "preferences"}
+
+	item, err := store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	assert.NoError(t, store.Put(ctx, namespace, "theme", map[string]interface{}{"mode": "dark"}))
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Equal(t, namespace, item.Namespace)
+	assert.Equal(t, map[string]interface{}{"mode": "dark"}, item.Value)
+	created := item.CreatedAt
+
+	// Returned values are copies
+	item.Value["mode"] = "light"
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, namespace, "theme", map[string]interface{}{"mode": "dark", "contrast": "high"}))
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Equal(t, "dark", item.Value["mode"])
+	assert.Equal(t, created, item.CreatedAt)
+	assert.True(t, item.UpdatedAt.After(created))
+
+	assert.NoError(t, store.Delete(ctx, namespace, "theme"))
+	item, err = store.Get(ctx, namespace, "theme")
+	assert.NoError(t, err)
+	assert.Nil(t, item)
+
+	assert.Error(t, store.Put(ctx, nil, "key", nil))
+	assert.Error(t, store.Put(ctx, []string{"a.b"}, "key", nil))
+	_, err = store.Get(ctx, []string{""}, "key")
+	assert.Error(t, err)
+}
+
+func TestMemoryStore_Search(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryStore()
+
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1", "facts"}, "f1", map[string]interface{}{"kind": "drink", "text": "likes coffee"}))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1", "facts"}, "f2", map[string]interface{}{"kind": "pet", "text": "has a cat"}))
+	time.Sleep(time.Millisecond)
+	assert.NoError(t, store.Put(ctx, []string{"users", "u1"}, "profile", map[string]interface{}{"name": "Ada"}))
+	assert.NoError(t, store.Put(ctx, []string{"users", "u10"}, "profile", map[string]interface{}{"name": "Grace"}))
+
+	// Prefixes match whole labels, most recent first
+	items, err := store.Search(ctx, []string{"users", "u1"}, graph.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"profile", "f2", "f1"}, itemKeys(items))
+
+	items, err = store.Search(ctx, nil, graph.SearchOptions{})
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+
+	items, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{Filter: map[string]interface{}{"kind": "drink"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f1"}, itemKeys(items))
+
+	items, err = store.Search(ctx, []string{"users", "u1"}, graph.SearchOptions{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f2"}, itemKeys(items))
+
+	_, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{Query: "coffee"})
+	assert.ErrorIs(t, err, graph.ErrStoreNotIndexed)
+}
+
+func TestMemoryStore_SemanticSearch(t *testing.T) {
+	ctx := context.Background()
+	store := graph.NewMemoryStoreWithIndex(&graph.StoreIndex{
+		Embedder: keywordEmbedder{vocabulary: []string{"coffee", "tea", "cat"}},
+		Fields:   []string{"text"},
+	})
+	namespace := []string{"users", "u1", "facts"}
+
+	assert.NoError(t, store.Put(ctx, namespace, "f1", map[string]interface{}{"text": "likes coffee, black coffee"}))
+	assert.NoError(t, store.Put(ctx, namespace, "f2", map[string]interface{}{"text": "has a cat"}))
+	assert.NoError(t, store.Put(ctx, namespace, "f3", map[string]interface{}{"text": "drinks tea and coffee"}))
+	assert.NoError(t, store.Put(ctx, namespace, "f4", map[string]interface{}{"other": "not indexed"}))
+
+	items, err := store.Search(ctx, []string{"users"}, graph.SearchOptions{Query: "coffee", Limit: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"f1", "f3"}, itemKeys(items))
+	assert.InDelta(t, 1.0, items[0].Score, 1e-9)
+	assert.Greater(t, items[0].Score, items[1].Score)
+
+	items, err = store.Search(ctx, []string{"users"}, graph.SearchOptions{Query: "cat"})
+	assert.NoError(t, err)
+	assert.Len(t, items, 4)
+	assert.Equal(t, "f2", items[0].Key)
+}
+
+func TestStore_Context(t *testing.T) {
+	ctx := context.Background()
+	assert.Nil(t, graph.GetStore(ctx))
+
+	store := graph.NewMemoryStore()
+	g := graph.NewStateGraph()
+	g.AddNode("remember", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, graph.GetStore(ctx).Put(ctx, []string{"users", "u1"}, "name", map[string]interface{}{"value": "Ada"})
+	})
+	g.AddNode("recall", func(ctx context.Context, state interface{}) (interface{}, error) {
+		item, err := graph.GetStore(ctx).Get(ctx, []string{"users", "u1"}, "name")
+		if err != nil {
+			return nil, err
+		}
+		return map[string]interface{}{"name": item.Value["value"]}, nil
+	})
+	g.AddEdge("remember", graph.END)
+	g.AddEdge("recall", graph.END)
+
+	// The store outlives threads
+	g.SetEntryPoint("remember")
+	runnable, err := g.Compile(graph.WithStore(store))
+	assert.NoError(t, err)
+	_, err = runnable.InvokeWithConfig(ctx, map[string]interface{}{}, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-1"},
+	})
+	assert.NoError(t, err)
+
+	g.SetEntryPoint("recall")
+	runnable, err = g.Compile(graph.WithStore(store))
+	assert.NoError(t, err)
+	result, err := runnable.InvokeWithConfig(ctx, map[string]interface{}{}, &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "thread-2"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada", result.(map[string]interface{})["name"])
+}
+
+func itemKeys(items []*graph.Item) []string {
+	keys := make([]string, 0, len(items))
+	for _, item := range items {
+		keys = append(keys, item.Key)
+	}
+	return keys
+}
@@ -2,6 +2,7 @@ package graph
 
 import (
 	"context"
+	"fmt"
 	"sync"
 	"time"
 )
@@ -33,6 +34,10 @@ type StreamConfig struct {
 
 	// Mode specifies what kind of events to stream
 	Mode StreamMode
+
+	// EventLog persists emitted events so that a disconnected client can
+	// resume the stream with Resume. Streams are not resumable when nil.
+	EventLog EventLog
 }
 
 // DefaultStreamConfig returns the default streaming configuration
@@ -61,6 +66,13 @@ type StreamResult struct {
 
 	// Cancel function can be called to stop streaming
 	Cancel context.CancelFunc
+
+	// RunID identifies the streamed run, used to resume the stream later
+	RunID string
+
+	// Usage collects the token usage of the run. It is the tracker of the
+	// stream context if it has one
+	Usage *UsageTracker
 }
 
 // StreamingListener implements NodeListener for streaming events
@@ -70,7 +82,13 @@ type StreamingListener struct {
 	mutex     sync.RWMutex
 
 	droppedEvents int
+	logErr        error
 	closed        bool
+
+	runID        string
+	eventLog     EventLog
+	publishMutex sync.Mutex
+	subscribers  map[chan StreamEvent]struct{}
 }
 
 // NewStreamingListener creates a new streaming listener
@@ -81,8 +99,19 @@ func NewStreamingListener(eventChan chan<- StreamEvent, config StreamConfig) *St
 	}
 }
 
+// WithEventLog makes the listener record every emitted event of the run in the event log
+func (sl *StreamingListener) WithEventLog(runID string, eventLog EventLog) *StreamingListener {
+	sl.runID = runID
+	sl.eventLog = eventLog
+	return sl
+}
+
 // emitEvent sends an event to the channel handling backpressure
 func (sl *StreamingListener) emitEvent(event StreamEvent) {
+	// Serialize publishing so that sequences are delivered in order
+	sl.publishMutex.Lock()
+	defer sl.publishMutex.Unlock()
+
 	// Check if listener is closed
 	sl.mutex.RLock()
 	if sl.closed {
@@ -96,6 +125,31 @@ func (sl *StreamingListener) emitEvent(event StreamEvent) {
 		return
 	}
 
+	event.RunID = sl.runID
+	if sl.eventLog != nil {
+		// A failed append still streams the event live, the stream reports
+		// the failure when the run ends since the event can't be replayed
+		logged, err := sl.eventLog.Append(context.Background(), sl.runID, event)
+		if err == nil {
+			event = logged
+		} else {
+			sl.mutex.Lock()
+			if sl.logErr == nil {
+				sl.logErr = err
+			}
+			sl.mutex.Unlock()
+		}
+	}
+
+	// Forward to resumed clients. Slow subscribers miss events, Resume reads
+	// them back from the event log when it sees the sequence gap.
+	for sub := range sl.subscribers {
+		select {
+		case sub <- event:
+		default:
+		}
+	}
+
 	// Try to send event without blocking
 	select {
 	case sl.eventChan <- event:
@@ -126,7 +180,7 @@ func (sl *StreamingListener) shouldEmit(event StreamEvent) bool {
 		return event.Event == EventToolEnd || event.Event == EventChainEnd || event.Event == NodeEventComplete
 	case StreamModeMessages:
 		// Emit LLM events
-		return event.Event == EventLLMEnd || event.Event == EventLLMStart
+		return event.Event == EventLLMEnd || event.Event == EventLLMStart || event.Event == EventUsage
 	default:
 		return true
 	}
@@ -189,6 +243,16 @@ func (sl *StreamingListener) OnLLMEnd(ctx context.Context, response interface{},
 	})
 }
 
+// OnUsage implements the UsageCallbackHandler interface
+func (sl *StreamingListener) OnUsage(ctx context.Context, record UsageRecord, runID string) {
+	sl.emitEvent(StreamEvent{
+		Timestamp: time.Now(),
+		NodeName:  record.Node,
+		Event:     EventUsage,
+		State:     record,
+	})
+}
+
 func (sl *StreamingListener) OnLLMError(ctx context.Context, err error, runID string) {
 	sl.emitEvent(StreamEvent{
 		Timestamp: time.Now(),
@@ -245,8 +309,46 @@ func (sl *StreamingListener) OnGraphStep(ctx context.Context, stepNode string, s
 // Close marks the listener as closed to prevent sending to closed channels
 func (sl *StreamingListener) Close() {
 	sl.mutex.Lock()
-	defer sl.mutex.Unlock()
 	sl.closed = true
+	sl.mutex.Unlock()
+
+	sl.publishMutex.Lock()
+	defer sl.publishMutex.Unlock()
+	for sub := range sl.subscribers {
+		close(sub)
+	}
+	sl.subscribers = nil
+}
+
+// subscribe registers a channel receiving the events emitted from now on.
+// The channel is closed when the listener closes.
+func (sl *StreamingListener) subscribe(bufferSize int) (<-chan StreamEvent, func()) {
+	sl.publishMutex.Lock()
+	defer sl.publishMutex.Unlock()
+
+	sub := make(chan StreamEvent, bufferSize)
+
+	sl.mutex.RLock()
+	closed := sl.closed
+	sl.mutex.RUnlock()
+	if closed {
+		close(sub)
+		return sub, func() {}
+	}
+
+	if sl.subscribers == nil {
+		sl.subscribers = make(map[chan StreamEvent]struct{})
+	}
+	sl.subscribers[sub] = struct{}{}
+
+	return sub, func() {
+		sl.publishMutex.Lock()
+		defer sl.publishMutex.Unlock()
+		if _, ok := sl.subscribers[sub]; ok {
+			delete(sl.subscribers, sub)
+			close(sub)
+		}
+	}
 }
 
 // handleBackpressure manages channel backpressure
@@ -267,17 +369,29 @@ func (sl *StreamingListener) GetDroppedEventsCount() int {
 	return sl.droppedEvents
 }
 
+// EventLogError returns the first error appending an event to the event log
+func (sl *StreamingListener) EventLogError() error {
+	sl.mutex.RLock()
+	defer sl.mutex.RUnlock()
+	return sl.logErr
+}
+
 // StreamingRunnable wraps a ListenableRunnable with streaming capabilities
 type StreamingRunnable struct {
 	runnable *ListenableRunnable
 	config   StreamConfig
+
+	// activeRuns tracks the listeners of running streams so resumed clients can follow them live
+	activeRuns map[string]*StreamingListener
+	runsMutex  sync.RWMutex
 }
 
 // NewStreamingRunnable creates a new streaming runnable
 func NewStreamingRunnable(runnable *ListenableRunnable, config StreamConfig) *StreamingRunnable {
 	return &StreamingRunnable{
-		runnable: runnable,
-		config:   config,
+		runnable:   runnable,
+		config:     config,
+		activeRuns: make(map[string]*StreamingListener),
 	}
 }
 
@@ -288,17 +402,39 @@ func NewStreamingRunnableWithDefaults(runnable *ListenableRunnable) *StreamingRu
 
 // Stream executes the graph with real-time event streaming
 func (sr *StreamingRunnable) Stream(ctx context.Context, initialState interface{}) *StreamResult {
+	return sr.StreamWithConfig(ctx, initialState, nil)
+}
+
+// StreamWithConfig executes the graph with real-time event streaming and config,
+// e.g. to stream a run on a checkpointed thread
+func (sr *StreamingRunnable) StreamWithConfig(ctx context.Context, initialState interface{}, config *Config) *StreamResult {
 	// Create channels
 	eventChan := make(chan StreamEvent, sr.config.BufferSize)
 	resultChan := make(chan interface{}, 1)
 	errorChan := make(chan error, 1)
 	doneChan := make(chan struct{})
 
+	// Track the token usage of the run
+	usage := GetUsageTracker(ctx)
+	if usage == nil {
+		usage = NewUsageTracker(nil)
+		ctx = WithUsageTracker(ctx, usage)
+	}
+
 	// Create cancellable context
 	streamCtx, cancel := context.WithCancel(ctx)
 
 	// Create streaming listener
+	runID := generateRunID()
 	streamingListener := NewStreamingListener(eventChan, sr.config)
+	if sr.config.EventLog != nil {
+		streamingListener.WithEventLog(runID, sr.config.EventLog)
+		sr.runsMutex.Lock()
+		sr.activeRuns[runID] = streamingListener
+		sr.runsMutex.Unlock()
+	} else {
+		streamingListener.runID = runID
+	}
 
 	// Add the streaming listener to all nodes
 	for _, node := range sr.runnable.listenableNodes {
@@ -311,6 +447,10 @@ func (sr *StreamingRunnable) Stream(ctx context.Context, initialState interface{
 			// First, close the streaming listener to prevent new events
 			streamingListener.Close()
 
+			sr.runsMutex.Lock()
+			delete(sr.activeRuns, runID)
+			sr.runsMutex.Unlock()
+
 			// Clean up: remove streaming listener from all nodes
 			for _, node := range sr.runnable.listenableNodes {
 				node.RemoveListener(streamingListener)
@@ -326,13 +466,15 @@ func (sr *StreamingRunnable) Stream(ctx context.Context, initialState interface{
 			close(doneChan)
 		}()
 
-		// Create config with streaming listener as callback
-		config := &Config{
-			Callbacks: []CallbackHandler{streamingListener},
+		// Add the streaming listener to a copy of the config callbacks
+		runConfig := &Config{}
+		if config != nil {
+			*runConfig = *config
 		}
+		runConfig.Callbacks = append(append([]CallbackHandler(nil), runConfig.Callbacks...), streamingListener)
 
 		// Execute the runnable
-		result, err := sr.runnable.InvokeWithConfig(streamCtx, initialState, config)
+		result, err := sr.runnable.InvokeWithConfig(streamCtx, initialState, runConfig)
 
 		// Send result or error
 		if err != nil {
@@ -345,6 +487,13 @@ func (sr *StreamingRunnable) Stream(ctx context.Context, initialState interface{
 			case resultChan <- result:
 			case <-streamCtx.Done():
 			}
+			// The run succeeded but clients can't resume it
+			if logErr := streamingListener.EventLogError(); logErr != nil {
+				select {
+				case errorChan <- fmt.Errorf("failed to log stream events: %w", logErr):
+				case <-streamCtx.Done():
+				}
+			}
 		}
 	}()
 
@@ -354,6 +503,108 @@ func (sr *StreamingRunnable) Stream(ctx context.Context, initialState interface{
 		Errors: errorChan,
 		Done:   doneChan,
 		Cancel: cancel,
+		RunID:  runID,
+		Usage:  usage,
+	}
+}
+
+// Resume reconnects to a run started by Stream. It first replays the events
+// logged after lastSequence and then, if the run is still executing, follows
+// its live events. The Result channel never receives a value since the final
+// result belongs to the original Stream call; Errors reports replay failures.
+//
+// Use a context that outlives the client connection for the original Stream
+// call, otherwise a disconnect cancels the run it wants to resume.
+func (sr *StreamingRunnable) Resume(ctx context.Context, runID string, lastSequence int64) *StreamResult {
+	eventChan := make(chan StreamEvent, sr.config.BufferSize)
+	resultChan := make(chan interface{})
+	errorChan := make(chan error, 1)
+	doneChan := make(chan struct{})
+
+	resumeCtx, cancel := context.WithCancel(ctx)
+
+	go func() {
+		defer func() {
+			close(eventChan)
+			close(resultChan)
+			close(errorChan)
+			close(doneChan)
+		}()
+
+		if sr.config.EventLog == nil {
+			errorChan <- ErrEventLogNotConfigured
+			return
+		}
+
+		// Subscribe before reading the log so no event falls between replay and live
+		var live <-chan StreamEvent
+		sr.runsMutex.RLock()
+		listener, running := sr.activeRuns[runID]
+		sr.runsMutex.RUnlock()
+		if running {
+			sub, unsubscribe := listener.subscribe(sr.config.BufferSize)
+			defer unsubscribe()
+			live = sub
+		}
+
+		replay, err := sr.config.EventLog.ReadAfter(resumeCtx, runID, lastSequence)
+		if err != nil {
+			errorChan <- err
+			return
+		}
+
+		last := lastSequence
+		forward := func(events ...StreamEvent) bool {
+			for _, event := range events {
+				// Skip events already delivered
+				if event.Sequence <= last {
+					continue
+				}
+				select {
+				case eventChan <- event:
+					last = event.Sequence
+				case <-resumeCtx.Done():
+					return false
+				}
+			}
+			return true
+		}
+
+		if !forward(replay...) || live == nil {
+			return
+		}
+
+		for {
+			select {
+			case event, ok := <-live:
+				// The subscription dropped events while it was full, read them
+				// back, including those dropped just before the run ended
+				if !ok || event.Sequence > last+1 {
+					missed, err := sr.config.EventLog.ReadAfter(resumeCtx, runID, last)
+					if err != nil {
+						errorChan <- err
+						return
+					}
+					if !forward(missed...) || !ok {
+						return
+					}
+				}
+				if !forward(event) {
+					return
+				}
+			case <-resumeCtx.Done():
+				return
+			}
+		}
+	}()
+
+	return &StreamResult{
+		Events: eventChan,
+		Result: resultChan,
+		Errors: errorChan,
+		Done:   doneChan,
+		Cancel: cancel,
+		RunID:  runID,
 	}
 }
 
@@ -380,8 +631,8 @@ func NewStreamingMessageGraphWithConfig(config StreamConfig) *StreamingMessageGr
 }
 
 // CompileStreaming compiles the graph into a streaming runnable
-func (g *StreamingMessageGraph) CompileStreaming() (*StreamingRunnable, error) {
-	listenableRunnable, err := g.CompileListenable()
+func (g *StreamingMessageGraph) CompileStreaming(opts ...CompileOption) (*StreamingRunnable, error) {
+	listenableRunnable, err := g.CompileListenable(opts...)
 	if err != nil {
 		return nil, err
 	}
@@ -469,6 +720,11 @@ func (se *StreamingExecutor) ExecuteAsync(ctx context.Context, initialState inte
 	return se.runnable.Stream(ctx, initialState)
 }
 
+// SetTracer sets a tracer for observability
+func (sr *StreamingRunnable) SetTracer(tracer *Tracer) {
+	sr.runnable.SetTracer(tracer)
+}
+
 // GetGraph returns a Exporter for the streaming runnable
 func (sr *StreamingRunnable) GetGraph() *Exporter {
 	return sr.runnable.GetGraph()
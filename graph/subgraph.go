@@ -43,6 +43,7 @@ func (g *MessageGraph) AddSubgraph(name string, subgraph *MessageGraph) error {
 	}
 
 	g.AddNode(name, sg.Execute)
+	g.structure.setSubgraph(name, subgraph)
 	return nil
 }
 
@@ -168,6 +169,7 @@ func (g *MessageGraph) AddRecursiveSubgraph(
 	rs := NewRecursiveSubgraph(name, maxDepth, condition)
 	builder(rs.graph)
 	g.AddNode(name, rs.Execute)
+	g.structure.setSubgraph(name, rs.graph)
 }
 
 // NestedConditionalSubgraph creates a subgraph with its own conditional routing
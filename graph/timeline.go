@@ -0,0 +1,533 @@
+package graph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// TimelineSlice is a span of a run on the timeline, such as a node execution
+type TimelineSlice struct {
+	Name     string
+	Category string
+	// Track is the row of the slice, 0 is the graph and checkpoint saves,
+	// parallel nodes are placed on separate tracks
+	Track int
+	// Depth is the nesting level of the slice in its track
+	Depth int
+	// Start is the offset of the slice from the start of the run
+	Start    time.Duration
+	Duration time.Duration
+	Args     map[string]interface{}
+}
+
+// TimelineInstant is an event of a run without duration, such as a retry or interrupt
+type TimelineInstant struct {
+	Name     string
+	Category string
+	Track    int
+	// At is the offset of the event from the start of the run
+	At   time.Duration
+	Args map[string]interface{}
+}
+
+// Timeline is the execution timeline of a completed run, built from its
+// trace spans. It exports to the Chrome Trace Event format and to a
+// self-contained HTML report.
+type Timeline struct {
+	// Start is the start time of the run
+	Start    time.Time
+	Duration time.Duration
+	// Tracks are the names of the tracks, by index
+	Tracks   []string
+	Slices   []TimelineSlice
+	Instants []TimelineInstant
+}
+
+// timelineTrack places slices on a track, nesting them in the slices of
+// their ancestors. Slices must be placed in order of start.
+type timelineTrack struct {
+	// open holds the slices containing the last placed one
+	open []*TraceSpan
+}
+
+// place puts the span on the track if it only overlaps slices of its
+// ancestors, and returns its depth
+func (tr *timelineTrack) place(span, root *TraceSpan, ancestors map[string]bool) (int, bool) {
+	for len(tr.open) > 0 && !spanEnd(tr.open[len(tr.open)-1], root).After(span.StartTime) {
+		tr.open = tr.open[:len(tr.open)-1]
+	}
+	if len(tr.open) > 0 {
+		top := tr.open[len(tr.open)-1]
+		if !ancestors[top.ID] || spanEnd(span, root).After(spanEnd(top, root)) {
+			return 0, false
+		}
+	}
+	tr.open = append(tr.open, span)
+	return len(tr.open) - 1, true
+}
+
+// Timeline builds the timeline of the run whose graph span has the given
+// ID, or of the last started run if the ID is empty. Subgraphs, retry
+// attempts and checkpoint saves of the run are included.
+func (t *Tracer) Timeline(rootSpanID string) (*Timeline, error) {
+	spans := t.GetSpans()
+
+	root, err := timelineRoot(spans, rootSpanID)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string][]*TraceSpan)
+	for _, span := range spans {
+		if span.ParentID != "" {
+			children[span.ParentID] = append(children[span.ParentID], span)
+		}
+	}
+	var run []*TraceSpan
+	var collect func(id string)
+	collect = func(id string) {
+		for _, child := range children[id] {
+			run = append(run, child)
+			collect(child.ID)
+		}
+	}
+	collect(root.ID)
+
+	// Containing spans are placed first, so that their children nest in them
+	sort.SliceStable(run, func(i, j int) bool {
+		if !run[i].StartTime.Equal(run[j].StartTime) {
+			return run[i].StartTime.Before(run[j].StartTime)
+		}
+		return spanEnd(run[i], root).After(spanEnd(run[j], root))
+	})
+
+	tl := &Timeline{
+		Start:    root.StartTime,
+		Duration: root.Duration,
+		Tracks:   []string{"graph"},
+	}
+	tracks := []*timelineTrack{{open: []*TraceSpan{root}}}
+	tl.addSlice(root, 0, 0)
+
+	trackOf := map[string]int{root.ID: 0}
+	for _, span := range run {
+		if span.Event == TraceEventEdgeTraversal {
+			continue
+		}
+		ancestors := make(map[string]bool)
+		for parent := spans[span.ParentID]; parent != nil && !ancestors[parent.ID]; parent = spans[parent.ParentID] {
+			ancestors[parent.ID] = true
+		}
+
+		// Checkpoint saves stay with the graph, other spans go under their
+		// parent when they fit, or on the first free node track
+		candidates := make([]int, 0, len(tracks))
+		if parent, ok := trackOf[span.ParentID]; ok && (parent > 0 || isCheckpointSpan(span)) {
+			candidates = append(candidates, parent)
+		}
+		for i := 1; i < len(tracks); i++ {
+			candidates = append(candidates, i)
+		}
+
+		track, depth, placed := -1, 0, false
+		for _, candidate := range candidates {
+			if depth, placed = tracks[candidate].place(span, root, ancestors); placed {
+				track = candidate
+				break
+			}
+		}
+		if !placed {
+			tracks = append(tracks, &timelineTrack{})
+			track = len(tracks) - 1
+			tl.Tracks = append(tl.Tracks, fmt.Sprintf("track %d", track))
+			depth, _ = tracks[track].place(span, root, ancestors)
+		}
+		trackOf[span.ID] = track
+
+		tl.addSlice(span, track, depth)
+		tl.addInstants(span, track)
+	}
+
+	tl.addInstants(root, 0)
+	return tl, nil
+}
+
+// timelineRoot returns the graph span of the run to export
+func timelineRoot(spans map[string]*TraceSpan, rootSpanID string) (*TraceSpan, error) {
+	var root *TraceSpan
+	if rootSpanID != "" {
+		root = spans[rootSpanID]
+		if root == nil {
+			return nil, fmt.Errorf("span %s not found", rootSpanID)
+		}
+	} else {
+		for _, span := range spans {
+			if span.ParentID != "" || !isGraphSpan(span) {
+				continue
+			}
+			if root == nil || span.StartTime.After(root.StartTime) {
+				root = span
+			}
+		}
+		if root == nil {
+			return nil, errors.New("no traced run found")
+		}
+	}
+
+	if root.EndTime.IsZero() {
+		return nil, fmt.Errorf("run %s has not completed", root.ID)
+	}
+	return root, nil
+}
+
+// spanEnd returns the end time of a span, spans left open by the run end with it
+func spanEnd(span, root *TraceSpan) time.Time {
+	if span.EndTime.IsZero() {
+		return root.EndTime
+	}
+	return span.EndTime
+}
+
+func isGraphSpan(span *TraceSpan) bool {
+	return span.Event == TraceEventGraphStart || span.Event == TraceEventGraphEnd
+}
+
+func isCheckpointSpan(span *TraceSpan) bool {
+	return span.Event == TraceEventCheckpointStart || span.Event == TraceEventCheckpointEnd
+}
+
+// addSlice adds a span to the timeline
+func (tl *Timeline) addSlice(span *TraceSpan, track, depth int) {
+	var name, category string
+	switch span.Event {
+	case TraceEventGraphStart, TraceEventGraphEnd:
+		name, category = span.NodeName, "graph"
+	case TraceEventAttemptStart, TraceEventAttemptEnd:
+		name, category = fmt.Sprintf("attempt %v", span.Metadata["attempt"]), "attempt"
+	case TraceEventCheckpointStart, TraceEventCheckpointEnd:
+		name, category = "checkpoint save", "checkpoint"
+	default:
+		name, category = span.NodeName, "node"
+	}
+
+	args := make(map[string]interface{}, len(span.Metadata)+1)
+	for key, value := range span.Metadata {
+		args[key] = value
+	}
+	if span.Error != nil {
+		args["error"] = span.Error.Error()
+	}
+
+	end := span.EndTime
+	if end.IsZero() {
+		end = tl.Start.Add(tl.Duration)
+	}
+	tl.Slices = append(tl.Slices, TimelineSlice{
+		Name:     name,
+		Category: category,
+		Track:    track,
+		Depth:    depth,
+		Start:    span.StartTime.Sub(tl.Start),
+		Duration: end.Sub(span.StartTime),
+		Args:     args,
+	})
+}
+
+// addInstants adds the retries and stops of a span to the timeline
+func (tl *Timeline) addInstants(span *TraceSpan, track int) {
+	if attempt, ok := span.Metadata["attempt"].(int); ok && attempt > 1 {
+		tl.Instants = append(tl.Instants, TimelineInstant{
+			Name:     "retry",
+			Category: "retry",
+			Track:    track,
+			At:       span.StartTime.Sub(tl.Start),
+			Args:     map[string]interface{}{"node": span.NodeName, "attempt": attempt},
+		})
+	}
+
+	if span.Error == nil || span.EndTime.IsZero() {
+		return
+	}
+	at := span.EndTime.Sub(tl.Start)
+
+	var nodeInterrupt *NodeInterrupt
+	var graphInterrupt *GraphInterrupt
+	var budgetErr *BudgetExceededError
+	switch {
+	case errors.As(span.Error, &nodeInterrupt) && span.Event == TraceEventNodeError:
+		tl.Instants = append(tl.Instants, TimelineInstant{
+			Name:     "interrupt",
+			Category: "interrupt",
+			Track:    track,
+			At:       at,
+			Args:     map[string]interface{}{"node": span.NodeName, "value": fmt.Sprint(nodeInterrupt.Value)},
+		})
+	case errors.As(span.Error, &graphInterrupt) && isGraphSpan(span) && !tl.hasInstant("interrupt"):
+		// Dynamic interrupts are already marked on the node that raised them
+		tl.Instants = append(tl.Instants, TimelineInstant{
+			Name:     "interrupt",
+			Category: "interrupt",
+			Track:    track,
+			At:       at,
+			Args:     map[string]interface{}{"node": graphInterrupt.Node, "next": graphInterrupt.NextNodes},
+		})
+	case errors.As(span.Error, &budgetErr) && isGraphSpan(span):
+		tl.Instants = append(tl.Instants, TimelineInstant{
+			Name:     "budget exceeded",
+			Category: "budget",
+			Track:    track,
+			At:       at,
+			Args:     map[string]interface{}{"limit": string(budgetErr.Limit), "next": budgetErr.NextNodes},
+		})
+	}
+}
+
+// hasInstant reports whether the timeline has an instant event of the category
+func (tl *Timeline) hasInstant(category string) bool {
+	for _, instant := range tl.Instants {
+		if instant.Category == category {
+			return true
+		}
+	}
+	return false
+}
+
+// ChromeTraceEvent is an event of the Chrome Trace Event format
+type ChromeTraceEvent struct {
+	Name  string  `json:"name"`
+	Cat   string  `json:"cat,omitempty"`
+	Phase string  `json:"ph"`
+	Ts    float64 `json:"ts"`
+	Dur   float64 `json:"dur,omitempty"`
+	Pid   int     `json:"pid"`
+	Tid   int     `json:"tid"`
+	// Scope of instant events, "t" for their track
+	Scope string                 `json:"s,omitempty"`
+	Args  map[string]interface{} `json:"args,omitempty"`
+}
+
+// ChromeTrace is a trace in the Chrome Trace Event format, viewable in
+// chrome://tracing or Perfetto
+type ChromeTrace struct {
+	TraceEvents     []ChromeTraceEvent `json:"traceEvents"`
+	DisplayTimeUnit string             `json:"displayTimeUnit"`
+}
+
+// microseconds converts a duration to the time unit of Chrome traces
+func microseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Microsecond)
+}
+
+// ChromeTrace converts the timeline to the Chrome Trace Event format. Each
+// track is a thread of the run, slices are complete events and retries and
+// interrupts are instant events.
+func (tl *Timeline) ChromeTrace() *ChromeTrace {
+	trace := &ChromeTrace{DisplayTimeUnit: "ms"}
+	trace.TraceEvents = append(trace.TraceEvents, ChromeTraceEvent{
+		Name:  "process_name",
+		Phase: "M",
+		Pid:   1,
+		Args:  map[string]interface{}{"name": "langgraph run"},
+	})
+	for tid, name := range tl.Tracks {
+		trace.TraceEvents = append(trace.TraceEvents,
+			ChromeTraceEvent{Name: "thread_name", Phase: "M", Pid: 1, Tid: tid, Args: map[string]interface{}{"name": name}},
+			ChromeTraceEvent{Name: "thread_sort_index", Phase: "M", Pid: 1, Tid: tid, Args: map[string]interface{}{"sort_index": tid}},
+		)
+	}
+
+	for _, slice := range tl.Slices {
+		trace.TraceEvents = append(trace.TraceEvents, ChromeTraceEvent{
+			Name:  slice.Name,
+			Cat:   slice.Category,
+			Phase: "X",
+			Ts:    microseconds(slice.Start),
+			Dur:   microseconds(slice.Duration),
+			Pid:   1,
+			Tid:   slice.Track,
+			Args:  slice.Args,
+		})
+	}
+	for _, instant := range tl.Instants {
+		trace.TraceEvents = append(trace.TraceEvents, ChromeTraceEvent{
+			Name:  instant.Name,
+			Cat:   instant.Category,
+			Phase: "i",
+			Ts:    microseconds(instant.At),
+			Pid:   1,
+			Tid:   instant.Track,
+			Scope: "t",
+			Args:  instant.Args,
+		})
+	}
+	return trace
+}
+
+// WriteChromeTrace writes the timeline as Chrome Trace Event JSON
+func (tl *Timeline) WriteChromeTrace(w io.Writer) error {
+	if err := json.NewEncoder(w).Encode(tl.ChromeTrace()); err != nil {
+		return fmt.Errorf("failed to write chrome trace: %w", err)
+	}
+	return nil
+}
+
+// timelineRowHeight is the height in pixels of a nesting level of a track
+const timelineRowHeight = 22
+
+// htmlSlice is a slice positioned in the HTML report
+type htmlSlice struct {
+	Name     string
+	Category string
+	Left     float64
+	Width    float64
+	Top      int
+	Title    string
+}
+
+// htmlInstant is an instant event positioned in the HTML report
+type htmlInstant struct {
+	Category string
+	Left     float64
+	Title    string
+}
+
+// htmlTrack is a track of the HTML report
+type htmlTrack struct {
+	Name     string
+	Height   int
+	Slices   []htmlSlice
+	Instants []htmlInstant
+}
+
+// WriteHTML writes a self-contained HTML report rendering the timeline,
+// viewable offline without scripts
+func (tl *Timeline) WriteHTML(w io.Writer, title string) error {
+	if title == "" {
+		title = "Graph timeline"
+	}
+
+	percent := func(d time.Duration) float64 {
+		if tl.Duration <= 0 {
+			return 0
+		}
+		return float64(d) / float64(tl.Duration) * 100
+	}
+
+	tracks := make([]htmlTrack, len(tl.Tracks))
+	for i, name := range tl.Tracks {
+		tracks[i] = htmlTrack{Name: name, Height: timelineRowHeight}
+	}
+	for _, slice := range tl.Slices {
+		track := &tracks[slice.Track]
+		top := slice.Depth * timelineRowHeight
+		if top+timelineRowHeight > track.Height {
+			track.Height = top + timelineRowHeight
+		}
+		track.Slices = append(track.Slices, htmlSlice{
+			Name:     slice.Name,
+			Category: slice.Category,
+			Left:     percent(slice.Start),
+			Width:    percent(slice.Duration),
+			Top:      top,
+			Title:    eventTitle(slice.Name, slice.Duration, slice.Args),
+		})
+	}
+	for _, instant := range tl.Instants {
+		track := &tracks[instant.Track]
+		track.Instants = append(track.Instants, htmlInstant{
+			Category: instant.Category,
+			Left:     percent(instant.At),
+			Title:    eventTitle(instant.Name, -1, instant.Args),
+		})
+	}
+
+	data := struct {
+		Title    string
+		Start    string
+		Duration time.Duration
+		Tracks   []htmlTrack
+	}{
+		Title:    title,
+		Start:    tl.Start.Format(time.RFC3339Nano),
+		Duration: tl.Duration,
+		Tracks:   tracks,
+	}
+	if err := timelineTemplate.Execute(w, data); err != nil {
+		return fmt.Errorf("failed to write timeline report: %w", err)
+	}
+	return nil
+}
+
+// eventTitle describes an event in the tooltip of the HTML report, a
+// negative duration is omitted
+func eventTitle(name string, duration time.Duration, args map[string]interface{}) string {
+	var sb strings.Builder
+	sb.WriteString(name)
+	if duration >= 0 {
+		fmt.Fprintf(&sb, " (%s)", duration)
+	}
+
+	keys := make([]string, 0, len(args))
+	for key := range args {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(&sb, "\n%s: %v", key, args[key])
+	}
+	return sb.String()
+}
+
+var timelineTemplate = template.Must(template.New("timeline").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: -apple-system, "Segoe UI", Helvetica, Arial, sans-serif; margin: 24px; color: #222; }
+h1 { font-size: 20px; margin-bottom: 4px; }
+.summary { color: #666; font-size: 13px; margin-bottom: 16px; }
+.track { display: flex; border-top: 1px solid #eee; }
+.label { width: 120px; flex-shrink: 0; font-size: 12px; padding: 4px 8px; color: #555; }
+.lane { position: relative; flex-grow: 1; }
+.slice { position: absolute; height: 20px; box-sizing: border-box; overflow: hidden; white-space: nowrap;
+  font-size: 11px; line-height: 20px; padding: 0 4px; border-radius: 3px; border: 1px solid rgba(0, 0, 0, 0.15); min-width: 2px; }
+.slice.graph { background: #dfe7f3; }
+.slice.node { background: #7fb3e6; }
+.slice.attempt { background: #f2c46d; }
+.slice.checkpoint { background: #9fd6a4; }
+.instant { position: absolute; top: 0; bottom: 0; width: 2px; margin-left: -1px; }
+.instant.retry { background: #e08a00; }
+.instant.interrupt { background: #8e44ad; }
+.instant.budget { background: #c0392b; }
+.legend { font-size: 12px; color: #555; margin-top: 16px; }
+.legend span { display: inline-block; width: 10px; height: 10px; margin: 0 4px 0 12px; border-radius: 2px; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+<div class="summary">Started {{.Start}}, took {{.Duration}}</div>
+{{range .Tracks}}<div class="track">
+<div class="label">{{.Name}}</div>
+<div class="lane" style="height: {{.Height}}px">
+{{range .Slices}}<div class="slice {{.Category}}" style="left: {{.Left}}%; width: {{.Width}}%; top: {{.Top}}px" title="{{.Title}}">{{.Name}}</div>
+{{end}}{{range .Instants}}<div class="instant {{.Category}}" style="left: {{.Left}}%" title="{{.Title}}"></div>
+{{end}}</div>
+</div>
+{{end}}<div class="legend">
+<span style="background: #7fb3e6"></span>node
+<span style="background: #f2c46d"></span>attempt
+<span style="background: #9fd6a4"></span>checkpoint save
+<span style="background: #e08a00"></span>retry
+<span style="background: #8e44ad"></span>interrupt
+<span style="background: #c0392b"></span>budget exceeded
+</div>
+</body>
+</html>
+`))
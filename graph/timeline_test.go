@@ -0,0 +1,228 @@
+package graph_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sliceByName returns the timeline slice with the name
+func sliceByName(t *testing.T, tl *graph.Timeline, name string) graph.TimelineSlice {
+	t.Helper()
+	for _, slice := range tl.Slices {
+		if slice.Name == name {
+			return slice
+		}
+	}
+	t.Fatalf("slice %s not found", name)
+	return graph.TimelineSlice{}
+}
+
+// newTimelineGraph fans out to two slow parallel nodes, then retries a flaky one
+func newTimelineGraph() *graph.MessageGraph {
+	sleep := func(ctx context.Context, state interface{}) (interface{}, error) {
+		time.Sleep(20 * time.Millisecond)
+		return state, nil
+	}
+
+	calls := 0
+	g := graph.NewMessageGraph()
+	g.AddNode("start", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddNode("search", sleep)
+	g.AddNode("fetch", sleep)
+	g.AddNodeWithRetry("flaky", func(ctx context.Context, state interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("transient")
+		}
+		return state, nil
+	}, &graph.RetryConfig{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffFactor: 1})
+	g.AddEdge("start", "search")
+	g.AddEdge("start", "fetch")
+	g.AddEdge("search", "flaky")
+	g.AddEdge("fetch", "flaky")
+	g.AddEdge("flaky", graph.END)
+	g.SetEntryPoint("start")
+	return g
+}
+
+func TestTracer_Timeline(t *testing.T) {
+	t.Parallel()
+
+	runnable, err := newTimelineGraph().Compile()
+	require.NoError(t, err)
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	_, err = runnable.Invoke(context.Background(), "input")
+	require.NoError(t, err)
+
+	tl, err := tracer.Timeline("")
+	require.NoError(t, err)
+
+	root := sliceByName(t, tl, "graph")
+	assert.Equal(t, 0, root.Track)
+	assert.Equal(t, tl.Duration, root.Duration)
+
+	// Overlapping nodes of a superstep sit on separate node tracks
+	search, fetch := sliceByName(t, tl, "search"), sliceByName(t, tl, "fetch")
+	assert.NotZero(t, search.Track)
+	assert.NotZero(t, fetch.Track)
+	assert.NotEqual(t, search.Track, fetch.Track)
+	assert.Equal(t, 2, search.Args["step"])
+
+	// Attempts nest in their node, the retry is an instant event
+	flaky := sliceByName(t, tl, "flaky")
+	attempt := sliceByName(t, tl, "attempt 2")
+	assert.Equal(t, flaky.Track, attempt.Track)
+	assert.Equal(t, flaky.Depth+1, attempt.Depth)
+	require.Len(t, tl.Instants, 1)
+	assert.Equal(t, "retry", tl.Instants[0].Name)
+	assert.Equal(t, flaky.Track, tl.Instants[0].Track)
+	assert.Equal(t, "transient", sliceByName(t, tl, "attempt 1").Args["error"])
+}
+
+func TestTimeline_ChromeTrace(t *testing.T) {
+	t.Parallel()
+
+	runnable, err := newTimelineGraph().Compile()
+	require.NoError(t, err)
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	_, err = runnable.Invoke(context.Background(), "input")
+	require.NoError(t, err)
+	tl, err := tracer.Timeline("")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tl.WriteChromeTrace(&buf))
+
+	var trace struct {
+		TraceEvents []map[string]interface{} `json:"traceEvents"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &trace))
+
+	threads := make(map[float64]string)
+	phases := make(map[string]string)
+	for _, event := range trace.TraceEvents {
+		name, _ := event["name"].(string)
+		if name == "thread_name" {
+			threads[event["tid"].(float64)] = event["args"].(map[string]interface{})["name"].(string)
+			continue
+		}
+		if event["ph"] != "M" {
+			phases[name] = event["ph"].(string)
+		}
+		if event["ph"] == "X" {
+			assert.Contains(t, event, "dur")
+		}
+		if event["ph"] == "i" {
+			assert.Equal(t, "t", event["s"])
+		}
+	}
+
+	assert.Equal(t, "graph", threads[0])
+	assert.Len(t, threads, len(tl.Tracks))
+	for _, name := range []string{"graph", "start", "search", "fetch", "flaky", "attempt 1", "attempt 2"} {
+		assert.Equal(t, "X", phases[name], name)
+	}
+	assert.Equal(t, "i", phases["retry"])
+}
+
+func TestTimeline_Interrupts(t *testing.T) {
+	t.Parallel()
+
+	g := graph.NewMessageGraph()
+	g.AddNode("ask", func(ctx context.Context, state interface{}) (interface{}, error) {
+		answer, err := graph.Interrupt(ctx, "approve?")
+		if err != nil {
+			return nil, err
+		}
+		return answer, nil
+	})
+	g.AddEdge("ask", graph.END)
+	g.SetEntryPoint("ask")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	_, err = runnable.Invoke(context.Background(), "input")
+	var graphInterrupt *graph.GraphInterrupt
+	require.True(t, errors.As(err, &graphInterrupt))
+
+	tl, err := tracer.Timeline("")
+	require.NoError(t, err)
+
+	// The interrupt is marked once, on the node that raised it
+	require.Len(t, tl.Instants, 1)
+	assert.Equal(t, "interrupt", tl.Instants[0].Name)
+	assert.Equal(t, sliceByName(t, tl, "ask").Track, tl.Instants[0].Track)
+	assert.Equal(t, "approve?", tl.Instants[0].Args["value"])
+
+	// Static interrupts are marked on the graph track
+	tracer.Clear()
+	_, err = runnable.InvokeWithConfig(context.Background(), "input", &graph.Config{InterruptBefore: []string{"ask"}})
+	require.True(t, errors.As(err, &graphInterrupt))
+
+	tl, err = tracer.Timeline("")
+	require.NoError(t, err)
+	require.Len(t, tl.Instants, 1)
+	assert.Equal(t, 0, tl.Instants[0].Track)
+	assert.Equal(t, "ask", tl.Instants[0].Args["node"])
+}
+
+func TestTimeline_WriteHTML(t *testing.T) {
+	t.Parallel()
+
+	runnable, err := newTimelineGraph().Compile()
+	require.NoError(t, err)
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	_, err = runnable.Invoke(context.Background(), "input")
+	require.NoError(t, err)
+	tl, err := tracer.Timeline("")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, tl.WriteHTML(&buf, "Research <run>"))
+	report := buf.String()
+
+	assert.True(t, strings.HasPrefix(report, "<!DOCTYPE html>"))
+	assert.Contains(t, report, "<title>Research &lt;run&gt;</title>")
+	assert.Contains(t, report, `class="slice node"`)
+	assert.Contains(t, report, `class="instant retry"`)
+	assert.Contains(t, report, ">search</div>")
+	assert.NotContains(t, report, "ZgotmplZ")
+	// The report is self-contained
+	assert.NotContains(t, report, "<script")
+	assert.NotContains(t, report, "http")
+}
+
+func TestTracer_TimelineErrors(t *testing.T) {
+	t.Parallel()
+
+	tracer := graph.NewTracer()
+	_, err := tracer.Timeline("")
+	assert.Error(t, err)
+
+	span := tracer.StartSpan(context.Background(), graph.TraceEventGraphStart, "graph")
+	_, err = tracer.Timeline(span.ID)
+	assert.ErrorContains(t, err, "not completed")
+
+	_, err = tracer.Timeline("missing")
+	assert.ErrorContains(t, err, "not found")
+}
@@ -2,7 +2,10 @@ package graph
 
 import (
 	"context"
+	"sync"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 // TraceEvent represents different types of events in graph execution
@@ -26,6 +29,18 @@ const (
 
 	// TraceEventEdgeTraversal indicates traversal from one node to another
 	TraceEventEdgeTraversal TraceEvent = "edge_traversal"
+
+	// TraceEventAttemptStart indicates the start of an attempt of a retried node
+	TraceEventAttemptStart TraceEvent = "attempt_start"
+
+	// TraceEventAttemptEnd indicates the end of an attempt of a retried node
+	TraceEventAttemptEnd TraceEvent = "attempt_end"
+
+	// TraceEventCheckpointStart indicates the start of a checkpoint save
+	TraceEventCheckpointStart TraceEvent = "checkpoint_start"
+
+	// TraceEventCheckpointEnd indicates the end of a checkpoint save
+	TraceEventCheckpointEnd TraceEvent = "checkpoint_end"
 )
 
 // TraceSpan represents a span of execution with timing and metadata
@@ -81,8 +96,10 @@ func (f TraceHookFunc) OnEvent(ctx context.Context, span *TraceSpan) {
 	f(ctx, span)
 }
 
-// Tracer manages trace collection and hooks
+// Tracer manages trace collection and hooks. It is safe for concurrent use,
+// parallel nodes start and end their spans from their own goroutines.
 type Tracer struct {
+	mu    sync.RWMutex
 	hooks []TraceHook
 	spans map[string]*TraceSpan
 }
@@ -97,17 +114,45 @@ func NewTracer() *Tracer {
 
 // AddHook registers a new trace hook
 func (t *Tracer) AddHook(hook TraceHook) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.hooks = append(t.hooks, hook)
 }
 
+// record stores a span and returns the hooks to notify
+func (t *Tracer) record(span *TraceSpan) []TraceHook {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if span != nil {
+		t.spans[span.ID] = span
+	}
+	return append([]TraceHook(nil), t.hooks...)
+}
+
+// notify calls the hooks outside the lock, so hooks may use the tracer
+func notify(ctx context.Context, hooks []TraceHook, span *TraceSpan) {
+	for _, hook := range hooks {
+		hook.OnEvent(ctx, span)
+	}
+}
+
 // StartSpan creates a new trace span
 func (t *Tracer) StartSpan(ctx context.Context, event TraceEvent, nodeName string) *TraceSpan {
+	return t.StartSpanWithMetadata(ctx, event, nodeName, nil)
+}
+
+// StartSpanWithMetadata creates a new trace span whose metadata is already
+// set when the hooks see it start
+func (t *Tracer) StartSpanWithMetadata(ctx context.Context, event TraceEvent, nodeName string, metadata map[string]interface{}) *TraceSpan {
 	span := &TraceSpan{
 		ID:        generateSpanID(),
 		Event:     event,
 		NodeName:  nodeName,
 		StartTime: time.Now(),
-		Metadata:  make(map[string]interface{}),
+		Metadata:  make(map[string]interface{}, len(metadata)),
+	}
+	for key, value := range metadata {
+		span.Metadata[key] = value
 	}
 
 	// Extract parent ID from context if available
@@ -115,12 +160,7 @@ func (t *Tracer) StartSpan(ctx context.Context, event TraceEvent, nodeName strin
 		span.ParentID = parentSpan.ID
 	}
 
-	t.spans[span.ID] = span
-
-	// Notify hooks
-	for _, hook := range t.hooks {
-		hook.OnEvent(ctx, span)
-	}
+	notify(ctx, t.record(span), span)
 
 	return span
 }
@@ -139,12 +179,13 @@ func (t *Tracer) EndSpan(ctx context.Context, span *TraceSpan, state interface{}
 		span.Event = TraceEventNodeEnd
 	} else if span.Event == TraceEventGraphStart {
 		span.Event = TraceEventGraphEnd
+	} else if span.Event == TraceEventAttemptStart {
+		span.Event = TraceEventAttemptEnd
+	} else if span.Event == TraceEventCheckpointStart {
+		span.Event = TraceEventCheckpointEnd
 	}
 
-	// Notify hooks
-	for _, hook := range t.hooks {
-		hook.OnEvent(ctx, span)
-	}
+	notify(ctx, t.record(nil), span)
 }
 
 // TraceEdgeTraversal records an edge traversal event
@@ -165,28 +206,34 @@ func (t *Tracer) TraceEdgeTraversal(ctx context.Context, fromNode, toNode string
 		span.ParentID = parentSpan.ID
 	}
 
-	t.spans[span.ID] = span
-
-	// Notify hooks
-	for _, hook := range t.hooks {
-		hook.OnEvent(ctx, span)
-	}
+	notify(ctx, t.record(span), span)
 }
 
-// GetSpans returns all collected spans
+// GetSpans returns a copy of the collected spans
 func (t *Tracer) GetSpans() map[string]*TraceSpan {
-	return t.spans
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	spans := make(map[string]*TraceSpan, len(t.spans))
+	for id, span := range t.spans {
+		spans[id] = span
+	}
+	return spans
 }
 
 // Clear removes all collected spans
 func (t *Tracer) Clear() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
 	t.spans = make(map[string]*TraceSpan)
 }
 
 // Context keys for span storage
 type contextKey string
 
-const spanContextKey contextKey = "langgraph_span"
+const (
+	spanContextKey   contextKey = "langgraph_span"
+	tracerContextKey contextKey = "langgraph_tracer"
+)
 
 // ContextWithSpan returns a new context with the span stored
 func ContextWithSpan(ctx context.Context, span *TraceSpan) context.Context {
@@ -201,9 +248,38 @@ func SpanFromContext(ctx context.Context) *TraceSpan {
 	return nil
 }
 
+// ContextWithTracer returns a new context with the tracer stored. Graphs
+// invoked with this context, such as subgraphs, are traced by it unless they
+// have a tracer of their own.
+func ContextWithTracer(ctx context.Context, tracer *Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey, tracer)
+}
+
+// TracerFromContext extracts a tracer from context
+func TracerFromContext(ctx context.Context) *Tracer {
+	if tracer, ok := ctx.Value(tracerContextKey).(*Tracer); ok {
+		return tracer
+	}
+	return nil
+}
+
+// traceAttempt runs one attempt of a retried node in a span nested under
+// the span of the node, if the context has a tracer
+func traceAttempt(ctx context.Context, nodeName string, attempt int, fn func(context.Context) (interface{}, error)) (interface{}, error) {
+	tracer := TracerFromContext(ctx)
+	if tracer == nil {
+		return fn(ctx)
+	}
+
+	span := tracer.StartSpanWithMetadata(ctx, TraceEventAttemptStart, nodeName, map[string]interface{}{"attempt": attempt})
+	result, err := fn(ContextWithSpan(ctx, span))
+	tracer.EndSpan(ctx, span, result, err)
+	return result, err
+}
+
 // generateSpanID creates a unique span identifier
 func generateSpanID() string {
-	return time.Now().Format("20060102150405.000000")
+	return uuid.New().String()
 }
 
 // TracedRunnable wraps a Runnable with tracing capabilities
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/smallnest/langgraphgo/graph"
 )
@@ -323,6 +325,188 @@ func TestTracer_SpanHierarchy(t *testing.T) {
 	}
 }
 
+func TestTracer_ConcurrentSpans(t *testing.T) {
+	t.Parallel()
+
+	tracer := graph.NewTracer()
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			span := tracer.StartSpan(ctx, graph.TraceEventNodeStart, "node")
+			tracer.EndSpan(ctx, span, nil, nil)
+		}()
+	}
+	wg.Wait()
+
+	// Span IDs stay unique when spans start at the same time
+	if len(tracer.GetSpans()) != 50 {
+		t.Errorf("Expected 50 spans, got %d", len(tracer.GetSpans()))
+	}
+}
+
+func TestStateRunnable_TracerSpans(t *testing.T) {
+	t.Parallel()
+
+	g := graph.NewStateGraph()
+	g.AddNode("ok", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	g.AddNode("fail", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return nil, errors.New("boom")
+	})
+	g.AddEdge("ok", "fail")
+	g.AddEdge("fail", graph.END)
+	g.SetEntryPoint("ok")
+
+	runnable, err := g.Compile()
+	if err != nil {
+		t.Fatalf("Failed to compile graph: %v", err)
+	}
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	if _, err := runnable.Invoke(context.Background(), map[string]interface{}{}); err == nil {
+		t.Fatal("Expected error from failing node")
+	}
+
+	var graphSpan *graph.TraceSpan
+	nodeSpans := make(map[string]*graph.TraceSpan)
+	for _, span := range tracer.GetSpans() {
+		switch span.Event {
+		case graph.TraceEventGraphEnd:
+			graphSpan = span
+		case graph.TraceEventNodeEnd, graph.TraceEventNodeError:
+			nodeSpans[span.NodeName] = span
+		}
+	}
+
+	// The run span ends with the error of the run
+	if graphSpan == nil || graphSpan.Error == nil {
+		t.Fatalf("Expected an ended graph span with an error, got %+v", graphSpan)
+	}
+	if len(nodeSpans) != 2 {
+		t.Fatalf("Expected one span per node, got %d", len(nodeSpans))
+	}
+	for name, span := range nodeSpans {
+		if span.ParentID != graphSpan.ID {
+			t.Errorf("Expected node %s to be a child of the graph span", name)
+		}
+	}
+	if nodeSpans["ok"].Event != graph.TraceEventNodeEnd || nodeSpans["ok"].Metadata["step"] != 1 {
+		t.Errorf("Unexpected span for node ok: %+v", nodeSpans["ok"])
+	}
+	if nodeSpans["fail"].Event != graph.TraceEventNodeError || nodeSpans["fail"].Metadata["step"] != 2 {
+		t.Errorf("Unexpected span for node fail: %+v", nodeSpans["fail"])
+	}
+}
+
+func TestRunnable_TracerNestsSubgraphsAndAttempts(t *testing.T) {
+	t.Parallel()
+
+	inner := graph.NewMessageGraph()
+	inner.AddNode("inner", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	inner.AddEdge("inner", graph.END)
+	inner.SetEntryPoint("inner")
+
+	calls := 0
+	g := graph.NewMessageGraph()
+	g.AddNodeWithRetry("flaky", func(ctx context.Context, state interface{}) (interface{}, error) {
+		calls++
+		if calls == 1 {
+			return nil, errors.New("transient")
+		}
+		return state, nil
+	}, &graph.RetryConfig{MaxAttempts: 2, InitialDelay: time.Millisecond, MaxDelay: time.Millisecond, BackoffFactor: 1})
+	if err := g.AddSubgraph("sub", inner); err != nil {
+		t.Fatalf("Failed to add subgraph: %v", err)
+	}
+	g.AddEdge("flaky", "sub")
+	g.AddEdge("sub", graph.END)
+	g.SetEntryPoint("flaky")
+
+	runnable, err := g.Compile()
+	if err != nil {
+		t.Fatalf("Failed to compile graph: %v", err)
+	}
+	tracer := graph.NewTracer()
+	runnable.SetTracer(tracer)
+
+	if _, err := runnable.Invoke(context.Background(), "input"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	spans := tracer.GetSpans()
+	var root *graph.TraceSpan
+	nodes := make(map[string]*graph.TraceSpan)
+	var attempts, edges []*graph.TraceSpan
+	var subgraphRun *graph.TraceSpan
+	for _, span := range spans {
+		switch span.Event {
+		case graph.TraceEventGraphEnd:
+			if span.ParentID == "" {
+				root = span
+			} else {
+				subgraphRun = span
+			}
+		case graph.TraceEventNodeEnd:
+			nodes[span.NodeName] = span
+		case graph.TraceEventAttemptEnd:
+			attempts = append(attempts, span)
+		case graph.TraceEventEdgeTraversal:
+			edges = append(edges, span)
+		}
+	}
+
+	if root == nil || subgraphRun == nil {
+		t.Fatal("Expected a run span for the graph and the subgraph")
+	}
+	if nodes["flaky"].ParentID != root.ID || nodes["sub"].ParentID != root.ID {
+		t.Error("Expected node spans to be children of the run span")
+	}
+
+	// Both attempts of the retried node are nested under its span
+	if len(attempts) != 2 {
+		t.Fatalf("Expected 2 attempt spans, got %d", len(attempts))
+	}
+	for _, attempt := range attempts {
+		if attempt.ParentID != nodes["flaky"].ID {
+			t.Errorf("Expected attempt %v to be a child of the node span", attempt.Metadata["attempt"])
+		}
+	}
+
+	// The subgraph run is nested under its node span
+	if subgraphRun.ParentID != nodes["sub"].ID {
+		t.Error("Expected the subgraph run span to be a child of the subgraph node span")
+	}
+	if nodes["inner"].ParentID != subgraphRun.ID {
+		t.Error("Expected the inner node span to be a child of the subgraph run span")
+	}
+
+	traversals := make(map[string]string)
+	for _, edge := range edges {
+		traversals[edge.FromNode+"->"+edge.ToNode] = edge.ParentID
+	}
+	expected := map[string]string{
+		"flaky->sub": root.ID,
+		"sub->END":   root.ID,
+		"inner->END": subgraphRun.ID,
+	}
+	if len(traversals) != len(expected) {
+		t.Errorf("Expected edge traversals %v, got %v", expected, traversals)
+	}
+	for edge, parentID := range expected {
+		if traversals[edge] != parentID {
+			t.Errorf("Expected traversal %s under span %s, got %q", edge, parentID, traversals[edge])
+		}
+	}
+}
+
 // Benchmark tests
 func BenchmarkTracer_StartEndSpan(b *testing.B) {
 	tracer := graph.NewTracer()
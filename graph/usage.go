@@ -0,0 +1,272 @@
+package graph
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// TokenUsage counts the tokens of LLM calls
+type TokenUsage struct {
+	PromptTokens     int `json:"prompt_tokens"`
+	CompletionTokens int `json:"completion_tokens"`
+	TotalTokens      int `json:"total_tokens"`
+}
+
+// Add returns the sum of two usages
+func (u TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		PromptTokens:     u.PromptTokens + other.PromptTokens,
+		CompletionTokens: u.CompletionTokens + other.CompletionTokens,
+		TotalTokens:      u.TotalTokens + other.TotalTokens,
+	}
+}
+
+// IsZero reports whether no tokens were counted
+func (u TokenUsage) IsZero() bool {
+	return u == TokenUsage{}
+}
+
+// generationInfoKeys are the prompt and completion token keys used by LLM
+// providers in the generation info of a response
+var generationInfoKeys = [][2]string{
+	{"PromptTokens", "CompletionTokens"},   // OpenAI
+	{"InputTokens", "OutputTokens"},        // Anthropic
+	{"input_tokens", "output_tokens"},      // Google AI
+	{"prompt_tokens", "completion_tokens"}, // OpenAI compatible APIs
+}
+
+// UsageFromGenerationInfo reads the token usage from the generation info of
+// an LLM response, and reports whether it had any. The total is computed
+// when the provider doesn't report it.
+func UsageFromGenerationInfo(info map[string]interface{}) (TokenUsage, bool) {
+	var usage TokenUsage
+	found := false
+	for _, keys := range generationInfoKeys {
+		prompt, okPrompt := tokenCount(info[keys[0]])
+		completion, okCompletion := tokenCount(info[keys[1]])
+		if okPrompt || okCompletion {
+			usage.PromptTokens, usage.CompletionTokens = prompt, completion
+			found = true
+			break
+		}
+	}
+	if !found {
+		return TokenUsage{}, false
+	}
+
+	usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	for _, key := range []string{"TotalTokens", "total_tokens"} {
+		if total, ok := tokenCount(info[key]); ok && total > 0 {
+			usage.TotalTokens = total
+		}
+	}
+	return usage, true
+}
+
+// tokenCount converts a token count of any numeric type
+func tokenCount(value interface{}) (int, bool) {
+	switch v := value.(type) {
+	case int:
+		return v, true
+	case int32:
+		return int(v), true
+	case int64:
+		return int(v), true
+	case float64:
+		return int(v), true
+	default:
+		return 0, false
+	}
+}
+
+// ModelPrice is the price of a model in currency units per million tokens
+type ModelPrice struct {
+	PromptPerMillion     float64 `json:"prompt_per_million"`
+	CompletionPerMillion float64 `json:"completion_per_million"`
+}
+
+// PriceTable holds the prices of models by name. A model without an exact
+// entry uses the longest entry its name starts with, so "gpt-4o" prices
+// "gpt-4o-2024-08-06".
+type PriceTable map[string]ModelPrice
+
+// Price returns the price of a model
+func (p PriceTable) Price(model string) (ModelPrice, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	best := ""
+	for name := range p {
+		if strings.HasPrefix(model, name) && len(name) > len(best) {
+			best = name
+		}
+	}
+	if best == "" {
+		return ModelPrice{}, false
+	}
+	return p[best], true
+}
+
+// Cost returns the estimated cost of the usage of a model, and reports
+// whether the model has a price
+func (p PriceTable) Cost(model string, usage TokenUsage) (float64, bool) {
+	price, ok := p.Price(model)
+	if !ok {
+		return 0, false
+	}
+	return (float64(usage.PromptTokens)*price.PromptPerMillion +
+		float64(usage.CompletionTokens)*price.CompletionPerMillion) / 1e6, true
+}
+
+// UsageRecord is the usage of one LLM call
+type UsageRecord struct {
+	// Node is the node that made the call, empty outside a node
+	Node string `json:"node,omitempty"`
+
+	// Model is the name of the called model
+	Model string `json:"model"`
+
+	Usage TokenUsage `json:"usage"`
+
+	// Cost is the estimated cost, zero if the model has no price
+	Cost float64 `json:"cost"`
+}
+
+// UsageSummary aggregates the usage of the LLM calls of a run
+type UsageSummary struct {
+	Total   TokenUsage            `json:"total"`
+	ByNode  map[string]TokenUsage `json:"by_node"`
+	ByModel map[string]TokenUsage `json:"by_model"`
+
+	// Cost is the estimated cost of the priced models
+	Cost        float64            `json:"cost"`
+	CostByNode  map[string]float64 `json:"cost_by_node"`
+	CostByModel map[string]float64 `json:"cost_by_model"`
+
+	// UnpricedModels lists the models missing from the price table
+	UnpricedModels []string `json:"unpriced_models,omitempty"`
+
+	// Calls is the number of recorded LLM calls
+	Calls int `json:"calls"`
+}
+
+// UsageTracker collects the token usage of the LLM calls of a run, per node
+// and per model. It is carried in the run context, so nodes, subgraphs and
+// the prebuilt agents record into it with RecordUsage.
+type UsageTracker struct {
+	prices PriceTable
+
+	mu       sync.Mutex
+	summary  UsageSummary
+	unpriced map[string]bool
+}
+
+// NewUsageTracker creates a usage tracker pricing calls with prices, which may be nil
+func NewUsageTracker(prices PriceTable) *UsageTracker {
+	return &UsageTracker{
+		prices: prices,
+		summary: UsageSummary{
+			ByNode:      make(map[string]TokenUsage),
+			ByModel:     make(map[string]TokenUsage),
+			CostByNode:  make(map[string]float64),
+			CostByModel: make(map[string]float64),
+		},
+		unpriced: make(map[string]bool),
+	}
+}
+
+// Add records the usage of a call, pricing it, and returns the priced record
+func (t *UsageTracker) Add(record UsageRecord) UsageRecord {
+	cost, priced := t.prices.Cost(record.Model, record.Usage)
+	record.Cost = cost
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := &t.summary
+	s.Calls++
+	s.Total = s.Total.Add(record.Usage)
+	s.ByNode[record.Node] = s.ByNode[record.Node].Add(record.Usage)
+	s.ByModel[record.Model] = s.ByModel[record.Model].Add(record.Usage)
+	if priced {
+		s.Cost += cost
+		s.CostByNode[record.Node] += cost
+		s.CostByModel[record.Model] += cost
+	} else {
+		t.unpriced[record.Model] = true
+	}
+	return record
+}
+
+// Summary returns a copy of the aggregated usage
+func (t *UsageTracker) Summary() UsageSummary {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s := t.summary
+	s.ByNode = copyMap(s.ByNode)
+	s.ByModel = copyMap(s.ByModel)
+	s.CostByNode = copyMap(s.CostByNode)
+	s.CostByModel = copyMap(s.CostByModel)
+	s.UnpricedModels = make([]string, 0, len(t.unpriced))
+	for model := range t.unpriced {
+		s.UnpricedModels = append(s.UnpricedModels, model)
+	}
+	sort.Strings(s.UnpricedModels)
+	return s
+}
+
+// copyMap returns a shallow copy of a map
+func copyMap[V any](m map[string]V) map[string]V {
+	c := make(map[string]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// UsageCallbackHandler is implemented by callback handlers that receive the
+// usage of every LLM call recorded during a run
+type UsageCallbackHandler interface {
+	OnUsage(ctx context.Context, record UsageRecord, runID string)
+}
+
+type usageTrackerKey struct{}
+
+// WithUsageTracker adds a usage tracker to the context. Runs invoked with
+// the context record the usage of their LLM calls into it.
+func WithUsageTracker(ctx context.Context, tracker *UsageTracker) context.Context {
+	return context.WithValue(ctx, usageTrackerKey{}, tracker)
+}
+
+// GetUsageTracker returns the usage tracker of the context, or nil
+func GetUsageTracker(ctx context.Context) *UsageTracker {
+	tracker, _ := ctx.Value(usageTrackerKey{}).(*UsageTracker)
+	return tracker
+}
+
+// RecordUsage records the usage of an LLM call made by the node running in
+// ctx. It is added to the usage tracker of the context, if any, counted
+// against the budgets of the run, and passed to the callbacks of the run
+// implementing UsageCallbackHandler.
+func RecordUsage(ctx context.Context, model string, usage TokenUsage) UsageRecord {
+	record := UsageRecord{Node: GetNodeName(ctx), Model: model, Usage: usage}
+	if tracker := GetUsageTracker(ctx); tracker != nil {
+		record = tracker.Add(record)
+	}
+	if meter := budgetMeterFromContext(ctx); meter != nil {
+		meter.addUsage(record)
+	}
+
+	if config := GetConfig(ctx); config != nil {
+		for _, cb := range config.Callbacks {
+			if ucb, ok := cb.(UsageCallbackHandler); ok {
+				ucb.OnUsage(ctx, record, GetRunID(ctx))
+			}
+		}
+	}
+	return record
+}
@@ -0,0 +1,173 @@
+package graph_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageFromGenerationInfo(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		info map[string]interface{}
+		want graph.TokenUsage
+		ok   bool
+	}{
+		{
+			name: "openai",
+			info: map[string]interface{}{"PromptTokens": 10, "CompletionTokens": 5, "TotalTokens": 15},
+			want: graph.TokenUsage{PromptTokens: 10, CompletionTokens: 5, TotalTokens: 15},
+			ok:   true,
+		},
+		{
+			name: "anthropic without total",
+			info: map[string]interface{}{"InputTokens": 7, "OutputTokens": 3},
+			want: graph.TokenUsage{PromptTokens: 7, CompletionTokens: 3, TotalTokens: 10},
+			ok:   true,
+		},
+		{
+			name: "googleai",
+			info: map[string]interface{}{"input_tokens": int32(4), "output_tokens": int32(2), "total_tokens": int32(6)},
+			want: graph.TokenUsage{PromptTokens: 4, CompletionTokens: 2, TotalTokens: 6},
+			ok:   true,
+		},
+		{
+			name: "no usage",
+			info: map[string]interface{}{"StopReason": "stop"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usage, ok := graph.UsageFromGenerationInfo(tt.info)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.want, usage)
+		})
+	}
+}
+
+func TestPriceTable_Cost(t *testing.T) {
+	t.Parallel()
+
+	prices := graph.PriceTable{
+		"gpt-4o":      {PromptPerMillion: 2.5, CompletionPerMillion: 10},
+		"gpt-4o-mini": {PromptPerMillion: 0.15, CompletionPerMillion: 0.6},
+	}
+	usage := graph.TokenUsage{PromptTokens: 1_000_000, CompletionTokens: 500_000}
+
+	cost, ok := prices.Cost("gpt-4o", usage)
+	assert.True(t, ok)
+	assert.InDelta(t, 7.5, cost, 1e-9)
+
+	// Versioned names use the longest matching prefix
+	cost, ok = prices.Cost("gpt-4o-mini-2024-07-18", usage)
+	assert.True(t, ok)
+	assert.InDelta(t, 0.45, cost, 1e-9)
+
+	_, ok = prices.Cost("claude", usage)
+	assert.False(t, ok)
+}
+
+// usageRecorder collects the usage records passed to callbacks
+type usageRecorder struct {
+	graph.NoOpCallbackHandler
+	mu      sync.Mutex
+	records []graph.UsageRecord
+	runIDs  []string
+}
+
+func (r *usageRecorder) OnUsage(ctx context.Context, record graph.UsageRecord, runID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.records = append(r.records, record)
+	r.runIDs = append(r.runIDs, runID)
+}
+
+func TestUsageTracker_Run(t *testing.T) {
+	t.Parallel()
+
+	g := graph.NewMessageGraph()
+	g.AddNode("plan", func(ctx context.Context, state interface{}) (interface{}, error) {
+		graph.RecordUsage(ctx, "gpt-4o", graph.TokenUsage{PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120})
+		return state, nil
+	})
+	g.AddNode("act", func(ctx context.Context, state interface{}) (interface{}, error) {
+		graph.RecordUsage(ctx, "gpt-4o", graph.TokenUsage{PromptTokens: 50, CompletionTokens: 10, TotalTokens: 60})
+		graph.RecordUsage(ctx, "local", graph.TokenUsage{PromptTokens: 5, CompletionTokens: 5, TotalTokens: 10})
+		return state, nil
+	})
+	g.AddEdge("plan", "act")
+	g.AddEdge("act", graph.END)
+	g.SetEntryPoint("plan")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	tracker := graph.NewUsageTracker(graph.PriceTable{
+		"gpt-4o": {PromptPerMillion: 1_000_000, CompletionPerMillion: 2_000_000},
+	})
+	recorder := &usageRecorder{}
+	ctx := graph.WithUsageTracker(context.Background(), tracker)
+	config := &graph.Config{Callbacks: []graph.CallbackHandler{recorder}}
+	_, err = runnable.InvokeWithConfig(ctx, "input", config)
+	require.NoError(t, err)
+
+	summary := tracker.Summary()
+	assert.Equal(t, 3, summary.Calls)
+	assert.Equal(t, graph.TokenUsage{PromptTokens: 155, CompletionTokens: 35, TotalTokens: 190}, summary.Total)
+	assert.Equal(t, map[string]graph.TokenUsage{
+		"plan": {PromptTokens: 100, CompletionTokens: 20, TotalTokens: 120},
+		"act":  {PromptTokens: 55, CompletionTokens: 15, TotalTokens: 70},
+	}, summary.ByNode)
+	assert.Equal(t, graph.TokenUsage{PromptTokens: 150, CompletionTokens: 30, TotalTokens: 180}, summary.ByModel["gpt-4o"])
+	assert.InDelta(t, 210, summary.Cost, 1e-9)
+	assert.InDelta(t, 140, summary.CostByNode["plan"], 1e-9)
+	assert.Equal(t, []string{"local"}, summary.UnpricedModels)
+
+	require.Len(t, recorder.records, 3)
+	assert.Equal(t, "plan", recorder.records[0].Node)
+	assert.InDelta(t, 140, recorder.records[0].Cost, 1e-9)
+	assert.NotEmpty(t, recorder.runIDs[0])
+	assert.Equal(t, recorder.runIDs[0], recorder.runIDs[2])
+}
+
+func TestStreamResult_Usage(t *testing.T) {
+	t.Parallel()
+
+	g := graph.NewStreamingMessageGraphWithConfig(graph.StreamConfig{
+		BufferSize: 100,
+		Mode:       graph.StreamModeMessages,
+	})
+	g.AddNode("agent", func(ctx context.Context, state interface{}) (interface{}, error) {
+		graph.RecordUsage(ctx, "gpt-4o", graph.TokenUsage{PromptTokens: 3, CompletionTokens: 2, TotalTokens: 5})
+		return state, nil
+	})
+	g.AddEdge("agent", graph.END)
+	g.SetEntryPoint("agent")
+
+	runnable, err := g.CompileStreaming()
+	require.NoError(t, err)
+
+	result := runnable.Stream(context.Background(), "input")
+	var events []graph.StreamEvent
+	for event := range result.Events {
+		events = append(events, event)
+	}
+	<-result.Done
+
+	require.Len(t, events, 1)
+	assert.Equal(t, graph.EventUsage, events[0].Event)
+	assert.Equal(t, "agent", events[0].NodeName)
+	record, ok := events[0].State.(graph.UsageRecord)
+	require.True(t, ok)
+	assert.Equal(t, 5, record.Usage.TotalTokens)
+
+	require.NotNil(t, result.Usage)
+	assert.Equal(t, 5, result.Usage.Summary().Total.TotalTokens)
+}
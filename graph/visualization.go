@@ -1,25 +1,68 @@
 package graph
 
 import (
+	"context"
 	"fmt"
 	"sort"
 	"strings"
 )
 
+// Drawable is implemented by the graphs an Exporter can draw: message,
+// state, listenable, checkpointable and streaming graphs
+type Drawable interface {
+	topology() *topology
+}
+
+// topology is the structure of a graph, as drawn by the Exporter
+type topology struct {
+	nodes            map[string]Node
+	edges            []Edge
+	conditionalEdges map[string]func(ctx context.Context, state interface{}) string
+	entryPoint       string
+	structure        *graphStructure
+}
+
+// conditionalSources returns the nodes with a conditional edge, sorted
+func (t *topology) conditionalSources() []string {
+	sources := make([]string, 0, len(t.conditionalEdges))
+	for from := range t.conditionalEdges {
+		sources = append(sources, from)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+func (g *MessageGraph) topology() *topology {
+	return &topology{nodes: g.nodes, edges: g.edges, conditionalEdges: g.conditionalEdges, entryPoint: g.entryPoint, structure: &g.structure}
+}
+
+func (g *StateGraph) topology() *topology {
+	return &topology{nodes: g.nodes, edges: g.edges, conditionalEdges: g.conditionalEdges, entryPoint: g.entryPoint, structure: &g.structure}
+}
+
 // Exporter provides methods to export graphs in different formats
 type Exporter struct {
-	graph *MessageGraph
+	graph *topology
 }
 
 // NewExporter creates a new graph exporter for the given graph
-func NewExporter(graph *MessageGraph) *Exporter {
-	return &Exporter{graph: graph}
+func NewExporter(graph Drawable) *Exporter {
+	return &Exporter{graph: graph.topology()}
 }
 
 // MermaidOptions defines configuration for Mermaid diagram generation
 type MermaidOptions struct {
 	// Direction of the flowchart (e.g., "TD", "LR")
 	Direction string
+
+	// XRay expands subgraphs, node groups and conditional branches, if set
+	XRay *XRayOptions
+}
+
+// DOTOptions defines configuration for DOT diagram generation
+type DOTOptions struct {
+	// XRay expands subgraphs, node groups and conditional branches, if set
+	XRay *XRayOptions
 }
 
 // DrawMermaid generates a Mermaid diagram representation of the graph
@@ -38,6 +81,9 @@ func (ge *Exporter) DrawMermaidWithOptions(opts MermaidOptions) string {
 	if direction == "" {
 		direction = "TD"
 	}
+	if opts.XRay != nil {
+		return ge.drawMermaidXRay(direction, *opts.XRay)
+	}
 	sb.WriteString(fmt.Sprintf("flowchart %s\n", direction))
 
 	// Add entry point styling
@@ -82,7 +128,7 @@ func (ge *Exporter) DrawMermaidWithOptions(opts MermaidOptions) string {
 	}
 
 	// Add conditional edges
-	for from := range ge.graph.conditionalEdges {
+	for _, from := range ge.graph.conditionalSources() {
 		sb.WriteString(fmt.Sprintf("    %s -.-> %s_condition((?))\n", from, from))
 		sb.WriteString(fmt.Sprintf("    style %s_condition fill:#FFFFE0,stroke:#333,stroke-dasharray: 5 5\n", from))
 	}
@@ -97,6 +143,15 @@ func (ge *Exporter) DrawMermaidWithOptions(opts MermaidOptions) string {
 
 // DrawDOT generates a DOT (Graphviz) representation of the graph
 func (ge *Exporter) DrawDOT() string {
+	return ge.DrawDOTWithOptions(DOTOptions{})
+}
+
+// DrawDOTWithOptions generates a DOT representation with custom options
+func (ge *Exporter) DrawDOTWithOptions(opts DOTOptions) string {
+	if opts.XRay != nil {
+		return ge.drawDOTXRay(*opts.XRay)
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString("digraph G {\n")
@@ -133,7 +188,7 @@ func (ge *Exporter) DrawDOT() string {
 	}
 
 	// Add conditional edges
-	for from := range ge.graph.conditionalEdges {
+	for _, from := range ge.graph.conditionalSources() {
 		sb.WriteString(fmt.Sprintf("    %s -> %s_condition [style=dashed, label=\"?\"];\n", from, from))
 		sb.WriteString(fmt.Sprintf("    %s_condition [label=\"?\", shape=diamond, style=filled, fillcolor=lightyellow];\n", from))
 	}
@@ -223,3 +278,13 @@ func (ge *Exporter) drawASCIINode(nodeName string, prefix string, isLast bool, v
 func (r *Runnable) GetGraph() *Exporter {
 	return NewExporter(r.graph)
 }
+
+// GetGraph returns a Exporter for the compiled graph's visualization
+func (r *StateRunnable) GetGraph() *Exporter {
+	return NewExporter(r.graph)
+}
+
+// GetGraph returns a Exporter for the compiled graph's visualization
+func (cr *CheckpointableRunnable) GetGraph() *Exporter {
+	return cr.runnable.GetGraph()
+}
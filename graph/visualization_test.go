@@ -48,3 +48,81 @@ func TestVisualization(t *testing.T) {
 	// C is not reachable via static edges from B, so it won't be shown under B.
 	// This is expected behavior for static visualization of dynamic graphs.
 }
+
+func TestVisualization_StateGraph(t *testing.T) {
+	noop := func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil }
+
+	g := NewStateGraph()
+	g.AddNode("agent", noop)
+	g.AddNode("tools", noop)
+	g.SetEntryPoint("agent")
+	g.AddConditionalEdge("agent", func(ctx context.Context, state interface{}) string { return END })
+	g.AddEdge("tools", "agent")
+
+	runnable, err := g.Compile()
+	assert.NoError(t, err)
+
+	for _, exporter := range []*Exporter{NewExporter(g), runnable.GetGraph()} {
+		mermaid := exporter.DrawMermaid()
+		assert.Contains(t, mermaid, "START --> agent")
+		assert.Contains(t, mermaid, "tools --> agent")
+		assert.Contains(t, mermaid, "agent -.-> agent_condition((?))")
+
+		assert.Contains(t, exporter.DrawDOT(), "tools -> agent")
+		assert.Contains(t, exporter.DrawASCII(), "agent")
+	}
+}
+
+func TestVisualization_WrappedGraphs(t *testing.T) {
+	build := func(g *MessageGraph) {
+		g.AddNode("A", func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil })
+		g.AddNode("B", func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil })
+		g.SetEntryPoint("A")
+		g.AddEdge("A", "B")
+		g.AddEdge("B", END)
+	}
+
+	listenable := NewListenableMessageGraph()
+	build(listenable.MessageGraph)
+	listenableRunnable, err := listenable.CompileListenable()
+	assert.NoError(t, err)
+
+	checkpointable := NewCheckpointableMessageGraph()
+	build(checkpointable.MessageGraph)
+	checkpointableRunnable, err := checkpointable.CompileCheckpointable()
+	assert.NoError(t, err)
+
+	streaming := NewStreamingMessageGraph()
+	build(streaming.MessageGraph)
+	streamingRunnable, err := streaming.CompileStreaming()
+	assert.NoError(t, err)
+
+	exporters := map[string]*Exporter{
+		"listenable":              NewExporter(listenable),
+		"listenable runnable":     listenableRunnable.GetGraph(),
+		"checkpointable":          NewExporter(checkpointable),
+		"checkpointable runnable": checkpointableRunnable.GetGraph(),
+		"streaming":               NewExporter(streaming),
+		"streaming runnable":      streamingRunnable.GetGraph(),
+	}
+	for name, exporter := range exporters {
+		assert.Contains(t, exporter.DrawMermaid(), "A --> B", name)
+		assert.Contains(t, exporter.DrawMermaid(), "B --> END", name)
+	}
+}
+
+func TestVisualization_ConditionalEdgesOrder(t *testing.T) {
+	g := NewMessageGraph()
+	for _, name := range []string{"c", "a", "b"} {
+		g.AddNode(name, func(ctx context.Context, state interface{}) (interface{}, error) { return state, nil })
+		g.AddConditionalEdge(name, func(ctx context.Context, state interface{}) string { return END })
+	}
+	g.SetEntryPoint("a")
+
+	// Conditional edges are drawn sorted, so the output is stable
+	mermaid := NewExporter(g).DrawMermaid()
+	assert.Regexp(t, `(?s)a -\.-> a_condition.*b -\.-> b_condition.*c -\.-> c_condition`, mermaid)
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, mermaid, NewExporter(g).DrawMermaid())
+	}
+}
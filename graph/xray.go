@@ -0,0 +1,409 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// XRayOptions configures the expanded drawing of a graph. Subgraphs are
+// drawn as nested Mermaid subgraphs or DOT clusters, parallel and map-reduce
+// groups show their members, conditional edges with known branches are drawn
+// to their destinations, and interrupt nodes are marked.
+type XRayOptions struct {
+	// Depth is the number of levels of subgraphs and node groups to expand,
+	// 0 expands all of them
+	Depth int
+
+	// InterruptBefore marks the nodes a run stops before, as in Config
+	InterruptBefore []string
+
+	// InterruptAfter marks the nodes a run stops after, as in Config
+	InterruptAfter []string
+}
+
+// Kinds of node groups
+const (
+	nodeGroupParallel  = "parallel"
+	nodeGroupMapReduce = "map-reduce"
+)
+
+// nodeGroup is a node running a group of member functions
+type nodeGroup struct {
+	kind    string
+	members []string
+}
+
+// graphStructure records how the nodes and conditional edges of a graph were
+// built, for the xray drawing
+type graphStructure struct {
+	subgraphs map[string]Drawable
+	groups    map[string]nodeGroup
+	branches  map[string]map[string]string
+}
+
+// setSubgraph records that the node runs a subgraph
+func (s *graphStructure) setSubgraph(name string, subgraph Drawable) {
+	if s.subgraphs == nil {
+		s.subgraphs = make(map[string]Drawable)
+	}
+	s.subgraphs[name] = subgraph
+}
+
+// setGroup records that the node runs a group of members
+func (s *graphStructure) setGroup(name, kind string, members []string) {
+	if s.groups == nil {
+		s.groups = make(map[string]nodeGroup)
+	}
+	sorted := append([]string(nil), members...)
+	sort.Strings(sorted)
+	s.groups[name] = nodeGroup{kind: kind, members: sorted}
+}
+
+// setBranches records the known branches of the conditional edge of a node
+func (s *graphStructure) setBranches(from string, branches map[string]string) {
+	if s.branches == nil {
+		s.branches = make(map[string]map[string]string)
+	}
+	s.branches[from] = copyMap(branches)
+}
+
+// forgetNode drops what was recorded about a node being replaced
+func (s *graphStructure) forgetNode(name string) {
+	delete(s.subgraphs, name)
+	delete(s.groups, name)
+}
+
+// forgetBranches drops the branches of a conditional edge being replaced
+func (s *graphStructure) forgetBranches(from string) {
+	delete(s.branches, from)
+}
+
+// routeBranches wraps a condition returning branch names into one returning
+// the nodes they map to. Names missing from branches are node names.
+func routeBranches(condition func(ctx context.Context, state interface{}) string, branches map[string]string) func(ctx context.Context, state interface{}) string {
+	routes := copyMap(branches)
+	return func(ctx context.Context, state interface{}) string {
+		branch := condition(ctx, state)
+		if to, ok := routes[branch]; ok {
+			return to
+		}
+		return branch
+	}
+}
+
+// xrayLevel is a graph drawn by xray, the root graph or an expanded subgraph
+type xrayLevel struct {
+	graph  *topology
+	opts   XRayOptions
+	prefix string
+	depth  int
+}
+
+// id returns the identifier of a node of the level in the drawing
+func (l *xrayLevel) id(name string) string {
+	return l.prefix + name
+}
+
+// expanded returns the subgraph or the group of a node, if drawn expanded
+func (l *xrayLevel) expanded(name string) (*xrayLevel, *nodeGroup) {
+	if l.opts.Depth > 0 && l.depth >= l.opts.Depth || l.graph.structure == nil {
+		return nil, nil
+	}
+	if subgraph, ok := l.graph.structure.subgraphs[name]; ok {
+		sub := subgraph.topology()
+		if sub.entryPoint == "" {
+			return nil, nil
+		}
+		return &xrayLevel{graph: sub, opts: l.opts, prefix: l.id(name) + "__", depth: l.depth + 1}, nil
+	}
+	if group, ok := l.graph.structure.groups[name]; ok && len(group.members) > 0 {
+		return nil, &group
+	}
+	return nil, nil
+}
+
+// anchor returns the node a DOT edge of a node attaches to, and the cluster
+// the edge is clipped to when the node is drawn expanded
+func (l *xrayLevel) anchor(name string) (string, string) {
+	if name == END {
+		return l.id(END), ""
+	}
+	sub, group := l.expanded(name)
+	switch {
+	case sub != nil:
+		id, _ := sub.anchor(sub.graph.entryPoint)
+		return id, "cluster_" + l.id(name)
+	case group != nil:
+		return l.id(name) + "__" + group.members[0], "cluster_" + l.id(name)
+	default:
+		return l.id(name), ""
+	}
+}
+
+// nodeNames returns the nodes of the level, sorted
+func (l *xrayLevel) nodeNames() []string {
+	names := make([]string, 0, len(l.graph.nodes))
+	for name := range l.graph.nodes {
+		if name != END {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// branches returns the known branches of the conditional edge of a node,
+// sorted by name, or nil
+func (l *xrayLevel) branches(from string) []string {
+	if l.graph.structure == nil {
+		return nil
+	}
+	branches := l.graph.structure.branches[from]
+	names := make([]string, 0, len(branches))
+	for name := range branches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// hasEnd reports whether an edge or a known branch of the level goes to END
+func (l *xrayLevel) hasEnd() bool {
+	for _, edge := range l.graph.edges {
+		if edge.To == END {
+			return true
+		}
+	}
+	for _, from := range l.graph.conditionalSources() {
+		for _, branch := range l.branches(from) {
+			if l.graph.structure.branches[from][branch] == END {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// label returns the label of a node, with its interrupts on the root graph
+func (l *xrayLevel) label(name string) (string, bool) {
+	if l.depth > 0 {
+		return name, false
+	}
+	var interrupts []string
+	if containsName(l.opts.InterruptBefore, name) {
+		interrupts = append(interrupts, "before")
+	}
+	if containsName(l.opts.InterruptAfter, name) {
+		interrupts = append(interrupts, "after")
+	}
+	if len(interrupts) == 0 {
+		return name, false
+	}
+	return fmt.Sprintf("%s (interrupt %s)", name, strings.Join(interrupts, ", ")), true
+}
+
+// containsName reports whether the names include name
+func containsName(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// drawMermaidXRay generates an expanded Mermaid diagram of the graph
+func (ge *Exporter) drawMermaidXRay(direction string, opts XRayOptions) string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("flowchart %s\n", direction))
+
+	root := &xrayLevel{graph: ge.graph, opts: opts}
+	if root.graph.entryPoint != "" {
+		sb.WriteString("    START([\"START\"])\n")
+		sb.WriteString("    style START fill:#90EE90\n")
+	}
+	root.writeMermaid(&sb, "    ")
+	if root.graph.entryPoint != "" {
+		sb.WriteString(fmt.Sprintf("    START --> %s\n", root.id(root.graph.entryPoint)))
+	}
+	return sb.String()
+}
+
+// writeMermaid writes the nodes and edges of the level
+func (l *xrayLevel) writeMermaid(sb *strings.Builder, indent string) {
+	var styles []string
+	for _, name := range l.nodeNames() {
+		id := l.id(name)
+		label, interrupted := l.label(name)
+		if interrupted {
+			styles = append(styles, fmt.Sprintf("style %s stroke:#DC143C,stroke-width:3px", id))
+		}
+
+		sub, group := l.expanded(name)
+		switch {
+		case sub != nil:
+			sb.WriteString(fmt.Sprintf("%ssubgraph %s [\"%s\"]\n", indent, id, label))
+			sub.writeMermaid(sb, indent+"    ")
+			sb.WriteString(fmt.Sprintf("%send\n", indent))
+		case group != nil:
+			sb.WriteString(fmt.Sprintf("%ssubgraph %s [\"%s (%s)\"]\n", indent, id, label, group.kind))
+			for _, member := range group.members {
+				sb.WriteString(fmt.Sprintf("%s    %s__%s[\"%s\"]\n", indent, id, member, member))
+			}
+			sb.WriteString(fmt.Sprintf("%send\n", indent))
+		default:
+			sb.WriteString(fmt.Sprintf("%s%s[\"%s\"]\n", indent, id, label))
+		}
+	}
+
+	if l.hasEnd() {
+		sb.WriteString(fmt.Sprintf("%s%s([\"END\"])\n", indent, l.id(END)))
+		styles = append(styles, fmt.Sprintf("style %s fill:#FFB6C1", l.id(END)))
+	}
+
+	for _, edge := range l.graph.edges {
+		sb.WriteString(fmt.Sprintf("%s%s --> %s\n", indent, l.id(edge.From), l.id(edge.To)))
+	}
+
+	for _, from := range l.graph.conditionalSources() {
+		branches := l.branches(from)
+		if len(branches) == 0 {
+			sb.WriteString(fmt.Sprintf("%s%s -.-> %s_condition((?))\n", indent, l.id(from), l.id(from)))
+			styles = append(styles, fmt.Sprintf("style %s_condition fill:#FFFFE0,stroke:#333,stroke-dasharray: 5 5", l.id(from)))
+			continue
+		}
+		for _, branch := range branches {
+			to := l.graph.structure.branches[from][branch]
+			if branch == to {
+				sb.WriteString(fmt.Sprintf("%s%s -.-> %s\n", indent, l.id(from), l.id(to)))
+			} else {
+				sb.WriteString(fmt.Sprintf("%s%s -.->|%s| %s\n", indent, l.id(from), branch, l.id(to)))
+			}
+		}
+	}
+
+	if l.graph.entryPoint != "" {
+		styles = append(styles, fmt.Sprintf("style %s fill:#87CEEB", l.id(l.graph.entryPoint)))
+	}
+	for _, style := range styles {
+		sb.WriteString(indent + style + "\n")
+	}
+}
+
+// drawDOTXRay generates an expanded DOT representation of the graph
+func (ge *Exporter) drawDOTXRay(opts XRayOptions) string {
+	var sb strings.Builder
+	sb.WriteString("digraph G {\n")
+	sb.WriteString("    compound=true;\n")
+	sb.WriteString("    rankdir=TD;\n")
+	sb.WriteString("    node [shape=box];\n")
+
+	root := &xrayLevel{graph: ge.graph, opts: opts}
+	if root.graph.entryPoint != "" {
+		sb.WriteString("    START [label=\"START\", shape=ellipse, style=filled, fillcolor=lightgreen];\n")
+	}
+	root.writeDOT(&sb, "    ")
+	if root.graph.entryPoint != "" {
+		sb.WriteString(fmt.Sprintf("    START -> %s;\n", dotEdgeTarget(root.anchor(root.graph.entryPoint))))
+	}
+
+	sb.WriteString("}\n")
+	return sb.String()
+}
+
+// dotEdgeTarget returns the head of a DOT edge to an anchor
+func dotEdgeTarget(id, cluster string) string {
+	if cluster == "" {
+		return id
+	}
+	return fmt.Sprintf("%s [lhead=%s]", id, cluster)
+}
+
+// writeDOTEdge writes an edge between two nodes of the level
+func (l *xrayLevel) writeDOTEdge(sb *strings.Builder, indent, from, to string, attrs []string) {
+	tail, tailCluster := l.anchor(from)
+	head, headCluster := l.anchor(to)
+	if tailCluster != "" {
+		attrs = append(attrs, "ltail="+tailCluster)
+	}
+	if headCluster != "" {
+		attrs = append(attrs, "lhead="+headCluster)
+	}
+	if len(attrs) == 0 {
+		sb.WriteString(fmt.Sprintf("%s%s -> %s;\n", indent, tail, head))
+		return
+	}
+	sb.WriteString(fmt.Sprintf("%s%s -> %s [%s];\n", indent, tail, head, strings.Join(attrs, ", ")))
+}
+
+// writeDOT writes the nodes and edges of the level
+func (l *xrayLevel) writeDOT(sb *strings.Builder, indent string) {
+	for _, name := range l.nodeNames() {
+		id := l.id(name)
+		label, interrupted := l.label(name)
+
+		sub, group := l.expanded(name)
+		switch {
+		case sub != nil:
+			sb.WriteString(fmt.Sprintf("%ssubgraph cluster_%s {\n", indent, id))
+			sb.WriteString(fmt.Sprintf("%s    label=\"%s\";\n", indent, label))
+			if interrupted {
+				sb.WriteString(fmt.Sprintf("%s    color=crimson;\n", indent))
+			}
+			sub.writeDOT(sb, indent+"    ")
+			sb.WriteString(fmt.Sprintf("%s}\n", indent))
+		case group != nil:
+			sb.WriteString(fmt.Sprintf("%ssubgraph cluster_%s {\n", indent, id))
+			sb.WriteString(fmt.Sprintf("%s    label=\"%s (%s)\";\n", indent, label, group.kind))
+			sb.WriteString(fmt.Sprintf("%s    style=dashed;\n", indent))
+			if interrupted {
+				sb.WriteString(fmt.Sprintf("%s    color=crimson;\n", indent))
+			}
+			for _, member := range group.members {
+				sb.WriteString(fmt.Sprintf("%s    %s__%s [label=\"%s\"];\n", indent, id, member, member))
+			}
+			sb.WriteString(fmt.Sprintf("%s}\n", indent))
+		default:
+			attrs := fmt.Sprintf("label=\"%s\"", label)
+			if name == l.graph.entryPoint {
+				attrs += ", style=filled, fillcolor=lightblue"
+			}
+			if interrupted {
+				attrs += ", color=crimson, penwidth=2"
+			}
+			sb.WriteString(fmt.Sprintf("%s%s [%s];\n", indent, id, attrs))
+		}
+	}
+
+	if l.hasEnd() {
+		sb.WriteString(fmt.Sprintf("%s%s [label=\"END\", shape=ellipse, style=filled, fillcolor=lightpink];\n", indent, l.id(END)))
+	}
+
+	for _, edge := range l.graph.edges {
+		l.writeDOTEdge(sb, indent, edge.From, edge.To, nil)
+	}
+
+	for _, from := range l.graph.conditionalSources() {
+		branches := l.branches(from)
+		if len(branches) == 0 {
+			tail, tailCluster := l.anchor(from)
+			attrs := "style=dashed, label=\"?\""
+			if tailCluster != "" {
+				attrs += ", ltail=" + tailCluster
+			}
+			sb.WriteString(fmt.Sprintf("%s%s -> %s_condition [%s];\n", indent, tail, l.id(from), attrs))
+			sb.WriteString(fmt.Sprintf("%s%s_condition [label=\"?\", shape=diamond, style=filled, fillcolor=lightyellow];\n", indent, l.id(from)))
+			continue
+		}
+		for _, branch := range branches {
+			to := l.graph.structure.branches[from][branch]
+			attrs := []string{"style=dashed"}
+			if branch != to {
+				attrs = append(attrs, fmt.Sprintf("label=\"%s\"", branch))
+			}
+			l.writeDOTEdge(sb, indent, from, to, attrs)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package graph
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func xrayNoop(ctx context.Context, state interface{}) (interface{}, error) { return state, nil }
+
+// newXRayGraph builds a graph with a subgraph nesting another one, a fan-out
+// group, a map-reduce node and a conditional edge with known branches
+func newXRayGraph(t *testing.T) *MessageGraph {
+	t.Helper()
+
+	inner := NewMessageGraph()
+	inner.AddNode("lookup", xrayNoop)
+	inner.AddEdge("lookup", END)
+	inner.SetEntryPoint("lookup")
+
+	research := NewMessageGraph()
+	research.AddNode("plan", xrayNoop)
+	require.NoError(t, research.AddSubgraph("search", inner))
+	research.AddEdge("plan", "search")
+	research.AddEdge("search", END)
+	research.SetEntryPoint("plan")
+
+	g := NewMessageGraph()
+	require.NoError(t, g.AddSubgraph("research", research))
+	g.AddNode("review", xrayNoop)
+	g.FanOutFanIn("review", nil, "collect",
+		map[string]func(context.Context, interface{}) (interface{}, error){"style": xrayNoop, "facts": xrayNoop},
+		func(results []interface{}) (interface{}, error) { return results, nil })
+	g.AddMapReduceNode("score", map[string]func(context.Context, interface{}) (interface{}, error){"b": xrayNoop, "a": xrayNoop},
+		func(results []interface{}) (interface{}, error) { return results, nil })
+	g.AddEdge("research", "review")
+	g.AddEdge("collect", "score")
+	g.AddConditionalEdgeWithBranches("score", func(ctx context.Context, state interface{}) string {
+		return "done"
+	}, map[string]string{"retry": "research", "done": END})
+	g.SetEntryPoint("research")
+	return g
+}
+
+func TestXRay_Mermaid(t *testing.T) {
+	exporter := NewExporter(newXRayGraph(t))
+	mermaid := exporter.DrawMermaidWithOptions(MermaidOptions{XRay: &XRayOptions{InterruptBefore: []string{"review"}}})
+
+	// Subgraphs nest, with their nodes prefixed by the subgraph
+	assert.Contains(t, mermaid, "    subgraph research [\"research\"]\n")
+	assert.Contains(t, mermaid, "        subgraph research__search [\"search\"]\n")
+	assert.Contains(t, mermaid, "            research__search__lookup[\"lookup\"]\n")
+	assert.Contains(t, mermaid, "        research__plan --> research__search\n")
+	assert.Contains(t, mermaid, "        research__search --> research__END\n")
+	assert.Contains(t, mermaid, "START --> research\n")
+	assert.Contains(t, mermaid, "research --> review\n")
+
+	// Groups show their members
+	assert.Contains(t, mermaid, "subgraph review_workers [\"review_workers (parallel)\"]\n")
+	assert.Contains(t, mermaid, "review_workers__facts[\"facts\"]\n")
+	assert.Contains(t, mermaid, "review_workers__style[\"style\"]\n")
+	assert.Contains(t, mermaid, "subgraph score [\"score (map-reduce)\"]\n")
+	assert.Regexp(t, `score__a\["a"\]\s+score__b\["b"\]`, mermaid)
+
+	// Known branches are labelled edges, and interrupts are marked
+	assert.Contains(t, mermaid, "score -.->|done| END\n")
+	assert.Contains(t, mermaid, "score -.->|retry| research\n")
+	assert.NotContains(t, mermaid, "(?)")
+	assert.Contains(t, mermaid, "review[\"review (interrupt before)\"]\n")
+	assert.Contains(t, mermaid, "style review stroke:#DC143C")
+
+	// Subgraph blocks are balanced
+	assert.Equal(t, strings.Count(mermaid, "subgraph "), strings.Count(mermaid, "end\n"))
+}
+
+func TestXRay_Depth(t *testing.T) {
+	exporter := NewExporter(newXRayGraph(t))
+
+	mermaid := exporter.DrawMermaidWithOptions(MermaidOptions{XRay: &XRayOptions{Depth: 1}})
+	assert.Contains(t, mermaid, "subgraph research [\"research\"]\n")
+	assert.Contains(t, mermaid, "research__search[\"search\"]\n")
+	assert.NotContains(t, mermaid, "lookup")
+
+	// Without xray, the drawing is unchanged
+	assert.Equal(t, exporter.DrawMermaid(), exporter.DrawMermaidWithOptions(MermaidOptions{}))
+	assert.Contains(t, exporter.DrawMermaid(), "score -.-> score_condition((?))")
+	assert.Equal(t, exporter.DrawDOT(), exporter.DrawDOTWithOptions(DOTOptions{}))
+}
+
+func TestXRay_DOT(t *testing.T) {
+	exporter := NewExporter(newXRayGraph(t))
+	dot := exporter.DrawDOTWithOptions(DOTOptions{XRay: &XRayOptions{InterruptAfter: []string{"collect"}}})
+
+	assert.Contains(t, dot, "compound=true;")
+	assert.Contains(t, dot, "subgraph cluster_research {")
+	assert.Contains(t, dot, "subgraph cluster_research__search {")
+	assert.Contains(t, dot, "subgraph cluster_review_workers {")
+	assert.Contains(t, dot, "label=\"review_workers (parallel)\";")
+
+	// Edges of clusters attach to a node inside, clipped to the cluster
+	assert.Contains(t, dot, "START -> research__plan [lhead=cluster_research];")
+	assert.Contains(t, dot, "research__plan -> research__search__lookup [lhead=cluster_research__search];")
+	assert.Contains(t, dot, "review -> review_workers__facts [lhead=cluster_review_workers];")
+	assert.Contains(t, dot, "score__a -> END [style=dashed, label=\"done\", ltail=cluster_score];")
+	assert.Contains(t, dot, "score__a -> research__plan [style=dashed, label=\"retry\", ltail=cluster_score, lhead=cluster_research];")
+	assert.Contains(t, dot, "collect [label=\"collect (interrupt after)\", color=crimson, penwidth=2];")
+	assert.Equal(t, strings.Count(dot, "{"), strings.Count(dot, "}"))
+}
+
+func TestConditionalEdgeWithBranches(t *testing.T) {
+	g := NewStateGraph()
+	g.AddNode("route", xrayNoop)
+	g.AddNode("approved", func(ctx context.Context, state interface{}) (interface{}, error) { return "approved", nil })
+	g.AddConditionalEdgeWithBranches("route", func(ctx context.Context, state interface{}) string {
+		return state.(string)
+	}, map[string]string{"yes": "approved", "no": END})
+	g.AddEdge("approved", END)
+	g.SetEntryPoint("route")
+
+	runnable, err := g.Compile()
+	require.NoError(t, err)
+
+	// Branch names route to their nodes
+	res, err := runnable.Invoke(context.Background(), "yes")
+	require.NoError(t, err)
+	assert.Equal(t, "approved", res)
+
+	res, err = runnable.Invoke(context.Background(), "no")
+	require.NoError(t, err)
+	assert.Equal(t, "no", res)
+
+	mermaid := runnable.GetGraph().DrawMermaidWithOptions(MermaidOptions{XRay: &XRayOptions{}})
+	assert.Contains(t, mermaid, "route -.->|yes| approved\n")
+	assert.Contains(t, mermaid, "route -.->|no| END\n")
+
+	// A plain conditional edge replaces the branches
+	g.AddConditionalEdge("route", func(ctx context.Context, state interface{}) string { return END })
+	assert.Contains(t, NewExporter(g).DrawMermaidWithOptions(MermaidOptions{XRay: &XRayOptions{}}), "route -.-> route_condition((?))")
+}
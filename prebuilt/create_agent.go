@@ -41,7 +41,7 @@ func WithStateModifier(modifier func(messages []llms.MessageContent) []llms.Mess
 }
 
 // WithCheckpointer sets the checkpointer for the agent
-// Note: Currently this is a placeholder and may not be fully integrated into the graph execution yet
+// Invocations with Configurable["thread_id"] then continue the conversation of that thread
 func WithCheckpointer(checkpointer graph.CheckpointStore) CreateAgentOption {
 	return func(o *CreateAgentOptions) {
 		o.Checkpointer = checkpointer
@@ -269,6 +269,7 @@ func CreateAgent(model llms.Model, inputTools []tools.Tool, opts ...CreateAgentO
 		if err != nil {
 			return nil, err
 		}
+		recordResponseUsage(ctx, model, resp)
 
 		choice := resp.Choices[0]
 
@@ -379,7 +380,7 @@ func CreateAgent(model llms.Model, inputTools []tools.Tool, opts ...CreateAgentO
 		workflow.SetEntryPoint("agent")
 	}
 
-	workflow.AddConditionalEdge("agent", func(ctx context.Context, state interface{}) string {
+	workflow.AddConditionalEdgeWithBranches("agent", func(ctx context.Context, state interface{}) string {
 		mState := state.(map[string]interface{})
 		messages := mState["messages"].([]llms.MessageContent)
 		lastMsg := messages[len(messages)-1]
@@ -396,11 +397,11 @@ func CreateAgent(model llms.Model, inputTools []tools.Tool, opts ...CreateAgentO
 			return "tools"
 		}
 		return graph.END
-	})
+	}, map[string]string{"tools": "tools", graph.END: graph.END})
 
 	workflow.AddEdge("tools", "agent")
 
-	return workflow.Compile()
+	return workflow.Compile(graph.WithCheckpointer(options.Checkpointer))
 }
 
 func discoverSkills(skillDir string) (map[string]*goskills.SkillPackage, error) {
@@ -443,6 +444,7 @@ Output:`, skillDescriptions, userPrompt)
 	if err != nil {
 		return "", err
 	}
+	recordResponseUsage(ctx, model, resp)
 
 	selection := resp.Choices[0].Content
 	return strings.TrimSpace(selection), nil
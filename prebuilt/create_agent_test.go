@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/smallnest/langgraphgo/graph"
 	"github.com/stretchr/testify/assert"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/tools"
@@ -179,3 +180,42 @@ func TestCreateAgent_StateModifier(t *testing.T) {
 	assert.Equal(t, llms.ChatMessageTypeHuman, firstCallMessages[0].Role)
 	assert.Equal(t, "Modified: Hello", firstCallMessages[0].Parts[0].(llms.TextContent).Text)
 }
+
+func TestCreateAgent_Checkpointer(t *testing.T) {
+	mockTool := &MockTool{name: "test-tool"}
+	mockLLM := &MockLLMWithInputCapture{
+		responses: []llms.ContentResponse{
+			{Choices: []*llms.ContentChoice{{Content: "Hi Alice"}}},
+			{Choices: []*llms.ContentChoice{{Content: "Your name is Alice"}}},
+		},
+	}
+
+	agent, err := CreateAgent(mockLLM, []tools.Tool{mockTool}, WithCheckpointer(graph.NewMemoryCheckpointStore()))
+	assert.NoError(t, err)
+
+	config := &graph.Config{
+		Configurable: map[string]interface{}{"thread_id": "conversation-1"},
+	}
+
+	_, err = agent.InvokeWithConfig(context.Background(), map[string]interface{}{
+		"messages": []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "I am Alice"),
+		},
+	}, config)
+	assert.NoError(t, err)
+
+	res, err := agent.InvokeWithConfig(context.Background(), map[string]interface{}{
+		"messages": []llms.MessageContent{
+			llms.TextParts(llms.ChatMessageTypeHuman, "What is my name?"),
+		},
+	}, config)
+	assert.NoError(t, err)
+
+	// The second turn sees the conversation of the first one
+	assert.Len(t, mockLLM.CapturedMessages, 2)
+	assert.Len(t, mockLLM.CapturedMessages[1], 3)
+
+	messages := res.(map[string]interface{})["messages"].([]llms.MessageContent)
+	assert.Len(t, messages, 4)
+	assert.Equal(t, "Your name is Alice", messages[3].Parts[0].(llms.TextContent).Text)
+}
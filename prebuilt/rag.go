@@ -346,6 +346,7 @@ func (p *RAGPipeline) generateNode(ctx context.Context, state interface{}) (inte
 	if err != nil {
 		return nil, fmt.Errorf("generation failed: %w", err)
 	}
+	recordResponseUsage(ctx, p.config.LLM, response)
 
 	if len(response.Choices) > 0 {
 		ragState.Answer = response.Choices[0].Content
@@ -68,6 +68,7 @@ func CreateReactAgent(model llms.Model, inputTools []tools.Tool) (*graph.StateRu
 		if err != nil {
 			return nil, err
 		}
+		recordResponseUsage(ctx, model, resp)
 
 		choice := resp.Choices[0]
 
@@ -156,7 +157,7 @@ func CreateReactAgent(model llms.Model, inputTools []tools.Tool) (*graph.StateRu
 	// Define edges
 	workflow.SetEntryPoint("agent")
 
-	workflow.AddConditionalEdge("agent", func(ctx context.Context, state interface{}) string {
+	workflow.AddConditionalEdgeWithBranches("agent", func(ctx context.Context, state interface{}) string {
 		mState := state.(map[string]interface{})
 		messages := mState["messages"].([]llms.MessageContent)
 		lastMsg := messages[len(messages)-1]
@@ -173,7 +174,7 @@ func CreateReactAgent(model llms.Model, inputTools []tools.Tool) (*graph.StateRu
 			return "tools"
 		}
 		return graph.END
-	})
+	}, map[string]string{"tools": "tools", graph.END: graph.END})
 
 	workflow.AddEdge("tools", "agent")
 
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/smallnest/langgraphgo/graph"
 	"github.com/stretchr/testify/assert"
 	"github.com/tmc/langchaingo/llms"
 	"github.com/tmc/langchaingo/tools"
@@ -112,3 +113,18 @@ func TestCreateReactAgent(t *testing.T) {
 	assert.True(t, ok)
 	assert.Equal(t, "Final Answer", textPart.Text)
 }
+
+func TestCreateReactAgent_GetGraph(t *testing.T) {
+	agent, err := CreateReactAgent(&MockLLM{}, []tools.Tool{&MockTool{name: "test-tool"}})
+	assert.NoError(t, err)
+
+	mermaid := agent.GetGraph().DrawMermaid()
+	assert.Contains(t, mermaid, "START --> agent")
+	assert.Contains(t, mermaid, "tools --> agent")
+	assert.Contains(t, mermaid, "agent -.-> agent_condition((?))")
+
+	// The xray drawing follows the known branches of the agent
+	xray := agent.GetGraph().DrawMermaidWithOptions(graph.MermaidOptions{XRay: &graph.XRayOptions{}})
+	assert.Contains(t, xray, "agent -.-> tools")
+	assert.Contains(t, xray, "agent -.-> END")
+}
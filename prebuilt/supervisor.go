@@ -78,6 +78,7 @@ func CreateSupervisor(model llms.Model, members map[string]*graph.StateRunnable)
 		if err != nil {
 			return nil, err
 		}
+		recordResponseUsage(ctx, model, resp)
 
 		choice := resp.Choices[0]
 		if len(choice.ToolCalls) == 0 {
@@ -129,8 +130,12 @@ func CreateSupervisor(model llms.Model, members map[string]*graph.StateRunnable)
 	// Define edges
 	workflow.SetEntryPoint("supervisor")
 
-	// Conditional edge from supervisor
-	workflow.AddConditionalEdge("supervisor", func(ctx context.Context, state interface{}) string {
+	// Conditional edge from supervisor, to a member or END
+	branches := map[string]string{graph.END: graph.END}
+	for _, name := range memberNames {
+		branches[name] = name
+	}
+	workflow.AddConditionalEdgeWithBranches("supervisor", func(ctx context.Context, state interface{}) string {
 		mState := state.(map[string]interface{})
 		next, ok := mState["next"].(string)
 		if !ok {
@@ -140,7 +145,7 @@ func CreateSupervisor(model llms.Model, members map[string]*graph.StateRunnable)
 			return graph.END
 		}
 		return next
-	})
+	}, branches)
 
 	// Edges from members back to supervisor
 	for _, name := range memberNames {
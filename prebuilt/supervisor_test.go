@@ -126,3 +126,24 @@ func TestCreateSupervisor(t *testing.T) {
 	// Verify "next" state
 	assert.Equal(t, "FINISH", mState["next"])
 }
+
+func TestCreateSupervisor_GetGraph(t *testing.T) {
+	member := graph.NewStateGraph()
+	member.AddNode("run", func(ctx context.Context, state interface{}) (interface{}, error) {
+		return state, nil
+	})
+	member.SetEntryPoint("run")
+	member.AddEdge("run", graph.END)
+	agent, err := member.Compile()
+	assert.NoError(t, err)
+
+	supervisor, err := CreateSupervisor(&MockLLM{}, map[string]*graph.StateRunnable{"Agent1": agent, "Agent2": agent})
+	assert.NoError(t, err)
+
+	// The supervisor routes to every member or ends
+	xray := supervisor.GetGraph().DrawMermaidWithOptions(graph.MermaidOptions{XRay: &graph.XRayOptions{}})
+	assert.Contains(t, xray, "supervisor -.-> Agent1")
+	assert.Contains(t, xray, "supervisor -.-> Agent2")
+	assert.Contains(t, xray, "supervisor -.-> END")
+	assert.Contains(t, xray, "Agent1 --> supervisor")
+}
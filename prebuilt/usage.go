@@ -0,0 +1,53 @@
+package prebuilt
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/tmc/langchaingo/llms"
+)
+
+// namedModel is a model with the name used to account its token usage
+type namedModel struct {
+	llms.Model
+	name string
+}
+
+// ModelName returns the name of the model
+func (m *namedModel) ModelName() string {
+	return m.name
+}
+
+// NamedModel wraps a model with the name its token usage is recorded and
+// priced under, such as "gpt-4o". Models without a name are recorded under
+// their Go type.
+func NamedModel(name string, model llms.Model) llms.Model {
+	return &namedModel{Model: model, name: name}
+}
+
+// modelName returns the name of a model for usage accounting
+func modelName(model llms.Model) string {
+	if named, ok := model.(interface{ ModelName() string }); ok {
+		return named.ModelName()
+	}
+	return fmt.Sprintf("%T", model)
+}
+
+// recordResponseUsage records the token usage reported in the generation
+// info of a response into the usage tracker and callbacks of the run
+func recordResponseUsage(ctx context.Context, model llms.Model, resp *llms.ContentResponse) {
+	if resp == nil {
+		return
+	}
+	for _, choice := range resp.Choices {
+		if choice == nil {
+			continue
+		}
+		// Providers report the usage of the whole call on the first choice
+		if usage, ok := graph.UsageFromGenerationInfo(choice.GenerationInfo); ok {
+			graph.RecordUsage(ctx, modelName(model), usage)
+			return
+		}
+	}
+}
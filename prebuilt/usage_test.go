@@ -0,0 +1,89 @@
+package prebuilt
+
+import (
+	"context"
+	"testing"
+
+	"github.com/smallnest/langgraphgo/graph"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/tmc/langchaingo/llms"
+	"github.com/tmc/langchaingo/tools"
+)
+
+func TestModelName(t *testing.T) {
+	assert.Equal(t, "gpt-4o", modelName(NamedModel("gpt-4o", &MockLLM{})))
+	assert.Equal(t, "*prebuilt.MockLLM", modelName(&MockLLM{}))
+}
+
+func TestCreateReactAgent_RecordsUsage(t *testing.T) {
+	mockLLM := &MockLLM{
+		responses: []llms.ContentResponse{
+			{
+				Choices: []*llms.ContentChoice{
+					{
+						Content: "done",
+						GenerationInfo: map[string]interface{}{
+							"PromptTokens":     12,
+							"CompletionTokens": 4,
+							"TotalTokens":      16,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	agent, err := CreateReactAgent(NamedModel("gpt-4o", mockLLM), []tools.Tool{})
+	require.NoError(t, err)
+
+	tracker := graph.NewUsageTracker(graph.PriceTable{
+		"gpt-4o": {PromptPerMillion: 1, CompletionPerMillion: 1},
+	})
+	ctx := graph.WithUsageTracker(context.Background(), tracker)
+	_, err = agent.Invoke(ctx, map[string]interface{}{
+		"messages": []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")},
+	})
+	require.NoError(t, err)
+
+	summary := tracker.Summary()
+	expected := graph.TokenUsage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}
+	assert.Equal(t, expected, summary.Total)
+	assert.Equal(t, expected, summary.ByNode["agent"])
+	assert.Equal(t, expected, summary.ByModel["gpt-4o"])
+	assert.InDelta(t, 16e-6, summary.Cost, 1e-12)
+}
+
+func TestCreateReactAgent_InvokeWithUsage(t *testing.T) {
+	response := llms.ContentResponse{
+		Choices: []*llms.ContentChoice{
+			{
+				Content: "done",
+				GenerationInfo: map[string]interface{}{
+					"PromptTokens":     12,
+					"CompletionTokens": 4,
+					"TotalTokens":      16,
+				},
+			},
+		},
+	}
+	mockLLM := &MockLLM{responses: []llms.ContentResponse{response, response}}
+
+	agent, err := CreateReactAgent(mockLLM, []tools.Tool{})
+	require.NoError(t, err)
+
+	// Runs sharing a config each get their own tracker, the config is left untouched
+	config := &graph.Config{Tags: []string{"shared"}}
+	expected := graph.TokenUsage{PromptTokens: 12, CompletionTokens: 4, TotalTokens: 16}
+	for i := 0; i < 2; i++ {
+		_, usage, err := agent.InvokeWithUsage(context.Background(), map[string]interface{}{
+			"messages": []llms.MessageContent{llms.TextParts(llms.ChatMessageTypeHuman, "hi")},
+		}, config)
+		require.NoError(t, err)
+
+		require.NotNil(t, usage)
+		assert.Equal(t, expected, usage.Summary().Total)
+		assert.Equal(t, expected, usage.Summary().ByNode["agent"])
+	}
+	assert.Equal(t, &graph.Config{Tags: []string{"shared"}}, config)
+}